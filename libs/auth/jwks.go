@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"io"
 	"math/big"
 	"net/http"
 	"sync"
@@ -28,6 +29,7 @@ type jwks struct {
 
 type JWKSClient struct {
 	url     string
+	http    *http.Client
 	ttl     time.Duration
 	mu      sync.Mutex
 	expires time.Time
@@ -35,10 +37,16 @@ type JWKSClient struct {
 }
 
 func NewJWKSClient(url string, ttl time.Duration) *JWKSClient {
+	return NewJWKSClientWithHTTP(url, ttl, http.DefaultClient)
+}
+
+// NewJWKSClientWithHTTP fetches keys through httpClient, for callers that
+// restrict where requests may go.
+func NewJWKSClientWithHTTP(url string, ttl time.Duration, httpClient *http.Client) *JWKSClient {
 	if ttl <= 0 {
 		ttl = 5 * time.Minute
 	}
-	return &JWKSClient{url: url, ttl: ttl, keys: map[string]*rsa.PublicKey{}}
+	return &JWKSClient{url: url, http: httpClient, ttl: ttl, keys: map[string]*rsa.PublicKey{}}
 }
 
 func (c *JWKSClient) Get(keyID string) (*rsa.PublicKey, error) {
@@ -65,7 +73,7 @@ func (c *JWKSClient) Get(keyID string) (*rsa.PublicKey, error) {
 }
 
 func (c *JWKSClient) refresh() error {
-	resp, err := http.Get(c.url)
+	resp, err := c.http.Get(c.url)
 	if err != nil {
 		return err
 	}
@@ -76,7 +84,7 @@ func (c *JWKSClient) refresh() error {
 	}
 
 	var data jwks
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&data); err != nil {
 		return err
 	}
 
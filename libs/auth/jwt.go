@@ -14,14 +14,40 @@ import (
 
 var ErrInvalidToken = errors.New("invalid token")
 
+var (
+	ErrWrongIssuer   = errors.New("token issuer mismatch")
+	ErrWrongAudience = errors.New("token audience mismatch")
+)
+
+// Claims is the access-token payload. StaffID links a "staff" user to their
+// business-service staff record and is empty for every other role. SessionID
+// names the auth-service session (refresh-token chain) the token came from;
+// ID (jti) names the token itself so it can be revoked.
 type Claims struct {
+	ID         string `json:"jti,omitempty"`
+	Issuer     string `json:"iss,omitempty"`
+	Audience   string `json:"aud,omitempty"`
 	Sub        string `json:"sub"`
 	BusinessID string `json:"business_id"`
 	Role       string `json:"role"`
+	StaffID    string `json:"staff_id,omitempty"`
+	SessionID  string `json:"sid,omitempty"`
 	Exp        int64  `json:"exp"`
 	Iat        int64  `json:"iat"`
 }
 
+// CheckIssuerAudience rejects a token minted by another issuer or for another
+// audience. An empty expectation is not checked.
+func (c *Claims) CheckIssuerAudience(issuer string, audience string) error {
+	if issuer != "" && c.Issuer != issuer {
+		return ErrWrongIssuer
+	}
+	if audience != "" && c.Audience != audience {
+		return ErrWrongAudience
+	}
+	return nil
+}
+
 type Header struct {
 	Alg string `json:"alg"`
 	Typ string `json:"typ"`
@@ -119,6 +145,24 @@ func hmacSHA256(data, secret string) string {
 }
 
 func VerifyRS256(token string, pubKey crypto.PublicKey) (*Claims, error) {
+	payload, err := VerifyRS256Payload(token, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.Exp > 0 && time.Now().Unix() > claims.Exp {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// VerifyRS256Payload checks the signature and returns the raw payload for
+// tokens whose claims do not fit Claims, such as third-party ID tokens. The
+// caller is responsible for checking exp and the other claims.
+func VerifyRS256Payload(token string, pubKey crypto.PublicKey) ([]byte, error) {
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
 		return nil, ErrInvalidToken
@@ -143,12 +187,5 @@ func VerifyRS256(token string, pubKey crypto.PublicKey) (*Claims, error) {
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
-	var claims Claims
-	if err := json.Unmarshal(payload, &claims); err != nil {
-		return nil, ErrInvalidToken
-	}
-	if claims.Exp > 0 && time.Now().Unix() > claims.Exp {
-		return nil, ErrInvalidToken
-	}
-	return &claims, nil
+	return payload, nil
 }
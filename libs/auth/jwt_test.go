@@ -63,6 +63,33 @@ func TestRS256Verify(t *testing.T) {
 	}
 }
 
+func TestCheckIssuerAudience(t *testing.T) {
+	claims := Claims{ID: "jti-1", Issuer: "apptremind-auth", Audience: "apptremind-api", Sub: "user-1"}
+	token, err := SignHS256(claims, "secret")
+	if err != nil {
+		t.Fatalf("SignHS256 failed: %v", err)
+	}
+	parsed, err := ParseAndVerifyHS256(token, "secret")
+	if err != nil {
+		t.Fatalf("ParseAndVerifyHS256 failed: %v", err)
+	}
+	if parsed.ID != "jti-1" {
+		t.Fatalf("jti lost in round trip: %+v", parsed)
+	}
+	if err := parsed.CheckIssuerAudience("apptremind-auth", "apptremind-api"); err != nil {
+		t.Fatalf("expected match, got %v", err)
+	}
+	if err := parsed.CheckIssuerAudience("", ""); err != nil {
+		t.Fatalf("empty expectations must not be checked, got %v", err)
+	}
+	if err := parsed.CheckIssuerAudience("someone-else", ""); err != ErrWrongIssuer {
+		t.Fatalf("expected ErrWrongIssuer, got %v", err)
+	}
+	if err := parsed.CheckIssuerAudience("", "billing-api"); err != ErrWrongAudience {
+		t.Fatalf("expected ErrWrongAudience, got %v", err)
+	}
+}
+
 func signRS256(claims Claims, key *rsa.PrivateKey, kid string) (string, error) {
 	header := map[string]string{
 		"alg": "RS256",
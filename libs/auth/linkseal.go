@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var ErrSealedLink = errors.New("sealed link cannot be opened")
+
+// LinkSealer encrypts account links (password reset, email verification,
+// invitations) with AES-256-GCM before they go into an event, so the bearer
+// token in the link is not readable from the outbox table or the Kafka topic.
+// Producer and consumer share the key.
+type LinkSealer struct {
+	aead cipher.AEAD
+}
+
+// NewLinkSealer derives the AES key from key with SHA-256.
+func NewLinkSealer(key string) (*LinkSealer, error) {
+	if key == "" {
+		return nil, errors.New("link sealing key required")
+	}
+	sum := sha256.Sum256([]byte("account-link:" + key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &LinkSealer{aead: aead}, nil
+}
+
+// Seal encrypts link for the event of eventType mailed to recipient. Open
+// fails for any other event type or recipient, so a sealed link cannot be
+// moved into another message.
+func (s *LinkSealer) Seal(link string, eventType string, recipient string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	out := s.aead.Seal(nonce, nonce, []byte(link), linkContext(eventType, recipient))
+	return base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+func (s *LinkSealer) Open(sealed string, eventType string, recipient string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return "", ErrSealedLink
+	}
+	nonce, ciphertext := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, ciphertext, linkContext(eventType, recipient))
+	if err != nil {
+		return "", ErrSealedLink
+	}
+	return string(plain), nil
+}
+
+func linkContext(eventType string, recipient string) []byte {
+	return []byte(eventType + "\x00" + strings.ToLower(strings.TrimSpace(recipient)))
+}
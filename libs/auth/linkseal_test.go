@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLinkSealerRoundTrip(t *testing.T) {
+	sealer, err := NewLinkSealer("shared-key")
+	if err != nil {
+		t.Fatalf("NewLinkSealer: %v", err)
+	}
+	link := "http://localhost:8080/reset-password?token=secret-token"
+	sealed, err := sealer.Seal(link, "auth.password_reset.requested.v1", "ana@example.com")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if strings.Contains(sealed, "secret-token") {
+		t.Fatalf("sealed link leaks the token: %s", sealed)
+	}
+	got, err := sealer.Open(sealed, "auth.password_reset.requested.v1", "ana@example.com")
+	if err != nil || got != link {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+
+	other, _ := NewLinkSealer("other-key")
+	if _, err := other.Open(sealed, "auth.password_reset.requested.v1", "ana@example.com"); !errors.Is(err, ErrSealedLink) {
+		t.Fatalf("wrong key: expected ErrSealedLink, got %v", err)
+	}
+	if _, err := sealer.Open(sealed, "auth.password_reset.requested.v1", "mallory@example.com"); !errors.Is(err, ErrSealedLink) {
+		t.Fatalf("other recipient: expected ErrSealedLink, got %v", err)
+	}
+	if _, err := sealer.Open(sealed, "auth.email_verification.requested.v1", "ana@example.com"); !errors.Is(err, ErrSealedLink) {
+		t.Fatalf("other event type: expected ErrSealedLink, got %v", err)
+	}
+	if _, err := NewLinkSealer(""); err == nil {
+		t.Fatal("expected an empty key to be rejected")
+	}
+}
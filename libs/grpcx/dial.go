@@ -25,7 +25,7 @@ func Dial(ctx context.Context, addr string, opts DialOptions, extra ...grpc.Dial
 
 	dialOpts := []grpc.DialOption{
 		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
-		grpc.WithChainUnaryInterceptor(UnaryClientRequestIDInterceptor()),
+		grpc.WithChainUnaryInterceptor(UnaryClientRequestIDInterceptor(), UnaryClientMetricsInterceptor()),
 		grpc.WithBlock(),
 	}
 	if opts.TransportCredentials != nil {
@@ -0,0 +1,43 @@
+package grpcx
+
+import (
+	"context"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	serverHandled = metrics.NewCounter("grpc_server_handled_total",
+		"Unary RPCs completed on the server by method and status code.", "grpc_method", "grpc_code")
+	serverDuration = metrics.NewHistogram("grpc_server_handling_seconds",
+		"Unary RPC latency on the server by method.", nil, "grpc_method")
+	clientHandled = metrics.NewCounter("grpc_client_handled_total",
+		"Unary RPCs completed by the client by method and status code.", "grpc_method", "grpc_code")
+	clientDuration = metrics.NewHistogram("grpc_client_handling_seconds",
+		"Unary RPC latency seen by the client by method.", nil, "grpc_method")
+)
+
+// UnaryServerMetricsInterceptor counts and times unary RPCs by full method name.
+func UnaryServerMetricsInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		serverHandled.Inc(info.FullMethod, status.Code(err).String())
+		serverDuration.Observe(time.Since(start).Seconds(), info.FullMethod)
+		return resp, err
+	}
+}
+
+// UnaryClientMetricsInterceptor counts and times outgoing unary RPCs.
+func UnaryClientMetricsInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		clientHandled.Inc(method, status.Code(err).String())
+		clientDuration.Observe(time.Since(start).Seconds(), method)
+		return err
+	}
+}
@@ -0,0 +1,54 @@
+package httpx
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounter("http_requests_total",
+		"HTTP requests by method, matched route and status code.", "method", "route", "code")
+	httpDuration = metrics.NewHistogram("http_request_duration_seconds",
+		"HTTP request latency by method and matched route.", nil, "method", "route")
+	httpInFlight = metrics.NewGauge("http_requests_in_flight",
+		"HTTP requests currently being served.")
+)
+
+// WithMetrics records rate, errors and duration per route. Routes are the
+// ServeMux pattern rather than the raw path so ids do not explode the series
+// count, which means it must sit directly around the mux: anything between
+// them that copies the request (WithRequestID, WithTimeout) would hide the
+// pattern.
+func WithMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusCapturingResponseWriter{ResponseWriter: w}
+		httpInFlight.Add(1)
+		defer httpInFlight.Add(-1)
+
+		next.ServeHTTP(sw, r)
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		method := metricMethod(r.Method)
+		httpRequests.Inc(method, route, strconv.Itoa(status))
+		httpDuration.Observe(time.Since(start).Seconds(), method, route)
+	})
+}
+
+func metricMethod(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return m
+	}
+	return "OTHER"
+}
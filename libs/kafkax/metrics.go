@@ -0,0 +1,35 @@
+package kafkax
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+// Consume outcomes recorded by ObserveConsumed.
+const (
+	ConsumeOK        = "ok"
+	ConsumeDuplicate = "duplicate"
+	ConsumeError     = "error"
+)
+
+var (
+	consumed = metrics.NewCounter("kafka_consumer_messages_total",
+		"Messages handled by consumers by topic, group and result (ok, duplicate, error).", "topic", "group", "result")
+	consumeDuration = metrics.NewHistogram("kafka_consumer_processing_seconds",
+		"Time from reading a message to finishing its handler, by topic and group.", nil, "topic", "group")
+	consumerLag = metrics.NewGauge("kafka_consumer_lag",
+		"Messages behind the partition high-water mark as of the last message read.", "topic", "group", "partition")
+)
+
+// ObserveConsumed records one handled message. Call it once per message read,
+// after the inbox check and handler, with the time the message was read.
+func ObserveConsumed(msg kafka.Message, group string, result string, readAt time.Time) {
+	consumed.Inc(msg.Topic, group, result)
+	consumeDuration.Observe(time.Since(readAt).Seconds(), msg.Topic, group)
+	if msg.HighWaterMark > 0 {
+		consumerLag.Set(float64(max(0, msg.HighWaterMark-msg.Offset-1)), msg.Topic, group, strconv.Itoa(msg.Partition))
+	}
+}
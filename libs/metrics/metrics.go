@@ -0,0 +1,317 @@
+// Package metrics is a small Prometheus-compatible metrics registry. It covers
+// what the services need (labelled counters, gauges and histograms rendered
+// in the text exposition format) without pulling in the Prometheus client.
+//
+// Metrics are usually declared as package-level variables, which registers
+// them with Default; every service serves Default on /metrics.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit request and handler latencies, in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds metric families by name.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+type family interface {
+	write(w io.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]family{}}
+}
+
+// Default is the registry the New* constructors register with.
+var Default = NewRegistry()
+
+// Handler serves Default in the Prometheus text format.
+func Handler() http.Handler {
+	return Default
+}
+
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.families[name]; ok {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.families[name] = f
+}
+
+// Render writes every family with at least one series, sorted by name.
+func (r *Registry) Render(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make([]family, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		families = append(families, r.families[name])
+	}
+	r.mu.Unlock()
+
+	for _, f := range families {
+		f.write(w)
+	}
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.Render(w)
+}
+
+// vec is the label bookkeeping shared by all metric types.
+type vec[S any] struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	newS   func() *S
+
+	mu     sync.Mutex
+	series map[string]*entry[S]
+}
+
+type entry[S any] struct {
+	values []string
+	s      *S
+}
+
+func newVec[S any](name, help, kind string, labels []string, newS func() *S) *vec[S] {
+	return &vec[S]{name: name, help: help, kind: kind, labels: labels, newS: newS, series: map[string]*entry[S]{}}
+}
+
+// get returns the series for values, creating it on first use; the caller
+// holds v.mu.
+func (v *vec[S]) get(values []string) *S {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	e, ok := v.series[key]
+	if !ok {
+		e = &entry[S]{values: append([]string(nil), values...), s: v.newS()}
+		v.series[key] = e
+	}
+	return e.s
+}
+
+// Reset drops every series, for gauges that are re-sampled as a whole.
+func (v *vec[S]) Reset() {
+	v.mu.Lock()
+	v.series = map[string]*entry[S]{}
+	v.mu.Unlock()
+}
+
+// each visits series sorted by label values; the caller holds v.mu.
+func (v *vec[S]) each(fn func(values []string, s *S)) {
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e := v.series[k]
+		fn(e.values, e.s)
+	}
+}
+
+func (v *vec[S]) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.kind)
+}
+
+type value struct {
+	v float64
+}
+
+// Counter is a monotonically increasing value per label set.
+type Counter struct {
+	*vec[value]
+}
+
+// NewCounter registers a counter with Default.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newVec(name, help, "counter", labels, func() *value { return &value{} })}
+	Default.register(name, c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter; negative deltas are ignored.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.get(labelValues).v += delta
+	c.mu.Unlock()
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.series) == 0 {
+		return
+	}
+	c.header(w)
+	c.each(func(values []string, s *value) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labelPairs(c.labels, values, "", ""), formatFloat(s.v))
+	})
+}
+
+// Gauge is a value that can go up and down per label set.
+type Gauge struct {
+	*vec[value]
+}
+
+// NewGauge registers a gauge with Default.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newVec(name, help, "gauge", labels, func() *value { return &value{} })}
+	Default.register(name, g)
+	return g
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	g.get(labelValues).v = v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.mu.Lock()
+	g.get(labelValues).v += delta
+	g.mu.Unlock()
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.series) == 0 {
+		return
+	}
+	g.header(w)
+	g.each(func(values []string, s *value) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, labelPairs(g.labels, values, "", ""), formatFloat(s.v))
+	})
+}
+
+type buckets struct {
+	counts []uint64 // per upper bound, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Histogram counts observations into fixed buckets per label set.
+type Histogram struct {
+	*vec[buckets]
+	bounds []float64
+}
+
+// NewHistogram registers a histogram with Default; nil bounds means
+// DefaultBuckets.
+func NewHistogram(name, help string, bounds []float64, labels ...string) *Histogram {
+	if bounds == nil {
+		bounds = DefaultBuckets
+	}
+	bounds = append([]float64(nil), bounds...)
+	sort.Float64s(bounds)
+	h := &Histogram{
+		vec:    newVec(name, help, "histogram", labels, func() *buckets { return &buckets{counts: make([]uint64, len(bounds))} }),
+		bounds: bounds,
+	}
+	Default.register(name, h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b := h.get(labelValues)
+	if i := sort.SearchFloat64s(h.bounds, v); i < len(h.bounds) {
+		b.counts[i]++
+	}
+	b.count++
+	b.sum += v
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.series) == 0 {
+		return
+	}
+	h.header(w)
+	h.each(func(values []string, b *buckets) {
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += b.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelPairs(h.labels, values, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelPairs(h.labels, values, "le", "+Inf"), b.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelPairs(h.labels, values, "", ""), formatFloat(b.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelPairs(h.labels, values, "", ""), b.count)
+	})
+}
+
+func labelPairs(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(values[i]))
+		sb.WriteByte('"')
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extraName)
+		sb.WriteString(`="`)
+		sb.WriteString(extraValue)
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	requests := NewCounter("test_requests_total", "Requests.", "code")
+	requests.Inc("200")
+	requests.Add(2, "200")
+	requests.Inc(`5"x`)
+	requests.Add(-1, "200")
+
+	inFlight := NewGauge("test_in_flight", "In flight.")
+	inFlight.Add(3)
+	inFlight.Add(-1)
+
+	latency := NewHistogram("test_latency_seconds", "Latency.", []float64{1, 0.1}, "route")
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(7, "/a")
+
+	NewGauge("test_unused", "Never set.", "x")
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{code="200"} 3` + "\n",
+		`test_requests_total{code="5\"x"} 1` + "\n",
+		"test_in_flight 2\n",
+		"# TYPE test_latency_seconds histogram\n",
+		`test_latency_seconds_bucket{route="/a",le="0.1"} 1` + "\n",
+		`test_latency_seconds_bucket{route="/a",le="1"} 2` + "\n",
+		`test_latency_seconds_bucket{route="/a",le="+Inf"} 3` + "\n",
+		`test_latency_seconds_sum{route="/a"} 7.55` + "\n",
+		`test_latency_seconds_count{route="/a"} 3` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("missing %q in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "test_unused") {
+		t.Fatalf("family without series was rendered:\n%s", body)
+	}
+	if strings.Index(body, "test_in_flight") > strings.Index(body, "test_latency_seconds") {
+		t.Fatalf("families not sorted by name:\n%s", body)
+	}
+}
+
+func TestGaugeReset(t *testing.T) {
+	lag := NewGauge("test_lag", "Lag.", "shard")
+	lag.Set(5, "1")
+	lag.Reset()
+	lag.Set(2, "2")
+
+	var sb strings.Builder
+	Default.Render(&sb)
+	if strings.Contains(sb.String(), `test_lag{shard="1"}`) || !strings.Contains(sb.String(), `test_lag{shard="2"} 2`) {
+		t.Fatalf("reset did not drop stale series:\n%s", sb.String())
+	}
+}
+
+func TestDuplicateNamePanics(t *testing.T) {
+	NewCounter("test_dup_total", "First.")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic on duplicate registration")
+		}
+	}()
+	NewCounter("test_dup_total", "Second.")
+}
@@ -0,0 +1,31 @@
+package reminder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AllChannels is used when neither the customer nor the business picked channels.
+var AllChannels = []string{"email", "sms"}
+
+// NormalizeChannels lowercases and de-duplicates channel names, rejecting
+// anything other than email or sms. Business-service validates stored defaults
+// and booking-service validates customer choices with it, so both accept the
+// same input.
+func NormalizeChannels(in []string) ([]string, error) {
+	var out []string
+	for _, c := range in {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c != "email" && c != "sms" {
+			return nil, fmt.Errorf("unsupported reminder channel %q", c)
+		}
+		dup := false
+		for _, seen := range out {
+			dup = dup || seen == c
+		}
+		if !dup {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,16 @@
+package reminder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeChannels(t *testing.T) {
+	got, err := NormalizeChannels([]string{" SMS", "sms", "Email"})
+	if err != nil || !reflect.DeepEqual(got, []string{"sms", "email"}) {
+		t.Fatalf("got %v err=%v", got, err)
+	}
+	if _, err := NormalizeChannels([]string{"push"}); err == nil {
+		t.Fatalf("expected error for unsupported channel")
+	}
+}
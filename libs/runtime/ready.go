@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/metrics"
 )
 
 // ReadyCheck is a named dependency check for /readyz.
@@ -13,7 +15,16 @@ type ReadyCheck struct {
 	Check func(context.Context) error
 }
 
+// NewBaseMuxWithReady serves /healthz, /readyz and the process's /metrics.
 func NewBaseMuxWithReady(checks ...ReadyCheck) *http.ServeMux {
+	mux := NewHealthMux(checks...)
+	mux.Handle("/metrics", metrics.Handler())
+	return mux
+}
+
+// NewHealthMux serves /healthz and /readyz only, for listeners that must not
+// expose /metrics (the gateway's public port).
+func NewHealthMux(checks ...ReadyCheck) *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -0,0 +1,23 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+TOPIC="${KAFKA_TEST_TOPIC:-scheduler.job.requested.v1}"
+BROKER="${KAFKA_BROKER:-kafka:9092}"
+EVENT_ID="${EVENT_ID:-$(python3 -c 'import uuid; print(uuid.uuid4())')}"
+DEDUP_KEY="${DEDUP_KEY:-demo:$EVENT_ID}"
+TARGET_TOPIC="${TARGET_TOPIC:-demo.delayed.v1}"
+FIRE_AT="${FIRE_AT:-$(python3 -c 'import datetime; print((datetime.datetime.now(datetime.timezone.utc) + datetime.timedelta(minutes=1)).strftime("%Y-%m-%dT%H:%M:%SZ"))')}"
+
+echo "Publishing to $TOPIC via $BROKER (event_id=$EVENT_ID dedup_key=$DEDUP_KEY fire_at=$FIRE_AT)"
+
+CONTAINER_NAME="${KAFKA_TOOLS_CONTAINER:-apptremind-kafka-tools-1}"
+
+docker exec -i "$CONTAINER_NAME" kcat -b "$BROKER" -t "$TOPIC" \
+  -H "event_id=$EVENT_ID" \
+  -H "event_type=$TOPIC" \
+  -k "$DEDUP_KEY" \
+  -P <<EOF
+{"dedup_key":"$DEDUP_KEY","target_topic":"$TARGET_TOPIC","fire_at":"$FIRE_AT","payload":{"hello":"world"},"source":"runbook"}
+EOF
+
+echo "Published."
@@ -148,6 +148,97 @@ func main() {
 	})
 	go failedConsumer.Run(ctx)
 
+	deliveredConsumerCfg := consumer.Config{
+		Brokers: config.String("KAFKA_BROKERS", ""),
+		GroupID: config.String("KAFKA_GROUP_ID", "analytics-service"),
+		Topic:   "notification.delivered.v1",
+	}
+	deliveredConsumer := consumer.New(logger, inboxRepo, deliveredConsumerCfg, func(ctx context.Context, msg kafka.Message) error {
+		var payload struct {
+			AppointmentID string `json:"appointment_id"`
+			BusinessID    string `json:"business_id"`
+			Channel       string `json:"channel"`
+			DeliveredAt   string `json:"delivered_at"`
+		}
+
+		if err := json.Unmarshal(msg.Value, &payload); err != nil {
+			logger.Error("invalid delivered payload", "err", err)
+			return nil
+		}
+		if payload.AppointmentID == "" || payload.Channel == "" || payload.DeliveredAt == "" {
+			logger.Error("missing delivered fields")
+			return nil
+		}
+		if _, err := time.Parse(time.RFC3339, payload.DeliveredAt); err != nil {
+			logger.Error("invalid delivered_at", "err", err)
+			return nil
+		}
+
+		_, err := pool.Exec(ctx, `
+			INSERT INTO notification_metrics (appointment_id, business_id, channel, sent_at, status)
+			VALUES ($1, NULLIF($2, '')::uuid, $3, $4, 'delivered')
+		`, payload.AppointmentID, payload.BusinessID, payload.Channel, payload.DeliveredAt)
+		if err != nil {
+			logger.Error("failed to write delivered metrics", "err", err)
+			return err
+		}
+
+		if err := bumpDeliveryAggregate(ctx, pool, payload.BusinessID, payload.Channel, payload.DeliveredAt, 1, 0); err != nil {
+			logger.Error("failed to update daily notification metrics", "err", err)
+			return err
+		}
+
+		logger.Info("notification delivery recorded", "appointment_id", payload.AppointmentID, "channel", payload.Channel)
+		return nil
+	})
+	go deliveredConsumer.Run(ctx)
+
+	bouncedConsumerCfg := consumer.Config{
+		Brokers: config.String("KAFKA_BROKERS", ""),
+		GroupID: config.String("KAFKA_GROUP_ID", "analytics-service"),
+		Topic:   "notification.bounced.v1",
+	}
+	bouncedConsumer := consumer.New(logger, inboxRepo, bouncedConsumerCfg, func(ctx context.Context, msg kafka.Message) error {
+		var payload struct {
+			AppointmentID string `json:"appointment_id"`
+			BusinessID    string `json:"business_id"`
+			Channel       string `json:"channel"`
+			BounceType    string `json:"bounce_type"`
+			BouncedAt     string `json:"bounced_at"`
+		}
+
+		if err := json.Unmarshal(msg.Value, &payload); err != nil {
+			logger.Error("invalid bounced payload", "err", err)
+			return nil
+		}
+		if payload.AppointmentID == "" || payload.Channel == "" || payload.BouncedAt == "" {
+			logger.Error("missing bounced fields")
+			return nil
+		}
+		if _, err := time.Parse(time.RFC3339, payload.BouncedAt); err != nil {
+			logger.Error("invalid bounced_at", "err", err)
+			return nil
+		}
+
+		_, err := pool.Exec(ctx, `
+			INSERT INTO notification_metrics (appointment_id, business_id, channel, sent_at, status)
+			VALUES ($1, NULLIF($2, '')::uuid, $3, $4, 'bounced')
+		`, payload.AppointmentID, payload.BusinessID, payload.Channel, payload.BouncedAt)
+		if err != nil {
+			logger.Error("failed to write bounced metrics", "err", err)
+			return err
+		}
+
+		if err := bumpDeliveryAggregate(ctx, pool, payload.BusinessID, payload.Channel, payload.BouncedAt, 0, 1); err != nil {
+			logger.Error("failed to update daily notification metrics", "err", err)
+			return err
+		}
+
+		logger.Info("notification bounce recorded", "appointment_id", payload.AppointmentID, "channel", payload.Channel, "bounce_type", payload.BounceType)
+		return nil
+	})
+	go bouncedConsumer.Run(ctx)
+
 	dlqConsumerCfg := consumer.Config{
 		Brokers: config.String("KAFKA_BROKERS", ""),
 		GroupID: config.String("KAFKA_GROUP_ID", "analytics-service"),
@@ -339,6 +430,7 @@ func main() {
 	handler := httpx.Chain(mux,
 		httpx.WithRequestID,
 		httpx.WithAccessLog(logger),
+		httpx.WithMetrics,
 	)
 	handler = otelhttp.NewHandler(handler, "analytics")
 	srv := &http.Server{
@@ -381,3 +473,22 @@ func bumpNotificationAggregate(ctx context.Context, pool *db.Pool, businessID, c
 	`, businessID, t.UTC(), channel, sentInc, failedInc)
 	return err
 }
+
+func bumpDeliveryAggregate(ctx context.Context, pool *db.Pool, businessID, channel, ts string, deliveredInc, bouncedInc int) error {
+	if businessID == "" || channel == "" || ts == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return nil
+	}
+	_, err = pool.Exec(ctx, `
+		INSERT INTO daily_notification_metrics (business_id, day, channel, delivered_count, bounced_count)
+		VALUES ($1, $2::date, $3, $4, $5)
+		ON CONFLICT (business_id, day, channel)
+		DO UPDATE SET delivered_count = daily_notification_metrics.delivered_count + EXCLUDED.delivered_count,
+		              bounced_count = daily_notification_metrics.bounced_count + EXCLUDED.bounced_count,
+		              updated_at = now()
+	`, businessID, t.UTC(), channel, deliveredInc, bouncedInc)
+	return err
+}
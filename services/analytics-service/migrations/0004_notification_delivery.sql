@@ -0,0 +1,3 @@
+ALTER TABLE daily_notification_metrics
+  ADD COLUMN IF NOT EXISTS delivered_count INT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS bounced_count INT NOT NULL DEFAULT 0;
@@ -2,21 +2,32 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/md-rashed-zaman/apptremind/libs/auth"
 	"github.com/md-rashed-zaman/apptremind/libs/config"
 	"github.com/md-rashed-zaman/apptremind/libs/db"
 	"github.com/md-rashed-zaman/apptremind/libs/httpx"
 	"github.com/md-rashed-zaman/apptremind/libs/kafkax"
 	otelx "github.com/md-rashed-zaman/apptremind/libs/otel"
 	"github.com/md-rashed-zaman/apptremind/libs/runtime"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/accounttokens"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/apikeys"
 	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/audit"
 	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/handlers"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/invites"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/loginguard"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/mfa"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/oidc"
 	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/revocation"
 	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/sessions"
 	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/storage"
+	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
@@ -81,18 +92,146 @@ func main() {
 	}
 	refreshTTL := time.Duration(refreshTTLHours) * time.Hour
 
-	authHandler := handlers.NewAuthHandler(signer, pool, userRepo, auditRepo, outboxRepo, refreshRepo, refreshTTL)
+	var guardStore loginguard.Store
+	if addr := strings.TrimSpace(config.String("REDIS_ADDR", "")); addr != "" {
+		redisDB := 0
+		if v, err := strconv.Atoi(config.String("REDIS_DB", "0")); err == nil && v >= 0 {
+			redisDB = v
+		}
+		rdb := redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: config.String("REDIS_PASSWORD", ""),
+			DB:       redisDB,
+		})
+		defer func() { _ = rdb.Close() }()
+		guardStore = loginguard.NewRedisStore(rdb, config.String("LOGIN_GUARD_PREFIX", "apptremind:auth:login"))
+		logger.Info("login guard enabled (redis)", "redis_addr", addr)
+	} else {
+		guardStore = loginguard.NewPostgresStore(pool)
+		logger.Info("login guard enabled (postgres)")
+	}
+	guard := loginguard.New(guardStore, loginguard.Config{
+		Window:            time.Duration(envInt("LOGIN_FAILURE_WINDOW_MINUTES", 15)) * time.Minute,
+		DelayAfter:        int64(envInt("LOGIN_DELAY_AFTER", 3)),
+		MaxDelay:          time.Duration(envInt("LOGIN_MAX_DELAY_MS", 2000)) * time.Millisecond,
+		AccountLockAfter:  int64(envInt("LOGIN_LOCK_AFTER", 10)),
+		IPLockAfter:       int64(envInt("LOGIN_IP_LOCK_AFTER", 50)),
+		LockDuration:      time.Duration(envInt("LOGIN_LOCK_MINUTES", 15)) * time.Minute,
+		ResetAccountLimit: int64(envInt("PASSWORD_RESET_LIMIT", 3)),
+		ResetIPLimit:      int64(envInt("PASSWORD_RESET_IP_LIMIT", 20)),
+	}, logger)
+
+	accessTokens := handlers.AccessTokenConfig{
+		Issuer:   config.String("JWT_ISSUER", "apptremind-auth"),
+		Audience: config.String("JWT_AUDIENCE", "apptremind-api"),
+		TTL:      time.Duration(envInt("ACCESS_TTL_MINUTES", 60)) * time.Minute,
+	}
+	authHandler := handlers.NewAuthHandler(signer, pool, userRepo, storage.NewMembershipRepository(pool), auditRepo, outboxRepo, refreshRepo, refreshTTL, accessTokens, revocation.NewRepository(pool), guard)
 	mux.HandleFunc("/api/v1/auth/register", authHandler.Register)
 	mux.HandleFunc("/api/v1/auth/login", authHandler.Login)
 	mux.HandleFunc("/api/v1/auth/refresh", authHandler.Refresh)
 	mux.HandleFunc("/api/v1/auth/logout", authHandler.Logout)
 	mux.HandleFunc("/api/v1/auth/me", authHandler.Me)
+	mux.HandleFunc("/api/v1/auth/switch-business", authHandler.SwitchBusiness)
+	mux.HandleFunc("/api/v1/auth/sessions", authHandler.Sessions)
+	mux.HandleFunc("/api/v1/auth/sessions/{id}", authHandler.RevokeSession)
 	mux.HandleFunc("/.well-known/jwks.json", authHandler.JWKS)
 	mux.HandleFunc("/api/v1/auth/rotate", authHandler.Rotate)
 	mux.HandleFunc("/api/v1/auth/audit", authHandler.Audit)
+	mux.HandleFunc("/api/v1/auth/admin/unlock", authHandler.UnlockLogin)
+
+	inviteTTLHours, err := strconv.Atoi(config.String("INVITE_TTL_HOURS", "72"))
+	if err != nil || inviteTTLHours <= 0 {
+		logger.Error("invalid invite ttl hours", "value", inviteTTLHours, "err", err)
+		panic(err)
+	}
+	// Account links (invitations, password resets, email verification) travel
+	// sealed through the outbox and Kafka; notification-service needs the
+	// same ACCOUNT_LINK_KEY to open them.
+	linkSealer, err := auth.NewLinkSealer(config.String("ACCOUNT_LINK_KEY", "dev-account-link-key"))
+	if err != nil {
+		logger.Error("invalid account link key", "err", err)
+		panic(err)
+	}
+	teamHandler := handlers.NewTeamHandler(authHandler, invites.NewRepository(pool), handlers.TeamConfig{
+		// Falls back to JWT_SECRET so local stacks work without extra setup.
+		TokenSecret: config.String("INVITE_TOKEN_SECRET", config.String("JWT_SECRET", "dev-secret")),
+		AcceptURL:   config.String("INVITE_ACCEPT_URL", "http://localhost:8080/invite"),
+		TTL:         time.Duration(inviteTTLHours) * time.Hour,
+		Links:       linkSealer,
+	})
+	mux.HandleFunc("/api/v1/auth/invitations", teamHandler.Invitations)
+	mux.HandleFunc("/api/v1/auth/invitations/revoke", teamHandler.RevokeInvitation)
+	mux.HandleFunc("/api/v1/auth/invitations/accept", teamHandler.AcceptInvitation)
+	mux.HandleFunc("/api/v1/auth/members", teamHandler.Members)
+	mux.HandleFunc("/api/v1/auth/members/role", teamHandler.ChangeRole)
+	mux.HandleFunc("/api/v1/auth/members/remove", teamHandler.RemoveMember)
+
+	resetTTLMinutes, err := strconv.Atoi(config.String("PASSWORD_RESET_TTL_MINUTES", "60"))
+	if err != nil || resetTTLMinutes <= 0 {
+		logger.Error("invalid password reset ttl minutes", "value", resetTTLMinutes, "err", err)
+		panic(err)
+	}
+	verifyTTLHours, err := strconv.Atoi(config.String("EMAIL_VERIFY_TTL_HOURS", "48"))
+	if err != nil || verifyTTLHours <= 0 {
+		logger.Error("invalid email verify ttl hours", "value", verifyTTLHours, "err", err)
+		panic(err)
+	}
+	accountHandler := handlers.NewAccountHandler(authHandler, accounttokens.NewRepository(pool), handlers.AccountConfig{
+		ResetURL:  config.String("PASSWORD_RESET_URL", "http://localhost:8080/reset-password"),
+		VerifyURL: config.String("EMAIL_VERIFY_URL", "http://localhost:8080/verify-email"),
+		ResetTTL:  time.Duration(resetTTLMinutes) * time.Minute,
+		VerifyTTL: time.Duration(verifyTTLHours) * time.Hour,
+		Links:     linkSealer,
+	})
+	mux.HandleFunc("/api/v1/auth/password/forgot", accountHandler.ForgotPassword)
+	mux.HandleFunc("/api/v1/auth/password/reset", accountHandler.ResetPassword)
+	mux.HandleFunc("/api/v1/auth/email/verify/request", accountHandler.RequestVerification)
+	mux.HandleFunc("/api/v1/auth/email/verify", accountHandler.VerifyEmail)
+
+	// Changing MFA_SECRET_KEY later makes enrolled secrets unreadable.
+	mfaSecretKey, err := buildMFASecretKey()
+	if err != nil {
+		logger.Error("mfa secret key missing", "err", err)
+		panic(err)
+	}
+	mfaSealer, err := mfa.NewSealer(mfaSecretKey)
+	if err != nil {
+		logger.Error("mfa sealer init failed", "err", err)
+		panic(err)
+	}
+	mfaHandler := handlers.NewMFAHandler(authHandler, mfa.NewRepository(pool), mfaSealer, handlers.MFAConfig{
+		Issuer:          config.String("MFA_ISSUER", "ApptRemind"),
+		ChallengeSecret: mfaSecretKey,
+		ChallengeTTL:    time.Duration(envInt("MFA_CHALLENGE_TTL_MINUTES", 5)) * time.Minute,
+	})
+	mux.HandleFunc("/api/v1/auth/mfa/verify", mfaHandler.Verify)
+	mux.HandleFunc("/api/v1/auth/mfa/enroll", mfaHandler.Enroll)
+	mux.HandleFunc("/api/v1/auth/mfa/enroll/confirm", mfaHandler.ConfirmEnrollment)
+	mux.HandleFunc("/api/v1/auth/mfa/disable", mfaHandler.Disable)
+	mux.HandleFunc("/api/v1/auth/mfa/policy", mfaHandler.Policy)
+
+	// IdP client secrets are sealed with the same key as TOTP secrets. An
+	// insecure issuer setting also lets provider calls reach local addresses.
+	ssoInsecure := isTruthy(config.String("SSO_ALLOW_INSECURE_ISSUER", "false"))
+	ssoHandler := handlers.NewSSOHandler(authHandler, oidc.NewRepository(pool), oidc.NewClient(nil, time.Hour, ssoInsecure), mfaSealer, handlers.SSOConfig{
+		RedirectURL:         config.String("SSO_REDIRECT_URL", "http://localhost:8080/sso/callback"),
+		StateTTL:            time.Duration(envInt("SSO_STATE_TTL_MINUTES", 10)) * time.Minute,
+		AllowInsecureIssuer: ssoInsecure,
+	})
+	mux.HandleFunc("/api/v1/auth/sso/config", ssoHandler.Config)
+	mux.HandleFunc("/api/v1/auth/sso/start", ssoHandler.Start)
+	mux.HandleFunc("/api/v1/auth/sso/callback", ssoHandler.Callback)
+
+	apiKeyHandler := handlers.NewAPIKeyHandler(authHandler, apikeys.NewRepository(pool), config.String("AUTH_INTERNAL_TOKEN", ""))
+	mux.HandleFunc("/api/v1/auth/api-keys", apiKeyHandler.Keys)
+	mux.HandleFunc("/api/v1/auth/api-keys/{id}", apiKeyHandler.RevokeKey)
+	// Called by the gateway only; /internal is never proxied.
+	mux.HandleFunc("/internal/v1/api-keys/verify", apiKeyHandler.Verify)
 	handler := httpx.Chain(mux,
 		httpx.WithRequestID,
 		httpx.WithAccessLog(logger),
+		httpx.WithMetrics,
 	)
 	handler = otelhttp.NewHandler(handler, "auth")
 	srv := &http.Server{
@@ -117,6 +256,39 @@ func main() {
 	logger.Info("http server stopped")
 }
 
+// envInt reads a positive integer setting; anything else yields fallback.
+func envInt(key string, fallback int) int {
+	v, err := strconv.Atoi(config.String(key, strconv.Itoa(fallback)))
+	if err != nil || v <= 0 {
+		return fallback
+	}
+	return v
+}
+
+func isTruthy(s string) bool {
+	switch strings.TrimSpace(strings.ToLower(s)) {
+	case "1", "true", "t", "yes", "y", "on":
+		return true
+	default:
+		return false
+	}
+}
+
+// buildMFASecretKey returns the key that signs MFA challenges and seals TOTP
+// and IdP client secrets. HS256 stacks may share JWT_SECRET so local setups
+// work without extra configuration; RS256 ones have no shared secret, and a
+// built-in default would let anyone forge enrollment challenges.
+func buildMFASecretKey() (string, error) {
+	if key := config.String("MFA_SECRET_KEY", ""); key != "" {
+		return key, nil
+	}
+	hs256 := config.String("JWT_PRIVATE_KEY_PEM", "") == "" && config.String("JWT_PRIVATE_KEYS_PEM", "") == ""
+	if secret := config.String("JWT_SECRET", ""); hs256 && secret != "" {
+		return secret, nil
+	}
+	return "", errors.New("MFA_SECRET_KEY is required unless JWT_SECRET signs HS256 tokens")
+}
+
 func buildSigner() (handlers.TokenSigner, error) {
 	privatePEM := config.String("JWT_PRIVATE_KEY_PEM", "")
 	privatePEMS := config.String("JWT_PRIVATE_KEYS_PEM", "")
@@ -0,0 +1,77 @@
+package accounttokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+)
+
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+)
+
+type Repository struct {
+	pool *db.Pool
+}
+
+func NewRepository(pool *db.Pool) *Repository {
+	return &Repository{pool: pool}
+}
+
+// NewToken returns a random token for an emailed link.
+func NewToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// IssueTx stores rawToken for userID and retires any earlier unused token of
+// the same purpose, so only the most recent link works.
+func (r *Repository) IssueTx(ctx context.Context, tx pgx.Tx, userID string, purpose string, rawToken string, expiresAt time.Time) error {
+	if _, err := tx.Exec(ctx, `
+		UPDATE account_tokens
+		SET used_at = now()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`, userID, purpose); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO account_tokens (id, user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, uuid.NewString(), userID, purpose, hashToken(rawToken), expiresAt)
+	return err
+}
+
+// ConsumeTx marks an unused, unexpired token as used and returns its user id.
+// It returns pgx.ErrNoRows for unknown, spent or expired tokens.
+func (r *Repository) ConsumeTx(ctx context.Context, tx pgx.Tx, purpose string, rawToken string) (string, error) {
+	var userID string
+	err := tx.QueryRow(ctx, `
+		UPDATE account_tokens
+		SET used_at = now()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id
+	`, hashToken(rawToken), purpose).Scan(&userID)
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+func IsNotFound(err error) bool {
+	return err == pgx.ErrNoRows
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
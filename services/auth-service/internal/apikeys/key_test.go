@@ -0,0 +1,43 @@
+package apikeys
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateAndPrefix(t *testing.T) {
+	raw, prefix, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !strings.HasPrefix(raw, prefix+"_") || !strings.HasPrefix(prefix, "ak_") {
+		t.Fatalf("unexpected key shape %q (prefix %q)", raw, prefix)
+	}
+	got, ok := Prefix(raw)
+	if !ok || got != prefix {
+		t.Fatalf("Prefix(%q) = %q, %v; want %q", raw, got, ok, prefix)
+	}
+	other, _, _ := Generate()
+	if Hash(raw) == Hash(other) {
+		t.Fatal("two keys hash alike")
+	}
+
+	for _, bad := range []string{"", "ak_", "ak_abc", "ak_abc_", "sk_abc_def", "Bearer ak_abc_def"} {
+		if _, ok := Prefix(bad); ok {
+			t.Fatalf("Prefix(%q) accepted a malformed key", bad)
+		}
+	}
+}
+
+func TestNormalizeScopes(t *testing.T) {
+	got, ok := NormalizeScopes([]string{" Appointments:Read", "appointments:write", "appointments:read"})
+	if !ok || len(got) != 2 || got[0] != ScopeAppointmentsRead || got[1] != ScopeAppointmentsWrite {
+		t.Fatalf("NormalizeScopes = %v, %v", got, ok)
+	}
+	if _, ok := NormalizeScopes(nil); ok {
+		t.Fatal("expected no scopes to be rejected")
+	}
+	if _, ok := NormalizeScopes([]string{"billing:write"}); ok {
+		t.Fatal("expected unknown scope to be rejected")
+	}
+}
@@ -0,0 +1,128 @@
+package apikeys
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+)
+
+type Key struct {
+	ID         string   `json:"key_id"`
+	BusinessID string   `json:"business_id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	// RateLimitPerMinute is nil when the gateway default applies.
+	RateLimitPerMinute *int       `json:"rate_limit_per_minute,omitempty"`
+	CreatedBy          string     `json:"created_by"`
+	CreatedAt          time.Time  `json:"created_at"`
+	LastUsedAt         *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt          *time.Time `json:"revoked_at,omitempty"`
+	hash               string
+}
+
+type Repository struct {
+	pool *db.Pool
+}
+
+func NewRepository(pool *db.Pool) *Repository {
+	return &Repository{pool: pool}
+}
+
+// Create stores key with the hash of raw; ID, CreatedAt and the hash are set
+// from the arguments.
+func (r *Repository) Create(ctx context.Context, key Key, raw string) (Key, error) {
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO api_keys (id, business_id, name, prefix, key_hash, scopes, rate_limit_per_minute, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at
+	`, key.ID, key.BusinessID, key.Name, key.Prefix, Hash(raw), key.Scopes, key.RateLimitPerMinute, key.CreatedBy).Scan(&key.CreatedAt)
+	if err != nil {
+		return Key{}, err
+	}
+	return key, nil
+}
+
+// List returns businessID's keys that have not been revoked, newest first.
+func (r *Repository) List(ctx context.Context, businessID string) ([]Key, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+keyColumns+`
+		FROM api_keys
+		WHERE business_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+		LIMIT 200
+	`, businessID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Key
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, key)
+	}
+	return out, rows.Err()
+}
+
+// Revoke disables one of businessID's keys. It reports whether a row changed.
+func (r *Repository) Revoke(ctx context.Context, businessID string, id string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE api_keys
+		SET revoked_at = now()
+		WHERE id = $1 AND business_id = $2 AND revoked_at IS NULL
+	`, id, businessID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Authenticate returns the live key matching raw and records its use.
+// last_used_at is written at most once a minute per key so busy integrations
+// do not turn every request into a write.
+func (r *Repository) Authenticate(ctx context.Context, raw string) (Key, error) {
+	prefix, ok := Prefix(raw)
+	if !ok {
+		return Key{}, pgx.ErrNoRows
+	}
+	key, err := scanKey(r.pool.QueryRow(ctx, `
+		SELECT `+keyColumns+`
+		FROM api_keys
+		WHERE prefix = $1 AND revoked_at IS NULL
+	`, prefix))
+	if err != nil {
+		return Key{}, err
+	}
+	if !hashesEqual(key.hash, Hash(raw)) {
+		return Key{}, pgx.ErrNoRows
+	}
+	if _, err := r.pool.Exec(ctx, `
+		UPDATE api_keys
+		SET last_used_at = now()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')
+	`, key.ID); err != nil {
+		return Key{}, err
+	}
+	return key, nil
+}
+
+func IsNotFound(err error) bool {
+	return err == pgx.ErrNoRows
+}
+
+const keyColumns = `id, business_id, name, prefix, key_hash, scopes, rate_limit_per_minute, created_by, created_at, last_used_at, revoked_at`
+
+func scanKey(row pgx.Row) (Key, error) {
+	var key Key
+	err := row.Scan(&key.ID, &key.BusinessID, &key.Name, &key.Prefix, &key.hash, &key.Scopes, &key.RateLimitPerMinute, &key.CreatedBy, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt)
+	if err != nil {
+		return Key{}, err
+	}
+	return key, nil
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/md-rashed-zaman/apptremind/libs/db"
 	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/outbox"
 )
@@ -29,6 +31,20 @@ func (r *Repository) Record(ctx context.Context, eventType string, actorID strin
 	return err
 }
 
+// RecordTx writes the audit row inside the caller's transaction so it commits
+// or rolls back with the change it describes.
+func (r *Repository) RecordTx(ctx context.Context, tx pgx.Tx, eventType string, actorID string, metadata map[string]any) error {
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO audit_events (event_type, actor_id, metadata)
+		VALUES ($1, NULLIF($2, ''), $3)
+	`, eventType, actorID, raw)
+	return err
+}
+
 func (r *Repository) RecordWithOutbox(ctx context.Context, outboxRepo *outbox.Repository, eventType string, actorID string, metadata map[string]any) error {
 	if outboxRepo == nil {
 		return r.Record(ctx, eventType, actorID, metadata)
@@ -62,9 +78,15 @@ func (r *Repository) RecordWithOutbox(ctx context.Context, outboxRepo *outbox.Re
 		return err
 	}
 
+	// outbox aggregate ids are UUIDs: key the event by its actor, or by a fresh
+	// id when there is none (failed logins for unknown emails, key rotation).
+	aggregateID := actorID
+	if _, err := uuid.Parse(aggregateID); err != nil {
+		aggregateID = uuid.NewString()
+	}
 	if err := outboxRepo.Insert(ctx, tx, outbox.Event{
 		AggregateType: "audit_event",
-		AggregateID:   "auth",
+		AggregateID:   aggregateID,
 		EventType:     "auth.audit.v1",
 		Payload:       payload,
 	}); err != nil {
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/auth"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/accounttokens"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/loginguard"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/storage"
+)
+
+type AccountConfig struct {
+	// ResetURL and VerifyURL are the pages emailed links open; the token is
+	// appended as ?token= and posted back to the matching confirm endpoint.
+	ResetURL  string
+	VerifyURL string
+	ResetTTL  time.Duration
+	VerifyTTL time.Duration
+	// Links encrypts the emailed links inside events; notification-service
+	// holds the same key.
+	Links *auth.LinkSealer
+}
+
+// AccountHandler runs the self-service flows that prove control of an email
+// address: forgotten passwords and email verification.
+type AccountHandler struct {
+	auth   *AuthHandler
+	tokens *accounttokens.Repository
+	cfg    AccountConfig
+}
+
+func NewAccountHandler(authHandler *AuthHandler, tokensRepo *accounttokens.Repository, cfg AccountConfig) *AccountHandler {
+	if cfg.ResetTTL <= 0 {
+		cfg.ResetTTL = time.Hour
+	}
+	if cfg.VerifyTTL <= 0 {
+		cfg.VerifyTTL = 48 * time.Hour
+	}
+	return &AccountHandler{auth: authHandler, tokens: tokensRepo, cfg: cfg}
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// ForgotPassword emails a reset link when the address belongs to an account.
+// It answers 202 either way so the endpoint cannot be used to probe for
+// registered addresses. Requests are throttled per address and per client by
+// the login guard.
+func (h *AccountHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req forgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" {
+		http.Error(w, "email required", http.StatusBadRequest)
+		return
+	}
+	if h.auth.guard != nil && !h.auth.guard.AllowReset(r.Context(), req.Email, loginguard.ClientIP(r)) {
+		http.Error(w, "too many reset requests, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	user, err := h.auth.users.GetByEmail(r.Context(), req.Email)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		http.Error(w, "failed to lookup user", http.StatusInternalServerError)
+		return
+	}
+	if err := h.sendLink(r.Context(), user, accounttokens.PurposePasswordReset); err != nil {
+		http.Error(w, "failed to request password reset", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password from a reset link and signs the user out
+// everywhere by revoking all of their refresh tokens.
+func (h *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req resetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	req.Password = strings.TrimSpace(req.Password)
+	if req.Token == "" || req.Password == "" {
+		http.Error(w, "token and password required", http.StatusBadRequest)
+		return
+	}
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		http.Error(w, "failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := h.auth.pool.Begin(ctx)
+	if err != nil {
+		http.Error(w, "failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	userID, err := h.tokens.ConsumeTx(ctx, tx, accounttokens.PurposePasswordReset, req.Token)
+	if err != nil {
+		if accounttokens.IsNotFound(err) {
+			http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "failed to check token", http.StatusInternalServerError)
+		return
+	}
+	if err := h.auth.users.UpdatePasswordTx(ctx, tx, userID, hash); err != nil {
+		http.Error(w, "failed to update password", http.StatusInternalServerError)
+		return
+	}
+	// The link arrived by email, which is as good as a verification link.
+	if err := h.auth.users.MarkEmailVerifiedTx(ctx, tx, userID); err != nil {
+		http.Error(w, "failed to update user", http.StatusInternalServerError)
+		return
+	}
+	revoked, err := h.auth.refreshRepo.RevokeAllForUser(ctx, tx, userID, "password_reset")
+	if err != nil {
+		http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	if err := h.auth.revokeUserTokensTx(ctx, tx, userID, "password_reset"); err != nil {
+		http.Error(w, "failed to revoke access tokens", http.StatusInternalServerError)
+		return
+	}
+	if err := h.auth.audit.RecordTx(ctx, tx, "auth.password_reset.completed", userID, map[string]any{
+		"revoked_refresh_tokens": revoked,
+	}); err != nil {
+		http.Error(w, "failed to record audit event", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+	// Proving control of the mailbox also lifts a lockout from password guessing.
+	if h.auth.guard != nil {
+		if user, err := h.auth.users.GetByID(ctx, userID); err == nil {
+			_ = h.auth.guard.Unlock(ctx, user.Email, "")
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestVerification emails the signed-in user a link confirming their
+// address.
+func (h *AccountHandler) RequestVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := h.auth.bearerClaims(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.auth.users.GetByID(r.Context(), claims.Sub)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "failed to lookup user", http.StatusInternalServerError)
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		http.Error(w, "email already verified", http.StatusConflict)
+		return
+	}
+	if err := h.sendLink(r.Context(), user, accounttokens.PurposeEmailVerification); err != nil {
+		http.Error(w, "failed to request verification", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// VerifyEmail confirms an address from a verification link.
+func (h *AccountHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req verifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		http.Error(w, "token required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := h.auth.pool.Begin(ctx)
+	if err != nil {
+		http.Error(w, "failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	userID, err := h.tokens.ConsumeTx(ctx, tx, accounttokens.PurposeEmailVerification, req.Token)
+	if err != nil {
+		if accounttokens.IsNotFound(err) {
+			http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "failed to check token", http.StatusInternalServerError)
+		return
+	}
+	if err := h.auth.users.MarkEmailVerifiedTx(ctx, tx, userID); err != nil {
+		http.Error(w, "failed to update user", http.StatusInternalServerError)
+		return
+	}
+	if err := h.auth.audit.RecordTx(ctx, tx, "auth.email_verification.completed", userID, map[string]any{}); err != nil {
+		http.Error(w, "failed to record audit event", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendLink issues a token for purpose and enqueues the event notification-service
+// turns into an email, together with the audit row, in one transaction.
+func (h *AccountHandler) sendLink(ctx context.Context, user storage.User, purpose string) error {
+	token, err := accounttokens.NewToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().UTC().Add(h.ttl(purpose)).Truncate(time.Second)
+
+	tx, err := h.auth.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := h.tokens.IssueTx(ctx, tx, user.ID, purpose, token, expiresAt); err != nil {
+		return err
+	}
+	if err := h.enqueueLink(ctx, tx, user, purpose, token, expiresAt); err != nil {
+		return err
+	}
+	if err := h.auth.audit.RecordTx(ctx, tx, "auth."+purpose+".requested", user.ID, map[string]any{
+		"expires_at": expiresAt.Format(time.RFC3339),
+	}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (h *AccountHandler) enqueueLink(ctx context.Context, tx pgx.Tx, user storage.User, purpose string, token string, expiresAt time.Time) error {
+	event := map[string]any{
+		"user_id":    user.ID,
+		"email":      user.Email,
+		"expires_at": expiresAt.Format(time.RFC3339),
+	}
+	eventType, field, link := "auth.email_verification.requested.v1", "verify_url_sealed", linkWithToken(h.cfg.VerifyURL, token)
+	if purpose == accounttokens.PurposePasswordReset {
+		eventType, field, link = "auth.password_reset.requested.v1", "reset_url_sealed", linkWithToken(h.cfg.ResetURL, token)
+	}
+	sealed, err := h.cfg.Links.Seal(link, eventType, user.Email)
+	if err != nil {
+		return err
+	}
+	event[field] = sealed
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return h.auth.outbox.Insert(ctx, tx, outbox.Event{
+		AggregateType: "user",
+		AggregateID:   user.ID,
+		EventType:     eventType,
+		Payload:       payload,
+	})
+}
+
+func (h *AccountHandler) ttl(purpose string) time.Duration {
+	if purpose == accounttokens.PurposePasswordReset {
+		return h.cfg.ResetTTL
+	}
+	return h.cfg.VerifyTTL
+}
+
+// linkWithToken appends token to base as a query parameter.
+func linkWithToken(base string, token string) string {
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/md-rashed-zaman/apptremind/libs/auth"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/accounttokens"
+)
+
+func TestPasswordResetRevokesSessions(t *testing.T) {
+	authHandler := newTestAuthHandler(t)
+	links, err := auth.NewLinkSealer("test-link-key")
+	if err != nil {
+		t.Fatalf("NewLinkSealer: %v", err)
+	}
+	h := NewAccountHandler(authHandler, accounttokens.NewRepository(authHandler.pool), AccountConfig{
+		ResetURL: "http://localhost:8080/reset",
+		Links:    links,
+	})
+	seedMember(t, authHandler, "ana@example.com", testBusinessID, "owner")
+	session := decodeSession(t, login(authHandler, "ana@example.com"))
+
+	post := func(handler http.HandlerFunc, body string) int {
+		rw := httptest.NewRecorder()
+		handler(rw, httptest.NewRequest(http.MethodPost, "/api/v1/auth/password", strings.NewReader(body)))
+		return rw.Code
+	}
+	// Unknown addresses get the same answer, so the endpoint cannot probe.
+	if code := post(h.ForgotPassword, `{"email":"nobody@example.com"}`); code != http.StatusAccepted {
+		t.Fatalf("forgot unknown: expected 202, got %d", code)
+	}
+	if code := post(h.ForgotPassword, `{"email":"ana@example.com"}`); code != http.StatusAccepted {
+		t.Fatalf("forgot: expected 202, got %d", code)
+	}
+	token := linkTokenFromOutbox(t, authHandler, links, "auth.password_reset.requested.v1", "reset_url_sealed", "ana@example.com")
+
+	if code := post(h.ResetPassword, `{"token":"not-the-token","password":"new-pass-5678"}`); code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: expected 401, got %d", code)
+	}
+	if code := post(h.ResetPassword, `{"token":"`+token+`","password":"new-pass-5678"}`); code != http.StatusNoContent {
+		t.Fatalf("reset: expected 204, got %d", code)
+	}
+	if code := post(h.ResetPassword, `{"token":"`+token+`","password":"again-9012"}`); code != http.StatusUnauthorized {
+		t.Fatalf("reused token: expected 401, got %d", code)
+	}
+
+	rw := httptest.NewRecorder()
+	authHandler.Refresh(rw, httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", strings.NewReader(`{"refresh_token":"`+session.RefreshToken+`"}`)))
+	if rw.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh after reset: expected 401, got %d", rw.Code)
+	}
+	rw = httptest.NewRecorder()
+	authHandler.Me(rw, bearerRequest(http.MethodGet, "/api/v1/auth/me", session.AccessToken, ""))
+	if rw.Code != http.StatusUnauthorized {
+		t.Fatalf("access token after reset: expected 401, got %d", rw.Code)
+	}
+	if rw := login(authHandler, "ana@example.com"); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("old password: expected 401, got %d", rw.Code)
+	}
+	rw = httptest.NewRecorder()
+	authHandler.Login(rw, httptest.NewRequest(http.MethodPost, "/api/v1/auth/login",
+		strings.NewReader(`{"email":"ana@example.com","password":"new-pass-5678"}`)))
+	decodeSession(t, rw)
+}
+
+func TestAccountDefaultTTLs(t *testing.T) {
+	h := NewAccountHandler(&AuthHandler{}, nil, AccountConfig{})
+	if got := h.ttl(accounttokens.PurposePasswordReset); got.Hours() != 1 {
+		t.Fatalf("reset ttl = %v, want 1h", got)
+	}
+	if got := h.ttl(accounttokens.PurposeEmailVerification); got.Hours() != 48 {
+		t.Fatalf("verify ttl = %v, want 48h", got)
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/apikeys"
+)
+
+const internalTokenHeader = "X-Internal-Token"
+
+// maxKeyRateLimit caps the per-key limit an owner can ask for.
+const maxKeyRateLimit = 10000
+
+// APIKeyHandler lets owners issue keys for integrations and lets the gateway
+// resolve a presented key to its business and scopes.
+type APIKeyHandler struct {
+	auth *AuthHandler
+	keys *apikeys.Repository
+	// internalToken guards the verify endpoint; empty disables it.
+	internalToken string
+}
+
+func NewAPIKeyHandler(authHandler *AuthHandler, keys *apikeys.Repository, internalToken string) *APIKeyHandler {
+	return &APIKeyHandler{auth: authHandler, keys: keys, internalToken: strings.TrimSpace(internalToken)}
+}
+
+type createAPIKeyRequest struct {
+	BusinessID         string   `json:"business_id,omitempty"` // admin only
+	Name               string   `json:"name"`
+	Scopes             []string `json:"scopes"`
+	RateLimitPerMinute *int     `json:"rate_limit_per_minute,omitempty"`
+}
+
+type createAPIKeyResponse struct {
+	apikeys.Key
+	// APIKey is only ever returned here; it is stored hashed.
+	APIKey string `json:"api_key"`
+}
+
+type verifyAPIKeyRequest struct {
+	Key string `json:"key"`
+}
+
+// Keys handles GET (the business's live keys) and POST (issue a key).
+func (h *APIKeyHandler) Keys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listKeys(w, r)
+	case http.MethodPost:
+		h.createKey(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *APIKeyHandler) listKeys(w http.ResponseWriter, r *http.Request) {
+	_, businessID, ok := teamScope(w, r, r.URL.Query().Get("business_id"))
+	if !ok {
+		return
+	}
+	items, err := h.keys.List(r.Context(), businessID)
+	if err != nil {
+		http.Error(w, "failed to list api keys", http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []apikeys.Key{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (h *APIKeyHandler) createKey(w http.ResponseWriter, r *http.Request) {
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	actorID, businessID, ok := teamScope(w, r, req.BusinessID)
+	if !ok {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		http.Error(w, "name required (max 100 characters)", http.StatusBadRequest)
+		return
+	}
+	scopes, ok := apikeys.NormalizeScopes(req.Scopes)
+	if !ok {
+		http.Error(w, "scopes must be one or more of appointments:read, appointments:write", http.StatusBadRequest)
+		return
+	}
+	if req.RateLimitPerMinute != nil && (*req.RateLimitPerMinute <= 0 || *req.RateLimitPerMinute > maxKeyRateLimit) {
+		http.Error(w, "rate_limit_per_minute must be between 1 and 10000", http.StatusBadRequest)
+		return
+	}
+
+	raw, prefix, err := apikeys.Generate()
+	if err != nil {
+		http.Error(w, "failed to generate api key", http.StatusInternalServerError)
+		return
+	}
+	key, err := h.keys.Create(r.Context(), apikeys.Key{
+		ID:                 uuid.NewString(),
+		BusinessID:         businessID,
+		Name:               req.Name,
+		Prefix:             prefix,
+		Scopes:             scopes,
+		RateLimitPerMinute: req.RateLimitPerMinute,
+		CreatedBy:          actorID,
+	}, raw)
+	if err != nil {
+		http.Error(w, "failed to create api key", http.StatusInternalServerError)
+		return
+	}
+	_ = h.auth.audit.Record(r.Context(), "auth.api_key.created", actorID, map[string]any{
+		"business_id": businessID,
+		"key_id":      key.ID,
+		"prefix":      key.Prefix,
+		"scopes":      key.Scopes,
+	})
+	writeJSON(w, http.StatusCreated, createAPIKeyResponse{Key: key, APIKey: raw})
+}
+
+// RevokeKey disables a key at once in auth-service; gateways stop accepting
+// it when their cached verification expires.
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	actorID, businessID, ok := teamScope(w, r, r.URL.Query().Get("business_id"))
+	if !ok {
+		return
+	}
+	keyID := strings.TrimSpace(r.PathValue("id"))
+	if _, err := uuid.Parse(keyID); err != nil {
+		http.Error(w, "key id must be a uuid", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := h.keys.Revoke(r.Context(), businessID, keyID)
+	if err != nil {
+		http.Error(w, "failed to revoke api key", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "api key not found", http.StatusNotFound)
+		return
+	}
+	_ = h.auth.audit.Record(r.Context(), "auth.api_key.revoked", actorID, map[string]any{
+		"business_id": businessID,
+		"key_id":      keyID,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Verify resolves a presented key for the gateway. It is served under
+// /internal and never proxied; the gateway authenticates with a shared token.
+func (h *APIKeyHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.internalToken == "" {
+		http.Error(w, "internal api not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(internalTokenHeader)), []byte(h.internalToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req verifyAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.keys.Authenticate(r.Context(), req.Key)
+	if err != nil {
+		if apikeys.IsNotFound(err) {
+			http.Error(w, "invalid api key", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "failed to verify api key", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/apikeys"
+)
+
+func TestAPIKeyIssueVerifyRevoke(t *testing.T) {
+	authHandler := newTestAuthHandler(t)
+	h := NewAPIKeyHandler(authHandler, apikeys.NewRepository(authHandler.pool), "internal-secret")
+	ownerID := seedMember(t, authHandler, "ana@example.com", testBusinessID, "owner")
+	asOwner := func(method, target, businessID, body string) *http.Request {
+		req := teamRequest(method, target, body, "owner")
+		req.Header.Set("X-User-Id", ownerID)
+		req.Header.Set("X-Business-Id", businessID)
+		return req
+	}
+
+	rw := httptest.NewRecorder()
+	h.Keys(rw, asOwner(http.MethodPost, "/api/v1/auth/api-keys", testBusinessID, `{"name":"EHR","scopes":["appointments:read"],"rate_limit_per_minute":30}`))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rw.Code, rw.Body.String())
+	}
+	var created createAPIKeyResponse
+	if err := json.NewDecoder(rw.Body).Decode(&created); err != nil || created.APIKey == "" {
+		t.Fatalf("decode created key: %v", err)
+	}
+
+	verify := func(raw string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/internal/v1/api-keys/verify", strings.NewReader(`{"key":"`+raw+`"}`))
+		req.Header.Set(internalTokenHeader, "internal-secret")
+		rw := httptest.NewRecorder()
+		h.Verify(rw, req)
+		return rw
+	}
+	rw = verify(created.APIKey)
+	var key apikeys.Key
+	if err := json.NewDecoder(rw.Body).Decode(&key); err != nil || rw.Code != http.StatusOK {
+		t.Fatalf("verify: got %d: %v", rw.Code, err)
+	}
+	if key.BusinessID != testBusinessID || len(key.Scopes) != 1 || key.Scopes[0] != "appointments:read" ||
+		key.RateLimitPerMinute == nil || *key.RateLimitPerMinute != 30 {
+		t.Fatalf("unexpected verified key %+v", key)
+	}
+	if rw := verify(created.APIKey + "x"); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("tampered key: expected 401, got %d", rw.Code)
+	}
+
+	revoke := func(businessID string) int {
+		req := asOwner(http.MethodDelete, "/api/v1/auth/api-keys/"+created.ID, businessID, "")
+		req.SetPathValue("id", created.ID)
+		rw := httptest.NewRecorder()
+		h.RevokeKey(rw, req)
+		return rw.Code
+	}
+	if code := revoke(otherBusinessID); code != http.StatusNotFound {
+		t.Fatalf("revoke from another business: expected 404, got %d", code)
+	}
+	if code := revoke(testBusinessID); code != http.StatusNoContent {
+		t.Fatalf("revoke: expected 204, got %d", code)
+	}
+	if rw := verify(created.APIKey); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked key: expected 401, got %d", rw.Code)
+	}
+}
+
+func TestAPIKeyVerifyRequiresInternalToken(t *testing.T) {
+	cases := []struct {
+		name   string
+		secret string
+		header string
+		want   int
+	}{
+		{"not configured", "", "anything", http.StatusServiceUnavailable},
+		{"missing token", "internal-secret", "", http.StatusUnauthorized},
+		{"wrong token", "internal-secret", "guess", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewAPIKeyHandler(&AuthHandler{}, nil, tc.secret)
+			req := httptest.NewRequest(http.MethodPost, "/internal/v1/api-keys/verify", strings.NewReader(`{"key":"ak_0011_22"}`))
+			if tc.header != "" {
+				req.Header.Set(internalTokenHeader, tc.header)
+			}
+			rw := httptest.NewRecorder()
+			h.Verify(rw, req)
+			if rw.Code != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, rw.Code)
+			}
+		})
+	}
+}
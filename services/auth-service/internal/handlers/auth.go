@@ -15,7 +15,9 @@ import (
 	"github.com/md-rashed-zaman/apptremind/libs/auth"
 	"github.com/md-rashed-zaman/apptremind/libs/db"
 	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/audit"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/loginguard"
 	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/revocation"
 	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/sessions"
 	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/storage"
 	"golang.org/x/crypto/bcrypt"
@@ -25,32 +27,65 @@ type AuthHandler struct {
 	signer       TokenSigner
 	pool         *db.Pool
 	users        *storage.UserRepository
+	memberships  *storage.MembershipRepository
 	audit        *audit.Repository
 	outbox       *outbox.Repository
 	refreshRepo  *sessions.RefreshRepository
 	refreshToken time.Duration
+	access       AccessTokenConfig
+	// revocations backs the access-token denylist; nil skips the check.
+	revocations *revocation.Repository
+	// guard throttles password guessing; nil disables it.
+	guard *loginguard.Guard
+	// mfa is set by NewMFAHandler; nil means logins stop at the password.
+	mfa *MFAHandler
 }
 
 func NewAuthHandler(
 	signer TokenSigner,
 	pool *db.Pool,
 	users *storage.UserRepository,
+	memberships *storage.MembershipRepository,
 	auditRepo *audit.Repository,
 	outboxRepo *outbox.Repository,
 	refreshRepo *sessions.RefreshRepository,
 	refreshTTL time.Duration,
+	access AccessTokenConfig,
+	revocations *revocation.Repository,
+	guard *loginguard.Guard,
 ) *AuthHandler {
 	return &AuthHandler{
 		signer:       signer,
 		pool:         pool,
 		users:        users,
+		memberships:  memberships,
 		audit:        auditRepo,
 		outbox:       outboxRepo,
 		refreshRepo:  refreshRepo,
 		refreshToken: refreshTTL,
+		access:       access,
+		revocations:  revocations,
+		guard:        guard,
 	}
 }
 
+// AccessTokenConfig sets the iss and aud claims of issued access tokens and
+// how long they live. Verifiers (the gateway, bearerClaims) expect the same
+// values.
+type AccessTokenConfig struct {
+	Issuer   string
+	Audience string
+	// TTL defaults to an hour.
+	TTL time.Duration
+}
+
+func (c AccessTokenConfig) ttl() time.Duration {
+	if c.TTL > 0 {
+		return c.TTL
+	}
+	return time.Hour
+}
+
 type loginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -66,6 +101,16 @@ type loginResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	TokenType    string `json:"token_type"`
+	// BusinessID is the business the tokens are scoped to; Businesses lists
+	// every membership the user can switch to.
+	BusinessID string               `json:"business_id,omitempty"`
+	Businesses []storage.Membership `json:"businesses,omitempty"`
+	// RecoveryCodes is only set when the session completes MFA enrollment.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type switchBusinessRequest struct {
+	BusinessID string `json:"business_id"`
 }
 
 type refreshRequest struct {
@@ -80,6 +125,7 @@ type meResponse struct {
 	UserID     string `json:"user_id"`
 	BusinessID string `json:"business_id"`
 	Role       string `json:"role"`
+	StaffID    string `json:"staff_id,omitempty"`
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -111,8 +157,8 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		BusinessID:   uuid.NewString(),
 		Email:        req.Email,
 		PasswordHash: string(hash),
-		Role:         "owner",
 	}
+	membership := storage.Membership{UserID: user.ID, BusinessID: user.BusinessID, Role: "owner"}
 	ctx := r.Context()
 	tx, err := h.pool.Begin(ctx)
 	if err != nil {
@@ -129,12 +175,16 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to create user", http.StatusInternalServerError)
 		return
 	}
+	if _, err := h.memberships.AddTx(ctx, tx, membership); err != nil {
+		http.Error(w, "failed to create membership", http.StatusInternalServerError)
+		return
+	}
 
 	createdPayload, err := json.Marshal(map[string]any{
 		"user_id":     user.ID,
 		"business_id": user.BusinessID,
 		"email":       user.Email,
-		"role":        user.Role,
+		"role":        membership.Role,
 		"created_at":  time.Now().UTC(),
 	})
 	if err != nil {
@@ -156,52 +206,89 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := issueJWT(user, h.signer)
-	if err != nil {
-		http.Error(w, "failed to issue token", http.StatusInternalServerError)
+	h.writeSession(w, r, http.StatusCreated, membership, []storage.Membership{membership})
+}
+
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	refreshToken, err := h.issueRefreshToken(r.Context(), user.ID)
-	if err != nil {
-		http.Error(w, "failed to issue refresh token", http.StatusInternalServerError)
+
+	claims, ok := h.bearerClaims(w, r)
+	if !ok {
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	_ = json.NewEncoder(w).Encode(loginResponse{
-		AccessToken:  token,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(meResponse{
+		UserID:     claims.Sub,
+		BusinessID: claims.BusinessID,
+		Role:       claims.Role,
+		StaffID:    claims.StaffID,
 	})
 }
 
-func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// SwitchBusiness exchanges a valid access token for tokens scoped to another
+// business the same user belongs to. The chosen business also becomes the
+// default for the next login.
+func (h *AuthHandler) SwitchBusiness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	claims, ok := h.bearerClaims(w, r)
+	if !ok {
+		return
+	}
 
-	authHeader := r.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") || len(strings.TrimSpace(authHeader)) <= len("Bearer ") {
-		http.Error(w, "missing or invalid Authorization header", http.StatusUnauthorized)
+	var req switchBusinessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.BusinessID = strings.TrimSpace(req.BusinessID)
+	if _, err := uuid.Parse(req.BusinessID); err != nil {
+		http.Error(w, "business_id must be a uuid", http.StatusBadRequest)
 		return
 	}
 
-	token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
-	claims, err := h.signer.Verify(token)
+	ctx := r.Context()
+	membership, err := h.memberships.Get(ctx, claims.Sub, req.BusinessID)
 	if err != nil {
-		http.Error(w, "invalid token", http.StatusUnauthorized)
+		if storage.IsNotFound(err) {
+			http.Error(w, "not a member of this business", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "failed to lookup membership", http.StatusInternalServerError)
 		return
 	}
+	if h.mfa != nil {
+		missing, err := h.mfa.missingRequiredFactor(ctx, membership)
+		if err != nil {
+			http.Error(w, "failed to check mfa policy", http.StatusInternalServerError)
+			return
+		}
+		if missing {
+			http.Error(w, "this business requires multi-factor authentication; enroll and sign in again", http.StatusForbidden)
+			return
+		}
+	}
+	if err := h.users.SetDefaultBusiness(ctx, claims.Sub, membership.BusinessID); err != nil {
+		http.Error(w, "failed to update default business", http.StatusInternalServerError)
+		return
+	}
+	if h.audit != nil {
+		_ = h.audit.Record(ctx, "auth.business_switched", claims.Sub, map[string]any{
+			"from_business_id": claims.BusinessID,
+			"to_business_id":   membership.BusinessID,
+		})
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(meResponse{
-		UserID:     claims.Sub,
-		BusinessID: claims.BusinessID,
-		Role:       claims.Role,
-	})
+	// The session moves with the user: its refresh token is replaced by one
+	// for the new business.
+	h.continueSession(w, r, membership, claims.SessionID)
 }
 
 func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
@@ -316,10 +403,15 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "email and password required", http.StatusBadRequest)
 		return
 	}
+	ip := loginguard.ClientIP(r)
+	if !h.throttleLogin(w, r, req.Email, ip) {
+		return
+	}
 
 	user, err := h.users.GetByEmail(r.Context(), req.Email)
 	if err != nil {
 		if storage.IsNotFound(err) {
+			h.loginFailed(r.Context(), req.Email, ip, "", "unknown_email")
 			http.Error(w, "invalid credentials", http.StatusUnauthorized)
 			return
 		}
@@ -328,27 +420,29 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := verifyPassword(user.PasswordHash, req.Password); err != nil {
+		h.loginFailed(r.Context(), req.Email, ip, user.ID, "bad_password")
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
+	if h.guard != nil {
+		h.guard.RecordSuccess(r.Context(), req.Email)
+	}
 
-	token, err := issueJWT(user, h.signer)
+	memberships, err := h.memberships.ListForUser(r.Context(), user.ID)
 	if err != nil {
-		http.Error(w, "failed to issue token", http.StatusInternalServerError)
+		http.Error(w, "failed to lookup memberships", http.StatusInternalServerError)
 		return
 	}
-	refreshToken, err := h.issueRefreshToken(r.Context(), user.ID)
-	if err != nil {
-		http.Error(w, "failed to issue refresh token", http.StatusInternalServerError)
+	membership, ok := defaultMembership(memberships, user.BusinessID)
+	if !ok {
+		http.Error(w, "no active business membership", http.StatusForbidden)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(loginResponse{
-		AccessToken:  token,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-	})
+	if h.mfa != nil && h.mfa.interceptLogin(w, r, user, membership) {
+		return
+	}
+
+	h.writeSession(w, r, http.StatusOK, membership, memberships)
 }
 
 func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
@@ -378,45 +472,54 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to lookup refresh token", http.StatusInternalServerError)
 		return
 	}
-	if tokenRecord.RevokedAt != nil || tokenRecord.ExpiresAt.Before(time.Now()) {
+	if tokenRecord.RevokedAt != nil {
+		h.refreshTokenReused(r, tokenRecord)
+		http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if tokenRecord.ExpiresAt.Before(time.Now()) {
 		http.Error(w, "refresh token expired", http.StatusUnauthorized)
 		return
 	}
 
-	user, err := h.users.GetByID(r.Context(), tokenRecord.UserID)
+	// The membership is re-read so role changes and removals take effect on
+	// the next refresh.
+	membership, err := h.memberships.Get(r.Context(), tokenRecord.UserID, tokenRecord.BusinessID)
 	if err != nil {
 		if storage.IsNotFound(err) {
 			http.Error(w, "invalid refresh token", http.StatusUnauthorized)
 			return
 		}
-		http.Error(w, "failed to lookup user", http.StatusInternalServerError)
+		http.Error(w, "failed to lookup membership", http.StatusInternalServerError)
 		return
 	}
-
-	if err := h.refreshRepo.Revoke(r.Context(), tokenRecord.ID); err != nil {
-		http.Error(w, "failed to rotate refresh token", http.StatusInternalServerError)
-		return
+	// A policy switched on after this session started forces a fresh login,
+	// which walks the user through enrollment.
+	if h.mfa != nil {
+		missing, err := h.mfa.missingRequiredFactor(r.Context(), membership)
+		if err != nil {
+			http.Error(w, "failed to check mfa policy", http.StatusInternalServerError)
+			return
+		}
+		if missing {
+			http.Error(w, "multi-factor authentication required, sign in again", http.StatusUnauthorized)
+			return
+		}
 	}
 
-	newRefreshToken, err := h.issueRefreshToken(r.Context(), user.ID)
+	rotated, err := h.refreshRepo.Rotate(r.Context(), tokenRecord.ID)
 	if err != nil {
-		http.Error(w, "failed to issue refresh token", http.StatusInternalServerError)
+		http.Error(w, "failed to rotate refresh token", http.StatusInternalServerError)
 		return
 	}
-
-	newAccessToken, err := issueJWT(user, h.signer)
-	if err != nil {
-		http.Error(w, "failed to issue token", http.StatusInternalServerError)
+	if !rotated {
+		// Another request spent the same token first.
+		h.refreshTokenReused(r, tokenRecord)
+		http.Error(w, "invalid refresh token", http.StatusUnauthorized)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(loginResponse{
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
-		TokenType:    "Bearer",
-	})
+	h.continueSession(w, r, membership, tokenRecord.SessionID)
 }
 
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
@@ -448,7 +551,7 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if tokenRecord.RevokedAt == nil {
-		if err := h.refreshRepo.Revoke(r.Context(), tokenRecord.ID); err != nil {
+		if _, err := h.endSession(r.Context(), tokenRecord.UserID, tokenRecord.SessionID, "logout"); err != nil {
 			http.Error(w, "failed to revoke refresh token", http.StatusInternalServerError)
 			return
 		}
@@ -457,24 +560,141 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func issueJWT(user storage.User, signer TokenSigner) (string, error) {
-	now := time.Now().Unix()
-	return signer.Sign(auth.Claims{
-		Sub:        user.ID,
-		BusinessID: user.BusinessID,
-		Role:       user.Role,
-		Iat:        now,
-		Exp:        time.Now().Add(1 * time.Hour).Unix(),
+// writeSession starts a session for membership, issues its access and refresh
+// token and writes them with the user's businesses. businesses may be nil, in
+// which case they are loaded.
+func (h *AuthHandler) writeSession(w http.ResponseWriter, r *http.Request, status int, membership storage.Membership, businesses []storage.Membership) {
+	h.writeTokens(w, r, status, membership, businesses, "", nil)
+}
+
+// writeSessionWithRecoveryCodes is writeSession for the one response that
+// also hands out freshly generated MFA recovery codes.
+func (h *AuthHandler) writeSessionWithRecoveryCodes(w http.ResponseWriter, r *http.Request, status int, membership storage.Membership, businesses []storage.Membership, recoveryCodes []string) {
+	h.writeTokens(w, r, status, membership, businesses, "", recoveryCodes)
+}
+
+// continueSession issues the next token pair of an existing session, as on
+// refresh. An empty sessionID (tokens from before sessions existed) starts a
+// new one.
+func (h *AuthHandler) continueSession(w http.ResponseWriter, r *http.Request, membership storage.Membership, sessionID string) {
+	h.writeTokens(w, r, http.StatusOK, membership, nil, sessionID, nil)
+}
+
+func (h *AuthHandler) writeTokens(w http.ResponseWriter, r *http.Request, status int, membership storage.Membership, businesses []storage.Membership, sessionID string, recoveryCodes []string) {
+	client := sessions.Client{UserAgent: r.UserAgent(), IP: loginguard.ClientIP(r)}
+	if sessionID == "" {
+		var err error
+		sessionID, err = h.refreshRepo.Start(r.Context(), membership.UserID, membership.BusinessID, client)
+		if err != nil {
+			http.Error(w, "failed to start session", http.StatusInternalServerError)
+			return
+		}
+	}
+	token, claims, err := issueJWT(membership, sessionID, h.access, h.signer)
+	if err != nil {
+		http.Error(w, "failed to issue token", http.StatusInternalServerError)
+		return
+	}
+	access := sessions.AccessToken{JTI: claims.ID, ExpiresAt: time.Unix(claims.Exp, 0)}
+	refreshToken, err := h.issueRefreshToken(r.Context(), membership, sessionID, client, access)
+	if err != nil {
+		http.Error(w, "failed to issue refresh token", http.StatusInternalServerError)
+		return
+	}
+	if businesses == nil {
+		businesses, err = h.memberships.ListForUser(r.Context(), membership.UserID)
+		if err != nil {
+			http.Error(w, "failed to lookup memberships", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(loginResponse{
+		AccessToken:   token,
+		RefreshToken:  refreshToken,
+		TokenType:     "Bearer",
+		BusinessID:    membership.BusinessID,
+		Businesses:    businesses,
+		RecoveryCodes: recoveryCodes,
 	})
 }
 
-func (h *AuthHandler) issueRefreshToken(ctx context.Context, userID string) (string, error) {
+// bearerClaims verifies the access token in the Authorization header,
+// including that it has not been revoked since it was issued.
+func (h *AuthHandler) bearerClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") || len(strings.TrimSpace(authHeader)) <= len("Bearer ") {
+		http.Error(w, "missing or invalid Authorization header", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+	claims, err := h.signer.Verify(token)
+	if err == nil {
+		err = claims.CheckIssuerAudience(h.access.Issuer, h.access.Audience)
+	}
+	if err != nil {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return nil, false
+	}
+	if h.revocations != nil {
+		revoked, err := h.revocations.IsRevoked(r.Context(), claims.Sub, claims.ID, time.Unix(claims.Iat, 0))
+		if err != nil {
+			http.Error(w, "failed to check token", http.StatusInternalServerError)
+			return nil, false
+		}
+		if revoked {
+			http.Error(w, "token revoked", http.StatusUnauthorized)
+			return nil, false
+		}
+	}
+	return claims, true
+}
+
+// defaultMembership picks the membership a login lands in: the user's default
+// business if they still belong to it, otherwise their oldest membership.
+func defaultMembership(memberships []storage.Membership, defaultBusinessID string) (storage.Membership, bool) {
+	for _, m := range memberships {
+		if m.BusinessID == defaultBusinessID {
+			return m, true
+		}
+	}
+	if len(memberships) == 0 {
+		return storage.Membership{}, false
+	}
+	return memberships[0], true
+}
+
+func issueJWT(membership storage.Membership, sessionID string, cfg AccessTokenConfig, signer TokenSigner) (string, auth.Claims, error) {
+	now := time.Now()
+	claims := auth.Claims{
+		ID:         uuid.NewString(),
+		Issuer:     cfg.Issuer,
+		Audience:   cfg.Audience,
+		Sub:        membership.UserID,
+		BusinessID: membership.BusinessID,
+		Role:       membership.Role,
+		StaffID:    membership.StaffID,
+		SessionID:  sessionID,
+		Iat:        now.Unix(),
+		Exp:        now.Add(cfg.ttl()).Unix(),
+	}
+	token, err := signer.Sign(claims)
+	if err != nil {
+		return "", auth.Claims{}, err
+	}
+	return token, claims, nil
+}
+
+func (h *AuthHandler) issueRefreshToken(ctx context.Context, membership storage.Membership, sessionID string, client sessions.Client, access sessions.AccessToken) (string, error) {
 	raw, err := newRefreshToken()
 	if err != nil {
 		return "", err
 	}
 	expiresAt := time.Now().Add(h.refreshToken)
-	if _, err := h.refreshRepo.Create(ctx, userID, raw, expiresAt); err != nil {
+	if _, err := h.refreshRepo.Create(ctx, sessionID, membership.UserID, membership.BusinessID, raw, expiresAt, client, access); err != nil {
 		return "", err
 	}
 	return raw, nil
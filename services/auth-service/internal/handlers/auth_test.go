@@ -1,6 +1,87 @@
 package handlers
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/md-rashed-zaman/apptremind/libs/db/dbtest"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/audit"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/revocation"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/sessions"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/storage"
+)
+
+var testAccess = AccessTokenConfig{Issuer: "apptremind-auth", Audience: "apptremind-api"}
+
+// newTestAuthHandler wires an AuthHandler to a freshly migrated schema. Tests
+// calling it skip unless TEST_DATABASE_URL is set.
+func newTestAuthHandler(t *testing.T) *AuthHandler {
+	t.Helper()
+	pool := dbtest.Open(t, "../../migrations")
+	return NewAuthHandler(
+		NewHS256Signer("secret"),
+		pool,
+		storage.NewUserRepository(pool),
+		storage.NewMembershipRepository(pool),
+		audit.NewRepository(pool),
+		outbox.NewRepository(pool),
+		sessions.NewRefreshRepository(pool),
+		24*time.Hour,
+		testAccess,
+		revocation.NewRepository(pool),
+		nil,
+	)
+}
+
+// seedMember creates a user with password "pass1234" and a membership in
+// businessID, returning the user's id.
+func seedMember(t *testing.T, h *AuthHandler, email string, businessID string, role string) string {
+	t.Helper()
+	ctx := context.Background()
+	hash, err := hashPassword("pass1234")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	user := storage.User{ID: uuid.NewString(), BusinessID: businessID, Email: email, PasswordHash: hash}
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := h.users.CreateTx(ctx, tx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := h.memberships.AddTx(ctx, tx, storage.Membership{UserID: user.ID, BusinessID: businessID, Role: role}); err != nil {
+		t.Fatalf("add membership: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	return user.ID
+}
+
+// decodeSession reads a login response, failing the test unless status is 200.
+func decodeSession(t *testing.T, rw *httptest.ResponseRecorder) loginResponse {
+	t.Helper()
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rw.Code, rw.Body.String())
+	}
+	var resp loginResponse
+	if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode session: %v", err)
+	}
+	if resp.AccessToken == "" || resp.RefreshToken == "" {
+		t.Fatalf("missing tokens in %+v", resp)
+	}
+	return resp
+}
 
 func TestPasswordHashing(t *testing.T) {
 	password := "pass123"
@@ -18,3 +99,84 @@ func TestPasswordHashing(t *testing.T) {
 		t.Fatal("verifyPassword should fail for wrong password")
 	}
 }
+
+func TestDefaultMembership(t *testing.T) {
+	memberships := []storage.Membership{
+		{BusinessID: "b1", Role: "staff"},
+		{BusinessID: "b2", Role: "owner"},
+	}
+	if m, ok := defaultMembership(memberships, "b2"); !ok || m.BusinessID != "b2" {
+		t.Fatalf("expected default business b2, got %q, %v", m.BusinessID, ok)
+	}
+	if m, ok := defaultMembership(memberships, "gone"); !ok || m.BusinessID != "b1" {
+		t.Fatalf("expected oldest membership b1, got %q, %v", m.BusinessID, ok)
+	}
+	if _, ok := defaultMembership(nil, "b1"); ok {
+		t.Fatal("expected no membership")
+	}
+}
+
+// login posts email and password "pass1234" to Login.
+func login(h *AuthHandler, email string) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	h.Login(rw, httptest.NewRequest(http.MethodPost, "/api/v1/auth/login",
+		strings.NewReader(`{"email":"`+email+`","password":"pass1234"}`)))
+	return rw
+}
+
+// bearerRequest builds a request authenticated with an access token.
+func bearerRequest(method, target, token, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestSwitchBusinessMovesSessionAndDefault(t *testing.T) {
+	h := newTestAuthHandler(t)
+	userID := seedMember(t, h, "ana@example.com", testBusinessID, "owner")
+	ctx := context.Background()
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err := h.memberships.AddTx(ctx, tx, storage.Membership{UserID: userID, BusinessID: otherBusinessID, Role: "receptionist"}); err != nil {
+		t.Fatalf("add membership: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	session := decodeSession(t, login(h, "ana@example.com"))
+	if session.BusinessID != testBusinessID || len(session.Businesses) != 2 {
+		t.Fatalf("unexpected login session %+v", session)
+	}
+
+	switchTo := func(token, businessID string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		h.SwitchBusiness(rw, bearerRequest(http.MethodPost, "/api/v1/auth/switch-business", token, `{"business_id":"`+businessID+`"}`))
+		return rw
+	}
+	if rw := switchTo(session.AccessToken, uuid.NewString()); rw.Code != http.StatusForbidden {
+		t.Fatalf("switch to a foreign business: expected 403, got %d", rw.Code)
+	}
+	switched := decodeSession(t, switchTo(session.AccessToken, otherBusinessID))
+	if switched.BusinessID != otherBusinessID {
+		t.Fatalf("expected tokens for %s, got %s", otherBusinessID, switched.BusinessID)
+	}
+	before, err := h.signer.Verify(session.AccessToken)
+	if err != nil {
+		t.Fatalf("verify login token: %v", err)
+	}
+	after, err := h.signer.Verify(switched.AccessToken)
+	if err != nil {
+		t.Fatalf("verify switched token: %v", err)
+	}
+	if after.BusinessID != otherBusinessID || after.Role != "receptionist" || after.SessionID != before.SessionID {
+		t.Fatalf("switched claims %+v do not continue session %s", after, before.SessionID)
+	}
+
+	// The switched-to business becomes where the next login lands.
+	if relogin := decodeSession(t, login(h, "ana@example.com")); relogin.BusinessID != otherBusinessID {
+		t.Fatalf("expected next login in %s, got %s", otherBusinessID, relogin.BusinessID)
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/loginguard"
+)
+
+type unlockRequest struct {
+	Email string `json:"email,omitempty"`
+	IP    string `json:"ip,omitempty"`
+}
+
+// throttleLogin applies the login guard before any password is checked. It
+// refuses locked accounts and addresses with 429 and otherwise waits out the
+// progressive delay. It reports whether the login may proceed.
+func (h *AuthHandler) throttleLogin(w http.ResponseWriter, r *http.Request, email string, ip string) bool {
+	if h.guard == nil {
+		return true
+	}
+	decision := h.guard.Check(r.Context(), email, ip)
+	if !decision.LockedUntil.IsZero() {
+		retryAfter := int(time.Until(decision.LockedUntil).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		http.Error(w, "too many failed attempts, try again later", http.StatusTooManyRequests)
+		return false
+	}
+	if decision.Delay > 0 {
+		timer := time.NewTimer(decision.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return false
+		}
+	}
+	return true
+}
+
+// loginFailed counts a failed password attempt and audits it, plus the lock it
+// caused, if any. userID is empty when the email matches no account.
+func (h *AuthHandler) loginFailed(ctx context.Context, email string, ip string, userID string, reason string) {
+	h.recordFailure(ctx, "auth.login.failed", email, ip, userID, reason)
+}
+
+// recordFailure feeds any failed sign-in step into the login guard, so wrong
+// MFA codes count towards the same lockout as wrong passwords.
+func (h *AuthHandler) recordFailure(ctx context.Context, eventType string, email string, ip string, userID string, reason string) {
+	var failure loginguard.Failure
+	if h.guard != nil {
+		failure = h.guard.RecordFailure(ctx, email, ip)
+	}
+	if h.audit == nil {
+		return
+	}
+	_ = h.audit.RecordWithOutbox(ctx, h.outbox, eventType, userID, map[string]any{
+		"email":            email,
+		"ip":               ip,
+		"reason":           reason,
+		"account_failures": failure.AccountFailures,
+		"ip_failures":      failure.IPFailures,
+	})
+	if !failure.AccountLocked && !failure.IPLocked {
+		return
+	}
+	metadata := map[string]any{
+		"locked_until": failure.LockedUntil.UTC().Format(time.RFC3339),
+	}
+	if failure.AccountLocked {
+		metadata["email"] = email
+	}
+	if failure.IPLocked {
+		metadata["ip"] = ip
+	}
+	_ = h.audit.RecordWithOutbox(ctx, h.outbox, "auth.account.locked", userID, metadata)
+}
+
+// UnlockLogin is the admin override for a lockout: it clears the failures and
+// lock of an account (by email), an address, or both.
+func (h *AuthHandler) UnlockLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	actorID := strings.TrimSpace(r.Header.Get("X-User-Id"))
+	if r.Header.Get("X-Role") != "admin" || actorID == "" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	var req unlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	req.IP = strings.TrimSpace(req.IP)
+	if req.Email == "" && req.IP == "" {
+		http.Error(w, "email or ip required", http.StatusBadRequest)
+		return
+	}
+	if req.IP != "" && net.ParseIP(req.IP) == nil {
+		http.Error(w, "ip must be an IP address", http.StatusBadRequest)
+		return
+	}
+	if h.guard == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := h.guard.Unlock(r.Context(), req.Email, req.IP); err != nil {
+		http.Error(w, "failed to unlock", http.StatusInternalServerError)
+		return
+	}
+	if h.audit != nil {
+		_ = h.audit.RecordWithOutbox(r.Context(), h.outbox, "auth.account.unlocked", actorID, map[string]any{
+			"email": req.Email,
+			"ip":    req.IP,
+		})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/loginguard"
+)
+
+func TestLockoutAfterFailuresUntilAdminUnlock(t *testing.T) {
+	h := newTestAuthHandler(t)
+	h.guard = loginguard.New(loginguard.NewPostgresStore(h.pool), loginguard.Config{
+		DelayAfter:       100,
+		AccountLockAfter: 3,
+	}, slog.Default())
+	seedMember(t, h, "ana@example.com", testBusinessID, "owner")
+
+	for i := 0; i < 3; i++ {
+		rw := httptest.NewRecorder()
+		h.Login(rw, httptest.NewRequest(http.MethodPost, "/api/v1/auth/login",
+			strings.NewReader(`{"email":"ana@example.com","password":"wrong-guess"}`)))
+		if rw.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i+1, rw.Code)
+		}
+	}
+	// Once locked, even the right password is refused without being checked.
+	rw := login(h, "ana@example.com")
+	if rw.Code != http.StatusTooManyRequests || rw.Header().Get("Retry-After") == "" {
+		t.Fatalf("locked: expected 429 with Retry-After, got %d %v", rw.Code, rw.Header())
+	}
+
+	unlock := func(role, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/admin/unlock", strings.NewReader(body))
+		req.Header.Set("X-User-Id", testOwnerID)
+		req.Header.Set("X-Role", role)
+		rw := httptest.NewRecorder()
+		h.UnlockLogin(rw, req)
+		return rw.Code
+	}
+	if code := unlock("owner", `{"email":"ana@example.com"}`); code != http.StatusForbidden {
+		t.Fatalf("owner unlock: expected 403, got %d", code)
+	}
+	if code := unlock("admin", `{"ip":"not-an-ip"}`); code != http.StatusBadRequest {
+		t.Fatalf("bad ip: expected 400, got %d", code)
+	}
+	if code := unlock("admin", `{"email":"ana@example.com"}`); code != http.StatusNoContent {
+		t.Fatalf("admin unlock: expected 204, got %d", code)
+	}
+	decodeSession(t, login(h, "ana@example.com"))
+}
@@ -0,0 +1,588 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/loginguard"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/mfa"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/storage"
+)
+
+const recoveryCodeCount = 10
+
+var errMFANotEnabled = errors.New("mfa not enabled")
+
+type MFAConfig struct {
+	// Issuer is the account name prefix authenticator apps display.
+	Issuer string
+	// ChallengeSecret signs the mfa_token handed out between the password
+	// and code steps.
+	ChallengeSecret string
+	ChallengeTTL    time.Duration
+}
+
+// MFAHandler runs TOTP enrollment, the second login step and the
+// per-business policy that makes MFA mandatory for owners.
+type MFAHandler struct {
+	auth   *AuthHandler
+	repo   *mfa.Repository
+	sealer *mfa.Sealer
+	cfg    MFAConfig
+}
+
+// NewMFAHandler also plugs the second step into authHandler's login.
+func NewMFAHandler(authHandler *AuthHandler, repo *mfa.Repository, sealer *mfa.Sealer, cfg MFAConfig) *MFAHandler {
+	if cfg.Issuer == "" {
+		cfg.Issuer = "ApptRemind"
+	}
+	if cfg.ChallengeTTL <= 0 {
+		cfg.ChallengeTTL = 5 * time.Minute
+	}
+	h := &MFAHandler{auth: authHandler, repo: repo, sealer: sealer, cfg: cfg}
+	authHandler.mfa = h
+	return h
+}
+
+type mfaChallengeResponse struct {
+	MFARequired        bool      `json:"mfa_required,omitempty"`
+	EnrollmentRequired bool      `json:"enrollment_required,omitempty"`
+	MFAToken           string    `json:"mfa_token"`
+	ExpiresAt          time.Time `json:"expires_at"`
+}
+
+type mfaVerifyRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type mfaEnrollRequest struct {
+	MFAToken string `json:"mfa_token,omitempty"`
+}
+
+type mfaEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type mfaConfirmRequest struct {
+	MFAToken string `json:"mfa_token,omitempty"`
+	Code     string `json:"code"`
+}
+
+type mfaDisableRequest struct {
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type mfaPolicyRequest struct {
+	BusinessID       string `json:"business_id,omitempty"`
+	RequireForOwners *bool  `json:"require_for_owners"`
+}
+
+// interceptLogin runs after the password check. Enrolled users, and owners of
+// a business that requires MFA but who have not enrolled yet, get a challenge
+// instead of tokens. It reports whether it wrote the response.
+func (h *MFAHandler) interceptLogin(w http.ResponseWriter, r *http.Request, user storage.User, membership storage.Membership) bool {
+	ctx := r.Context()
+	factor, err := h.repo.GetFactor(ctx, user.ID)
+	if err != nil && !mfa.IsNotFound(err) {
+		http.Error(w, "failed to lookup mfa factor", http.StatusInternalServerError)
+		return true
+	}
+	if err == nil && factor.ConfirmedAt != nil {
+		h.writeChallenge(w, membership, mfa.PurposeVerify)
+		return true
+	}
+	required, err := h.policyRequires(ctx, membership)
+	if err != nil {
+		http.Error(w, "failed to check mfa policy", http.StatusInternalServerError)
+		return true
+	}
+	if required {
+		h.writeChallenge(w, membership, mfa.PurposeEnroll)
+		return true
+	}
+	return false
+}
+
+// missingRequiredFactor reports whether membership's business requires MFA
+// for its role and the user has no confirmed factor.
+func (h *MFAHandler) missingRequiredFactor(ctx context.Context, membership storage.Membership) (bool, error) {
+	required, err := h.policyRequires(ctx, membership)
+	if err != nil || !required {
+		return false, err
+	}
+	enrolled, err := h.enrolled(ctx, membership.UserID)
+	if err != nil {
+		return false, err
+	}
+	return !enrolled, nil
+}
+
+// Verify is the second login step: it exchanges an mfa_token and a TOTP or
+// recovery code for the access and refresh tokens.
+func (h *MFAHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req mfaVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	req.RecoveryCode = strings.TrimSpace(req.RecoveryCode)
+	if req.Code == "" && req.RecoveryCode == "" {
+		http.Error(w, "code or recovery_code required", http.StatusBadRequest)
+		return
+	}
+	challenge, err := mfa.VerifyChallenge(h.cfg.ChallengeSecret, req.MFAToken, mfa.PurposeVerify, time.Now())
+	if err != nil {
+		http.Error(w, "invalid or expired mfa_token", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := h.auth.users.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			http.Error(w, "invalid or expired mfa_token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "failed to lookup user", http.StatusInternalServerError)
+		return
+	}
+	ip := loginguard.ClientIP(r)
+	if !h.auth.throttleLogin(w, r, user.Email, ip) {
+		return
+	}
+
+	method, ok, err := h.checkCode(ctx, user.ID, req.Code, req.RecoveryCode)
+	if err != nil {
+		if errors.Is(err, errMFANotEnabled) {
+			http.Error(w, "invalid or expired mfa_token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "failed to check code", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.auth.recordFailure(ctx, "auth.mfa.failed", user.Email, ip, user.ID, "bad_"+method)
+		http.Error(w, "invalid code", http.StatusUnauthorized)
+		return
+	}
+	if h.auth.guard != nil {
+		h.auth.guard.RecordSuccess(ctx, user.Email)
+	}
+
+	membership, err := h.auth.memberships.Get(ctx, user.ID, challenge.BusinessID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			http.Error(w, "no active business membership", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "failed to lookup membership", http.StatusInternalServerError)
+		return
+	}
+	if h.auth.audit != nil {
+		_ = h.auth.audit.RecordWithOutbox(ctx, h.auth.outbox, "auth.mfa.verified", user.ID, map[string]any{
+			"business_id": membership.BusinessID,
+			"method":      method,
+		})
+	}
+	h.auth.writeSession(w, r, http.StatusOK, membership, nil)
+}
+
+// Enroll starts (or restarts) enrollment and returns the secret to load into
+// an authenticator app. The caller is either signed in or holds an enrollment
+// mfa_token from a login their business's policy stopped.
+func (h *MFAHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req mfaEnrollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	userID, _, ok := h.enrollingUser(w, r, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	user, err := h.auth.users.GetByID(ctx, userID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "failed to lookup user", http.StatusInternalServerError)
+		return
+	}
+	secret, err := mfa.GenerateSecret()
+	if err != nil {
+		http.Error(w, "failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+	sealed, err := h.sealer.Seal(secret)
+	if err != nil {
+		http.Error(w, "failed to seal secret", http.StatusInternalServerError)
+		return
+	}
+	started, err := h.repo.StartEnrollment(ctx, user.ID, sealed)
+	if err != nil {
+		http.Error(w, "failed to start enrollment", http.StatusInternalServerError)
+		return
+	}
+	if !started {
+		http.Error(w, "mfa already enabled", http.StatusConflict)
+		return
+	}
+	if h.auth.audit != nil {
+		_ = h.auth.audit.RecordWithOutbox(ctx, h.auth.outbox, "auth.mfa.enrollment_started", user.ID, map[string]any{})
+	}
+	writeJSON(w, http.StatusOK, mfaEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: mfa.URI(h.cfg.Issuer, user.Email, secret),
+	})
+}
+
+// ConfirmEnrollment turns the pending secret on once the user proves their
+// app produces matching codes, and returns the recovery codes. Enrollment
+// that started from a login challenge finishes that login too.
+func (h *MFAHandler) ConfirmEnrollment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req mfaConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	if req.Code == "" {
+		http.Error(w, "code required", http.StatusBadRequest)
+		return
+	}
+	userID, businessID, ok := h.enrollingUser(w, r, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	factor, err := h.repo.GetFactor(ctx, userID)
+	if err != nil {
+		if mfa.IsNotFound(err) {
+			http.Error(w, "no enrollment in progress", http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to lookup mfa factor", http.StatusInternalServerError)
+		return
+	}
+	if factor.ConfirmedAt != nil {
+		http.Error(w, "mfa already enabled", http.StatusConflict)
+		return
+	}
+	secret, err := h.sealer.Open(factor.SealedSecret)
+	if err != nil {
+		http.Error(w, "failed to open secret", http.StatusInternalServerError)
+		return
+	}
+	step, valid := mfa.Validate(secret, req.Code, time.Now())
+	if !valid {
+		if h.auth.audit != nil {
+			_ = h.auth.audit.RecordWithOutbox(ctx, h.auth.outbox, "auth.mfa.failed", userID, map[string]any{
+				"reason": "bad_enrollment_code",
+			})
+		}
+		http.Error(w, "invalid code", http.StatusUnauthorized)
+		return
+	}
+	codes, err := mfa.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		http.Error(w, "failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = mfa.HashRecoveryCode(code)
+	}
+
+	tx, err := h.auth.pool.Begin(ctx)
+	if err != nil {
+		http.Error(w, "failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := h.repo.ConfirmTx(ctx, tx, userID, step, hashes); err != nil {
+		http.Error(w, "failed to confirm enrollment", http.StatusInternalServerError)
+		return
+	}
+	if err := h.auth.audit.RecordTx(ctx, tx, "auth.mfa.enrolled", userID, map[string]any{
+		"recovery_codes": len(codes),
+	}); err != nil {
+		http.Error(w, "failed to record audit event", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	if businessID == "" {
+		writeJSON(w, http.StatusOK, map[string]any{"recovery_codes": codes})
+		return
+	}
+	membership, err := h.auth.memberships.Get(ctx, userID, businessID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			http.Error(w, "no active business membership", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "failed to lookup membership", http.StatusInternalServerError)
+		return
+	}
+	// The challenge only stands in for a password when the policy forced
+	// enrollment at login; otherwise the user signs in again with the new
+	// factor.
+	required, err := h.policyRequires(ctx, membership)
+	if err != nil {
+		http.Error(w, "failed to check mfa policy", http.StatusInternalServerError)
+		return
+	}
+	if !required {
+		writeJSON(w, http.StatusOK, map[string]any{"recovery_codes": codes})
+		return
+	}
+	h.auth.writeSessionWithRecoveryCodes(w, r, http.StatusOK, membership, nil, codes)
+}
+
+// Disable removes the signed-in user's factor and recovery codes. It needs a
+// current code, and is refused while any business the user owns requires MFA.
+func (h *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := h.auth.bearerClaims(w, r)
+	if !ok {
+		return
+	}
+	var req mfaDisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	req.RecoveryCode = strings.TrimSpace(req.RecoveryCode)
+	if req.Code == "" && req.RecoveryCode == "" {
+		http.Error(w, "code or recovery_code required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	memberships, err := h.auth.memberships.ListForUser(ctx, claims.Sub)
+	if err != nil {
+		http.Error(w, "failed to lookup memberships", http.StatusInternalServerError)
+		return
+	}
+	for _, m := range memberships {
+		required, err := h.policyRequires(ctx, m)
+		if err != nil {
+			http.Error(w, "failed to check mfa policy", http.StatusInternalServerError)
+			return
+		}
+		if required {
+			http.Error(w, "a business you own requires multi-factor authentication", http.StatusConflict)
+			return
+		}
+	}
+
+	method, valid, err := h.checkCode(ctx, claims.Sub, req.Code, req.RecoveryCode)
+	if err != nil {
+		if errors.Is(err, errMFANotEnabled) {
+			http.Error(w, "mfa not enabled", http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to check code", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		if h.auth.audit != nil {
+			_ = h.auth.audit.RecordWithOutbox(ctx, h.auth.outbox, "auth.mfa.failed", claims.Sub, map[string]any{
+				"reason": "bad_" + method,
+			})
+		}
+		http.Error(w, "invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	tx, err := h.auth.pool.Begin(ctx)
+	if err != nil {
+		http.Error(w, "failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := h.repo.DeleteTx(ctx, tx, claims.Sub); err != nil {
+		http.Error(w, "failed to disable mfa", http.StatusInternalServerError)
+		return
+	}
+	if err := h.auth.audit.RecordTx(ctx, tx, "auth.mfa.disabled", claims.Sub, map[string]any{
+		"method": method,
+	}); err != nil {
+		http.Error(w, "failed to record audit event", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Policy reads (GET) or sets (PUT) whether a business requires MFA for its
+// owners. Owners manage their own business; admins pass business_id.
+func (h *MFAHandler) Policy(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		_, businessID, ok := teamScope(w, r, r.URL.Query().Get("business_id"))
+		if !ok {
+			return
+		}
+		policy, err := h.repo.GetPolicy(r.Context(), businessID)
+		if err != nil {
+			http.Error(w, "failed to load mfa policy", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, policy)
+	case http.MethodPut:
+		var req mfaPolicyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+			return
+		}
+		actorID, businessID, ok := teamScope(w, r, req.BusinessID)
+		if !ok {
+			return
+		}
+		if req.RequireForOwners == nil {
+			http.Error(w, "require_for_owners required", http.StatusBadRequest)
+			return
+		}
+		policy, err := h.repo.SetPolicy(r.Context(), businessID, *req.RequireForOwners, actorID)
+		if err != nil {
+			http.Error(w, "failed to save mfa policy", http.StatusInternalServerError)
+			return
+		}
+		if h.auth.audit != nil {
+			_ = h.auth.audit.RecordWithOutbox(r.Context(), h.auth.outbox, "auth.mfa.policy_updated", actorID, map[string]any{
+				"business_id":        businessID,
+				"require_for_owners": policy.RequireForOwners,
+			})
+		}
+		writeJSON(w, http.StatusOK, policy)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *MFAHandler) writeChallenge(w http.ResponseWriter, membership storage.Membership, purpose string) {
+	expiresAt := time.Now().Add(h.cfg.ChallengeTTL).UTC().Truncate(time.Second)
+	token, err := mfa.SignChallenge(h.cfg.ChallengeSecret, mfa.Challenge{
+		UserID:     membership.UserID,
+		BusinessID: membership.BusinessID,
+		Purpose:    purpose,
+		Exp:        expiresAt.Unix(),
+	})
+	if err != nil {
+		http.Error(w, "failed to issue mfa challenge", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, mfaChallengeResponse{
+		MFARequired:        purpose == mfa.PurposeVerify,
+		EnrollmentRequired: purpose == mfa.PurposeEnroll,
+		MFAToken:           token,
+		ExpiresAt:          expiresAt,
+	})
+}
+
+// enrollingUser identifies who is enrolling: the holder of an enrollment
+// mfa_token (whose login then completes in businessID), or else the bearer of
+// an access token.
+func (h *MFAHandler) enrollingUser(w http.ResponseWriter, r *http.Request, mfaToken string) (string, string, bool) {
+	if strings.TrimSpace(mfaToken) != "" {
+		challenge, err := mfa.VerifyChallenge(h.cfg.ChallengeSecret, mfaToken, mfa.PurposeEnroll, time.Now())
+		if err != nil {
+			http.Error(w, "invalid or expired mfa_token", http.StatusUnauthorized)
+			return "", "", false
+		}
+		return challenge.UserID, challenge.BusinessID, true
+	}
+	claims, ok := h.auth.bearerClaims(w, r)
+	if !ok {
+		return "", "", false
+	}
+	return claims.Sub, "", true
+}
+
+// checkCode validates a TOTP code, or else a recovery code, against the user's
+// confirmed factor; either works only once. method names which kind was tried.
+func (h *MFAHandler) checkCode(ctx context.Context, userID string, code string, recoveryCode string) (method string, ok bool, err error) {
+	method = "code"
+	if code == "" {
+		method = "recovery_code"
+	}
+	factor, err := h.repo.GetFactor(ctx, userID)
+	if mfa.IsNotFound(err) || (err == nil && factor.ConfirmedAt == nil) {
+		return method, false, errMFANotEnabled
+	}
+	if err != nil {
+		return method, false, err
+	}
+	if code == "" {
+		ok, err = h.repo.UseRecoveryCode(ctx, userID, mfa.HashRecoveryCode(recoveryCode))
+		return method, ok, err
+	}
+	secret, err := h.sealer.Open(factor.SealedSecret)
+	if err != nil {
+		return method, false, err
+	}
+	step, valid := mfa.Validate(secret, code, time.Now())
+	if !valid {
+		return method, false, nil
+	}
+	ok, err = h.repo.AcceptStep(ctx, userID, step)
+	return method, ok, err
+}
+
+func (h *MFAHandler) policyRequires(ctx context.Context, membership storage.Membership) (bool, error) {
+	if membership.Role != "owner" {
+		return false, nil
+	}
+	policy, err := h.repo.GetPolicy(ctx, membership.BusinessID)
+	if err != nil {
+		return false, err
+	}
+	return policy.RequireForOwners, nil
+}
+
+func (h *MFAHandler) enrolled(ctx context.Context, userID string) (bool, error) {
+	factor, err := h.repo.GetFactor(ctx, userID)
+	if err != nil {
+		if mfa.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return factor.ConfirmedAt != nil, nil
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/mfa"
+)
+
+func TestMFAPolicyEnrollmentAndVerify(t *testing.T) {
+	authHandler := newTestAuthHandler(t)
+	sealer, err := mfa.NewSealer("test-mfa-key")
+	if err != nil {
+		t.Fatalf("NewSealer: %v", err)
+	}
+	h := NewMFAHandler(authHandler, mfa.NewRepository(authHandler.pool), sealer, MFAConfig{ChallengeSecret: "secret"})
+	ownerID := seedMember(t, authHandler, "ana@example.com", testBusinessID, "owner")
+
+	req := teamRequest(http.MethodPut, "/api/v1/auth/mfa/policy", `{"require_for_owners":true}`, "owner")
+	req.Header.Set("X-User-Id", ownerID)
+	rw := httptest.NewRecorder()
+	h.Policy(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("policy: expected 200, got %d: %s", rw.Code, rw.Body.String())
+	}
+
+	challenge := func(rw *httptest.ResponseRecorder) mfaChallengeResponse {
+		t.Helper()
+		var out mfaChallengeResponse
+		if err := json.NewDecoder(rw.Body).Decode(&out); err != nil || out.MFAToken == "" {
+			t.Fatalf("expected an mfa challenge, got %d: %v", rw.Code, err)
+		}
+		return out
+	}
+	post := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		handler(rw, httptest.NewRequest(http.MethodPost, "/api/v1/auth/mfa", strings.NewReader(body)))
+		return rw
+	}
+
+	// The policy turns the owner's password login into an enrollment.
+	enroll := challenge(login(authHandler, "ana@example.com"))
+	if !enroll.EnrollmentRequired {
+		t.Fatalf("expected enrollment_required, got %+v", enroll)
+	}
+	if rw := post(h.Verify, `{"mfa_token":"`+enroll.MFAToken+`","code":"123456"}`); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("verify with enrollment token: expected 401, got %d", rw.Code)
+	}
+	rw = post(h.Enroll, `{"mfa_token":"`+enroll.MFAToken+`"}`)
+	var started mfaEnrollResponse
+	if err := json.NewDecoder(rw.Body).Decode(&started); err != nil || started.Secret == "" {
+		t.Fatalf("enroll: got %d: %v", rw.Code, err)
+	}
+	code, err := mfa.Code(started.Secret, time.Now())
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	session := decodeSession(t, post(h.ConfirmEnrollment, `{"mfa_token":"`+enroll.MFAToken+`","code":"`+code+`"}`))
+	if session.BusinessID != testBusinessID || len(session.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("confirm should finish the login with recovery codes: %+v", session)
+	}
+
+	// Enrolled, the next login asks for a code; the one spent on enrollment
+	// cannot be replayed, a recovery code works exactly once.
+	verify := challenge(login(authHandler, "ana@example.com"))
+	if !verify.MFARequired {
+		t.Fatalf("expected mfa_required, got %+v", verify)
+	}
+	if rw := post(h.Verify, `{"mfa_token":"`+verify.MFAToken+`","code":"`+code+`"}`); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed code: expected 401, got %d", rw.Code)
+	}
+	recovery := `{"mfa_token":"` + verify.MFAToken + `","recovery_code":"` + session.RecoveryCodes[0] + `"}`
+	if verified := decodeSession(t, post(h.Verify, recovery)); verified.BusinessID != testBusinessID {
+		t.Fatalf("unexpected verified session %+v", verified)
+	}
+	if rw := post(h.Verify, recovery); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("reused recovery code: expected 401, got %d", rw.Code)
+	}
+}
+
+func TestConfirmEnrollmentWithoutPolicyIssuesNoSession(t *testing.T) {
+	authHandler := newTestAuthHandler(t)
+	sealer, err := mfa.NewSealer("test-mfa-key")
+	if err != nil {
+		t.Fatalf("NewSealer: %v", err)
+	}
+	h := NewMFAHandler(authHandler, mfa.NewRepository(authHandler.pool), sealer, MFAConfig{ChallengeSecret: "secret"})
+	userID := seedMember(t, authHandler, "rita@example.com", testBusinessID, "receptionist")
+
+	// No policy asked this user to enroll, so an enrollment challenge must
+	// not stand in for their password.
+	token, err := mfa.SignChallenge("secret", mfa.Challenge{
+		UserID:     userID,
+		BusinessID: testBusinessID,
+		Purpose:    mfa.PurposeEnroll,
+		Exp:        time.Now().Add(time.Minute).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	rw := httptest.NewRecorder()
+	h.Enroll(rw, httptest.NewRequest(http.MethodPost, "/api/v1/auth/mfa/enroll", strings.NewReader(`{"mfa_token":"`+token+`"}`)))
+	var started mfaEnrollResponse
+	if err := json.NewDecoder(rw.Body).Decode(&started); err != nil || started.Secret == "" {
+		t.Fatalf("enroll: got %d: %v", rw.Code, err)
+	}
+	code, err := mfa.Code(started.Secret, time.Now())
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	rw = httptest.NewRecorder()
+	h.ConfirmEnrollment(rw, httptest.NewRequest(http.MethodPost, "/api/v1/auth/mfa/enroll/confirm",
+		strings.NewReader(`{"mfa_token":"`+token+`","code":"`+code+`"}`)))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("confirm: expected 200, got %d: %s", rw.Code, rw.Body.String())
+	}
+	var out loginResponse
+	if err := json.NewDecoder(rw.Body).Decode(&out); err != nil {
+		t.Fatalf("decode confirm: %v", err)
+	}
+	if out.AccessToken != "" || out.RefreshToken != "" || len(out.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("expected recovery codes only, got %+v", out)
+	}
+}
+
+func TestNewMFAHandlerHooksLogin(t *testing.T) {
+	authHandler := &AuthHandler{}
+	h := NewMFAHandler(authHandler, nil, nil, MFAConfig{})
+	if authHandler.mfa != h {
+		t.Fatal("login should be wired to the mfa handler")
+	}
+	if h.cfg.ChallengeTTL != 5*time.Minute || h.cfg.Issuer == "" {
+		t.Fatalf("defaults not applied: %+v", h.cfg)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/sessions"
+)
+
+// accessTokenRevokedEvent tells the gateway to stop accepting access tokens
+// before they expire: either the one named by JTI, or every token of the user
+// issued before IssuedBefore. ExpiresAt is when the entry can be forgotten.
+type accessTokenRevokedEvent struct {
+	UserID       string `json:"user_id"`
+	JTI          string `json:"jti,omitempty"`
+	IssuedBefore string `json:"issued_before,omitempty"`
+	ExpiresAt    string `json:"expires_at"`
+	Reason       string `json:"reason"`
+	RevokedAt    string `json:"revoked_at"`
+}
+
+// revokeUserTokensTx invalidates every access token userID currently holds,
+// in all of their businesses.
+func (h *AuthHandler) revokeUserTokensTx(ctx context.Context, tx pgx.Tx, userID string, reason string) error {
+	if h.revocations == nil {
+		return nil
+	}
+	before, err := h.revocations.RevokeUserTx(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	return h.publishRevocation(ctx, tx, accessTokenRevokedEvent{
+		UserID:       userID,
+		IssuedBefore: before.UTC().Format(time.RFC3339),
+		// No token issued before the watermark outlives it by more than a TTL.
+		ExpiresAt: before.Add(h.access.ttl()).UTC().Format(time.RFC3339),
+		Reason:    reason,
+	})
+}
+
+// revokeAccessTokensTx denylists the given access tokens of userID.
+func (h *AuthHandler) revokeAccessTokensTx(ctx context.Context, tx pgx.Tx, userID string, tokens []sessions.AccessToken, reason string) error {
+	if h.revocations == nil {
+		return nil
+	}
+	for _, token := range tokens {
+		if err := h.revocations.RevokeTokenTx(ctx, tx, userID, token.JTI, token.ExpiresAt, reason); err != nil {
+			return err
+		}
+		if err := h.publishRevocation(ctx, tx, accessTokenRevokedEvent{
+			UserID:    userID,
+			JTI:       token.JTI,
+			ExpiresAt: token.ExpiresAt.UTC().Format(time.RFC3339),
+			Reason:    reason,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endSession revokes one of userID's sessions together with the access tokens
+// it handed out. It reports false when there was no live session to end.
+func (h *AuthHandler) endSession(ctx context.Context, userID string, sessionID string, reason string) (bool, error) {
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	ended, access, err := h.refreshRepo.RevokeSession(ctx, tx, userID, sessionID, reason)
+	if err != nil || !ended {
+		return false, err
+	}
+	if err := h.revokeAccessTokensTx(ctx, tx, userID, access, reason); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (h *AuthHandler) publishRevocation(ctx context.Context, tx pgx.Tx, event accessTokenRevokedEvent) error {
+	event.RevokedAt = time.Now().UTC().Format(time.RFC3339)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return h.outbox.Insert(ctx, tx, outbox.Event{
+		AggregateType: "user",
+		AggregateID:   event.UserID,
+		EventType:     "auth.access_token.revoked.v1",
+		Payload:       payload,
+	})
+}
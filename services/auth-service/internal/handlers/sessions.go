@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/loginguard"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/sessions"
+)
+
+// Sessions lists the caller's active sessions (GET) or signs them out
+// everywhere (DELETE), including the session making the call.
+func (h *AuthHandler) Sessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := h.bearerClaims(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+
+	if r.Method == http.MethodGet {
+		items, err := h.refreshRepo.ListActive(ctx, claims.Sub)
+		if err != nil {
+			http.Error(w, "failed to list sessions", http.StatusInternalServerError)
+			return
+		}
+		if items == nil {
+			items = []sessions.Session{}
+		}
+		for i := range items {
+			items[i].Current = items[i].ID == claims.SessionID
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+		return
+	}
+
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		http.Error(w, "failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	revoked, err := h.refreshRepo.RevokeAllForUser(ctx, tx, claims.Sub, "sign_out_everywhere")
+	if err != nil {
+		http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	if err := h.revokeUserTokensTx(ctx, tx, claims.Sub, "sign_out_everywhere"); err != nil {
+		http.Error(w, "failed to revoke access tokens", http.StatusInternalServerError)
+		return
+	}
+	if err := h.audit.RecordTx(ctx, tx, "auth.sessions.revoked_all", claims.Sub, map[string]any{
+		"revoked_refresh_tokens": revoked,
+	}); err != nil {
+		http.Error(w, "failed to record audit event", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeSession signs out one of the caller's sessions, e.g. a lost device,
+// revoking its refresh and access tokens.
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := h.bearerClaims(w, r)
+	if !ok {
+		return
+	}
+	sessionID := strings.TrimSpace(r.PathValue("id"))
+	if _, err := uuid.Parse(sessionID); err != nil {
+		http.Error(w, "session id must be a uuid", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := h.endSession(r.Context(), claims.Sub, sessionID, "user_revoked")
+	if err != nil {
+		http.Error(w, "failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if h.audit != nil {
+		_ = h.audit.Record(r.Context(), "auth.session.revoked", claims.Sub, map[string]any{
+			"session_id": sessionID,
+			"current":    sessionID == claims.SessionID,
+		})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// refreshTokenReused handles a refresh token presented after it was rotated
+// or revoked. Either the client or a thief holds a stale copy, and there is no
+// telling which, so the whole session is ended.
+func (h *AuthHandler) refreshTokenReused(r *http.Request, token sessions.RefreshToken) {
+	ctx := r.Context()
+	ended := false
+	if token.SessionID != "" {
+		ended, _ = h.endSession(ctx, token.UserID, token.SessionID, "refresh_token_reuse")
+	}
+	if h.audit == nil {
+		return
+	}
+	_ = h.audit.RecordWithOutbox(ctx, h.outbox, "auth.refresh_token.reused", token.UserID, map[string]any{
+		"session_id":    token.SessionID,
+		"business_id":   token.BusinessID,
+		"session_ended": ended,
+		"ip":            loginguard.ClientIP(r),
+		"user_agent":    r.UserAgent(),
+	})
+}
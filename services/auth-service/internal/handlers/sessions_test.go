@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/sessions"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/storage"
+)
+
+func TestIssueJWTCarriesSession(t *testing.T) {
+	signer := NewHS256Signer("secret")
+	token, _, err := issueJWT(storage.Membership{UserID: testOwnerID, BusinessID: testBusinessID, Role: "owner"}, "55555555-5555-5555-5555-555555555555", AccessTokenConfig{}, signer)
+	if err != nil {
+		t.Fatalf("issueJWT: %v", err)
+	}
+	claims, err := signer.Verify(token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.SessionID != "55555555-5555-5555-5555-555555555555" {
+		t.Fatalf("sid = %q", claims.SessionID)
+	}
+}
+
+func TestBearerClaimsChecksIssuerAndAudience(t *testing.T) {
+	signer := NewHS256Signer("secret")
+	cfg := AccessTokenConfig{Issuer: "apptremind-auth", Audience: "apptremind-api"}
+	h := &AuthHandler{signer: signer, access: cfg}
+	membership := storage.Membership{UserID: testOwnerID, BusinessID: testBusinessID, Role: "owner"}
+
+	token, issued, err := issueJWT(membership, "", cfg, signer)
+	if err != nil {
+		t.Fatalf("issueJWT: %v", err)
+	}
+	if issued.ID == "" || issued.Exp-issued.Iat != 3600 {
+		t.Fatalf("expected a jti and a one hour lifetime, got %+v", issued)
+	}
+	foreign, _, err := issueJWT(membership, "", AccessTokenConfig{Issuer: "apptremind-auth", Audience: "billing-api"}, signer)
+	if err != nil {
+		t.Fatalf("issueJWT: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		bearer string
+		want   bool
+	}{
+		{"matching", token, true},
+		{"other audience", foreign, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/me", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.bearer)
+			rw := httptest.NewRecorder()
+			claims, ok := h.bearerClaims(rw, req)
+			if ok != tc.want {
+				t.Fatalf("expected ok=%v, got %v: %s", tc.want, ok, rw.Body.String())
+			}
+			if ok && claims.ID != issued.ID {
+				t.Fatalf("jti = %q, want %q", claims.ID, issued.ID)
+			}
+		})
+	}
+}
+
+func refresh(h *AuthHandler, refreshToken string) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	h.Refresh(rw, httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", strings.NewReader(`{"refresh_token":"`+refreshToken+`"}`)))
+	return rw
+}
+
+func listSessions(t *testing.T, h *AuthHandler, accessToken string) []sessions.Session {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	h.Sessions(rw, bearerRequest(http.MethodGet, "/api/v1/auth/sessions", accessToken, ""))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("list sessions: expected 200, got %d: %s", rw.Code, rw.Body.String())
+	}
+	var out struct {
+		Items []sessions.Session `json:"items"`
+	}
+	if err := json.NewDecoder(rw.Body).Decode(&out); err != nil {
+		t.Fatalf("decode sessions: %v", err)
+	}
+	return out.Items
+}
+
+func TestRefreshReuseEndsOnlyThatSession(t *testing.T) {
+	h := newTestAuthHandler(t)
+	seedMember(t, h, "ana@example.com", testBusinessID, "owner")
+	laptop := decodeSession(t, login(h, "ana@example.com"))
+	phone := decodeSession(t, login(h, "ana@example.com"))
+	if got := listSessions(t, h, phone.AccessToken); len(got) != 2 {
+		t.Fatalf("expected two sessions, got %+v", got)
+	}
+
+	rotated := decodeSession(t, refresh(h, laptop.RefreshToken))
+	// Presenting the spent token again looks like theft: the whole laptop
+	// session ends, including the pair it was rotated into.
+	if rw := refresh(h, laptop.RefreshToken); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token: expected 401, got %d", rw.Code)
+	}
+	if rw := refresh(h, rotated.RefreshToken); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("rotated refresh token after reuse: expected 401, got %d", rw.Code)
+	}
+	rw := httptest.NewRecorder()
+	h.Me(rw, bearerRequest(http.MethodGet, "/api/v1/auth/me", rotated.AccessToken, ""))
+	if rw.Code != http.StatusUnauthorized {
+		t.Fatalf("access token of the ended session: expected 401, got %d", rw.Code)
+	}
+
+	remaining := listSessions(t, h, phone.AccessToken)
+	if len(remaining) != 1 || !remaining[0].Current {
+		t.Fatalf("expected only the phone session, got %+v", remaining)
+	}
+
+	revoke := func(id string) int {
+		req := bearerRequest(http.MethodDelete, "/api/v1/auth/sessions/"+id, phone.AccessToken, "")
+		req.SetPathValue("id", id)
+		rw := httptest.NewRecorder()
+		h.RevokeSession(rw, req)
+		return rw.Code
+	}
+	if code := revoke(uuid.NewString()); code != http.StatusNotFound {
+		t.Fatalf("unknown session: expected 404, got %d", code)
+	}
+	if code := revoke(remaining[0].ID); code != http.StatusNoContent {
+		t.Fatalf("revoke: expected 204, got %d", code)
+	}
+	if rw := refresh(h, phone.RefreshToken); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh after revoke: expected 401, got %d", rw.Code)
+	}
+}
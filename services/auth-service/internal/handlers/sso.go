@@ -0,0 +1,598 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/mfa"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/oidc"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/storage"
+)
+
+// ssoRoles are the roles an SSO login may provision. Staff need a staff
+// record and admin is platform-wide, so neither can come from an IdP.
+var ssoRoles = map[string]bool{
+	"owner":        true,
+	"receptionist": true,
+}
+
+type SSOConfig struct {
+	// RedirectURL is the registered redirect_uri: the page that receives
+	// code and state and posts them to the callback endpoint.
+	RedirectURL string
+	StateTTL    time.Duration
+	// AllowInsecureIssuer admits http://localhost issuers for local mock
+	// providers.
+	AllowInsecureIssuer bool
+}
+
+// SSOHandler runs OpenID Connect logins against each business's own
+// identity provider: authorization code with PKCE, then just-in-time
+// provisioning of the user and membership when the business allows it.
+type SSOHandler struct {
+	auth   *AuthHandler
+	repo   *oidc.Repository
+	client *oidc.Client
+	sealer *mfa.Sealer
+	cfg    SSOConfig
+}
+
+func NewSSOHandler(authHandler *AuthHandler, repo *oidc.Repository, client *oidc.Client, sealer *mfa.Sealer, cfg SSOConfig) *SSOHandler {
+	if cfg.StateTTL <= 0 {
+		cfg.StateTTL = 10 * time.Minute
+	}
+	return &SSOHandler{auth: authHandler, repo: repo, client: client, sealer: sealer, cfg: cfg}
+}
+
+type ssoConfigRequest struct {
+	BusinessID string `json:"business_id,omitempty"`
+	Issuer     string `json:"issuer"`
+	ClientID   string `json:"client_id"`
+	// ClientSecret is write-only: omitted keeps the stored one, "" clears it.
+	ClientSecret   *string  `json:"client_secret,omitempty"`
+	AllowedDomains []string `json:"allowed_domains"`
+	AutoProvision  bool     `json:"auto_provision"`
+	DefaultRole    string   `json:"default_role,omitempty"`
+	Enabled        *bool    `json:"enabled,omitempty"`
+}
+
+type ssoStartRequest struct {
+	BusinessID string `json:"business_id"`
+	LoginHint  string `json:"login_hint,omitempty"`
+}
+
+type ssoStartResponse struct {
+	AuthorizationURL string    `json:"authorization_url"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// ssoBindingCookie carries oidc.Login.Binding from Start to Callback. It is
+// scoped to the SSO endpoints and never readable by scripts.
+const ssoBindingCookie = "apptremind_sso_binding"
+
+type ssoCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+// Config reads or replaces the caller's business IdP configuration.
+func (h *SSOHandler) Config(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		_, businessID, ok := teamScope(w, r, r.URL.Query().Get("business_id"))
+		if !ok {
+			return
+		}
+		cfg, err := h.repo.GetConfig(r.Context(), businessID)
+		if err != nil {
+			if oidc.IsNotFound(err) {
+				http.Error(w, "sso not configured", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to load sso config", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, cfg)
+	case http.MethodPut:
+		h.saveConfig(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *SSOHandler) saveConfig(w http.ResponseWriter, r *http.Request) {
+	var req ssoConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	actorID, businessID, ok := teamScope(w, r, req.BusinessID)
+	if !ok {
+		return
+	}
+	cfg := oidc.Config{
+		BusinessID:    businessID,
+		Issuer:        strings.TrimSuffix(strings.TrimSpace(req.Issuer), "/"),
+		ClientID:      strings.TrimSpace(req.ClientID),
+		AutoProvision: req.AutoProvision,
+		DefaultRole:   strings.TrimSpace(req.DefaultRole),
+		Enabled:       req.Enabled == nil || *req.Enabled,
+	}
+	if err := oidc.CheckIssuer(cfg.Issuer, h.cfg.AllowInsecureIssuer); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if cfg.ClientID == "" {
+		http.Error(w, "client_id required", http.StatusBadRequest)
+		return
+	}
+	if cfg.DefaultRole == "" {
+		cfg.DefaultRole = "receptionist"
+	}
+	if !ssoRoles[cfg.DefaultRole] {
+		http.Error(w, "default_role must be owner or receptionist", http.StatusBadRequest)
+		return
+	}
+	domains, ok := normalizeDomains(req.AllowedDomains)
+	if !ok {
+		http.Error(w, "allowed_domains must be bare domain names", http.StatusBadRequest)
+		return
+	}
+	if cfg.AutoProvision && len(domains) == 0 {
+		http.Error(w, "allowed_domains required when auto_provision is on", http.StatusBadRequest)
+		return
+	}
+	cfg.AllowedDomains = domains
+
+	ctx := r.Context()
+	switch {
+	case req.ClientSecret == nil:
+		existing, err := h.repo.GetConfig(ctx, businessID)
+		if err != nil && !oidc.IsNotFound(err) {
+			http.Error(w, "failed to load sso config", http.StatusInternalServerError)
+			return
+		}
+		cfg.SealedClientSecret = existing.SealedClientSecret
+	case strings.TrimSpace(*req.ClientSecret) != "":
+		sealed, err := h.sealer.Seal(strings.TrimSpace(*req.ClientSecret))
+		if err != nil {
+			http.Error(w, "failed to seal client secret", http.StatusInternalServerError)
+			return
+		}
+		cfg.SealedClientSecret = sealed
+	}
+	// Catch a mistyped issuer now rather than on the first login.
+	if cfg.Enabled {
+		if _, err := h.client.Provider(ctx, cfg.Issuer); err != nil {
+			http.Error(w, "issuer discovery failed", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	saved, err := h.repo.SaveConfig(ctx, cfg, actorID)
+	if err != nil {
+		http.Error(w, "failed to save sso config", http.StatusInternalServerError)
+		return
+	}
+	if h.auth.audit != nil {
+		_ = h.auth.audit.RecordWithOutbox(ctx, h.auth.outbox, "auth.sso.config_updated", actorID, map[string]any{
+			"business_id":    businessID,
+			"issuer":         saved.Issuer,
+			"auto_provision": saved.AutoProvision,
+			"default_role":   saved.DefaultRole,
+			"enabled":        saved.Enabled,
+		})
+	}
+	writeJSON(w, http.StatusOK, saved)
+}
+
+// Start begins a login and returns the provider URL to send the browser to.
+// With a bearer token the login also links the provider account to the
+// caller, which is how an existing password account opts in to SSO.
+func (h *SSOHandler) Start(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req ssoStartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.BusinessID = strings.TrimSpace(req.BusinessID)
+	if _, err := uuid.Parse(req.BusinessID); err != nil {
+		http.Error(w, "business_id is required", http.StatusBadRequest)
+		return
+	}
+	linkUserID := ""
+	if r.Header.Get("Authorization") != "" {
+		claims, ok := h.auth.bearerClaims(w, r)
+		if !ok {
+			return
+		}
+		linkUserID = claims.Sub
+	}
+
+	ctx := r.Context()
+	cfg, err := h.repo.GetConfig(ctx, req.BusinessID)
+	if err != nil && !oidc.IsNotFound(err) {
+		http.Error(w, "failed to load sso config", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || !cfg.Enabled {
+		http.Error(w, "sso not enabled for this business", http.StatusNotFound)
+		return
+	}
+	provider, err := h.client.Provider(ctx, cfg.Issuer)
+	if err != nil {
+		http.Error(w, "identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+	login, err := oidc.NewLogin()
+	if err != nil {
+		http.Error(w, "failed to start sso login", http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().Add(h.cfg.StateTTL).UTC().Truncate(time.Second)
+	if err := h.repo.CreateState(ctx, oidc.HashState(login.State), oidc.State{
+		BusinessID:   cfg.BusinessID,
+		Nonce:        login.Nonce,
+		CodeVerifier: login.CodeVerifier,
+		LinkUserID:   linkUserID,
+		BindingHash:  oidc.HashState(login.Binding),
+	}, expiresAt); err != nil {
+		http.Error(w, "failed to start sso login", http.StatusInternalServerError)
+		return
+	}
+	setSSOBindingCookie(w, r, login.Binding, h.cfg.StateTTL)
+	writeJSON(w, http.StatusOK, ssoStartResponse{
+		AuthorizationURL: provider.AuthCodeURL(oidc.AuthRequest{
+			ClientID:      cfg.ClientID,
+			RedirectURL:   h.cfg.RedirectURL,
+			State:         login.State,
+			Nonce:         login.Nonce,
+			CodeChallenge: oidc.CodeChallenge(login.CodeVerifier),
+			LoginHint:     strings.TrimSpace(req.LoginHint),
+		}),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// Callback finishes a login: it redeems the code, verifies the ID token and
+// resolves the provider account to a user and membership, creating them when
+// the business allows it.
+func (h *SSOHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req ssoCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	req.State = strings.TrimSpace(req.State)
+	if req.Code == "" || req.State == "" {
+		http.Error(w, "code and state required", http.StatusBadRequest)
+		return
+	}
+
+	// Only the browser that started the login holds its binding cookie, so a
+	// code and state relayed to anyone else cannot be completed there.
+	binding, err := r.Cookie(ssoBindingCookie)
+	if err != nil || binding.Value == "" {
+		http.Error(w, "invalid or expired sso state", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+	state, err := h.repo.ConsumeState(ctx, oidc.HashState(req.State), oidc.HashState(binding.Value))
+	if err != nil {
+		if oidc.IsNotFound(err) {
+			http.Error(w, "invalid or expired sso state", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "failed to check sso state", http.StatusInternalServerError)
+		return
+	}
+	setSSOBindingCookie(w, r, "", -1)
+	// A link binds the provider account to whoever started it, so that same
+	// user must be the one finishing it.
+	if state.LinkUserID != "" {
+		claims, ok := h.auth.bearerClaims(w, r)
+		if !ok {
+			return
+		}
+		if claims.Sub != state.LinkUserID {
+			http.Error(w, "sso link was started by another account", http.StatusForbidden)
+			return
+		}
+	}
+	cfg, err := h.repo.GetConfig(ctx, state.BusinessID)
+	if err != nil && !oidc.IsNotFound(err) {
+		http.Error(w, "failed to load sso config", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || !cfg.Enabled {
+		http.Error(w, "sso not enabled for this business", http.StatusForbidden)
+		return
+	}
+	clientSecret := ""
+	if cfg.SealedClientSecret != "" {
+		if clientSecret, err = h.sealer.Open(cfg.SealedClientSecret); err != nil {
+			http.Error(w, "failed to read client secret", http.StatusInternalServerError)
+			return
+		}
+	}
+	provider, err := h.client.Provider(ctx, cfg.Issuer)
+	if err != nil {
+		http.Error(w, "identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+	rawIDToken, err := provider.Exchange(ctx, oidc.ExchangeRequest{
+		Code:         req.Code,
+		RedirectURL:  h.cfg.RedirectURL,
+		ClientID:     cfg.ClientID,
+		ClientSecret: clientSecret,
+		CodeVerifier: state.CodeVerifier,
+	})
+	if err != nil {
+		http.Error(w, "identity provider rejected the code", http.StatusUnauthorized)
+		return
+	}
+	claims, err := provider.VerifyIDToken(rawIDToken, cfg.ClientID, state.Nonce, time.Now())
+	if err != nil {
+		http.Error(w, "invalid id token", http.StatusUnauthorized)
+		return
+	}
+
+	tx, err := h.auth.pool.Begin(ctx)
+	if err != nil {
+		http.Error(w, "failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	identity := oidc.Identity{Issuer: provider.Issuer, Subject: claims.Subject, Email: strings.TrimSpace(claims.Email)}
+	var user storage.User
+	userCreated, linked := false, false
+	existing, err := h.repo.FindIdentity(ctx, identity.Issuer, identity.Subject)
+	switch {
+	case err == nil:
+		if state.LinkUserID != "" && existing.UserID != state.LinkUserID {
+			http.Error(w, "identity already linked to another account", http.StatusConflict)
+			return
+		}
+		if user, err = h.auth.users.GetByID(ctx, existing.UserID); err != nil {
+			http.Error(w, "failed to lookup user", http.StatusInternalServerError)
+			return
+		}
+		if err := h.repo.TouchTx(ctx, tx, identity); err != nil {
+			http.Error(w, "failed to update identity", http.StatusInternalServerError)
+			return
+		}
+	case oidc.IsNotFound(err):
+		if state.LinkUserID != "" {
+			if user, err = h.auth.users.GetByID(ctx, state.LinkUserID); err != nil {
+				http.Error(w, "failed to lookup user", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			var ok bool
+			if user, ok = h.provisionUser(w, r, tx, cfg, claims); !ok {
+				return
+			}
+			userCreated = true
+		}
+		identity.UserID = user.ID
+		added, err := h.repo.LinkTx(ctx, tx, identity)
+		if err != nil {
+			http.Error(w, "failed to link identity", http.StatusInternalServerError)
+			return
+		}
+		if !added {
+			http.Error(w, "identity already linked to another account", http.StatusConflict)
+			return
+		}
+		linked = true
+	default:
+		http.Error(w, "failed to lookup identity", http.StatusInternalServerError)
+		return
+	}
+
+	membership, err := h.auth.memberships.Get(ctx, user.ID, cfg.BusinessID)
+	membershipCreated := false
+	switch {
+	case err == nil:
+	case storage.IsNotFound(err):
+		if !cfg.AutoProvision {
+			http.Error(w, "not a member of this business", http.StatusForbidden)
+			return
+		}
+		// A linked or existing identity reaches here without going through
+		// provisionUser, and the issuer may serve other tenants too.
+		if !admitEmail(w, cfg, claims) {
+			return
+		}
+		membership = storage.Membership{UserID: user.ID, BusinessID: cfg.BusinessID, Role: cfg.DefaultRole}
+		if _, err := h.auth.memberships.AddTx(ctx, tx, membership); err != nil {
+			http.Error(w, "failed to create membership", http.StatusInternalServerError)
+			return
+		}
+		membershipCreated = true
+	default:
+		http.Error(w, "failed to lookup membership", http.StatusInternalServerError)
+		return
+	}
+
+	meta := map[string]any{
+		"business_id": cfg.BusinessID,
+		"issuer":      identity.Issuer,
+		"subject":     identity.Subject,
+	}
+	if userCreated || membershipCreated {
+		if err := h.auth.audit.RecordTx(ctx, tx, "auth.sso.provisioned", user.ID, map[string]any{
+			"business_id":  cfg.BusinessID,
+			"issuer":       identity.Issuer,
+			"role":         membership.Role,
+			"user_created": userCreated,
+		}); err != nil {
+			http.Error(w, "failed to record audit event", http.StatusInternalServerError)
+			return
+		}
+	}
+	if linked && !userCreated {
+		if err := h.auth.audit.RecordTx(ctx, tx, "auth.sso.linked", user.ID, meta); err != nil {
+			http.Error(w, "failed to record audit event", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := h.auth.audit.RecordTx(ctx, tx, "auth.sso.login", user.ID, meta); err != nil {
+		http.Error(w, "failed to record audit event", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	memberships, err := h.auth.memberships.ListForUser(ctx, user.ID)
+	if err != nil {
+		http.Error(w, "failed to lookup memberships", http.StatusInternalServerError)
+		return
+	}
+	// The provider may have asked for its own second factor, but an enrolled
+	// TOTP factor or the owner MFA policy still applies here.
+	if h.auth.mfa != nil && h.auth.mfa.interceptLogin(w, r, user, membership) {
+		return
+	}
+	h.auth.writeSession(w, r, http.StatusOK, membership, memberships)
+}
+
+// provisionUser creates an account for a provider identity seen for the
+// first time. It never adopts an existing account with the same email: that
+// would let any business's IdP sign in as users of other businesses, so those
+// users link from a signed-in session instead.
+func (h *SSOHandler) provisionUser(w http.ResponseWriter, r *http.Request, tx pgx.Tx, cfg oidc.Config, claims oidc.IDClaims) (storage.User, bool) {
+	ctx := r.Context()
+	if !cfg.AutoProvision {
+		http.Error(w, "no account is linked to this identity", http.StatusForbidden)
+		return storage.User{}, false
+	}
+	if !admitEmail(w, cfg, claims) {
+		return storage.User{}, false
+	}
+	email := strings.TrimSpace(claims.Email)
+	if _, err := h.auth.users.GetByEmail(ctx, email); err == nil {
+		http.Error(w, "an account with this email already exists; sign in and link single sign-on from it", http.StatusConflict)
+		return storage.User{}, false
+	} else if !storage.IsNotFound(err) {
+		http.Error(w, "failed to lookup user", http.StatusInternalServerError)
+		return storage.User{}, false
+	}
+
+	// SSO accounts get a password nobody knows; a password reset can still
+	// set one.
+	unusable, err := newRefreshToken()
+	if err != nil {
+		http.Error(w, "failed to create user", http.StatusInternalServerError)
+		return storage.User{}, false
+	}
+	hash, err := hashPassword(unusable)
+	if err != nil {
+		http.Error(w, "failed to hash password", http.StatusInternalServerError)
+		return storage.User{}, false
+	}
+	user := storage.User{
+		ID:           uuid.NewString(),
+		BusinessID:   cfg.BusinessID,
+		Email:        email,
+		PasswordHash: hash,
+	}
+	if err := h.auth.users.CreateTx(ctx, tx, user); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			http.Error(w, "email already registered", http.StatusConflict)
+			return storage.User{}, false
+		}
+		http.Error(w, "failed to create user", http.StatusInternalServerError)
+		return storage.User{}, false
+	}
+	// The provider vouched for the address.
+	if err := h.auth.users.MarkEmailVerifiedTx(ctx, tx, user.ID); err != nil {
+		http.Error(w, "failed to update user", http.StatusInternalServerError)
+		return storage.User{}, false
+	}
+	return user, true
+}
+
+// admitEmail reports whether the business provisions the identity's email,
+// writing the refusal when it does not. The provider must have verified the
+// address and its domain must be one the business allows.
+func admitEmail(w http.ResponseWriter, cfg oidc.Config, claims oidc.IDClaims) bool {
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		http.Error(w, "identity provider did not return a verified email", http.StatusForbidden)
+		return false
+	}
+	if !domainAllowed(cfg.AllowedDomains, oidc.EmailDomain(email)) {
+		http.Error(w, "email domain not allowed for this business", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// normalizeDomains lower-cases, de-duplicates and checks allowed_domains
+// entries; a leading "@" is tolerated.
+func normalizeDomains(raw []string) ([]string, bool) {
+	out := make([]string, 0, len(raw))
+	seen := map[string]bool{}
+	for _, d := range raw {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+		if d == "" || strings.ContainsAny(d, "@/: ") || !strings.Contains(d, ".") {
+			return nil, false
+		}
+		if !seen[d] {
+			seen[d] = true
+			out = append(out, d)
+		}
+	}
+	return out, true
+}
+
+func domainAllowed(allowed []string, domain string) bool {
+	if domain == "" {
+		return false
+	}
+	for _, d := range allowed {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// setSSOBindingCookie sets the login binding cookie, or clears it when maxAge
+// is negative. It is Secure whenever the request reached the gateway over TLS.
+func setSSOBindingCookie(w http.ResponseWriter, r *http.Request, value string, maxAge time.Duration) {
+	cookie := &http.Cookie{
+		Name:     ssoBindingCookie,
+		Value:    value,
+		Path:     "/api/v1/auth/sso",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/mfa"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/oidc"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/oidc/oidctest"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/storage"
+)
+
+const otherBusinessID = "33333333-3333-3333-3333-333333333333"
+
+func newTestSSOHandler(t *testing.T) (*SSOHandler, *oidctest.Provider) {
+	t.Helper()
+	authHandler := newTestAuthHandler(t)
+	sealer, err := mfa.NewSealer("test-key")
+	if err != nil {
+		t.Fatalf("NewSealer: %v", err)
+	}
+	h := NewSSOHandler(authHandler, oidc.NewRepository(authHandler.pool), oidc.NewClient(nil, 0, true), sealer, SSOConfig{
+		RedirectURL:         "http://localhost:8080/sso/callback",
+		AllowInsecureIssuer: true,
+	})
+	return h, oidctest.NewProvider(t)
+}
+
+// configureSSO points businessID at the mock provider through the admin API.
+func configureSSO(t *testing.T, h *SSOHandler, idp *oidctest.Provider, businessID string, domains ...string) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{
+		"business_id":     businessID,
+		"issuer":          idp.URL,
+		"client_id":       oidctest.ClientID,
+		"allowed_domains": domains,
+		"auto_provision":  true,
+	})
+	rw := httptest.NewRecorder()
+	h.Config(rw, teamRequest(http.MethodPut, "/api/v1/auth/sso/config", string(body), "admin"))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("save config: %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+// ssoStart runs Start and returns the provider URL and the binding cookie it
+// set. A bearer token makes it a link.
+func ssoStart(t *testing.T, h *SSOHandler, businessID string, bearer string) (string, *http.Cookie) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/sso/start", strings.NewReader(`{"business_id":"`+businessID+`"}`))
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	rw := httptest.NewRecorder()
+	h.Start(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("start: %d %s", rw.Code, rw.Body.String())
+	}
+	var started ssoStartResponse
+	if err := json.NewDecoder(rw.Body).Decode(&started); err != nil {
+		t.Fatalf("decode start: %v", err)
+	}
+	for _, c := range rw.Result().Cookies() {
+		if c.Name == ssoBindingCookie && c.HttpOnly && c.Value != "" {
+			return started.AuthorizationURL, c
+		}
+	}
+	t.Fatal("start did not set an HttpOnly binding cookie")
+	return "", nil
+}
+
+// ssoCallback posts the code the provider issued for authorizationURL, from
+// a browser holding binding (nil for none) and signed in as bearer.
+func ssoCallback(t *testing.T, h *SSOHandler, authorizationURL string, code string, binding *http.Cookie, bearer string) *httptest.ResponseRecorder {
+	t.Helper()
+	authURL, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatalf("parse authorization url: %v", err)
+	}
+	body, _ := json.Marshal(ssoCallbackRequest{Code: code, State: authURL.Query().Get("state")})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/sso/callback", strings.NewReader(string(body)))
+	if binding != nil {
+		req.AddCookie(binding)
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	rw := httptest.NewRecorder()
+	h.Callback(rw, req)
+	return rw
+}
+
+// ssoLogin runs Start, approves the login at the mock provider with claims
+// and posts the code to Callback from the same browser. A bearer token makes
+// it a link.
+func ssoLogin(t *testing.T, h *SSOHandler, idp *oidctest.Provider, businessID string, bearer string, claims map[string]any) *httptest.ResponseRecorder {
+	t.Helper()
+	authorizationURL, binding := ssoStart(t, h, businessID, bearer)
+	return ssoCallback(t, h, authorizationURL, idp.Authorize(authorizationURL, claims), binding, bearer)
+}
+
+func TestSSOConfigValidation(t *testing.T) {
+	h := NewSSOHandler(&AuthHandler{}, nil, nil, nil, SSOConfig{RedirectURL: "http://localhost:8080/sso/callback"})
+	for name, body := range map[string]string{
+		"plain http issuer":              `{"issuer":"http://idp.example.com","client_id":"c"}`,
+		"admin default role":             `{"issuer":"https://idp.example.com","client_id":"c","default_role":"admin"}`,
+		"auto provision without domains": `{"issuer":"https://idp.example.com","client_id":"c","auto_provision":true}`,
+		"bad domain":                     `{"issuer":"https://idp.example.com","client_id":"c","allowed_domains":["ana@example.com"]}`,
+	} {
+		rw := httptest.NewRecorder()
+		h.Config(rw, teamRequest(http.MethodPut, "/api/v1/auth/sso/config", body, "owner"))
+		if rw.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rw.Code)
+		}
+	}
+}
+
+func TestSSOCallbackProvisionsAndLogsIn(t *testing.T) {
+	h, idp := newTestSSOHandler(t)
+	configureSSO(t, h, idp, testBusinessID, "example.com")
+	ctx := context.Background()
+
+	if rw := ssoLogin(t, h, idp, testBusinessID, "", map[string]any{"email": "ana@example.com"}); rw.Code != http.StatusForbidden {
+		t.Fatalf("unverified email: expected 403, got %d", rw.Code)
+	}
+	if rw := ssoLogin(t, h, idp, testBusinessID, "", map[string]any{"email": "ana@elsewhere.com", "email_verified": true}); rw.Code != http.StatusForbidden {
+		t.Fatalf("foreign domain: expected 403, got %d", rw.Code)
+	}
+
+	session := decodeSession(t, ssoLogin(t, h, idp, testBusinessID, "", map[string]any{"email": "ana@example.com", "email_verified": true}))
+	if session.BusinessID != testBusinessID {
+		t.Fatalf("session for %q, want %q", session.BusinessID, testBusinessID)
+	}
+	user, err := h.auth.users.GetByEmail(ctx, "ana@example.com")
+	if err != nil || user.EmailVerifiedAt == nil {
+		t.Fatalf("expected a verified provisioned user, got %+v, %v", user, err)
+	}
+	m, err := h.auth.memberships.Get(ctx, user.ID, testBusinessID)
+	if err != nil || m.Role != "receptionist" {
+		t.Fatalf("expected receptionist membership, got %+v, %v", m, err)
+	}
+
+	// The next login finds the linked identity.
+	again := decodeSession(t, ssoLogin(t, h, idp, testBusinessID, "", map[string]any{"email": "ana@example.com", "email_verified": true}))
+	if again.BusinessID != testBusinessID {
+		t.Fatalf("second login for %q", again.BusinessID)
+	}
+
+	// A password account with the same email is never adopted.
+	seedMember(t, h.auth, "bo@example.com", testBusinessID, "owner")
+	if rw := ssoLogin(t, h, idp, testBusinessID, "", map[string]any{"sub": "idp-user-2", "email": "bo@example.com", "email_verified": true}); rw.Code != http.StatusConflict {
+		t.Fatalf("existing email: expected 409, got %d", rw.Code)
+	}
+}
+
+func TestSSOCallbackRefusesMembershipOutsideAllowedDomains(t *testing.T) {
+	h, idp := newTestSSOHandler(t)
+	// Both businesses trust the same issuer, as tenants of a shared IdP do.
+	configureSSO(t, h, idp, otherBusinessID, "evil.test")
+	configureSSO(t, h, idp, testBusinessID, "example.com")
+	ctx := context.Background()
+
+	mallory := map[string]any{"sub": "mallory", "email": "mallory@evil.test", "email_verified": true}
+	session := decodeSession(t, ssoLogin(t, h, idp, otherBusinessID, "", mallory))
+
+	t.Run("linked identity", func(t *testing.T) {
+		if rw := ssoLogin(t, h, idp, testBusinessID, "", mallory); rw.Code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d: %s", rw.Code, rw.Body.String())
+		}
+	})
+	t.Run("link from a signed-in session", func(t *testing.T) {
+		second := map[string]any{"sub": "mallory-2", "email": "mallory@evil.test", "email_verified": true}
+		if rw := ssoLogin(t, h, idp, testBusinessID, session.AccessToken, second); rw.Code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d: %s", rw.Code, rw.Body.String())
+		}
+	})
+	t.Run("allowed domain but unverified", func(t *testing.T) {
+		unverified := map[string]any{"sub": "mallory-3", "email": "mallory@example.com", "email_verified": false}
+		if rw := ssoLogin(t, h, idp, testBusinessID, session.AccessToken, unverified); rw.Code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d: %s", rw.Code, rw.Body.String())
+		}
+	})
+
+	user, err := h.auth.users.GetByEmail(ctx, "mallory@evil.test")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if _, err := h.auth.memberships.Get(ctx, user.ID, testBusinessID); !storage.IsNotFound(err) {
+		t.Fatalf("expected no membership in the victim business, got %v", err)
+	}
+}
+
+func TestSSOCallbackIsBoundToTheStartingCaller(t *testing.T) {
+	h, idp := newTestSSOHandler(t)
+	configureSSO(t, h, idp, testBusinessID, "example.com")
+	seedMember(t, h.auth, "mallory@example.com", testBusinessID, "receptionist")
+	seedMember(t, h.auth, "victim@example.com", testBusinessID, "owner")
+	mallory := decodeSession(t, login(h.auth, "mallory@example.com"))
+	victim := decodeSession(t, login(h.auth, "victim@example.com"))
+	victimIdP := map[string]any{"sub": "victim-corp", "email": "victim@example.com", "email_verified": true}
+
+	// Mallory starts a link and gets the victim to finish the provider login.
+	authorizationURL, binding := ssoStart(t, h, testBusinessID, mallory.AccessToken)
+	code := idp.Authorize(authorizationURL, victimIdP)
+	if rw := ssoCallback(t, h, authorizationURL, code, nil, victim.AccessToken); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("callback from another browser: expected 401, got %d", rw.Code)
+	}
+	if rw := ssoCallback(t, h, authorizationURL, code, binding, victim.AccessToken); rw.Code != http.StatusForbidden {
+		t.Fatalf("link finished by another account: expected 403, got %d", rw.Code)
+	}
+	if _, err := h.repo.FindIdentity(context.Background(), idp.URL, "victim-corp"); !oidc.IsNotFound(err) {
+		t.Fatalf("victim identity must stay unlinked, got %v", err)
+	}
+
+	// A link still needs the starting account's token on the callback.
+	authorizationURL, binding = ssoStart(t, h, testBusinessID, mallory.AccessToken)
+	code = idp.Authorize(authorizationURL, map[string]any{"sub": "mallory-corp", "email": "mallory@example.com", "email_verified": true})
+	if rw := ssoCallback(t, h, authorizationURL, code, binding, ""); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("link without a bearer: expected 401, got %d", rw.Code)
+	}
+}
+
+func TestNormalizeDomains(t *testing.T) {
+	got, ok := normalizeDomains([]string{" Example.com ", "@example.com", "corp.example.org"})
+	if !ok || !reflect.DeepEqual(got, []string{"example.com", "corp.example.org"}) {
+		t.Fatalf("unexpected %v, %v", got, ok)
+	}
+	for _, bad := range []string{"", "localhost", "a@example.com", "https://example.com"} {
+		if _, ok := normalizeDomains([]string{bad}); ok {
+			t.Errorf("%q should be rejected", bad)
+		}
+	}
+	if !domainAllowed([]string{"example.com"}, "example.com") || domainAllowed([]string{"example.com"}, "evil-example.com") || domainAllowed(nil, "") {
+		t.Fatal("domainAllowed must match whole domains only")
+	}
+}
@@ -0,0 +1,577 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/md-rashed-zaman/apptremind/libs/auth"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/invites"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/storage"
+)
+
+// assignableRoles are the roles an owner can hand out. "admin" is a platform
+// role and never belongs to a business.
+var assignableRoles = map[string]bool{
+	"owner":        true,
+	"receptionist": true,
+	"staff":        true,
+}
+
+type TeamConfig struct {
+	// TokenSecret signs invitation links.
+	TokenSecret string
+	// AcceptURL is the page the emailed link opens; the token is appended as
+	// ?token=. The page posts it back to /api/v1/auth/invitations/accept.
+	AcceptURL string
+	TTL       time.Duration
+	// Links encrypts the accept link inside the invitation event.
+	Links *auth.LinkSealer
+}
+
+// TeamHandler manages who belongs to a business: invitations, the member list,
+// role changes and removals. Everything except accepting an invitation is
+// owner/admin only, enforced by the gateway and checked again here.
+type TeamHandler struct {
+	auth    *AuthHandler
+	invites *invites.Repository
+	cfg     TeamConfig
+}
+
+func NewTeamHandler(authHandler *AuthHandler, invitesRepo *invites.Repository, cfg TeamConfig) *TeamHandler {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 72 * time.Hour
+	}
+	return &TeamHandler{auth: authHandler, invites: invitesRepo, cfg: cfg}
+}
+
+type inviteRequest struct {
+	BusinessID string `json:"business_id,omitempty"` // admin only
+	Email      string `json:"email"`
+	Role       string `json:"role"`
+	StaffID    string `json:"staff_id,omitempty"`
+}
+
+type invitationIDRequest struct {
+	BusinessID   string `json:"business_id,omitempty"` // admin only
+	InvitationID string `json:"invitation_id"`
+}
+
+type acceptInvitationRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type memberRequest struct {
+	BusinessID string `json:"business_id,omitempty"` // admin only
+	UserID     string `json:"user_id"`
+	Role       string `json:"role,omitempty"`
+	StaffID    string `json:"staff_id,omitempty"`
+}
+
+// Invitations handles GET (pending invitations) and POST (invite someone).
+func (h *TeamHandler) Invitations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listInvitations(w, r)
+	case http.MethodPost:
+		h.createInvitation(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *TeamHandler) listInvitations(w http.ResponseWriter, r *http.Request) {
+	_, businessID, ok := teamScope(w, r, r.URL.Query().Get("business_id"))
+	if !ok {
+		return
+	}
+	items, err := h.invites.ListPending(r.Context(), businessID)
+	if err != nil {
+		http.Error(w, "failed to list invitations", http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []invites.Invitation{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (h *TeamHandler) createInvitation(w http.ResponseWriter, r *http.Request) {
+	var req inviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	actorID, businessID, ok := teamScope(w, r, req.BusinessID)
+	if !ok {
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	req.Role = strings.ToLower(strings.TrimSpace(req.Role))
+	if req.Email == "" || !strings.Contains(req.Email, "@") {
+		http.Error(w, "valid email required", http.StatusBadRequest)
+		return
+	}
+	if !assignableRoles[req.Role] {
+		http.Error(w, "role must be owner, receptionist or staff", http.StatusBadRequest)
+		return
+	}
+	staffID, ok := staffLink(req.Role, req.StaffID)
+	if !ok {
+		http.Error(w, "staff_id is required for role staff", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	member, err := h.auth.memberships.IsActiveByEmail(ctx, businessID, req.Email)
+	if err != nil {
+		http.Error(w, "failed to lookup member", http.StatusInternalServerError)
+		return
+	}
+	if member {
+		http.Error(w, "already a member of this business", http.StatusConflict)
+		return
+	}
+
+	inv := invites.Invitation{
+		ID:         uuid.NewString(),
+		BusinessID: businessID,
+		Email:      req.Email,
+		Role:       req.Role,
+		StaffID:    staffID,
+		InvitedBy:  actorID,
+		ExpiresAt:  time.Now().UTC().Add(h.cfg.TTL).Truncate(time.Second),
+	}
+	token, err := invites.SignToken(h.cfg.TokenSecret, inv.ID, inv.ExpiresAt)
+	if err != nil {
+		http.Error(w, "failed to sign invitation", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := h.auth.pool.Begin(ctx)
+	if err != nil {
+		http.Error(w, "failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := h.invites.RevokePending(ctx, tx, businessID, inv.Email); err != nil {
+		http.Error(w, "failed to supersede invitations", http.StatusInternalServerError)
+		return
+	}
+	if err := h.invites.Insert(ctx, tx, inv); err != nil {
+		http.Error(w, "failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+	acceptLink, err := h.cfg.Links.Seal(h.acceptLink(token), "auth.invitation.created.v1", inv.Email)
+	if err != nil {
+		http.Error(w, "failed to seal invitation link", http.StatusInternalServerError)
+		return
+	}
+	event := map[string]any{
+		"invitation_id":     inv.ID,
+		"business_id":       inv.BusinessID,
+		"email":             inv.Email,
+		"role":              inv.Role,
+		"invited_by":        inv.InvitedBy,
+		"accept_url_sealed": acceptLink,
+		"expires_at":        inv.ExpiresAt.Format(time.RFC3339),
+	}
+	if inv.StaffID != "" {
+		event["staff_id"] = inv.StaffID
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		http.Error(w, "failed to marshal invitation event", http.StatusInternalServerError)
+		return
+	}
+	if err := h.auth.outbox.Insert(ctx, tx, outbox.Event{
+		AggregateType: "invitation",
+		AggregateID:   inv.ID,
+		EventType:     "auth.invitation.created.v1",
+		Payload:       payload,
+	}); err != nil {
+		http.Error(w, "failed to enqueue invitation event", http.StatusInternalServerError)
+		return
+	}
+	if err := h.auth.audit.RecordTx(ctx, tx, "team.invitation.created", actorID, map[string]any{
+		"business_id":   businessID,
+		"invitation_id": inv.ID,
+		"email":         inv.Email,
+		"role":          inv.Role,
+	}); err != nil {
+		http.Error(w, "failed to record audit event", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	inv.CreatedAt = time.Now().UTC()
+	writeJSON(w, http.StatusCreated, inv)
+}
+
+func (h *TeamHandler) acceptLink(token string) string {
+	return linkWithToken(h.cfg.AcceptURL, token)
+}
+
+// RevokeInvitation cancels a pending invitation; its link stops working.
+func (h *TeamHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req invitationIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	actorID, businessID, ok := teamScope(w, r, req.BusinessID)
+	if !ok {
+		return
+	}
+	req.InvitationID = strings.TrimSpace(req.InvitationID)
+	if _, err := uuid.Parse(req.InvitationID); err != nil {
+		http.Error(w, "invitation_id must be a uuid", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := h.invites.Revoke(r.Context(), businessID, req.InvitationID)
+	if err != nil {
+		http.Error(w, "failed to revoke invitation", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "invitation not found", http.StatusNotFound)
+		return
+	}
+	_ = h.auth.audit.Record(r.Context(), "team.invitation.revoked", actorID, map[string]any{
+		"business_id":   businessID,
+		"invitation_id": req.InvitationID,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvitation is public: the signed token is the credential. It adds a
+// membership in the inviting business and logs the user into it. New addresses
+// get an account with the given password; existing accounts must confirm with
+// their current one.
+func (h *TeamHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req acceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Password = strings.TrimSpace(req.Password)
+	if strings.TrimSpace(req.Token) == "" || req.Password == "" {
+		http.Error(w, "token and password required", http.StatusBadRequest)
+		return
+	}
+	invitationID, err := invites.VerifyToken(h.cfg.TokenSecret, req.Token, time.Now())
+	if err != nil {
+		http.Error(w, "invalid or expired invitation", http.StatusUnauthorized)
+		return
+	}
+	ctx := r.Context()
+	tx, err := h.auth.pool.Begin(ctx)
+	if err != nil {
+		http.Error(w, "failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	inv, err := h.invites.GetForUpdate(ctx, tx, invitationID)
+	if err != nil {
+		if invites.IsNotFound(err) {
+			http.Error(w, "invalid or expired invitation", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "failed to load invitation", http.StatusInternalServerError)
+		return
+	}
+	if !inv.Pending(time.Now()) {
+		http.Error(w, "invitation is no longer valid", http.StatusGone)
+		return
+	}
+
+	user, err := h.auth.users.GetByEmail(ctx, inv.Email)
+	switch {
+	case err == nil:
+		if err := verifyPassword(user.PasswordHash, req.Password); err != nil {
+			http.Error(w, "invalid credentials", http.StatusUnauthorized)
+			return
+		}
+	case storage.IsNotFound(err):
+		hash, err := hashPassword(req.Password)
+		if err != nil {
+			http.Error(w, "failed to hash password", http.StatusInternalServerError)
+			return
+		}
+		user = storage.User{
+			ID:           uuid.NewString(),
+			BusinessID:   inv.BusinessID,
+			Email:        inv.Email,
+			PasswordHash: hash,
+		}
+		if err := h.auth.users.CreateTx(ctx, tx, user); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				http.Error(w, "email already registered", http.StatusConflict)
+				return
+			}
+			http.Error(w, "failed to create user", http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "failed to lookup user", http.StatusInternalServerError)
+		return
+	}
+
+	membership := storage.Membership{
+		UserID:     user.ID,
+		BusinessID: inv.BusinessID,
+		Role:       inv.Role,
+		StaffID:    inv.StaffID,
+	}
+	added, err := h.auth.memberships.AddTx(ctx, tx, membership)
+	if err != nil {
+		http.Error(w, "failed to create membership", http.StatusInternalServerError)
+		return
+	}
+	if !added {
+		http.Error(w, "already a member of this business", http.StatusConflict)
+		return
+	}
+	if err := h.invites.MarkAccepted(ctx, tx, inv.ID, user.ID); err != nil {
+		http.Error(w, "failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+	if err := h.auth.audit.RecordTx(ctx, tx, "team.invitation.accepted", user.ID, map[string]any{
+		"business_id":   inv.BusinessID,
+		"invitation_id": inv.ID,
+		"role":          inv.Role,
+	}); err != nil {
+		http.Error(w, "failed to record audit event", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+	// The membership stands either way; an existing account with MFA still
+	// has to pass its second step before getting tokens.
+	if h.auth.mfa != nil && h.auth.mfa.interceptLogin(w, r, user, membership) {
+		return
+	}
+
+	h.auth.writeSession(w, r, http.StatusCreated, membership, nil)
+}
+
+// Members lists the active members of the caller's business.
+func (h *TeamHandler) Members(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	_, businessID, ok := teamScope(w, r, r.URL.Query().Get("business_id"))
+	if !ok {
+		return
+	}
+	members, err := h.auth.memberships.ListByBusiness(r.Context(), businessID)
+	if err != nil {
+		http.Error(w, "failed to list members", http.StatusInternalServerError)
+		return
+	}
+	if members == nil {
+		members = []storage.Member{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": members})
+}
+
+// ChangeRole sets a member's role. The member's access tokens for the business
+// are revoked, so the new role applies from their next token refresh.
+func (h *TeamHandler) ChangeRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req memberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Role = strings.ToLower(strings.TrimSpace(req.Role))
+	if !assignableRoles[req.Role] {
+		http.Error(w, "role must be owner, receptionist or staff", http.StatusBadRequest)
+		return
+	}
+	staffID, ok := staffLink(req.Role, req.StaffID)
+	if !ok {
+		http.Error(w, "staff_id is required for role staff", http.StatusBadRequest)
+		return
+	}
+	req.StaffID = staffID
+	h.updateMember(w, r, req, "team.member.role_changed", func(tx pgx.Tx, member storage.Membership) error {
+		if err := h.auth.memberships.UpdateRoleTx(r.Context(), tx, member.BusinessID, member.UserID, req.Role, staffID); err != nil {
+			return err
+		}
+		return h.revokeMembershipAccess(r.Context(), tx, member, "role_changed")
+	})
+}
+
+// RemoveMember ends a user's membership of the business and revokes the
+// refresh and access tokens issued for it. Their account and other memberships remain.
+func (h *TeamHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req memberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.Role = ""
+	h.updateMember(w, r, req, "team.member.removed", func(tx pgx.Tx, member storage.Membership) error {
+		if err := h.auth.memberships.RemoveTx(r.Context(), tx, member.BusinessID, member.UserID); err != nil {
+			return err
+		}
+		if _, err := h.auth.refreshRepo.RevokeForMembership(r.Context(), tx, member.UserID, member.BusinessID); err != nil {
+			return err
+		}
+		return h.revokeMembershipAccess(r.Context(), tx, member, "membership_removed")
+	})
+}
+
+// revokeMembershipAccess revokes the access tokens member holds for its
+// business; tokens for the user's other businesses keep working.
+func (h *TeamHandler) revokeMembershipAccess(ctx context.Context, tx pgx.Tx, member storage.Membership, reason string) error {
+	tokens, err := h.auth.refreshRepo.AccessTokensForMembership(ctx, tx, member.UserID, member.BusinessID)
+	if err != nil {
+		return err
+	}
+	return h.auth.revokeAccessTokensTx(ctx, tx, member.UserID, tokens, reason)
+}
+
+// updateMember runs apply against a locked membership row, refusing changes that
+// would leave the business without an owner or that target the caller.
+func (h *TeamHandler) updateMember(w http.ResponseWriter, r *http.Request, req memberRequest, auditType string, apply func(pgx.Tx, storage.Membership) error) {
+	actorID, businessID, ok := teamScope(w, r, req.BusinessID)
+	if !ok {
+		return
+	}
+	req.UserID = strings.TrimSpace(req.UserID)
+	if _, err := uuid.Parse(req.UserID); err != nil {
+		http.Error(w, "user_id must be a uuid", http.StatusBadRequest)
+		return
+	}
+	if req.UserID == actorID {
+		http.Error(w, "cannot change your own membership", http.StatusConflict)
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := h.auth.pool.Begin(ctx)
+	if err != nil {
+		http.Error(w, "failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	owners, err := h.auth.memberships.CountOwnersForUpdate(ctx, tx, businessID)
+	if err != nil {
+		http.Error(w, "failed to load owners", http.StatusInternalServerError)
+		return
+	}
+	member, err := h.auth.memberships.GetForUpdate(ctx, tx, businessID, req.UserID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			http.Error(w, "member not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to load member", http.StatusInternalServerError)
+		return
+	}
+	if member.Role == "owner" && req.Role != "owner" && owners <= 1 {
+		http.Error(w, "business must keep at least one owner", http.StatusConflict)
+		return
+	}
+
+	if err := apply(tx, member); err != nil {
+		http.Error(w, "failed to update member", http.StatusInternalServerError)
+		return
+	}
+	metadata := map[string]any{
+		"business_id": businessID,
+		"user_id":     member.UserID,
+		"old_role":    member.Role,
+	}
+	if req.Role != "" {
+		metadata["new_role"] = req.Role
+	}
+	if req.StaffID != "" {
+		metadata["staff_id"] = req.StaffID
+	}
+	if err := h.auth.audit.RecordTx(ctx, tx, auditType, actorID, metadata); err != nil {
+		http.Error(w, "failed to record audit event", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// staffLink returns the staff record a "staff" user is tied to. Business-service
+// owns staff records, so only the format is checked here; services scope every
+// staff request by business as well, which keeps a foreign id harmless.
+func staffLink(role string, staffID string) (string, bool) {
+	if role != "staff" {
+		return "", true
+	}
+	staffID = strings.TrimSpace(staffID)
+	if _, err := uuid.Parse(staffID); err != nil {
+		return "", false
+	}
+	return staffID, true
+}
+
+// teamScope returns the acting user and the business the request applies to.
+// Admins may name any business; owners always act on their own.
+func teamScope(w http.ResponseWriter, r *http.Request, requested string) (string, string, bool) {
+	role := r.Header.Get("X-Role")
+	actorID := strings.TrimSpace(r.Header.Get("X-User-Id"))
+	if _, err := uuid.Parse(actorID); err != nil || (role != "owner" && role != "admin") {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return "", "", false
+	}
+	businessID := strings.TrimSpace(r.Header.Get("X-Business-Id"))
+	if requested = strings.TrimSpace(requested); role == "admin" && requested != "" {
+		businessID = requested
+	}
+	if _, err := uuid.Parse(businessID); err != nil {
+		http.Error(w, "business_id is required", http.StatusBadRequest)
+		return "", "", false
+	}
+	return actorID, businessID, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/auth"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/invites"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/storage"
+)
+
+const (
+	testOwnerID    = "11111111-1111-1111-1111-111111111111"
+	testBusinessID = "22222222-2222-2222-2222-222222222222"
+)
+
+func teamRequest(method, target, body, role string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("X-User-Id", testOwnerID)
+	req.Header.Set("X-Business-Id", testBusinessID)
+	req.Header.Set("X-Role", role)
+	return req
+}
+
+func TestTeamScope(t *testing.T) {
+	cases := []struct {
+		name      string
+		role      string
+		requested string
+		wantOK    bool
+		wantBiz   string
+	}{
+		{name: "owner", role: "owner", wantOK: true, wantBiz: testBusinessID},
+		{name: "owner cannot pick business", role: "owner", requested: "33333333-3333-3333-3333-333333333333", wantOK: true, wantBiz: testBusinessID},
+		{name: "admin picks business", role: "admin", requested: "33333333-3333-3333-3333-333333333333", wantOK: true, wantBiz: "33333333-3333-3333-3333-333333333333"},
+		{name: "receptionist", role: "receptionist"},
+		{name: "staff", role: "staff"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			_, biz, ok := teamScope(rw, teamRequest(http.MethodGet, "/api/v1/auth/members", "", tc.role), tc.requested)
+			if ok != tc.wantOK {
+				t.Fatalf("ok = %v, want %v (status %d)", ok, tc.wantOK, rw.Code)
+			}
+			if ok && biz != tc.wantBiz {
+				t.Fatalf("business = %q, want %q", biz, tc.wantBiz)
+			}
+			if !ok && rw.Code != http.StatusForbidden {
+				t.Fatalf("expected 403, got %d", rw.Code)
+			}
+		})
+	}
+}
+
+// newTestTeamHandler wires a TeamHandler to a migrated schema and returns the
+// sealer its invitation events use.
+func newTestTeamHandler(t *testing.T) (*TeamHandler, *auth.LinkSealer) {
+	t.Helper()
+	authHandler := newTestAuthHandler(t)
+	links, err := auth.NewLinkSealer("test-link-key")
+	if err != nil {
+		t.Fatalf("NewLinkSealer: %v", err)
+	}
+	return NewTeamHandler(authHandler, invites.NewRepository(authHandler.pool), TeamConfig{
+		TokenSecret: "secret",
+		AcceptURL:   "http://localhost:8080/invite",
+		Links:       links,
+	}), links
+}
+
+// linkTokenFromOutbox opens the sealed link of the latest eventType event in
+// the outbox and returns its token, the way the mailer would.
+func linkTokenFromOutbox(t *testing.T, h *AuthHandler, links *auth.LinkSealer, eventType, field, recipient string) string {
+	t.Helper()
+	var payload map[string]any
+	if err := h.pool.QueryRow(context.Background(), `
+		SELECT payload FROM outbox_events WHERE event_type = $1 ORDER BY id DESC LIMIT 1
+	`, eventType).Scan(&payload); err != nil {
+		t.Fatalf("load %s event: %v", eventType, err)
+	}
+	sealed, _ := payload[field].(string)
+	link, err := links.Open(sealed, eventType, recipient)
+	if err != nil {
+		t.Fatalf("open %s: %v", field, err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parse link: %v", err)
+	}
+	return u.Query().Get("token")
+}
+
+func TestInvitationAcceptanceAddsMember(t *testing.T) {
+	h, links := newTestTeamHandler(t)
+	ownerID := seedMember(t, h.auth, "owner@example.com", testBusinessID, "owner")
+	asOwner := func(method, target, body string) *http.Request {
+		req := teamRequest(method, target, body, "owner")
+		req.Header.Set("X-User-Id", ownerID)
+		return req
+	}
+
+	rw := httptest.NewRecorder()
+	h.Invitations(rw, asOwner(http.MethodPost, "/api/v1/auth/invitations", `{"email":"rita@example.com","role":"receptionist"}`))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("invite: expected 201, got %d: %s", rw.Code, rw.Body.String())
+	}
+	token := linkTokenFromOutbox(t, h.auth, links, "auth.invitation.created.v1", "accept_url_sealed", "rita@example.com")
+
+	accept := func(token, password string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		h.AcceptInvitation(rw, httptest.NewRequest(http.MethodPost, "/api/v1/auth/invitations/accept",
+			strings.NewReader(`{"token":"`+token+`","password":"`+password+`"}`)))
+		return rw
+	}
+	if rw := accept("abc.def", "pw123456"); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("forged token: expected 401, got %d", rw.Code)
+	}
+	rw = accept(token, "pw123456")
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("accept: expected 201, got %d: %s", rw.Code, rw.Body.String())
+	}
+	var session loginResponse
+	if err := json.NewDecoder(rw.Body).Decode(&session); err != nil || session.BusinessID != testBusinessID || session.AccessToken == "" {
+		t.Fatalf("accept session %+v, %v", session, err)
+	}
+	claims, err := h.auth.signer.Verify(session.AccessToken)
+	if err != nil || claims.Role != "receptionist" {
+		t.Fatalf("expected a receptionist token, got %+v, %v", claims, err)
+	}
+
+	rw = httptest.NewRecorder()
+	h.Members(rw, asOwner(http.MethodGet, "/api/v1/auth/members", ""))
+	var members struct {
+		Items []storage.Member `json:"items"`
+	}
+	if err := json.NewDecoder(rw.Body).Decode(&members); err != nil {
+		t.Fatalf("decode members: %v", err)
+	}
+	found := false
+	for _, m := range members.Items {
+		found = found || (m.Email == "rita@example.com" && m.Role == "receptionist")
+	}
+	if !found {
+		t.Fatalf("rita not listed as receptionist: %+v", members.Items)
+	}
+
+	if rw := accept(token, "pw123456"); rw.Code != http.StatusGone {
+		t.Fatalf("second accept: expected 410, got %d", rw.Code)
+	}
+	rw = httptest.NewRecorder()
+	h.Invitations(rw, asOwner(http.MethodPost, "/api/v1/auth/invitations", `{"email":"rita@example.com","role":"receptionist"}`))
+	if rw.Code != http.StatusConflict {
+		t.Fatalf("re-invite member: expected 409, got %d", rw.Code)
+	}
+	// The last owner cannot demote themselves.
+	rw = httptest.NewRecorder()
+	h.ChangeRole(rw, asOwner(http.MethodPost, "/api/v1/auth/members/role", `{"user_id":"`+ownerID+`","role":"receptionist"}`))
+	if rw.Code != http.StatusConflict {
+		t.Fatalf("self demotion: expected 409, got %d", rw.Code)
+	}
+}
+
+func TestStaffInvitationLinksStaffRecord(t *testing.T) {
+	h, links := newTestTeamHandler(t)
+	ownerID := seedMember(t, h.auth, "owner@example.com", testBusinessID, "owner")
+	const staffID = "44444444-4444-4444-4444-444444444444"
+	invite := func(body string) int {
+		req := teamRequest(http.MethodPost, "/api/v1/auth/invitations", body, "owner")
+		req.Header.Set("X-User-Id", ownerID)
+		rw := httptest.NewRecorder()
+		h.Invitations(rw, req)
+		return rw.Code
+	}
+	if code := invite(`{"email":"sam@example.com","role":"staff"}`); code != http.StatusBadRequest {
+		t.Fatalf("staff without staff_id: expected 400, got %d", code)
+	}
+	if code := invite(`{"email":"sam@example.com","role":"staff","staff_id":"` + staffID + `"}`); code != http.StatusCreated {
+		t.Fatalf("invite staff: expected 201, got %d", code)
+	}
+	token := linkTokenFromOutbox(t, h.auth, links, "auth.invitation.created.v1", "accept_url_sealed", "sam@example.com")
+
+	rw := httptest.NewRecorder()
+	h.AcceptInvitation(rw, httptest.NewRequest(http.MethodPost, "/api/v1/auth/invitations/accept",
+		strings.NewReader(`{"token":"`+token+`","password":"pw123456"}`)))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("accept: expected 201, got %d: %s", rw.Code, rw.Body.String())
+	}
+	var session loginResponse
+	if err := json.NewDecoder(rw.Body).Decode(&session); err != nil {
+		t.Fatalf("decode session: %v", err)
+	}
+	claims, err := h.auth.signer.Verify(session.AccessToken)
+	if err != nil || claims.Role != "staff" || claims.StaffID != staffID {
+		t.Fatalf("expected a staff token linked to %s, got %+v, %v", staffID, claims, err)
+	}
+}
+
+func TestRemoveMemberRevokesOnlyThatBusinessTokens(t *testing.T) {
+	h, _ := newTestTeamHandler(t)
+	ownerID := seedMember(t, h.auth, "owner@example.com", testBusinessID, "owner")
+	userID := seedMember(t, h.auth, "rita@example.com", otherBusinessID, "owner")
+	ctx := context.Background()
+	tx, err := h.auth.pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err := h.auth.memberships.AddTx(ctx, tx, storage.Membership{UserID: userID, BusinessID: testBusinessID, Role: "receptionist"}); err != nil {
+		t.Fatalf("add membership: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	// Rita lands in her own business and also opens a session she switches
+	// into the business she is about to be removed from.
+	kept := decodeSession(t, login(h.auth, "rita@example.com"))
+	moving := decodeSession(t, login(h.auth, "rita@example.com"))
+	rw := httptest.NewRecorder()
+	h.auth.SwitchBusiness(rw, bearerRequest(http.MethodPost, "/api/v1/auth/switch-business", moving.AccessToken, `{"business_id":"`+testBusinessID+`"}`))
+	removed := decodeSession(t, rw)
+	if kept.BusinessID != otherBusinessID || removed.BusinessID != testBusinessID {
+		t.Fatalf("unexpected sessions in %s and %s", kept.BusinessID, removed.BusinessID)
+	}
+
+	req := teamRequest(http.MethodPost, "/api/v1/auth/members/remove", `{"user_id":"`+userID+`"}`, "owner")
+	req.Header.Set("X-User-Id", ownerID)
+	rw = httptest.NewRecorder()
+	h.RemoveMember(rw, req)
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("remove: expected 204, got %d: %s", rw.Code, rw.Body.String())
+	}
+
+	me := func(token string) int {
+		rw := httptest.NewRecorder()
+		h.auth.Me(rw, bearerRequest(http.MethodGet, "/api/v1/auth/me", token, ""))
+		return rw.Code
+	}
+	if code := me(removed.AccessToken); code != http.StatusUnauthorized {
+		t.Fatalf("access token for the removed membership: expected 401, got %d", code)
+	}
+	if rw := refresh(h.auth, removed.RefreshToken); rw.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh token for the removed membership: expected 401, got %d", rw.Code)
+	}
+	if code := me(kept.AccessToken); code != http.StatusOK {
+		t.Fatalf("access token for the other business: expected 200, got %d", code)
+	}
+	decodeSession(t, refresh(h.auth, kept.RefreshToken))
+}
+
+func TestAcceptRejectsExpiredToken(t *testing.T) {
+	h := NewTeamHandler(&AuthHandler{}, nil, TeamConfig{TokenSecret: "secret"})
+	token, err := invites.SignToken("secret", "33333333-3333-3333-3333-333333333333", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	rw := httptest.NewRecorder()
+	h.AcceptInvitation(rw, httptest.NewRequest(http.MethodPost, "/api/v1/auth/invitations/accept", strings.NewReader(`{"token":"`+token+`","password":"pw"}`)))
+	if rw.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rw.Code)
+	}
+}
+
+func TestStaffLink(t *testing.T) {
+	const staffID = "44444444-4444-4444-4444-444444444444"
+	if got, ok := staffLink("staff", " "+staffID+" "); !ok || got != staffID {
+		t.Fatalf("staff with id: got %q, %v", got, ok)
+	}
+	if _, ok := staffLink("staff", ""); ok {
+		t.Fatal("staff without id should be rejected")
+	}
+	if got, ok := staffLink("receptionist", staffID); !ok || got != "" {
+		t.Fatalf("non-staff roles must drop the link, got %q, %v", got, ok)
+	}
+}
+
+func TestAcceptLink(t *testing.T) {
+	h := NewTeamHandler(&AuthHandler{}, nil, TeamConfig{AcceptURL: "https://app.example.com/invite"})
+	if got := h.acceptLink("a.b"); got != "https://app.example.com/invite?token=a.b" {
+		t.Fatalf("unexpected link %q", got)
+	}
+	h.cfg.AcceptURL = "https://app.example.com/join?src=email"
+	if got := h.acceptLink("a.b"); got != "https://app.example.com/join?src=email&token=a.b" {
+		t.Fatalf("unexpected link %q", got)
+	}
+}
@@ -0,0 +1,131 @@
+package invites
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+)
+
+type Invitation struct {
+	ID         string     `json:"invitation_id"`
+	BusinessID string     `json:"business_id"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	StaffID    string     `json:"staff_id,omitempty"`
+	InvitedBy  string     `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Pending reports whether the invitation can still be accepted.
+func (i Invitation) Pending(now time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
+
+type Repository struct {
+	pool *db.Pool
+}
+
+func NewRepository(pool *db.Pool) *Repository {
+	return &Repository{pool: pool}
+}
+
+func (r *Repository) Insert(ctx context.Context, tx pgx.Tx, inv Invitation) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO invitations (id, business_id, email, role, staff_id, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, $6, $7)
+	`, inv.ID, inv.BusinessID, inv.Email, inv.Role, inv.StaffID, inv.InvitedBy, inv.ExpiresAt)
+	return err
+}
+
+// RevokePending supersedes any open invitation for the same address, so only
+// the most recent link works.
+func (r *Repository) RevokePending(ctx context.Context, tx pgx.Tx, businessID string, email string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE invitations
+		SET revoked_at = now()
+		WHERE business_id = $1
+		  AND lower(email) = lower($2)
+		  AND accepted_at IS NULL
+		  AND revoked_at IS NULL
+	`, businessID, email)
+	return err
+}
+
+// Revoke cancels one pending invitation of businessID. It reports whether a
+// row changed.
+func (r *Repository) Revoke(ctx context.Context, businessID string, id string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE invitations
+		SET revoked_at = now()
+		WHERE id = $1
+		  AND business_id = $2
+		  AND accepted_at IS NULL
+		  AND revoked_at IS NULL
+	`, id, businessID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetForUpdate locks the invitation so two accepts of the same link cannot
+// both create a user.
+func (r *Repository) GetForUpdate(ctx context.Context, tx pgx.Tx, id string) (Invitation, error) {
+	var inv Invitation
+	err := tx.QueryRow(ctx, `
+		SELECT id, business_id, email, role, COALESCE(staff_id::text, ''), invited_by, expires_at, accepted_at, revoked_at, created_at
+		FROM invitations
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&inv.ID, &inv.BusinessID, &inv.Email, &inv.Role, &inv.StaffID, &inv.InvitedBy, &inv.ExpiresAt, &inv.AcceptedAt, &inv.RevokedAt, &inv.CreatedAt)
+	if err != nil {
+		return Invitation{}, err
+	}
+	return inv, nil
+}
+
+func (r *Repository) MarkAccepted(ctx context.Context, tx pgx.Tx, id string, userID string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE invitations
+		SET accepted_at = now(), accepted_user_id = $2
+		WHERE id = $1
+	`, id, userID)
+	return err
+}
+
+// ListPending returns open, unexpired invitations for a business, newest first.
+func (r *Repository) ListPending(ctx context.Context, businessID string) ([]Invitation, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, business_id, email, role, COALESCE(staff_id::text, ''), invited_by, expires_at, accepted_at, revoked_at, created_at
+		FROM invitations
+		WHERE business_id = $1
+		  AND accepted_at IS NULL
+		  AND revoked_at IS NULL
+		  AND expires_at > now()
+		ORDER BY created_at DESC
+		LIMIT 200
+	`, businessID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Invitation
+	for rows.Next() {
+		var inv Invitation
+		if err := rows.Scan(&inv.ID, &inv.BusinessID, &inv.Email, &inv.Role, &inv.StaffID, &inv.InvitedBy, &inv.ExpiresAt, &inv.AcceptedAt, &inv.RevokedAt, &inv.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, inv)
+	}
+	return out, rows.Err()
+}
+
+func IsNotFound(err error) bool {
+	return err == pgx.ErrNoRows
+}
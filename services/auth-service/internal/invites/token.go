@@ -0,0 +1,61 @@
+package invites
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid invitation token")
+
+// tokenClaims is what an accept link carries. The signature proves auth-service
+// issued it; whether the invitation is still pending is checked in the database.
+type tokenClaims struct {
+	InvitationID string `json:"i"`
+	Exp          int64  `json:"e"`
+}
+
+// SignToken returns "<base64url(json)>.<base64url(hmac-sha256)>".
+func SignToken(secret string, invitationID string, expiresAt time.Time) (string, error) {
+	raw, err := json.Marshal(tokenClaims{InvitationID: invitationID, Exp: expiresAt.Unix()})
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(tokenMAC(secret, payload)), nil
+}
+
+// VerifyToken checks the signature and expiry and returns the invitation id.
+func VerifyToken(secret string, token string, now time.Time) (string, error) {
+	payload, sig, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || payload == "" || sig == "" {
+		return "", ErrInvalidToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, tokenMAC(secret, payload)) {
+		return "", ErrInvalidToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	var c tokenClaims
+	if err := json.Unmarshal(raw, &c); err != nil || c.InvitationID == "" {
+		return "", ErrInvalidToken
+	}
+	if now.Unix() >= c.Exp {
+		return "", ErrInvalidToken
+	}
+	return c.InvitationID, nil
+}
+
+func tokenMAC(secret, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("invite:"))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
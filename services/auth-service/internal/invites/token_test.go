@@ -0,0 +1,44 @@
+package invites
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenRoundTrip(t *testing.T) {
+	now := time.Now()
+	token, err := SignToken("secret", "inv-1", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	id, err := VerifyToken("secret", token, now)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if id != "inv-1" {
+		t.Fatalf("got %q, want inv-1", id)
+	}
+}
+
+func TestTokenRejectsTamperingAndExpiry(t *testing.T) {
+	now := time.Now()
+	token, _ := SignToken("secret", "inv-1", now.Add(time.Hour))
+	other, _ := SignToken("secret", "inv-2", now.Add(time.Hour))
+
+	otherPayload, _, _ := strings.Cut(other, ".")
+	_, sig, _ := strings.Cut(token, ".")
+	forged := otherPayload + "." + sig
+	for _, tc := range []string{"", "abc", token + "x", forged} {
+		if _, err := VerifyToken("secret", tc, now); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken for %q, got %v", tc, err)
+		}
+	}
+	if _, err := VerifyToken("other-secret", token, now); !errors.Is(err, ErrInvalidToken) {
+		t.Fatal("expected ErrInvalidToken for wrong secret")
+	}
+	if _, err := VerifyToken("secret", token, now.Add(2*time.Hour)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatal("expected ErrInvalidToken after expiry")
+	}
+}
@@ -0,0 +1,252 @@
+package loginguard
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// State is what a Store knows about one key.
+type State struct {
+	// Failures counts failed attempts in the current window.
+	Failures int64
+	// LockedUntil is zero unless the key is locked.
+	LockedUntil time.Time
+}
+
+// Store keeps failed-attempt counters and locks. Keys are opaque strings such
+// as "acct:owner@example.com" or "ip:203.0.113.7".
+type Store interface {
+	// Get returns the failures of the current window and any active lock.
+	Get(ctx context.Context, key string, window time.Duration) (State, error)
+	// RecordFailure adds one failure to key and returns the new count; counts
+	// older than window are forgotten.
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset clears both the counter and any lock.
+	Reset(ctx context.Context, key string) error
+}
+
+type Config struct {
+	// Window is how long a failure counts towards delays and locks.
+	Window time.Duration
+	// DelayAfter is how many account failures are free before each attempt
+	// is slowed down; the delay doubles from BaseDelay up to MaxDelay.
+	DelayAfter int64
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// AccountLockAfter and IPLockAfter are the failure counts that lock the
+	// account or the client address for LockDuration.
+	AccountLockAfter int64
+	IPLockAfter      int64
+	LockDuration     time.Duration
+	// ResetAccountLimit and ResetIPLimit cap password reset requests per
+	// account and per client address within Window.
+	ResetAccountLimit int64
+	ResetIPLimit      int64
+}
+
+func (c Config) withDefaults() Config {
+	if c.Window <= 0 {
+		c.Window = 15 * time.Minute
+	}
+	if c.DelayAfter <= 0 {
+		c.DelayAfter = 3
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = 250 * time.Millisecond
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = 2 * time.Second
+	}
+	if c.AccountLockAfter <= 0 {
+		c.AccountLockAfter = 10
+	}
+	if c.IPLockAfter <= 0 {
+		c.IPLockAfter = 50
+	}
+	if c.LockDuration <= 0 {
+		c.LockDuration = 15 * time.Minute
+	}
+	if c.ResetAccountLimit <= 0 {
+		c.ResetAccountLimit = 3
+	}
+	if c.ResetIPLimit <= 0 {
+		c.ResetIPLimit = 20
+	}
+	return c
+}
+
+// Decision is the outcome of Check.
+type Decision struct {
+	// Delay is how long to wait before checking the password.
+	Delay time.Duration
+	// LockedUntil is set when the account or address is locked; the attempt
+	// must be refused without checking the password.
+	LockedUntil time.Time
+}
+
+// Failure describes what a failed attempt changed.
+type Failure struct {
+	AccountFailures int64
+	IPFailures      int64
+	// AccountLocked and IPLocked report a lock placed by this failure.
+	AccountLocked bool
+	IPLocked      bool
+	LockedUntil   time.Time
+}
+
+// Guard applies the throttling policy. Store errors are logged and treated as
+// "no record", so an outage of the store never blocks logins.
+type Guard struct {
+	store  Store
+	cfg    Config
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+func New(store Store, cfg Config, logger *slog.Logger) *Guard {
+	return &Guard{store: store, cfg: cfg.withDefaults(), logger: logger, now: time.Now}
+}
+
+// Check runs before the password is verified.
+func (g *Guard) Check(ctx context.Context, email string, ip string) Decision {
+	now := g.now()
+	account := g.get(ctx, accountKey(email))
+	addr := g.get(ctx, ipKey(ip))
+
+	var d Decision
+	for _, s := range []State{account, addr} {
+		if s.LockedUntil.After(now) && s.LockedUntil.After(d.LockedUntil) {
+			d.LockedUntil = s.LockedUntil
+		}
+	}
+	if d.LockedUntil.IsZero() {
+		d.Delay = g.delay(account.Failures)
+	}
+	return d
+}
+
+// RecordFailure counts a failed attempt against the account and the address
+// and locks whichever crossed its threshold.
+func (g *Guard) RecordFailure(ctx context.Context, email string, ip string) Failure {
+	var f Failure
+	until := g.now().Add(g.cfg.LockDuration)
+
+	f.AccountFailures = g.recordFailure(ctx, accountKey(email))
+	if f.AccountFailures >= g.cfg.AccountLockAfter && g.lock(ctx, accountKey(email), until) {
+		f.AccountLocked = true
+		f.LockedUntil = until
+	}
+	if ip != "" {
+		f.IPFailures = g.recordFailure(ctx, ipKey(ip))
+		if f.IPFailures >= g.cfg.IPLockAfter && g.lock(ctx, ipKey(ip), until) {
+			f.IPLocked = true
+			f.LockedUntil = until
+		}
+	}
+	return f
+}
+
+// RecordSuccess clears the account's failures. The address keeps its count so
+// one good password does not hide a spray across other accounts.
+func (g *Guard) RecordSuccess(ctx context.Context, email string) {
+	if err := g.store.Reset(ctx, accountKey(email)); err != nil {
+		g.logger.Warn("login guard reset failed", "err", err)
+	}
+}
+
+// Unlock clears the lock and failures of an account and, if ip is set, of an
+// address.
+func (g *Guard) Unlock(ctx context.Context, email string, ip string) error {
+	if email != "" {
+		if err := g.store.Reset(ctx, accountKey(email)); err != nil {
+			return err
+		}
+	}
+	if ip != "" {
+		if err := g.store.Reset(ctx, ipKey(ip)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AllowReset counts a password reset request against the account and the
+// address and reports whether both are still within their limits, so the
+// endpoint cannot be used to flood an inbox. Requests are counted whether or
+// not the email belongs to an account.
+func (g *Guard) AllowReset(ctx context.Context, email string, ip string) bool {
+	if g.recordFailure(ctx, "reset:"+accountKey(email)) > g.cfg.ResetAccountLimit {
+		return false
+	}
+	return ip == "" || g.recordFailure(ctx, "reset:"+ipKey(ip)) <= g.cfg.ResetIPLimit
+}
+
+func (g *Guard) delay(failures int64) time.Duration {
+	if failures < g.cfg.DelayAfter {
+		return 0
+	}
+	d := g.cfg.BaseDelay
+	for i := g.cfg.DelayAfter; i < failures && d < g.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > g.cfg.MaxDelay {
+		d = g.cfg.MaxDelay
+	}
+	return d
+}
+
+func (g *Guard) get(ctx context.Context, key string) State {
+	s, err := g.store.Get(ctx, key, g.cfg.Window)
+	if err != nil {
+		g.logger.Warn("login guard lookup failed", "err", err)
+		return State{}
+	}
+	return s
+}
+
+func (g *Guard) recordFailure(ctx context.Context, key string) int64 {
+	n, err := g.store.RecordFailure(ctx, key, g.cfg.Window)
+	if err != nil {
+		g.logger.Warn("login guard record failed", "err", err)
+		return 0
+	}
+	return n
+}
+
+func (g *Guard) lock(ctx context.Context, key string, until time.Time) bool {
+	if err := g.store.Lock(ctx, key, until); err != nil {
+		g.logger.Warn("login guard lock failed", "err", err)
+		return false
+	}
+	return true
+}
+
+func accountKey(email string) string {
+	return "acct:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// ClientIP returns the address the gateway saw. The gateway's reverse proxy
+// appends the peer address to X-Forwarded-For, so the last entry is the one a
+// client cannot forge; earlier entries are whatever the client sent.
+func ClientIP(r *http.Request) string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		parts := strings.Split(xff, ",")
+		if ip := strings.TrimSpace(parts[len(parts)-1]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,170 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type memStore struct {
+	states map[string]State
+	err    error
+}
+
+func newMemStore() *memStore { return &memStore{states: map[string]State{}} }
+
+func (m *memStore) Get(_ context.Context, key string, _ time.Duration) (State, error) {
+	return m.states[key], m.err
+}
+
+func (m *memStore) RecordFailure(_ context.Context, key string, _ time.Duration) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	s := m.states[key]
+	s.Failures++
+	m.states[key] = s
+	return s.Failures, nil
+}
+
+func (m *memStore) Lock(_ context.Context, key string, until time.Time) error {
+	if m.err != nil {
+		return m.err
+	}
+	s := m.states[key]
+	s.LockedUntil = until
+	m.states[key] = s
+	return nil
+}
+
+func (m *memStore) Reset(_ context.Context, key string) error {
+	delete(m.states, key)
+	return m.err
+}
+
+func testGuard(store Store) *Guard {
+	return New(store, Config{
+		DelayAfter:       2,
+		BaseDelay:        100 * time.Millisecond,
+		MaxDelay:         time.Second,
+		AccountLockAfter: 5,
+		IPLockAfter:      8,
+		LockDuration:     time.Minute,
+	}, slog.Default())
+}
+
+func TestProgressiveDelayAndAccountLock(t *testing.T) {
+	ctx := context.Background()
+	g := testGuard(newMemStore())
+
+	want := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}
+	for i, delay := range want {
+		if d := g.Check(ctx, "Owner@Example.com", "10.0.0.1"); d.Delay != delay || !d.LockedUntil.IsZero() {
+			t.Fatalf("attempt %d: got %+v, want delay %v", i+1, d, delay)
+		}
+		f := g.RecordFailure(ctx, "owner@example.com", "10.0.0.1")
+		if f.AccountLocked != (i == len(want)-1) {
+			t.Fatalf("attempt %d: AccountLocked = %v", i+1, f.AccountLocked)
+		}
+	}
+	if d := g.Check(ctx, "owner@example.com", "10.0.0.2"); d.LockedUntil.IsZero() {
+		t.Fatal("account should stay locked from another address")
+	}
+	if d := g.Check(ctx, "other@example.com", "10.0.0.1"); !d.LockedUntil.IsZero() {
+		t.Fatal("other accounts on the same address should not be locked yet")
+	}
+
+	if err := g.Unlock(ctx, "owner@example.com", ""); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if d := g.Check(ctx, "owner@example.com", "10.0.0.1"); d != (Decision{}) {
+		t.Fatalf("after unlock got %+v", d)
+	}
+}
+
+func TestDelayIsCapped(t *testing.T) {
+	g := testGuard(newMemStore())
+	if d := g.delay(40); d != time.Second {
+		t.Fatalf("delay(40) = %v, want cap of 1s", d)
+	}
+}
+
+func TestIPLockSpansAccounts(t *testing.T) {
+	ctx := context.Background()
+	g := testGuard(newMemStore())
+	var last Failure
+	for i := 0; i < 8; i++ {
+		last = g.RecordFailure(ctx, "user"+string(rune('a'+i))+"@example.com", "10.0.0.9")
+	}
+	if !last.IPLocked || last.AccountLocked {
+		t.Fatalf("expected only the address to lock, got %+v", last)
+	}
+	if d := g.Check(ctx, "fresh@example.com", "10.0.0.9"); d.LockedUntil.IsZero() {
+		t.Fatal("locked address should refuse every account")
+	}
+}
+
+func TestSuccessClearsAccountOnly(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	g := testGuard(store)
+	g.RecordFailure(ctx, "owner@example.com", "10.0.0.1")
+	g.RecordSuccess(ctx, "owner@example.com")
+	if store.states[accountKey("owner@example.com")].Failures != 0 {
+		t.Fatal("success should clear the account counter")
+	}
+	if store.states[ipKey("10.0.0.1")].Failures != 1 {
+		t.Fatal("success should keep the address counter")
+	}
+}
+
+func TestStoreErrorsFailOpen(t *testing.T) {
+	store := newMemStore()
+	store.err = errors.New("down")
+	g := testGuard(store)
+	if d := g.Check(context.Background(), "owner@example.com", "10.0.0.1"); d != (Decision{}) {
+		t.Fatalf("expected no throttling when the store is down, got %+v", d)
+	}
+	if f := g.RecordFailure(context.Background(), "owner@example.com", "10.0.0.1"); f.AccountLocked || f.IPLocked {
+		t.Fatalf("expected no lock when the store is down, got %+v", f)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/v1/auth/login", nil)
+	req.RemoteAddr = "172.18.0.5:40000"
+	if got := ClientIP(req); got != "172.18.0.5" {
+		t.Fatalf("without header got %q", got)
+	}
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 203.0.113.7")
+	if got := ClientIP(req); got != "203.0.113.7" {
+		t.Fatalf("expected the gateway-appended address, got %q", got)
+	}
+}
+
+func TestAllowResetLimitsAccountAndAddress(t *testing.T) {
+	ctx := context.Background()
+	g := New(newMemStore(), Config{ResetAccountLimit: 2, ResetIPLimit: 3}, slog.Default())
+
+	for i := 0; i < 2; i++ {
+		if !g.AllowReset(ctx, "ana@example.com", "203.0.113.7") {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+	if g.AllowReset(ctx, "ANA@example.com", "198.51.100.1") {
+		t.Fatal("third request for the account should be refused, whatever the address")
+	}
+	if !g.AllowReset(ctx, "bo@example.com", "203.0.113.7") {
+		t.Fatal("third request from the address should be allowed")
+	}
+	if g.AllowReset(ctx, "cy@example.com", "203.0.113.7") {
+		t.Fatal("fourth request from the address should be refused")
+	}
+	// Reset counters are separate from login failures.
+	if d := g.Check(ctx, "ana@example.com", "203.0.113.7"); d.Delay != 0 || !d.LockedUntil.IsZero() {
+		t.Fatalf("reset requests must not slow down logins: %+v", d)
+	}
+}
@@ -0,0 +1,74 @@
+package loginguard
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+)
+
+// PostgresStore keeps counters in the login_attempts table. It is the
+// fallback when no Redis is configured.
+type PostgresStore struct {
+	pool *db.Pool
+}
+
+func NewPostgresStore(pool *db.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string, window time.Duration) (State, error) {
+	var st State
+	var lockedUntil *time.Time
+	err := s.pool.QueryRow(ctx, `
+		SELECT CASE WHEN window_started_at < now() - make_interval(secs => $2) THEN 0 ELSE failures END,
+			locked_until
+		FROM login_attempts
+		WHERE key = $1
+	`, key, window.Seconds()).Scan(&st.Failures, &lockedUntil)
+	if err == pgx.ErrNoRows {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+	if lockedUntil != nil {
+		st.LockedUntil = *lockedUntil
+	}
+	return st, nil
+}
+
+func (s *PostgresStore) RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	var n int64
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO login_attempts (key, failures, window_started_at)
+		VALUES ($1, 1, now())
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE
+				WHEN login_attempts.window_started_at < now() - make_interval(secs => $2) THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			window_started_at = CASE
+				WHEN login_attempts.window_started_at < now() - make_interval(secs => $2) THEN now()
+				ELSE login_attempts.window_started_at
+			END
+		RETURNING failures
+	`, key, window.Seconds()).Scan(&n)
+	return n, err
+}
+
+func (s *PostgresStore) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO login_attempts (key, failures, window_started_at, locked_until)
+		VALUES ($1, 0, now(), $2)
+		ON CONFLICT (key) DO UPDATE
+		SET locked_until = EXCLUDED.locked_until
+	`, key, until)
+	return err
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
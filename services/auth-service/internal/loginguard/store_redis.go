@@ -0,0 +1,74 @@
+package loginguard
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps counters in Redis so every auth-service replica sees the
+// same numbers without touching Postgres on each login.
+type RedisStore struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+func NewRedisStore(rdb *redis.Client, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "login"
+	}
+	return &RedisStore{rdb: rdb, prefix: prefix}
+}
+
+// The window starts at the first failure and is not extended by later ones,
+// matching the Postgres store.
+var redisRecordFailureScript = redis.NewScript(`
+local current = redis.call("INCR", KEYS[1])
+if current == 1 then
+  redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return current
+`)
+
+// Get ignores window: the counter key expires with it.
+func (s *RedisStore) Get(ctx context.Context, key string, _ time.Duration) (State, error) {
+	var st State
+	n, err := s.rdb.Get(ctx, s.failKey(key)).Int64()
+	if err != nil && err != redis.Nil {
+		return State{}, err
+	}
+	st.Failures = n
+	ttl, err := s.rdb.PTTL(ctx, s.lockKey(key)).Result()
+	if err != nil {
+		return State{}, err
+	}
+	if ttl > 0 {
+		st.LockedUntil = time.Now().Add(ttl)
+	}
+	return st, nil
+}
+
+func (s *RedisStore) RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return redisRecordFailureScript.Run(ctx, s.rdb, []string{s.failKey(key)}, window.Milliseconds()).Int64()
+}
+
+func (s *RedisStore) Lock(ctx context.Context, key string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.rdb.Set(ctx, s.lockKey(key), "1", ttl).Err()
+}
+
+func (s *RedisStore) Reset(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, s.failKey(key), s.lockKey(key)).Err()
+}
+
+func (s *RedisStore) failKey(key string) string {
+	return s.prefix + ":fail:" + key
+}
+
+func (s *RedisStore) lockKey(key string) string {
+	return s.prefix + ":lock:" + key
+}
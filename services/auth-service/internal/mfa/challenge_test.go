@@ -0,0 +1,45 @@
+package mfa
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChallengeRoundTrip(t *testing.T) {
+	now := time.Now()
+	token, err := SignChallenge("secret", Challenge{UserID: "u1", BusinessID: "b1", Purpose: PurposeVerify, Exp: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	c, err := VerifyChallenge("secret", token, PurposeVerify, now)
+	if err != nil || c.UserID != "u1" || c.BusinessID != "b1" {
+		t.Fatalf("verify: %+v %v", c, err)
+	}
+	if _, err := VerifyChallenge("secret", token, PurposeEnroll, now); err != ErrInvalidChallenge {
+		t.Fatalf("wrong purpose should fail, got %v", err)
+	}
+	if _, err := VerifyChallenge("other", token, PurposeVerify, now); err != ErrInvalidChallenge {
+		t.Fatalf("wrong secret should fail, got %v", err)
+	}
+	if _, err := VerifyChallenge("secret", token, PurposeVerify, now.Add(2*time.Minute)); err != ErrInvalidChallenge {
+		t.Fatalf("expired token should fail, got %v", err)
+	}
+}
+
+func TestSealerRoundTrip(t *testing.T) {
+	s, err := NewSealer("key")
+	if err != nil {
+		t.Fatalf("sealer: %v", err)
+	}
+	sealed, err := s.Seal("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if got, err := s.Open(sealed); err != nil || got != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("open: %q %v", got, err)
+	}
+	other, _ := NewSealer("other-key")
+	if _, err := other.Open(sealed); err == nil {
+		t.Fatal("a different key must not open the secret")
+	}
+}
@@ -0,0 +1,161 @@
+package mfa
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+)
+
+// Factor is a user's TOTP enrollment. It only counts once ConfirmedAt is set.
+type Factor struct {
+	UserID       string
+	SealedSecret string
+	ConfirmedAt  *time.Time
+	LastUsedStep int64
+}
+
+// Policy is a business's MFA requirement.
+type Policy struct {
+	BusinessID       string     `json:"business_id"`
+	RequireForOwners bool       `json:"require_for_owners"`
+	UpdatedAt        *time.Time `json:"updated_at,omitempty"`
+}
+
+type Repository struct {
+	pool *db.Pool
+}
+
+func NewRepository(pool *db.Pool) *Repository {
+	return &Repository{pool: pool}
+}
+
+func (r *Repository) GetFactor(ctx context.Context, userID string) (Factor, error) {
+	f := Factor{UserID: userID}
+	err := r.pool.QueryRow(ctx, `
+		SELECT secret_sealed, confirmed_at, last_used_step
+		FROM mfa_factors
+		WHERE user_id = $1
+	`, userID).Scan(&f.SealedSecret, &f.ConfirmedAt, &f.LastUsedStep)
+	if err != nil {
+		return Factor{}, err
+	}
+	return f, nil
+}
+
+// StartEnrollment stores a new unconfirmed secret, replacing an earlier
+// unconfirmed one. It reports false when the user already has a confirmed
+// factor.
+func (r *Repository) StartEnrollment(ctx context.Context, userID string, sealedSecret string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO mfa_factors (user_id, secret_sealed)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret_sealed = EXCLUDED.secret_sealed, last_used_step = 0, created_at = now()
+		WHERE mfa_factors.confirmed_at IS NULL
+	`, userID, sealedSecret)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ConfirmTx marks the factor confirmed and replaces the recovery codes.
+func (r *Repository) ConfirmTx(ctx context.Context, tx pgx.Tx, userID string, step int64, recoveryHashes []string) error {
+	if _, err := tx.Exec(ctx, `
+		UPDATE mfa_factors
+		SET confirmed_at = now(), last_used_step = $2
+		WHERE user_id = $1
+	`, userID, step); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range recoveryHashes {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO mfa_recovery_codes (id, user_id, code_hash)
+			VALUES ($1, $2, $3)
+		`, uuid.NewString(), userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AcceptStep records step as used. It reports false when an equal or later
+// step was already accepted, which makes every code single-use.
+func (r *Repository) AcceptStep(ctx context.Context, userID string, step int64) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE mfa_factors
+		SET last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2
+	`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// UseRecoveryCode spends one unused recovery code. It reports false when no
+// unused code has that hash.
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID string, codeHash string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE mfa_recovery_codes
+		SET used_at = now()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *Repository) DeleteTx(ctx context.Context, tx pgx.Tx, userID string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `DELETE FROM mfa_factors WHERE user_id = $1`, userID)
+	return err
+}
+
+// GetPolicy returns the business's policy; businesses that never set one
+// require nothing.
+func (r *Repository) GetPolicy(ctx context.Context, businessID string) (Policy, error) {
+	p := Policy{BusinessID: businessID}
+	err := r.pool.QueryRow(ctx, `
+		SELECT require_for_owners, updated_at
+		FROM business_mfa_policies
+		WHERE business_id = $1
+	`, businessID).Scan(&p.RequireForOwners, &p.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return p, nil
+	}
+	if err != nil {
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+func (r *Repository) SetPolicy(ctx context.Context, businessID string, requireForOwners bool, updatedBy string) (Policy, error) {
+	p := Policy{BusinessID: businessID}
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO business_mfa_policies (business_id, require_for_owners, updated_by, updated_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (business_id) DO UPDATE
+		SET require_for_owners = EXCLUDED.require_for_owners,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at
+		RETURNING require_for_owners, updated_at
+	`, businessID, requireForOwners, updatedBy).Scan(&p.RequireForOwners, &p.UpdatedAt)
+	if err != nil {
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+func IsNotFound(err error) bool {
+	return err == pgx.ErrNoRows
+}
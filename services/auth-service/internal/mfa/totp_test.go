@@ -0,0 +1,92 @@
+package mfa
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed from RFC 6238 appendix B.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; the 6-digit code is their last six digits.
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tc := range cases {
+		got, err := Code(rfcSecret, time.Unix(tc.unix, 0))
+		if err != nil {
+			t.Fatalf("code: %v", err)
+		}
+		if got != tc.want {
+			t.Fatalf("t=%d: got %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestValidateAcceptsAdjacentSteps(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	current := now.Unix() / period
+	for _, offset := range []int64{-1, 0, 1} {
+		code, _ := Code(rfcSecret, now.Add(time.Duration(offset*period)*time.Second))
+		step, ok := Validate(rfcSecret, code, now)
+		if !ok || step != current+offset {
+			t.Fatalf("offset %d: step %d ok %v", offset, step, ok)
+		}
+	}
+	old, _ := Code(rfcSecret, now.Add(-2*period*time.Second))
+	if _, ok := Validate(rfcSecret, old, now); ok {
+		t.Fatal("code two steps old should be rejected")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now); ok {
+		t.Fatal("short code should be rejected")
+	}
+}
+
+func TestGenerateSecretRoundTrips(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	now := time.Now()
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	if _, ok := Validate(secret, code, now); !ok {
+		t.Fatal("fresh code should validate")
+	}
+	uri := URI("ApptRemind", "owner@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/ApptRemind:owner@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("unexpected uri %q", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Fatalf("unexpected shape %q", code)
+		}
+		if seen[code] {
+			t.Fatalf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+	typed := " " + strings.ToUpper(strings.ReplaceAll(codes[0], "-", "")) + " "
+	if HashRecoveryCode(typed) != HashRecoveryCode(codes[0]) {
+		t.Fatal("hash should ignore case, spaces and dashes")
+	}
+}
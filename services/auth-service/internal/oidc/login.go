@@ -0,0 +1,96 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+var ErrInvalidIssuer = errors.New("issuer must be an https URL without query or fragment")
+
+// Login holds the per-attempt secrets of one authorization-code flow. State
+// travels through the browser; Nonce and CodeVerifier stay on the server.
+// Binding goes to the starting browser in a cookie and must come back with
+// the callback, which ties State to that browser.
+type Login struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	Binding      string
+}
+
+// NewLogin draws fresh state, nonce, PKCE verifier and binding values.
+func NewLogin() (Login, error) {
+	var l Login
+	for _, dst := range []*string{&l.State, &l.Nonce, &l.CodeVerifier, &l.Binding} {
+		v, err := randomString()
+		if err != nil {
+			return Login{}, err
+		}
+		*dst = v
+	}
+	return l, nil
+}
+
+// CodeChallenge is the S256 PKCE challenge for verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// HashState is how a state value is stored and looked up.
+func HashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckIssuer accepts https issuer URLs. allowInsecure also admits plain http
+// to loopback hosts, for local mock providers.
+func CheckIssuer(issuer string, allowInsecure bool) error {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return ErrInvalidIssuer
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if allowInsecure && isLoopback(u.Hostname()) {
+			return nil
+		}
+	}
+	return ErrInvalidIssuer
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// EmailDomain returns the lower-cased domain of email, or "" when email has
+// no single @.
+func EmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 || strings.Count(email, "@") != 1 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
+
+// randomString returns 32 random bytes base64url encoded: 43 characters, the
+// minimum length RFC 7636 allows for a verifier.
+func randomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,240 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/oidc/oidctest"
+)
+
+// startLogin runs the flow up to the redirect back from the provider.
+func startLogin(t *testing.T, m *oidctest.Provider, claims map[string]any) (*Provider, Login, string) {
+	t.Helper()
+	provider, err := NewClient(nil, 0, true).Provider(context.Background(), m.URL)
+	if err != nil {
+		t.Fatalf("discovery: %v", err)
+	}
+	login, err := NewLogin()
+	if err != nil {
+		t.Fatalf("NewLogin: %v", err)
+	}
+	authURL := provider.AuthCodeURL(AuthRequest{
+		ClientID:      oidctest.ClientID,
+		RedirectURL:   "http://localhost:8080/sso/callback",
+		State:         login.State,
+		Nonce:         login.Nonce,
+		CodeChallenge: CodeChallenge(login.CodeVerifier),
+	})
+	if !strings.HasPrefix(authURL, m.URL+"/authorize?") {
+		t.Fatalf("unexpected authorization url %q", authURL)
+	}
+	return provider, login, m.Authorize(authURL, claims)
+}
+
+func TestLoginAgainstMockIdP(t *testing.T) {
+	m := oidctest.NewProvider(t)
+	m.Secret = "s3cret"
+	provider, login, code := startLogin(t, m, map[string]any{"email": "ana@example.com", "email_verified": true})
+
+	raw, err := provider.Exchange(context.Background(), ExchangeRequest{
+		Code:         code,
+		RedirectURL:  "http://localhost:8080/sso/callback",
+		ClientID:     oidctest.ClientID,
+		ClientSecret: "s3cret",
+		CodeVerifier: login.CodeVerifier,
+	})
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims, err := provider.VerifyIDToken(raw, oidctest.ClientID, login.Nonce, time.Now())
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.Subject != "idp-user-1" || claims.Email != "ana@example.com" || !claims.EmailVerified {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	// Codes are single-use.
+	if _, err := provider.Exchange(context.Background(), ExchangeRequest{
+		Code: code, ClientID: oidctest.ClientID, ClientSecret: "s3cret", CodeVerifier: login.CodeVerifier,
+	}); !errors.Is(err, ErrExchange) {
+		t.Fatalf("expected ErrExchange on replay, got %v", err)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	m := oidctest.NewProvider(t)
+	provider, _, code := startLogin(t, m, nil)
+	other, err := NewLogin()
+	if err != nil {
+		t.Fatalf("NewLogin: %v", err)
+	}
+	_, err = provider.Exchange(context.Background(), ExchangeRequest{Code: code, ClientID: oidctest.ClientID, CodeVerifier: other.CodeVerifier})
+	if !errors.Is(err, ErrExchange) {
+		t.Fatalf("expected ErrExchange, got %v", err)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	m := oidctest.NewProvider(t)
+	provider, err := NewClient(nil, 0, true).Provider(context.Background(), m.URL)
+	if err != nil {
+		t.Fatalf("discovery: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	now := time.Now()
+	base := func() map[string]any {
+		return map[string]any{
+			"iss":   m.URL,
+			"sub":   "idp-user-1",
+			"aud":   oidctest.ClientID,
+			"exp":   now.Add(5 * time.Minute).Unix(),
+			"iat":   now.Unix(),
+			"nonce": "n-1",
+		}
+	}
+	with := func(k string, v any) map[string]any {
+		c := base()
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+		return c
+	}
+
+	cases := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid", m.Sign(base(), m.Key, m.Kid), true},
+		{"audience array with azp", m.Sign(with("aud", []string{oidctest.ClientID, "other"}), m.Key, m.Kid), false},
+		{"wrong nonce", m.Sign(with("nonce", "n-2"), m.Key, m.Kid), false},
+		{"missing nonce", m.Sign(with("nonce", nil), m.Key, m.Kid), false},
+		{"wrong audience", m.Sign(with("aud", "someone-else"), m.Key, m.Kid), false},
+		{"wrong issuer", m.Sign(with("iss", "https://evil.example.com"), m.Key, m.Kid), false},
+		{"expired", m.Sign(with("exp", now.Add(-5*time.Minute).Unix()), m.Key, m.Kid), false},
+		{"issued in the future", m.Sign(with("iat", now.Add(time.Hour).Unix()), m.Key, m.Kid), false},
+		{"missing sub", m.Sign(with("sub", nil), m.Key, m.Kid), false},
+		{"signed by another key", m.Sign(base(), otherKey, m.Kid), false},
+		{"unknown kid", m.Sign(base(), m.Key, "idp-key-2"), false},
+		{"not a jwt", "abc", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(tc.token, oidctest.ClientID, "n-1", now)
+			if tc.ok && err != nil {
+				t.Fatalf("expected valid, got %v", err)
+			}
+			if !tc.ok && !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+
+	multi := with("aud", []string{oidctest.ClientID, "other"})
+	multi["azp"] = oidctest.ClientID
+	if _, err := provider.VerifyIDToken(m.Sign(multi, m.Key, m.Kid), oidctest.ClientID, "n-1", now); err != nil {
+		t.Fatalf("audience array with matching azp should verify, got %v", err)
+	}
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	m := oidctest.NewProvider(t)
+	m.Issuer = "https://idp.example.com"
+	if _, err := NewClient(nil, 0, true).Provider(context.Background(), m.URL); !errors.Is(err, ErrDiscovery) {
+		t.Fatalf("expected ErrDiscovery, got %v", err)
+	}
+}
+
+func TestDiscoveryRejectsUnsafeEndpoints(t *testing.T) {
+	for _, endpoint := range []string{"jwks_uri", "token_endpoint"} {
+		m := oidctest.NewProvider(t)
+		m.Endpoints[endpoint] = "http://169.254.169.254/latest/meta-data"
+		if _, err := NewClient(nil, 0, true).Provider(context.Background(), m.URL); !errors.Is(err, ErrDiscovery) {
+			t.Fatalf("%s: expected ErrDiscovery, got %v", endpoint, err)
+		}
+	}
+}
+
+func TestHTTPClientRefusesPrivateAddresses(t *testing.T) {
+	m := oidctest.NewProvider(t)
+	// The mock listens on loopback, so only the insecure client may reach it.
+	_, err := NewClient(nil, 0, false).Provider(context.Background(), m.URL)
+	if !errors.Is(err, ErrDiscovery) || !strings.Contains(err.Error(), ErrForbiddenAddress.Error()) {
+		t.Fatalf("expected a refused dial, got %v", err)
+	}
+
+	for _, ip := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1"} {
+		if publicIP(net.ParseIP(ip)) {
+			t.Errorf("%s should be refused", ip)
+		}
+	}
+	for _, ip := range []string{"8.8.8.8", "2001:4860:4860::8888"} {
+		if !publicIP(net.ParseIP(ip)) {
+			t.Errorf("%s should be allowed", ip)
+		}
+	}
+}
+
+func TestProviderCacheIsBounded(t *testing.T) {
+	c := NewClient(nil, time.Hour, false)
+	for i := 0; i < maxProviders+10; i++ {
+		c.store(fmt.Sprintf("https://idp-%d.example.com", i), &Provider{})
+	}
+	if len(c.providers) != maxProviders {
+		t.Fatalf("cache holds %d providers, want %d", len(c.providers), maxProviders)
+	}
+	if _, ok := c.providers[fmt.Sprintf("https://idp-%d.example.com", maxProviders+9)]; !ok {
+		t.Fatal("the newest provider must be cached")
+	}
+}
+
+func TestCheckIssuer(t *testing.T) {
+	cases := []struct {
+		issuer   string
+		insecure bool
+		ok       bool
+	}{
+		{"https://login.example.com", false, true},
+		{"https://login.example.com/tenant/v2.0", false, true},
+		{"http://login.example.com", true, false},
+		{"http://127.0.0.1:9000", false, false},
+		{"http://127.0.0.1:9000", true, true},
+		{"http://localhost:9000", true, true},
+		{"https://login.example.com?x=1", false, false},
+		{"https://user:pw@login.example.com", false, false},
+		{"login.example.com", false, false},
+	}
+	for _, tc := range cases {
+		if err := CheckIssuer(tc.issuer, tc.insecure); (err == nil) != tc.ok {
+			t.Errorf("CheckIssuer(%q, %v) = %v, want ok=%v", tc.issuer, tc.insecure, err, tc.ok)
+		}
+	}
+}
+
+func TestEmailDomain(t *testing.T) {
+	cases := map[string]string{
+		"ana@Example.COM": "example.com",
+		"ana":             "",
+		"@example.com":    "",
+		"ana@":            "",
+		"a@b@example.com": "",
+	}
+	for email, want := range cases {
+		if got := EmailDomain(email); got != want {
+			t.Errorf("EmailDomain(%q) = %q, want %q", email, got, want)
+		}
+	}
+}
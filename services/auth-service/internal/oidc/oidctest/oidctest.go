@@ -0,0 +1,174 @@
+// Package oidctest runs an in-process OpenID provider for tests: discovery,
+// JWKS and a token endpoint that redeems codes handed out by Authorize.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ClientID is the client the provider expects in authorization requests.
+const ClientID = "apptremind-test"
+
+type Provider struct {
+	t   testing.TB
+	srv *httptest.Server
+	// URL is the provider's issuer and base URL.
+	URL string
+	Key *rsa.PrivateKey
+	Kid string
+	// Secret, when set, is the client secret the token endpoint requires
+	// over HTTP Basic.
+	Secret string
+	// Issuer overrides the issuer advertised in discovery.
+	Issuer string
+	// Endpoints overrides discovery entries, e.g. "jwks_uri".
+	Endpoints map[string]string
+
+	mu    sync.Mutex
+	codes map[string]pendingCode
+}
+
+type pendingCode struct {
+	challenge string
+	nonce     string
+	claims    map[string]any
+}
+
+func NewProvider(t testing.TB) *Provider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	p := &Provider{t: t, Key: key, Kid: "idp-key-1", Endpoints: map[string]string{}, codes: map[string]pendingCode{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/token", p.token)
+	p.srv = httptest.NewServer(mux)
+	p.URL = p.srv.URL
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, _ *http.Request) {
+	doc := map[string]any{
+		"issuer":                 p.URL,
+		"authorization_endpoint": p.URL + "/authorize",
+		"token_endpoint":         p.URL + "/token",
+		"jwks_uri":               p.URL + "/jwks",
+	}
+	if p.Issuer != "" {
+		doc["issuer"] = p.Issuer
+	}
+	for k, v := range p.Endpoints {
+		doc[k] = v
+	}
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, _ *http.Request) {
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": p.Kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(p.Key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.Key.E)).Bytes()),
+		}},
+	})
+}
+
+// Authorize plays the user approving the login at authURL and returns the
+// code the provider would redirect back with. claims are added to, or
+// replace, the ID token's defaults.
+func (p *Provider) Authorize(authURL string, claims map[string]any) string {
+	p.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatalf("parse auth url: %v", err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != ClientID || q.Get("response_type") != "code" {
+		p.t.Fatalf("unexpected authorization request %s", u.RawQuery)
+	}
+	code := "code-" + q.Get("state")[:8]
+	p.mu.Lock()
+	p.codes[code] = pendingCode{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), claims: claims}
+	p.mu.Unlock()
+	return code
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" {
+		tokenError(w, "invalid_request")
+		return
+	}
+	if p.Secret != "" {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != ClientID || secret != p.Secret {
+			tokenError(w, "invalid_client")
+			return
+		}
+	}
+	p.mu.Lock()
+	pending, ok := p.codes[r.Form.Get("code")]
+	delete(p.codes, r.Form.Get("code"))
+	p.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != pending.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+	claims := map[string]any{
+		"iss":   p.URL,
+		"sub":   "idp-user-1",
+		"aud":   ClientID,
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": pending.nonce,
+	}
+	for k, v := range pending.claims {
+		claims[k] = v
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "opaque",
+		"token_type":   "Bearer",
+		"id_token":     p.Sign(claims, p.Key, p.Kid),
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+// Sign returns claims as an RS256 JWT signed by key under kid.
+func (p *Provider) Sign(claims map[string]any, key *rsa.PrivateKey, kid string) string {
+	p.t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		p.t.Fatalf("marshal claims: %v", err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		p.t.Fatalf("sign: %v", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
@@ -0,0 +1,422 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/auth"
+)
+
+var (
+	ErrDiscovery      = errors.New("oidc discovery failed")
+	ErrExchange       = errors.New("oidc code exchange failed")
+	ErrInvalidIDToken = errors.New("invalid id token")
+	// ErrForbiddenAddress is returned when a provider URL resolves to an
+	// address on our own network.
+	ErrForbiddenAddress = errors.New("provider address not allowed")
+)
+
+// clockSkew is how far the provider's clock may drift from ours when
+// checking exp and iat.
+const clockSkew = time.Minute
+
+// maxProviders bounds the discovery cache. Issuers come from business
+// configuration, so the set is small but not ours to limit.
+const maxProviders = 512
+
+// Metadata is the part of the discovery document the login flow uses.
+type Metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// Provider is one discovered identity provider. Its signing keys come from
+// jwks_uri and are cached by the embedded JWKS client.
+type Provider struct {
+	Metadata
+	http *http.Client
+	keys *auth.JWKSClient
+}
+
+// Client discovers providers and caches them per issuer.
+type Client struct {
+	http *http.Client
+	ttl  time.Duration
+	// allowInsecure admits http endpoints on loopback hosts, as CheckIssuer.
+	allowInsecure bool
+
+	mu        sync.Mutex
+	providers map[string]cachedProvider
+}
+
+type cachedProvider struct {
+	provider *Provider
+	expires  time.Time
+}
+
+// NewClient returns a client whose discovery results live for ttl. A nil
+// httpClient gets NewHTTPClient(allowInsecure).
+func NewClient(httpClient *http.Client, ttl time.Duration, allowInsecure bool) *Client {
+	if httpClient == nil {
+		httpClient = NewHTTPClient(allowInsecure)
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &Client{http: httpClient, ttl: ttl, allowInsecure: allowInsecure, providers: map[string]cachedProvider{}}
+}
+
+// NewHTTPClient returns the client provider requests go through. Issuers are
+// set by business admins, so unless allowPrivate is set it will not connect
+// to loopback, private or link-local addresses, whatever the URL's host
+// resolves to. Redirects are held to https for the same reason.
+func NewHTTPClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		// No proxy: the dialer has to see the real destination.
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        16,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("stopped after 5 redirects")
+			}
+			if req.URL.Scheme != "https" && !allowPrivate {
+				return fmt.Errorf("%w: redirect to %s", ErrForbiddenAddress, req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// cgnat is the shared address space carriers and some clouds use internally.
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// refusePrivate runs after name resolution, on the address actually dialled.
+func refusePrivate(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || cgnat.Contains(ip))
+}
+
+// Provider returns issuer's discovered configuration, fetching
+// /.well-known/openid-configuration when the cached copy is missing or stale.
+func (c *Client) Provider(ctx context.Context, issuer string) (*Provider, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	c.mu.Lock()
+	cached, ok := c.providers[issuer]
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.provider, nil
+	}
+
+	meta, err := c.discover(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	p := &Provider{
+		Metadata: meta,
+		http:     c.http,
+		keys:     auth.NewJWKSClientWithHTTP(meta.JWKSURI, 0, c.http),
+	}
+	c.store(issuer, p)
+	return p, nil
+}
+
+// store caches p, first dropping expired entries and then, if the cache is
+// still full, an arbitrary one.
+func (c *Client) store(issuer string, p *Provider) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.providers[issuer]; !ok && len(c.providers) >= maxProviders {
+		for k, v := range c.providers {
+			if now.After(v.expires) {
+				delete(c.providers, k)
+			}
+		}
+		for k := range c.providers {
+			if len(c.providers) < maxProviders {
+				break
+			}
+			delete(c.providers, k)
+		}
+	}
+	c.providers[issuer] = cachedProvider{provider: p, expires: now.Add(c.ttl)}
+}
+
+func (c *Client) discover(ctx context.Context, issuer string) (Metadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Metadata{}, fmt.Errorf("%w: status %d", ErrDiscovery, resp.StatusCode)
+	}
+	var meta Metadata
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&meta); err != nil {
+		return Metadata{}, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	// The document must describe the issuer it was fetched from, or a
+	// provider could vouch for tokens minted in someone else's name.
+	if strings.TrimSuffix(meta.Issuer, "/") != issuer {
+		return Metadata{}, fmt.Errorf("%w: issuer %q does not match %q", ErrDiscovery, meta.Issuer, issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return Metadata{}, fmt.Errorf("%w: missing endpoints", ErrDiscovery)
+	}
+	// We call these ourselves, so they get the issuer's own rules.
+	for _, endpoint := range []string{meta.TokenEndpoint, meta.JWKSURI} {
+		if err := CheckIssuer(endpoint, c.allowInsecure); err != nil {
+			return Metadata{}, fmt.Errorf("%w: endpoint %q: %v", ErrDiscovery, endpoint, err)
+		}
+	}
+	return meta, nil
+}
+
+// AuthRequest is what the authorization redirect carries.
+type AuthRequest struct {
+	ClientID    string
+	RedirectURL string
+	State       string
+	Nonce       string
+	// CodeChallenge is the S256 challenge of the PKCE verifier.
+	CodeChallenge string
+	LoginHint     string
+}
+
+// AuthCodeURL returns the authorization endpoint URL that starts the login.
+func (p *Provider) AuthCodeURL(req AuthRequest) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", req.ClientID)
+	q.Set("redirect_uri", req.RedirectURL)
+	q.Set("scope", "openid email profile")
+	q.Set("state", req.State)
+	q.Set("nonce", req.Nonce)
+	q.Set("code_challenge", req.CodeChallenge)
+	q.Set("code_challenge_method", "S256")
+	if req.LoginHint != "" {
+		q.Set("login_hint", req.LoginHint)
+	}
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// ExchangeRequest redeems an authorization code.
+type ExchangeRequest struct {
+	Code         string
+	RedirectURL  string
+	ClientID     string
+	ClientSecret string
+	CodeVerifier string
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems the code at the token endpoint and returns the raw ID
+// token. Confidential clients authenticate with HTTP Basic unless the
+// provider only supports client_secret_post.
+func (p *Provider) Exchange(ctx context.Context, req ExchangeRequest) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", req.Code)
+	form.Set("redirect_uri", req.RedirectURL)
+	form.Set("code_verifier", req.CodeVerifier)
+	basic := req.ClientSecret != "" && !p.postOnly()
+	if !basic {
+		form.Set("client_id", req.ClientID)
+		if req.ClientSecret != "" {
+			form.Set("client_secret", req.ClientSecret)
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if basic {
+		httpReq.SetBasicAuth(url.QueryEscape(req.ClientID), url.QueryEscape(req.ClientSecret))
+	}
+	resp, err := p.http.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("%w: status %d", ErrExchange, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("%w: status %d: %s %s", ErrExchange, resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("%w: no id_token in response", ErrExchange)
+	}
+	return body.IDToken, nil
+}
+
+func (p *Provider) postOnly() bool {
+	if len(p.TokenAuthMethods) == 0 {
+		return false
+	}
+	for _, m := range p.TokenAuthMethods {
+		if m == "client_secret_basic" {
+			return false
+		}
+	}
+	for _, m := range p.TokenAuthMethods {
+		if m == "client_secret_post" {
+			return true
+		}
+	}
+	return false
+}
+
+// IDClaims are the ID token claims the login uses.
+type IDClaims struct {
+	Issuer          string       `json:"iss"`
+	Subject         string       `json:"sub"`
+	Audience        audience     `json:"aud"`
+	AuthorizedParty string       `json:"azp"`
+	Expiry          int64        `json:"exp"`
+	IssuedAt        int64        `json:"iat"`
+	Nonce           string       `json:"nonce"`
+	Email           string       `json:"email"`
+	EmailVerified   flexibleBool `json:"email_verified"`
+	Name            string       `json:"name"`
+}
+
+// VerifyIDToken checks the token's RS256 signature against the provider's
+// JWKS and then its issuer, audience, lifetime and nonce.
+func (p *Provider) VerifyIDToken(raw string, clientID string, nonce string, now time.Time) (IDClaims, error) {
+	header, err := auth.ParseHeader(raw)
+	if err != nil {
+		return IDClaims{}, ErrInvalidIDToken
+	}
+	if header.Alg != "RS256" || header.Kid == "" {
+		return IDClaims{}, fmt.Errorf("%w: unsupported alg %q or missing kid", ErrInvalidIDToken, header.Alg)
+	}
+	key, err := p.keys.Get(header.Kid)
+	if err != nil {
+		return IDClaims{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	payload, err := auth.VerifyRS256Payload(raw, key)
+	if err != nil {
+		return IDClaims{}, ErrInvalidIDToken
+	}
+	var claims IDClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return IDClaims{}, ErrInvalidIDToken
+	}
+	if err := claims.check(p.Issuer, clientID, nonce, now); err != nil {
+		return IDClaims{}, err
+	}
+	return claims, nil
+}
+
+func (c IDClaims) check(issuer string, clientID string, nonce string, now time.Time) error {
+	switch {
+	case strings.TrimSuffix(c.Issuer, "/") != strings.TrimSuffix(issuer, "/"):
+		return fmt.Errorf("%w: issuer mismatch", ErrInvalidIDToken)
+	case c.Subject == "":
+		return fmt.Errorf("%w: missing sub", ErrInvalidIDToken)
+	case !c.Audience.contains(clientID):
+		return fmt.Errorf("%w: audience mismatch", ErrInvalidIDToken)
+	case len(c.Audience) > 1 && c.AuthorizedParty != clientID:
+		return fmt.Errorf("%w: azp mismatch", ErrInvalidIDToken)
+	case c.Expiry == 0 || now.Add(-clockSkew).Unix() > c.Expiry:
+		return fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	case c.IssuedAt > now.Add(clockSkew).Unix():
+		return fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	case nonce == "" || subtle.ConstantTimeCompare([]byte(c.Nonce), []byte(nonce)) != 1:
+		return fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return nil
+}
+
+// audience accepts aud as a single string or an array, as the spec allows.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, v := range a {
+		if v == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// flexibleBool reads email_verified, which some providers send as "true".
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package oidc
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+)
+
+// Config is a business's identity provider. The client secret is only ever
+// held sealed; HasClientSecret tells admins whether one is set.
+type Config struct {
+	BusinessID         string    `json:"business_id"`
+	Issuer             string    `json:"issuer"`
+	ClientID           string    `json:"client_id"`
+	SealedClientSecret string    `json:"-"`
+	HasClientSecret    bool      `json:"has_client_secret"`
+	AllowedDomains     []string  `json:"allowed_domains"`
+	AutoProvision      bool      `json:"auto_provision"`
+	DefaultRole        string    `json:"default_role"`
+	Enabled            bool      `json:"enabled"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// State is a started login, looked up by the hash of its state value.
+type State struct {
+	BusinessID   string
+	Nonce        string
+	CodeVerifier string
+	// LinkUserID is set when a signed-in user is linking an identity rather
+	// than logging in.
+	LinkUserID string
+	// BindingHash is the hash of the starting browser's binding cookie.
+	BindingHash string
+}
+
+// Identity is a provider account linked to a user.
+type Identity struct {
+	Issuer  string
+	Subject string
+	UserID  string
+	Email   string
+}
+
+type Repository struct {
+	pool *db.Pool
+}
+
+func NewRepository(pool *db.Pool) *Repository {
+	return &Repository{pool: pool}
+}
+
+func (r *Repository) GetConfig(ctx context.Context, businessID string) (Config, error) {
+	c := Config{BusinessID: businessID}
+	err := r.pool.QueryRow(ctx, `
+		SELECT issuer, client_id, client_secret_sealed, allowed_domains, auto_provision, default_role, enabled, updated_at
+		FROM business_sso_configs
+		WHERE business_id = $1
+	`, businessID).Scan(&c.Issuer, &c.ClientID, &c.SealedClientSecret, &c.AllowedDomains, &c.AutoProvision, &c.DefaultRole, &c.Enabled, &c.UpdatedAt)
+	if err != nil {
+		return Config{}, err
+	}
+	c.HasClientSecret = c.SealedClientSecret != ""
+	return c, nil
+}
+
+// SaveConfig creates or replaces the business's configuration.
+func (r *Repository) SaveConfig(ctx context.Context, c Config, updatedBy string) (Config, error) {
+	if c.AllowedDomains == nil {
+		c.AllowedDomains = []string{}
+	}
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO business_sso_configs (business_id, issuer, client_id, client_secret_sealed, allowed_domains, auto_provision, default_role, enabled, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now())
+		ON CONFLICT (business_id) DO UPDATE
+		SET issuer = EXCLUDED.issuer,
+			client_id = EXCLUDED.client_id,
+			client_secret_sealed = EXCLUDED.client_secret_sealed,
+			allowed_domains = EXCLUDED.allowed_domains,
+			auto_provision = EXCLUDED.auto_provision,
+			default_role = EXCLUDED.default_role,
+			enabled = EXCLUDED.enabled,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`, c.BusinessID, c.Issuer, c.ClientID, c.SealedClientSecret, c.AllowedDomains, c.AutoProvision, c.DefaultRole, c.Enabled, updatedBy).Scan(&c.UpdatedAt)
+	if err != nil {
+		return Config{}, err
+	}
+	c.HasClientSecret = c.SealedClientSecret != ""
+	return c, nil
+}
+
+// CreateState stores a started login and drops logins that expired a day ago.
+func (r *Repository) CreateState(ctx context.Context, stateHash string, s State, expiresAt time.Time) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM sso_login_states WHERE expires_at < now() - interval '1 day'`); err != nil {
+		return err
+	}
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO sso_login_states (state_hash, business_id, nonce, code_verifier, link_user_id, binding_hash, expires_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, $6, $7)
+	`, stateHash, s.BusinessID, s.Nonce, s.CodeVerifier, s.LinkUserID, s.BindingHash, expiresAt)
+	return err
+}
+
+// ConsumeState marks a pending, unexpired login as used and returns it, so
+// each state value completes at most one login. A state presented by another
+// browser is not found and stays pending for its owner.
+func (r *Repository) ConsumeState(ctx context.Context, stateHash string, bindingHash string) (State, error) {
+	var s State
+	err := r.pool.QueryRow(ctx, `
+		UPDATE sso_login_states
+		SET consumed_at = now()
+		WHERE state_hash = $1 AND binding_hash = $2 AND binding_hash <> ''
+			AND consumed_at IS NULL AND expires_at > now()
+		RETURNING business_id, nonce, code_verifier, COALESCE(link_user_id::text, ''), binding_hash
+	`, stateHash, bindingHash).Scan(&s.BusinessID, &s.Nonce, &s.CodeVerifier, &s.LinkUserID, &s.BindingHash)
+	if err != nil {
+		return State{}, err
+	}
+	return s, nil
+}
+
+// FindIdentity returns the user linked to the provider account.
+func (r *Repository) FindIdentity(ctx context.Context, issuer string, subject string) (Identity, error) {
+	id := Identity{Issuer: issuer, Subject: subject}
+	err := r.pool.QueryRow(ctx, `
+		SELECT user_id, email
+		FROM user_identities
+		WHERE issuer = $1 AND subject = $2
+	`, issuer, subject).Scan(&id.UserID, &id.Email)
+	if err != nil {
+		return Identity{}, err
+	}
+	return id, nil
+}
+
+// LinkTx links the provider account to a user. It reports false when the
+// account is already linked, to that user or another one.
+func (r *Repository) LinkTx(ctx context.Context, tx pgx.Tx, id Identity) (bool, error) {
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO user_identities (issuer, subject, user_id, email, last_login_at)
+		VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (issuer, subject) DO NOTHING
+	`, id.Issuer, id.Subject, id.UserID, id.Email)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// TouchTx records a login through the identity and the email it reported.
+func (r *Repository) TouchTx(ctx context.Context, tx pgx.Tx, id Identity) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE user_identities
+		SET last_login_at = now(), email = $3
+		WHERE issuer = $1 AND subject = $2
+	`, id.Issuer, id.Subject, id.Email)
+	return err
+}
+
+func IsNotFound(err error) bool {
+	return err == pgx.ErrNoRows
+}
@@ -66,14 +66,36 @@ func (r *Repository) FetchUnpublished(ctx context.Context, tx pgx.Tx, limit int)
 	return records, nil
 }
 
+// Backlog returns the number of unpublished events and the age in seconds of
+// the oldest one.
+func (r *Repository) Backlog(ctx context.Context) (int64, float64, error) {
+	var count int64
+	var age float64
+	err := r.pool.QueryRow(ctx, `
+		SELECT count(*), COALESCE(EXTRACT(EPOCH FROM now() - min(created_at)), 0)::float8
+		FROM outbox_events
+		WHERE published_at IS NULL
+	`).Scan(&count, &age)
+	return count, age, err
+}
+
+// scrubbedEventTypes carry (sealed) account links. Their payload is emptied
+// once published, so the outbox keeps no copy of a link after it is sent.
+var scrubbedEventTypes = []string{
+	"auth.invitation.created.v1",
+	"auth.password_reset.requested.v1",
+	"auth.email_verification.requested.v1",
+}
+
 func (r *Repository) MarkPublished(ctx context.Context, tx pgx.Tx, ids []int64) error {
 	if len(ids) == 0 {
 		return nil
 	}
 	_, err := tx.Exec(ctx, `
 		UPDATE outbox_events
-		SET published_at = now()
+		SET published_at = now(),
+		    payload = CASE WHEN event_type = ANY($2) THEN '{}'::jsonb ELSE payload END
 		WHERE id = ANY($1)
-	`, ids)
+	`, ids, scrubbedEventTypes)
 	return err
 }
@@ -0,0 +1,64 @@
+package revocation
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+)
+
+type Repository struct {
+	pool *db.Pool
+}
+
+func NewRepository(pool *db.Pool) *Repository {
+	return &Repository{pool: pool}
+}
+
+// RevokeUserTx invalidates every access token of userID issued up to now and
+// returns the watermark: tokens with an iat before it are revoked. iat has
+// whole-second precision, so the watermark is the start of the next second;
+// rounding down would let a token issued earlier in the same second survive.
+// The price is that a token issued later in that second is revoked too.
+func (r *Repository) RevokeUserTx(ctx context.Context, tx pgx.Tx, userID string) (time.Time, error) {
+	var before time.Time
+	err := tx.QueryRow(ctx, `
+		UPDATE users
+		SET tokens_valid_after = date_trunc('second', now()) + interval '1 second'
+		WHERE id = $1
+		RETURNING tokens_valid_after
+	`, userID).Scan(&before)
+	return before, err
+}
+
+// RevokeTokenTx denylists one access token until it would have expired, and
+// drops entries that no longer matter.
+func (r *Repository) RevokeTokenTx(ctx context.Context, tx pgx.Tx, userID string, jti string, expiresAt time.Time, reason string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM revoked_access_tokens WHERE expires_at < now()`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO revoked_access_tokens (jti, user_id, expires_at, reason)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (jti) DO NOTHING
+	`, jti, userID, expiresAt, reason)
+	return err
+}
+
+// IsRevoked reports whether an access token of userID, identified by jti and
+// issued at issuedAt, has been revoked. A user that no longer exists counts as
+// revoked.
+func (r *Repository) IsRevoked(ctx context.Context, userID string, jti string, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT (u.tokens_valid_after IS NOT NULL AND $3 < u.tokens_valid_after)
+			OR EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $2 AND $2 <> '')
+		FROM users u
+		WHERE u.id = $1
+	`, userID, jti, issuedAt).Scan(&revoked)
+	if err == pgx.ErrNoRows {
+		return true, nil
+	}
+	return revoked, err
+}
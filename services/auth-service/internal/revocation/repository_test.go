@@ -0,0 +1,58 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/md-rashed-zaman/apptremind/libs/db/dbtest"
+	"github.com/md-rashed-zaman/apptremind/services/auth-service/internal/storage"
+)
+
+func TestRevokeUserCoversTokensOfTheSameSecond(t *testing.T) {
+	pool := dbtest.Open(t, "../../migrations")
+	ctx := context.Background()
+	repo := NewRepository(pool)
+	userID := uuid.NewString()
+	if err := storage.NewUserRepository(pool).Create(ctx, storage.User{
+		ID:           userID,
+		BusinessID:   uuid.NewString(),
+		Email:        "ana@example.com",
+		PasswordHash: "x",
+	}); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// A token issued just before the revocation carries the same whole-second
+	// iat as the revocation itself.
+	issuedAt := time.Unix(time.Now().Unix(), 0)
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	before, err := repo.RevokeUserTx(ctx, tx, userID)
+	if err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if !before.After(issuedAt) {
+		t.Fatalf("watermark %v must be after the revocation second %v", before, issuedAt)
+	}
+	for _, tc := range []struct {
+		name    string
+		iat     time.Time
+		revoked bool
+	}{
+		{"earlier token", issuedAt.Add(-time.Minute), true},
+		{"same second", issuedAt, true},
+		{"issued at the watermark", before, false},
+	} {
+		revoked, err := repo.IsRevoked(ctx, userID, "", tc.iat)
+		if err != nil || revoked != tc.revoked {
+			t.Errorf("%s: IsRevoked = %v, %v; want %v", tc.name, revoked, err, tc.revoked)
+		}
+	}
+}
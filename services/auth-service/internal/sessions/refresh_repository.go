@@ -12,8 +12,13 @@ import (
 )
 
 type RefreshToken struct {
-	ID        string
-	UserID    string
+	ID     string
+	UserID string
+	// BusinessID is the membership the token was issued for; refreshing
+	// stays in that business.
+	BusinessID string
+	// SessionID groups the tokens that rotated from one sign-in.
+	SessionID string
 	Hash      string
 	ExpiresAt time.Time
 	RevokedAt *time.Time
@@ -27,39 +32,137 @@ func NewRefreshRepository(pool *db.Pool) *RefreshRepository {
 	return &RefreshRepository{pool: pool}
 }
 
-func (r *RefreshRepository) Create(ctx context.Context, userID string, rawToken string, expiresAt time.Time) (string, error) {
+// AccessToken identifies an access token handed out alongside a refresh
+// token, so it can be revoked when the session ends.
+type AccessToken struct {
+	JTI       string
+	ExpiresAt time.Time
+}
+
+// Create stores a new refresh token in sessionID, superseding any token the
+// session still holds, and marks the session used by client. access is the
+// access token issued with it.
+func (r *RefreshRepository) Create(ctx context.Context, sessionID string, userID string, businessID string, rawToken string, expiresAt time.Time, client Client, access AccessToken) (string, error) {
 	id := uuid.NewString()
 	hash := hashToken(rawToken)
 	_, err := r.pool.Exec(ctx, `
-		INSERT INTO refresh_tokens (id, user_id, token_hash, expires_at)
-		VALUES ($1, $2, $3, $4)
-	`, id, userID, hash, expiresAt)
+		WITH touched AS (
+			UPDATE sessions
+			SET last_used_at = now(), business_id = $3, user_agent = $6, ip = $7
+			WHERE id = $1
+		), superseded AS (
+			UPDATE refresh_tokens
+			SET revoked_at = now()
+			WHERE session_id = $1 AND revoked_at IS NULL
+		)
+		INSERT INTO refresh_tokens (id, session_id, user_id, business_id, token_hash, expires_at, access_jti, access_expires_at)
+		VALUES ($8, $1, $2, $3, $4, $5, NULLIF($9, ''), $10)
+	`, sessionID, userID, businessID, hash, expiresAt, client.userAgent(), client.IP, id, access.JTI, access.ExpiresAt)
 	if err != nil {
 		return "", err
 	}
 	return id, nil
 }
 
+// GetByHash reports a token as revoked when its session has ended, too.
 func (r *RefreshRepository) GetByHash(ctx context.Context, hash string) (RefreshToken, error) {
 	var token RefreshToken
 	err := r.pool.QueryRow(ctx, `
-		SELECT id, user_id, token_hash, expires_at, revoked_at
-		FROM refresh_tokens
-		WHERE token_hash = $1
-	`, hash).Scan(&token.ID, &token.UserID, &token.Hash, &token.ExpiresAt, &token.RevokedAt)
+		SELECT t.id, t.user_id, COALESCE(t.business_id::text, ''), COALESCE(t.session_id::text, ''),
+			t.token_hash, t.expires_at, COALESCE(t.revoked_at, s.revoked_at)
+		FROM refresh_tokens t
+		LEFT JOIN sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1
+	`, hash).Scan(&token.ID, &token.UserID, &token.BusinessID, &token.SessionID, &token.Hash, &token.ExpiresAt, &token.RevokedAt)
 	if err != nil {
 		return RefreshToken{}, err
 	}
 	return token, nil
 }
 
-func (r *RefreshRepository) Revoke(ctx context.Context, id string) error {
-	_, err := r.pool.Exec(ctx, `
+// Rotate revokes a token that is being exchanged for a new one. It reports
+// false when the token was already revoked, which means it is being reused.
+func (r *RefreshRepository) Rotate(ctx context.Context, id string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
 		UPDATE refresh_tokens
 		SET revoked_at = now()
-		WHERE id = $1
+		WHERE id = $1 AND revoked_at IS NULL
 	`, id)
-	return err
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RevokeAllForUser ends every session of userID inside tx, across all of
+// their businesses, and reports how many refresh tokens it revoked.
+func (r *RefreshRepository) RevokeAllForUser(ctx context.Context, tx pgx.Tx, userID string, reason string) (int64, error) {
+	if _, err := tx.Exec(ctx, `
+		UPDATE sessions
+		SET revoked_at = now(), revoke_reason = $2
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID, reason); err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = now()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RevokeForMembership revokes every live refresh token userID holds for
+// businessID inside tx; sessions in the user's other businesses are kept.
+func (r *RefreshRepository) RevokeForMembership(ctx context.Context, tx pgx.Tx, userID string, businessID string) (int64, error) {
+	if _, err := tx.Exec(ctx, `
+		UPDATE sessions
+		SET revoked_at = now(), revoke_reason = 'membership_removed'
+		WHERE user_id = $1 AND business_id = $2 AND revoked_at IS NULL
+	`, userID, businessID); err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = now()
+		WHERE user_id = $1 AND business_id = $2 AND revoked_at IS NULL
+	`, userID, businessID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// AccessTokensForMembership returns the unexpired access tokens userID was
+// issued for businessID, so a role change or removal can revoke them.
+func (r *RefreshRepository) AccessTokensForMembership(ctx context.Context, tx pgx.Tx, userID string, businessID string) ([]AccessToken, error) {
+	return queryAccessTokens(ctx, tx, `
+		SELECT access_jti, access_expires_at
+		FROM refresh_tokens
+		WHERE user_id = $1 AND business_id = $2
+		  AND access_jti IS NOT NULL AND access_expires_at > now()
+	`, userID, businessID)
+}
+
+func queryAccessTokens(ctx context.Context, tx pgx.Tx, query string, args ...any) ([]AccessToken, error) {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AccessToken
+	for rows.Next() {
+		var t AccessToken
+		if err := rows.Scan(&t.JTI, &t.ExpiresAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
 }
 
 func IsNotFound(err error) bool {
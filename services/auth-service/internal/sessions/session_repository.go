@@ -0,0 +1,116 @@
+package sessions
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxUserAgent matches sessions.user_agent.
+const maxUserAgent = 512
+
+// Client is what a session remembers about the device that signed in.
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+func (c Client) userAgent() string {
+	if len(c.UserAgent) > maxUserAgent {
+		return c.UserAgent[:maxUserAgent]
+	}
+	return c.UserAgent
+}
+
+// Session is one sign-in: the refresh tokens rotated from a single login.
+type Session struct {
+	ID         string    `json:"session_id"`
+	BusinessID string    `json:"business_id,omitempty"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	// Current is set by the handler for the session of the calling token.
+	Current bool `json:"current"`
+}
+
+// Start opens a session for a fresh sign-in. Create records its first token.
+func (r *RefreshRepository) Start(ctx context.Context, userID string, businessID string, client Client) (string, error) {
+	id := uuid.NewString()
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO sessions (id, user_id, business_id, user_agent, ip)
+		VALUES ($1, $2, $3, $4, $5)
+	`, id, userID, businessID, client.userAgent(), client.IP)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// ListActive returns userID's sessions that still hold a usable refresh
+// token, most recently used first.
+func (r *RefreshRepository) ListActive(ctx context.Context, userID string) ([]Session, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT s.id, COALESCE(s.business_id::text, ''), s.user_agent, s.ip, s.created_at, s.last_used_at
+		FROM sessions s
+		WHERE s.user_id = $1
+		  AND s.revoked_at IS NULL
+		  AND EXISTS (
+			SELECT 1 FROM refresh_tokens t
+			WHERE t.session_id = s.id AND t.revoked_at IS NULL AND t.expires_at > now()
+		  )
+		ORDER BY s.last_used_at DESC
+		LIMIT 200
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Session
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.BusinessID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastUsedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// RevokeSession ends one of userID's sessions and every refresh token in it
+// inside tx. It reports false when no such live session exists, and otherwise
+// returns the session's access tokens that have not expired yet.
+func (r *RefreshRepository) RevokeSession(ctx context.Context, tx pgx.Tx, userID string, sessionID string, reason string) (bool, []AccessToken, error) {
+	var revoked int64
+	err := tx.QueryRow(ctx, `
+		WITH ended AS (
+			UPDATE sessions
+			SET revoked_at = now(), revoke_reason = $3
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+			RETURNING id
+		), tokens AS (
+			UPDATE refresh_tokens
+			SET revoked_at = now()
+			WHERE session_id IN (SELECT id FROM ended) AND revoked_at IS NULL
+		)
+		SELECT count(*) FROM ended
+	`, sessionID, userID, reason).Scan(&revoked)
+	if err != nil || revoked == 0 {
+		return false, nil, err
+	}
+	// Superseded refresh tokens count too: their access tokens may still be
+	// within their lifetime.
+	access, err := queryAccessTokens(ctx, tx, `
+		SELECT access_jti, access_expires_at
+		FROM refresh_tokens
+		WHERE session_id = $1 AND user_id = $2
+		  AND access_jti IS NOT NULL AND access_expires_at > now()
+	`, sessionID, userID)
+	if err != nil {
+		return false, nil, err
+	}
+	return true, access, nil
+}
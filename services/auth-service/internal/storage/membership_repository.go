@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+)
+
+// Membership is a user's role in one business. Access tokens are always
+// scoped to a single membership.
+type Membership struct {
+	UserID     string `json:"-"`
+	BusinessID string `json:"business_id"`
+	Role       string `json:"role"`
+	// StaffID is set only for role "staff".
+	StaffID string `json:"staff_id,omitempty"`
+}
+
+// Member is a membership as seen from the team list of its business.
+type Member struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	StaffID   string    `json:"staff_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MembershipRepository struct {
+	pool *db.Pool
+}
+
+func NewMembershipRepository(pool *db.Pool) *MembershipRepository {
+	return &MembershipRepository{pool: pool}
+}
+
+// AddTx creates the membership, or re-activates a removed one with the new
+// role. It reports false when the user is already an active member.
+func (r *MembershipRepository) AddTx(ctx context.Context, tx pgx.Tx, m Membership) (bool, error) {
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO memberships (user_id, business_id, role, staff_id)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid)
+		ON CONFLICT (user_id, business_id) DO UPDATE
+		SET role = EXCLUDED.role, staff_id = EXCLUDED.staff_id, created_at = now(), removed_at = NULL
+		WHERE memberships.removed_at IS NOT NULL
+	`, m.UserID, m.BusinessID, m.Role, m.StaffID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *MembershipRepository) Get(ctx context.Context, userID string, businessID string) (Membership, error) {
+	m := Membership{UserID: userID, BusinessID: businessID}
+	err := r.pool.QueryRow(ctx, `
+		SELECT role, COALESCE(staff_id::text, '')
+		FROM memberships
+		WHERE user_id = $1 AND business_id = $2 AND removed_at IS NULL
+	`, userID, businessID).Scan(&m.Role, &m.StaffID)
+	if err != nil {
+		return Membership{}, err
+	}
+	return m, nil
+}
+
+// ListForUser returns the active memberships of userID, oldest first.
+func (r *MembershipRepository) ListForUser(ctx context.Context, userID string) ([]Membership, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT business_id, role, COALESCE(staff_id::text, '')
+		FROM memberships
+		WHERE user_id = $1 AND removed_at IS NULL
+		ORDER BY created_at, business_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Membership
+	for rows.Next() {
+		m := Membership{UserID: userID}
+		if err := rows.Scan(&m.BusinessID, &m.Role, &m.StaffID); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// IsActiveByEmail reports whether the account registered under email is
+// already an active member of businessID.
+func (r *MembershipRepository) IsActiveByEmail(ctx context.Context, businessID string, email string) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1
+			FROM memberships m
+			JOIN users u ON u.id = m.user_id
+			WHERE m.business_id = $1 AND lower(u.email) = lower($2) AND m.removed_at IS NULL
+		)
+	`, businessID, email).Scan(&exists)
+	return exists, err
+}
+
+func (r *MembershipRepository) ListByBusiness(ctx context.Context, businessID string) ([]Member, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT u.id, u.email, m.role, COALESCE(m.staff_id::text, ''), m.created_at
+		FROM memberships m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.business_id = $1 AND m.removed_at IS NULL
+		ORDER BY m.created_at
+	`, businessID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Member
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.UserID, &m.Email, &m.Role, &m.StaffID, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// GetForUpdate loads an active membership of businessID and locks the row.
+func (r *MembershipRepository) GetForUpdate(ctx context.Context, tx pgx.Tx, businessID string, userID string) (Membership, error) {
+	m := Membership{UserID: userID, BusinessID: businessID}
+	err := tx.QueryRow(ctx, `
+		SELECT role, COALESCE(staff_id::text, '')
+		FROM memberships
+		WHERE user_id = $1 AND business_id = $2 AND removed_at IS NULL
+		FOR UPDATE
+	`, userID, businessID).Scan(&m.Role, &m.StaffID)
+	if err != nil {
+		return Membership{}, err
+	}
+	return m, nil
+}
+
+// CountOwnersForUpdate locks every active owner membership of businessID and
+// returns how many there are; two owners demoting each other concurrently
+// serialise here.
+func (r *MembershipRepository) CountOwnersForUpdate(ctx context.Context, tx pgx.Tx, businessID string) (int, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT user_id
+		FROM memberships
+		WHERE business_id = $1 AND role = 'owner' AND removed_at IS NULL
+		FOR UPDATE
+	`, businessID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	return n, rows.Err()
+}
+
+// UpdateRoleTx sets the role and staff link together; staffID must be empty
+// unless role is "staff".
+func (r *MembershipRepository) UpdateRoleTx(ctx context.Context, tx pgx.Tx, businessID string, userID string, role string, staffID string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE memberships
+		SET role = $3, staff_id = NULLIF($4, '')::uuid
+		WHERE user_id = $1 AND business_id = $2
+	`, userID, businessID, role, staffID)
+	return err
+}
+
+// RemoveTx ends the membership. The row is kept so a later invitation to the
+// same business re-activates it.
+func (r *MembershipRepository) RemoveTx(ctx context.Context, tx pgx.Tx, businessID string, userID string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE memberships
+		SET removed_at = now()
+		WHERE user_id = $1 AND business_id = $2
+	`, userID, businessID)
+	return err
+}
@@ -2,17 +2,22 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/md-rashed-zaman/apptremind/libs/db"
 )
 
+// User is an account. Its role lives on each Membership; BusinessID is only
+// the business a login lands in by default.
 type User struct {
 	ID           string
 	BusinessID   string
 	Email        string
 	PasswordHash string
-	Role         string
+	// EmailVerifiedAt is nil until the user follows a verification or
+	// password reset link.
+	EmailVerifiedAt *time.Time
 }
 
 type UserRepository struct {
@@ -25,27 +30,27 @@ func NewUserRepository(pool *db.Pool) *UserRepository {
 
 func (r *UserRepository) Create(ctx context.Context, user User) error {
 	_, err := r.pool.Exec(ctx, `
-		INSERT INTO users (id, business_id, email, password_hash, role)
-		VALUES ($1, $2, $3, $4, $5)
-	`, user.ID, user.BusinessID, user.Email, user.PasswordHash, user.Role)
+		INSERT INTO users (id, business_id, email, password_hash)
+		VALUES ($1, $2, $3, $4)
+	`, user.ID, user.BusinessID, user.Email, user.PasswordHash)
 	return err
 }
 
 func (r *UserRepository) CreateTx(ctx context.Context, tx pgx.Tx, user User) error {
 	_, err := tx.Exec(ctx, `
-		INSERT INTO users (id, business_id, email, password_hash, role)
-		VALUES ($1, $2, $3, $4, $5)
-	`, user.ID, user.BusinessID, user.Email, user.PasswordHash, user.Role)
+		INSERT INTO users (id, business_id, email, password_hash)
+		VALUES ($1, $2, $3, $4)
+	`, user.ID, user.BusinessID, user.Email, user.PasswordHash)
 	return err
 }
 
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (User, error) {
 	var user User
 	err := r.pool.QueryRow(ctx, `
-		SELECT id, business_id, email, password_hash, role
+		SELECT id, business_id, email, password_hash, email_verified_at
 		FROM users
 		WHERE email = $1
-	`, email).Scan(&user.ID, &user.BusinessID, &user.Email, &user.PasswordHash, &user.Role)
+	`, email).Scan(&user.ID, &user.BusinessID, &user.Email, &user.PasswordHash, &user.EmailVerifiedAt)
 	if err != nil {
 		return User{}, err
 	}
@@ -55,16 +60,46 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (User, er
 func (r *UserRepository) GetByID(ctx context.Context, id string) (User, error) {
 	var user User
 	err := r.pool.QueryRow(ctx, `
-		SELECT id, business_id, email, password_hash, role
+		SELECT id, business_id, email, password_hash, email_verified_at
 		FROM users
 		WHERE id = $1
-	`, id).Scan(&user.ID, &user.BusinessID, &user.Email, &user.PasswordHash, &user.Role)
+	`, id).Scan(&user.ID, &user.BusinessID, &user.Email, &user.PasswordHash, &user.EmailVerifiedAt)
 	if err != nil {
 		return User{}, err
 	}
 	return user, nil
 }
 
+// SetDefaultBusiness makes businessID the one the user's next login lands in.
+func (r *UserRepository) SetDefaultBusiness(ctx context.Context, userID string, businessID string) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE users
+		SET business_id = $2
+		WHERE id = $1
+	`, userID, businessID)
+	return err
+}
+
+func (r *UserRepository) UpdatePasswordTx(ctx context.Context, tx pgx.Tx, userID string, passwordHash string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE users
+		SET password_hash = $2
+		WHERE id = $1
+	`, userID, passwordHash)
+	return err
+}
+
+// MarkEmailVerifiedTx records the first proof that the user controls their
+// address; later calls keep the original timestamp.
+func (r *UserRepository) MarkEmailVerifiedTx(ctx context.Context, tx pgx.Tx, userID string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, now())
+		WHERE id = $1
+	`, userID)
+	return err
+}
+
 func IsNotFound(err error) bool {
 	return err == pgx.ErrNoRows
 }
@@ -0,0 +1,24 @@
+-- Removed members keep their row (and email) so audit history and a later
+-- re-invite still resolve to the same user id.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS removed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_users_business
+    ON users (business_id)
+    WHERE removed_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS invitations (
+    id UUID PRIMARY KEY,
+    business_id UUID NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    invited_by UUID NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    accepted_user_id UUID,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_business_pending
+    ON invitations (business_id, created_at DESC)
+    WHERE accepted_at IS NULL AND revoked_at IS NULL;
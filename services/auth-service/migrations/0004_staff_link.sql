@@ -0,0 +1,4 @@
+-- Staff users are tied to one business-service staff record; the id is copied
+-- into their access token so services can scope them to their own schedule.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS staff_id UUID;
+ALTER TABLE invitations ADD COLUMN IF NOT EXISTS staff_id UUID;
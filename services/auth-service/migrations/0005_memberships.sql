@@ -0,0 +1,35 @@
+-- A user can belong to several businesses with a different role in each.
+-- users.business_id stays as the business a login lands in by default;
+-- users.role, users.staff_id and users.removed_at are superseded by this table
+-- and no longer read. Drop them once every instance runs this version.
+CREATE TABLE IF NOT EXISTS memberships (
+    user_id UUID NOT NULL REFERENCES users(id),
+    business_id UUID NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    staff_id UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    removed_at TIMESTAMPTZ,
+    PRIMARY KEY (user_id, business_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_memberships_business
+    ON memberships (business_id)
+    WHERE removed_at IS NULL;
+
+INSERT INTO memberships (user_id, business_id, role, staff_id, created_at, removed_at)
+SELECT id, business_id, role, staff_id, created_at, removed_at
+FROM users
+ON CONFLICT (user_id, business_id) DO NOTHING;
+
+-- Removal is per business now; the account itself stays usable for its
+-- other memberships (or a later invitation).
+UPDATE users SET removed_at = NULL WHERE removed_at IS NOT NULL;
+
+-- Refresh tokens remember which membership they were issued for, so a refresh
+-- keeps the business the client switched to.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS business_id UUID;
+
+UPDATE refresh_tokens rt
+SET business_id = u.business_id
+FROM users u
+WHERE rt.user_id = u.id AND rt.business_id IS NULL;
@@ -0,0 +1,9 @@
+-- Failed-login counters and temporary locks, used when auth-service runs
+-- without Redis. Keys are "acct:<email>" or "ip:<address>". Rows whose window
+-- and lock have both passed are inert and safe to delete.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INT NOT NULL,
+    window_started_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
@@ -0,0 +1,29 @@
+-- TOTP enrollment. The secret is AES-GCM sealed with MFA_SECRET_KEY; a factor
+-- only counts once confirmed with a first code. last_used_step stops a code
+-- from being used twice.
+CREATE TABLE IF NOT EXISTS mfa_factors (
+    user_id UUID PRIMARY KEY REFERENCES users(id),
+    secret_sealed TEXT NOT NULL,
+    confirmed_at TIMESTAMPTZ,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id),
+    code_hash VARCHAR(255) NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_unused
+    ON mfa_recovery_codes (user_id)
+    WHERE used_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS business_mfa_policies (
+    business_id UUID PRIMARY KEY,
+    require_for_owners BOOLEAN NOT NULL DEFAULT false,
+    updated_by UUID,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
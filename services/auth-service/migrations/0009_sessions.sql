@@ -0,0 +1,35 @@
+-- A session is one sign-in on one device: the chain of refresh tokens that
+-- rotate from a single login. Revoking it revokes every token in the chain.
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id),
+    business_id UUID,
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ,
+    revoke_reason VARCHAR(50)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_active
+    ON sessions (user_id)
+    WHERE revoked_at IS NULL;
+
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS session_id UUID REFERENCES sessions(id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_hash
+    ON refresh_tokens (token_hash);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session
+    ON refresh_tokens (session_id);
+
+-- Tokens issued before this version each become their own session, reusing
+-- the token id, so they keep working and show up in the session list.
+INSERT INTO sessions (id, user_id, business_id, created_at, last_used_at, revoked_at)
+SELECT id, user_id, business_id, created_at, created_at, revoked_at
+FROM refresh_tokens
+WHERE session_id IS NULL
+ON CONFLICT (id) DO NOTHING;
+
+UPDATE refresh_tokens SET session_id = id WHERE session_id IS NULL;
@@ -0,0 +1,21 @@
+-- Access tokens are revoked two ways: every token of a user issued before
+-- users.tokens_valid_after (password reset, sign out everywhere), or one token
+-- by jti (a session ending, a membership changed or removed). Both are also
+-- published as auth.access_token.revoked.v1 for the gateway.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_valid_after TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_access_tokens_expires
+    ON revoked_access_tokens (expires_at);
+
+-- The access token issued with each refresh token, so ending a session can
+-- revoke the access tokens it handed out.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS access_jti VARCHAR(64);
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS access_expires_at TIMESTAMPTZ;
@@ -0,0 +1,21 @@
+-- Business API keys for server-to-server integrations. Only the SHA-256 of the
+-- key is stored; prefix is the public part used to find it and to tell keys
+-- apart in listings.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY,
+    business_id UUID NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(32) NOT NULL UNIQUE,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL,
+    -- NULL uses the gateway's default per-key limit.
+    rate_limit_per_minute INT,
+    created_by UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_business
+    ON api_keys (business_id, created_at DESC)
+    WHERE revoked_at IS NULL;
@@ -0,0 +1,48 @@
+-- OpenID Connect single sign-on. Each business may point at one identity
+-- provider; the client secret is sealed with the MFA key like TOTP secrets.
+CREATE TABLE IF NOT EXISTS business_sso_configs (
+    business_id UUID PRIMARY KEY,
+    issuer TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    -- Empty for public clients that rely on PKCE alone.
+    client_secret_sealed TEXT NOT NULL DEFAULT '',
+    -- Email domains a just-in-time provisioned account may have.
+    allowed_domains TEXT[] NOT NULL DEFAULT '{}',
+    auto_provision BOOLEAN NOT NULL DEFAULT FALSE,
+    default_role VARCHAR(50) NOT NULL DEFAULT 'receptionist',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_by UUID NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One row per started login. Only the hash of state leaves the server, so the
+-- PKCE verifier and nonce never pass through the browser. link_user_id is set
+-- when a signed-in user is attaching an identity to their account.
+CREATE TABLE IF NOT EXISTS sso_login_states (
+    state_hash VARCHAR(64) PRIMARY KEY,
+    business_id UUID NOT NULL,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    link_user_id UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_sso_login_states_expires
+    ON sso_login_states (expires_at);
+
+-- Identity provider accounts linked to users, keyed by the stable (iss, sub)
+-- pair rather than the email the provider reports.
+CREATE TABLE IF NOT EXISTS user_identities (
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id),
+    email TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_login_at TIMESTAMPTZ,
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user
+    ON user_identities (user_id);
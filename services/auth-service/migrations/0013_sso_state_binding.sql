@@ -0,0 +1,5 @@
+-- A started SSO login is bound to the browser that started it: the hash of a
+-- value kept in an HttpOnly cookie must match on the callback, so a victim
+-- cannot be made to finish someone else's login or link. States started
+-- before this column existed can no longer complete.
+ALTER TABLE sso_login_states ADD COLUMN IF NOT EXISTS binding_hash VARCHAR(64) NOT NULL DEFAULT '';
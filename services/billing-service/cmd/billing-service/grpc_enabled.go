@@ -25,7 +25,7 @@ func startGrpcServer(ctx context.Context, logger *slog.Logger, pool *db.Pool) er
 
 	srv := grpc.NewServer(
 		grpc.StatsHandler(otelgrpc.NewServerHandler()),
-		grpc.ChainUnaryInterceptor(grpcx.UnaryServerRequestIDInterceptor()),
+		grpc.ChainUnaryInterceptor(grpcx.UnaryServerRequestIDInterceptor(), grpcx.UnaryServerMetricsInterceptor()),
 	)
 	entitlements.Register(srv, storage.NewRepository(pool))
 
@@ -92,6 +92,7 @@ func main() {
 	handler := httpx.Chain(mux,
 		httpx.WithRequestID,
 		httpx.WithAccessLog(logger),
+		httpx.WithMetrics,
 	)
 	handler = otelhttp.NewHandler(handler, "billing")
 	srv := &http.Server{
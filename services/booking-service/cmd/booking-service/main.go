@@ -157,9 +157,14 @@ func main() {
 	mux.HandleFunc("/api/v1/public/book", bookingHandler.Create)
 	mux.HandleFunc("/api/v1/appointments", bookingHandler.List)
 	mux.HandleFunc("/api/v1/appointments/cancel", bookingHandler.Cancel)
+
+	internalHandler := handlers.NewInternalHandler(bookingHandler, config.String("BOOKING_INTERNAL_TOKEN", ""))
+	mux.HandleFunc("/internal/v1/appointments/confirm", internalHandler.Confirm)
+	mux.HandleFunc("/internal/v1/appointments/cancel", internalHandler.Cancel)
 	httpHandler := httpx.Chain(mux,
 		httpx.WithRequestID,
 		httpx.WithAccessLog(logger),
+		httpx.WithMetrics,
 	)
 	httpHandler = otelhttp.NewHandler(httpHandler, "booking")
 	srv := &http.Server{
@@ -11,7 +11,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/reminder"
 	"github.com/md-rashed-zaman/apptremind/services/booking-service/internal/availability"
 	"github.com/md-rashed-zaman/apptremind/services/booking-service/internal/model"
 	"github.com/md-rashed-zaman/apptremind/services/booking-service/internal/outbox"
@@ -49,6 +51,8 @@ type createBookingRequest struct {
 	CustomerPhone string `json:"customer_phone"`
 	StartTime     string `json:"start_time"`
 	EndTime       string `json:"end_time"`
+	// ReminderChannels is optional ("email", "sms"); the business default applies when empty.
+	ReminderChannels []string `json:"reminder_channels"`
 }
 
 type createBookingResponse struct {
@@ -99,6 +103,17 @@ func (h *BookingHandler) Create(w http.ResponseWriter, r *http.Request) {
 	req.ServiceID = strings.TrimSpace(req.ServiceID)
 	req.StaffID = strings.TrimSpace(req.StaffID)
 	req.CustomerName = strings.TrimSpace(req.CustomerName)
+	businessID, allowed := callerBusiness(r, req.BusinessID)
+	// Staff may only book into their own schedule.
+	staffID, staffAllowed := staffScope(r)
+	if !allowed || !staffAllowed || (staffID != "" && req.StaffID != "" && req.StaffID != staffID) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	req.BusinessID = businessID
+	if staffID != "" {
+		req.StaffID = staffID
+	}
 
 	if req.BusinessID == "" || req.ServiceID == "" || req.StaffID == "" || req.CustomerName == "" {
 		http.Error(w, "missing required fields", http.StatusBadRequest)
@@ -119,6 +134,11 @@ func (h *BookingHandler) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "end_time must be after start_time", http.StatusBadRequest)
 		return
 	}
+	customerChannels, err := reminder.NormalizeChannels(req.ReminderChannels)
+	if err != nil {
+		http.Error(w, "reminder_channels must contain email and/or sms", http.StatusBadRequest)
+		return
+	}
 
 	appt := &model.Appointment{
 		BusinessID:    req.BusinessID,
@@ -195,6 +215,14 @@ func (h *BookingHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	reminderPolicy, err := h.reminderPolicy(r.Context(), appt.BusinessID, appt.ServiceID)
+	if err != nil {
+		h.logger.Error("reminder policy fetch failed", "err", err)
+		http.Error(w, "reminder policy unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	appt.ReminderChannels = policy.ResolveChannels(customerChannels, reminderPolicy.Channels)
+
 	id, err := h.repo.Create(ctx, tx, appt)
 	if err != nil {
 		if storage.IsConflict(err) {
@@ -231,21 +259,19 @@ func (h *BookingHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	now := time.Now().UTC()
-	offsets := h.defaults
-	if h.policy != nil {
-		if policyOffsets, err := h.policy.ReminderOffsets(r.Context(), appt.BusinessID); err == nil && len(policyOffsets) > 0 {
-			offsets = policyOffsets
-		} else if err != nil {
-			h.logger.Warn("policy offsets fetch failed; using defaults", "err", err)
-		}
-	}
-	for _, offset := range offsets {
+	for _, offset := range reminderPolicy.Offsets {
 		remindAt := appt.StartTime.Add(-offset)
 		if remindAt.Before(now) {
 			continue
 		}
-		h.enqueueReminder(ctx, tx, id, appt, remindAt, "email", appt.CustomerEmail)
-		h.enqueueReminder(ctx, tx, id, appt, remindAt, "sms", appt.CustomerPhone)
+		for _, channel := range appt.ReminderChannels {
+			switch channel {
+			case "email":
+				h.enqueueReminder(ctx, tx, id, appt, remindAt, "email", appt.CustomerEmail, reminderPolicy)
+			case "sms":
+				h.enqueueReminder(ctx, tx, id, appt, remindAt, "sms", appt.CustomerPhone, reminderPolicy)
+			}
+		}
 	}
 
 	respBody, err := json.Marshal(createBookingResponse{AppointmentID: id})
@@ -301,6 +327,28 @@ func (h *BookingHandler) enforceMonthlyAppointmentLimit(ctx context.Context, tx
 	return nil
 }
 
+// reminderPolicy resolves the policy for a new appointment. Only an unreachable
+// business service falls back to the configured defaults; when it answers with
+// an error the appointment is refused rather than reminded at the wrong offsets.
+func (h *BookingHandler) reminderPolicy(ctx context.Context, businessID, serviceID string) (policy.ReminderPolicy, error) {
+	out := policy.ReminderPolicy{Offsets: h.defaults}
+	if h.policy == nil {
+		return out, nil
+	}
+	p, err := h.policy.ReminderPolicy(ctx, businessID, serviceID)
+	if errors.Is(err, policy.ErrUnavailable) {
+		h.logger.Warn("policy source unavailable; using default offsets", "err", err)
+		return out, nil
+	}
+	if err != nil {
+		return policy.ReminderPolicy{}, err
+	}
+	if len(p.Offsets) == 0 {
+		p.Offsets = h.defaults
+	}
+	return p, nil
+}
+
 func (h *BookingHandler) Cancel(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -315,42 +363,80 @@ func (h *BookingHandler) Cancel(w http.ResponseWriter, r *http.Request) {
 	req.BusinessID = strings.TrimSpace(req.BusinessID)
 	req.AppointmentID = strings.TrimSpace(req.AppointmentID)
 	req.Reason = strings.TrimSpace(req.Reason)
+	businessID, ok := callerBusiness(r, req.BusinessID)
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	req.BusinessID = businessID
 	if req.BusinessID == "" || req.AppointmentID == "" {
 		http.Error(w, "business_id and appointment_id required", http.StatusBadRequest)
 		return
 	}
+	staffID, ok := staffScope(r)
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
 
-	ctx := r.Context()
-	tx, err := h.repo.Begin(ctx)
+	cancelledAt, err := h.cancelAppointment(r.Context(), req.BusinessID, staffID, req.AppointmentID, req.Reason)
 	if err != nil {
-		http.Error(w, "db error", http.StatusInternalServerError)
+		writeAppointmentError(w, err)
+		return
+	}
+	h.writeCancelResponse(w, req.AppointmentID, cancelledAt)
+}
+
+// appointmentError carries the HTTP status for a failed appointment state change,
+// so the public and internal endpoints answer the same way.
+type appointmentError struct {
+	status int
+	msg    string
+}
+
+func (e *appointmentError) Error() string { return e.msg }
+
+func writeAppointmentError(w http.ResponseWriter, err error) {
+	var ae *appointmentError
+	if errors.As(err, &ae) {
+		http.Error(w, ae.msg, ae.status)
 		return
 	}
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+// cancelAppointment cancels a booked appointment and emits
+// booking.appointment.cancelled.v1. Cancelling twice returns the original time.
+// A non-empty staffID restricts it to that staff member's appointments; others
+// answer "not found" so staff cannot probe their colleagues' bookings.
+func (h *BookingHandler) cancelAppointment(ctx context.Context, businessID, staffID, appointmentID, reason string) (time.Time, error) {
+	tx, err := h.repo.Begin(ctx)
+	if err != nil {
+		return time.Time{}, &appointmentError{http.StatusInternalServerError, "db error"}
+	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
-	appt, err := h.repo.GetAppointmentForUpdate(ctx, tx, req.BusinessID, req.AppointmentID)
+	appt, err := h.repo.GetAppointmentForUpdate(ctx, tx, businessID, appointmentID)
 	if err != nil {
 		if storage.IsNotFound(err) {
-			http.Error(w, "appointment not found", http.StatusNotFound)
-			return
+			return time.Time{}, &appointmentError{http.StatusNotFound, "appointment not found"}
 		}
-		http.Error(w, "failed to load appointment", http.StatusInternalServerError)
-		return
+		return time.Time{}, &appointmentError{http.StatusInternalServerError, "failed to load appointment"}
+	}
+	if staffID != "" && appt.StaffID != staffID {
+		return time.Time{}, &appointmentError{http.StatusNotFound, "appointment not found"}
 	}
 
 	if appt.Status == "cancelled" && appt.CancelledAt != nil {
-		h.writeCancelResponse(w, appt.ID, appt.CancelledAt.UTC())
-		return
+		return appt.CancelledAt.UTC(), nil
 	}
 	if appt.Status != "booked" {
-		http.Error(w, "appointment cannot be cancelled", http.StatusConflict)
-		return
+		return time.Time{}, &appointmentError{http.StatusConflict, "appointment cannot be cancelled"}
 	}
 
-	cancelledAt, err := h.repo.CancelAppointment(ctx, tx, req.BusinessID, appt.ID, req.Reason)
+	cancelledAt, err := h.repo.CancelAppointment(ctx, tx, businessID, appt.ID, reason)
 	if err != nil {
-		http.Error(w, "failed to cancel appointment", http.StatusInternalServerError)
-		return
+		return time.Time{}, &appointmentError{http.StatusInternalServerError, "failed to cancel appointment"}
 	}
 
 	cancelPayload, err := json.Marshal(map[string]any{
@@ -361,11 +447,10 @@ func (h *BookingHandler) Cancel(w http.ResponseWriter, r *http.Request) {
 		"start_time":     appt.StartTime.UTC().Format(time.RFC3339),
 		"end_time":       appt.EndTime.UTC().Format(time.RFC3339),
 		"cancelled_at":   cancelledAt.UTC().Format(time.RFC3339),
-		"reason":         req.Reason,
+		"reason":         reason,
 	})
 	if err != nil {
-		http.Error(w, "failed to build cancellation event", http.StatusInternalServerError)
-		return
+		return time.Time{}, &appointmentError{http.StatusInternalServerError, "failed to build cancellation event"}
 	}
 	if err := h.outboxRepo.Insert(ctx, tx, outbox.Event{
 		AggregateType: "appointment",
@@ -373,15 +458,13 @@ func (h *BookingHandler) Cancel(w http.ResponseWriter, r *http.Request) {
 		EventType:     "booking.appointment.cancelled.v1",
 		Payload:       cancelPayload,
 	}); err != nil {
-		http.Error(w, "failed to write outbox event", http.StatusInternalServerError)
-		return
+		return time.Time{}, &appointmentError{http.StatusInternalServerError, "failed to write outbox event"}
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		http.Error(w, "failed to commit", http.StatusInternalServerError)
-		return
+		return time.Time{}, &appointmentError{http.StatusInternalServerError, "failed to commit"}
 	}
-	h.writeCancelResponse(w, appt.ID, cancelledAt.UTC())
+	return cancelledAt.UTC(), nil
 }
 
 func (h *BookingHandler) List(w http.ResponseWriter, r *http.Request) {
@@ -399,6 +482,21 @@ func (h *BookingHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	staffID, ok := staffScope(r)
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if staffID == "" {
+		staffID = strings.TrimSpace(r.URL.Query().Get("staff_id"))
+	}
+	if staffID != "" {
+		if _, err := uuid.Parse(staffID); err != nil {
+			http.Error(w, "invalid staff_id", http.StatusBadRequest)
+			return
+		}
+	}
+
 	limit := 50
 	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
 		if n, err := strconv.Atoi(raw); err == nil && n > 0 && n <= 200 {
@@ -406,7 +504,7 @@ func (h *BookingHandler) List(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	appts, err := h.repo.ListByBusiness(r.Context(), businessID, limit)
+	appts, err := h.repo.ListByBusiness(r.Context(), businessID, staffID, limit)
 	if err != nil {
 		http.Error(w, "failed to list appointments", http.StatusInternalServerError)
 		return
@@ -439,6 +537,32 @@ func (h *BookingHandler) List(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(body)
 }
 
+// callerBusiness returns the business a request acts on. Behind the gateway
+// the caller's own business (from X-Business-Id, set from the JWT or API key)
+// wins and naming another one is refused; only admins act on others. Direct
+// calls without the header keep the requested business.
+func callerBusiness(r *http.Request, requested string) (string, bool) {
+	callerBusinessID := strings.TrimSpace(r.Header.Get("X-Business-Id"))
+	if callerBusinessID == "" || r.Header.Get("X-Role") == "admin" {
+		return requested, true
+	}
+	if requested != "" && requested != callerBusinessID {
+		return "", false
+	}
+	return callerBusinessID, true
+}
+
+// staffScope returns the staff member a "staff" caller is confined to (from the
+// gateway's X-Staff-Id), or "" for roles that see the whole business. ok is
+// false for a staff caller whose token carries no staff link.
+func staffScope(r *http.Request) (string, bool) {
+	if r.Header.Get("X-Role") != "staff" {
+		return "", true
+	}
+	staffID := strings.TrimSpace(r.Header.Get("X-Staff-Id"))
+	return staffID, staffID != ""
+}
+
 func (h *BookingHandler) Slots(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -618,22 +742,33 @@ func minMaxWindows(windows []availability.Interval) (time.Time, time.Time) {
 	return min, max
 }
 
-func (h *BookingHandler) enqueueReminder(ctx context.Context, tx pgx.Tx, appointmentID string, appt *model.Appointment, remindAt time.Time, channel string, recipient string) {
+func (h *BookingHandler) enqueueReminder(ctx context.Context, tx pgx.Tx, appointmentID string, appt *model.Appointment, remindAt time.Time, channel string, recipient string, reminderPolicy policy.ReminderPolicy) {
 	if strings.TrimSpace(recipient) == "" {
 		return
 	}
-	payload, err := json.Marshal(map[string]any{
-		"appointment_id": appointmentID,
-		"business_id":    appt.BusinessID,
-		"channel":        channel,
-		"recipient":      recipient,
-		"remind_at":      remindAt.UTC().Format(time.RFC3339),
+	fields := map[string]any{
+		"appointment_id":    appointmentID,
+		"business_id":       appt.BusinessID,
+		"channel":           channel,
+		"recipient":         recipient,
+		"remind_at":         remindAt.UTC().Format(time.RFC3339),
+		"appointment_start": appt.StartTime.UTC().Format(time.RFC3339),
 		"template_data": map[string]any{
 			"customer_name": appt.CustomerName,
 			"service_id":    appt.ServiceID,
 			"start_time":    appt.StartTime.UTC().Format(time.RFC3339),
 		},
-	})
+	}
+	// The scheduler applies quiet hours in the business timezone when the reminder comes due.
+	if qh := reminderPolicy.QuietHours; qh != nil {
+		fields["quiet_hours"] = map[string]any{
+			"start":    qh.Start,
+			"end":      qh.End,
+			"mode":     qh.Mode,
+			"timezone": reminderPolicy.Timezone,
+		}
+	}
+	payload, err := json.Marshal(fields)
 	if err != nil {
 		h.logger.Error("failed to build reminder payload", "err", err)
 		return
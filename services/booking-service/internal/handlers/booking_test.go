@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/services/booking-service/internal/policy"
+)
+
+const (
+	testBusinessID = "22222222-2222-2222-2222-222222222222"
+	testStaffID    = "44444444-4444-4444-4444-444444444444"
+)
+
+func bookingRequest(target, role, staffID, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("X-Business-Id", testBusinessID)
+	req.Header.Set("X-Role", role)
+	if staffID != "" {
+		req.Header.Set("X-Staff-Id", staffID)
+	}
+	return req
+}
+
+func TestCreatePinsCallerBusinessAndStaff(t *testing.T) {
+	// Scope checks run before any storage access, so no database is needed.
+	h := NewBookingHandler(nil, nil, slog.Default(), nil, nil, nil)
+	const foreignBusiness = "33333333-3333-3333-3333-333333333333"
+	body := func(businessID, staffID string) string {
+		return `{"business_id":"` + businessID + `","service_id":"55555555-5555-5555-5555-555555555555","staff_id":"` + staffID +
+			`","customer_name":"Ana","start_time":"2026-11-02T10:00:00Z","end_time":"2026-11-02T10:30:00Z"}`
+	}
+
+	for _, tc := range []struct {
+		name, role, staffHeader, body string
+	}{
+		// The gateway sets X-Business-Id from the key and X-Role to integration.
+		{"api key naming a foreign business", "integration", "", body(foreignBusiness, testStaffID)},
+		{"owner naming a foreign business", "owner", "", body(foreignBusiness, testStaffID)},
+		{"staff booking for someone else", "staff", testStaffID, body(testBusinessID, "66666666-6666-6666-6666-666666666666")},
+		{"staff without a staff link", "staff", "", body(testBusinessID, testStaffID)},
+	} {
+		rw := httptest.NewRecorder()
+		h.Create(rw, bookingRequest("/api/v1/appointments", tc.role, tc.staffHeader, tc.body))
+		if rw.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", tc.name, rw.Code)
+		}
+	}
+}
+
+func TestCancelPinsCallerBusiness(t *testing.T) {
+	h := NewBookingHandler(nil, nil, slog.Default(), nil, nil, nil)
+	rw := httptest.NewRecorder()
+	h.Cancel(rw, bookingRequest("/api/v1/appointments/cancel", "integration", "",
+		`{"business_id":"33333333-3333-3333-3333-333333333333","appointment_id":"77777777-7777-7777-7777-777777777777"}`))
+	if rw.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rw.Code)
+	}
+}
+
+func TestCallerBusiness(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	if got, ok := callerBusiness(req, "b1"); !ok || got != "b1" {
+		t.Fatalf("direct call: got %q, %v", got, ok)
+	}
+	req.Header.Set("X-Business-Id", testBusinessID)
+	req.Header.Set("X-Role", "receptionist")
+	if got, ok := callerBusiness(req, ""); !ok || got != testBusinessID {
+		t.Fatalf("omitted business: got %q, %v", got, ok)
+	}
+	req.Header.Set("X-Role", "admin")
+	if got, ok := callerBusiness(req, "b1"); !ok || got != "b1" {
+		t.Fatalf("admin: got %q, %v", got, ok)
+	}
+}
+
+type stubPolicy struct {
+	policy policy.ReminderPolicy
+	err    error
+}
+
+func (p stubPolicy) ReminderPolicy(context.Context, string, string) (policy.ReminderPolicy, error) {
+	return p.policy, p.err
+}
+
+func TestReminderPolicyFallsBackOnlyWhenUnreachable(t *testing.T) {
+	defaults := []time.Duration{24 * time.Hour}
+	resolve := func(p policy.Provider) (policy.ReminderPolicy, error) {
+		h := NewBookingHandler(nil, nil, slog.Default(), p, nil, defaults)
+		return h.reminderPolicy(context.Background(), testBusinessID, "")
+	}
+
+	got, err := resolve(stubPolicy{err: fmt.Errorf("%w: connection refused", policy.ErrUnavailable)})
+	if err != nil || len(got.Offsets) != 1 || got.Offsets[0] != 24*time.Hour {
+		t.Fatalf("unreachable: got %v, %v", got.Offsets, err)
+	}
+	if _, err := resolve(stubPolicy{err: errors.New("failed to load service reminder offsets")}); err == nil {
+		t.Fatal("a failing policy source must not fall back to the defaults")
+	}
+	got, err = resolve(stubPolicy{policy: policy.ReminderPolicy{Timezone: "UTC"}})
+	if err != nil || len(got.Offsets) != 1 || got.Timezone != "UTC" {
+		t.Fatalf("no offsets: got %+v, %v", got, err)
+	}
+}
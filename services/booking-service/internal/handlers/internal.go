@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/services/booking-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/booking-service/internal/storage"
+)
+
+const internalTokenHeader = "X-Internal-Token"
+
+// InternalHandler serves service-to-service appointment actions (e.g. SMS replies
+// relayed by notification-service). These routes are not proxied by the gateway;
+// callers authenticate with a shared token instead of a user JWT.
+type InternalHandler struct {
+	booking *BookingHandler
+	token   string
+}
+
+func NewInternalHandler(booking *BookingHandler, token string) *InternalHandler {
+	return &InternalHandler{booking: booking, token: strings.TrimSpace(token)}
+}
+
+type internalAppointmentRequest struct {
+	BusinessID    string `json:"business_id"`
+	AppointmentID string `json:"appointment_id"`
+	Reason        string `json:"reason,omitempty"`
+	Source        string `json:"source,omitempty"`
+}
+
+// Confirm marks an appointment as confirmed by the customer.
+func (h *InternalHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+	confirmedAt, err := h.booking.confirmAppointment(r.Context(), req.BusinessID, req.AppointmentID, req.Source)
+	if err != nil {
+		writeAppointmentError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"appointment_id": req.AppointmentID,
+		"status":         "confirmed",
+		"confirmed_at":   confirmedAt.Format(time.RFC3339),
+	})
+}
+
+// Cancel cancels an appointment on the customer's behalf.
+func (h *InternalHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+	cancelledAt, err := h.booking.cancelAppointment(r.Context(), req.BusinessID, "", req.AppointmentID, req.Reason)
+	if err != nil {
+		writeAppointmentError(w, err)
+		return
+	}
+	h.booking.writeCancelResponse(w, req.AppointmentID, cancelledAt)
+}
+
+func (h *InternalHandler) decode(w http.ResponseWriter, r *http.Request) (internalAppointmentRequest, bool) {
+	var req internalAppointmentRequest
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return req, false
+	}
+	if h.token == "" {
+		http.Error(w, "internal api not configured", http.StatusServiceUnavailable)
+		return req, false
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(internalTokenHeader)), []byte(h.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return req, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return req, false
+	}
+	req.BusinessID = strings.TrimSpace(req.BusinessID)
+	req.AppointmentID = strings.TrimSpace(req.AppointmentID)
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.Source = strings.TrimSpace(req.Source)
+	if req.BusinessID == "" || req.AppointmentID == "" {
+		http.Error(w, "business_id and appointment_id required", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// confirmAppointment records a customer confirmation and emits
+// booking.appointment.confirmed.v1 the first time only.
+func (h *BookingHandler) confirmAppointment(ctx context.Context, businessID, appointmentID, source string) (time.Time, error) {
+	tx, err := h.repo.Begin(ctx)
+	if err != nil {
+		return time.Time{}, &appointmentError{http.StatusInternalServerError, "db error"}
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	appt, err := h.repo.GetAppointmentForUpdate(ctx, tx, businessID, appointmentID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return time.Time{}, &appointmentError{http.StatusNotFound, "appointment not found"}
+		}
+		return time.Time{}, &appointmentError{http.StatusInternalServerError, "failed to load appointment"}
+	}
+	if appt.Status != "booked" {
+		return time.Time{}, &appointmentError{http.StatusConflict, "appointment cannot be confirmed"}
+	}
+	if appt.ConfirmedAt != nil {
+		return appt.ConfirmedAt.UTC(), nil
+	}
+
+	confirmedAt, err := h.repo.ConfirmAppointment(ctx, tx, businessID, appt.ID)
+	if err != nil {
+		return time.Time{}, &appointmentError{http.StatusInternalServerError, "failed to confirm appointment"}
+	}
+	payload, err := json.Marshal(map[string]any{
+		"appointment_id": appt.ID,
+		"business_id":    appt.BusinessID,
+		"staff_id":       appt.StaffID,
+		"service_id":     appt.ServiceID,
+		"start_time":     appt.StartTime.UTC().Format(time.RFC3339),
+		"confirmed_at":   confirmedAt.UTC().Format(time.RFC3339),
+		"source":         source,
+	})
+	if err != nil {
+		return time.Time{}, &appointmentError{http.StatusInternalServerError, "failed to build confirmation event"}
+	}
+	if err := h.outboxRepo.Insert(ctx, tx, outbox.Event{
+		AggregateType: "appointment",
+		AggregateID:   appt.ID,
+		EventType:     "booking.appointment.confirmed.v1",
+		Payload:       payload,
+	}); err != nil {
+		return time.Time{}, &appointmentError{http.StatusInternalServerError, "failed to write outbox event"}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return time.Time{}, &appointmentError{http.StatusInternalServerError, "failed to commit"}
+	}
+	return confirmedAt.UTC(), nil
+}
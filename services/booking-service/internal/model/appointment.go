@@ -15,5 +15,8 @@ type Appointment struct {
 	Status        string
 	CancelledAt   *time.Time
 	CancelReason  string
-	CreatedAt     time.Time
+	ConfirmedAt   *time.Time
+	// ReminderChannels lists the channels reminders go to (email, sms).
+	ReminderChannels []string
+	CreatedAt        time.Time
 }
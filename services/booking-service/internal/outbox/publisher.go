@@ -7,17 +7,29 @@ import (
 
 	"github.com/md-rashed-zaman/apptremind/libs/db"
 	"github.com/md-rashed-zaman/apptremind/libs/kafkax"
+	"github.com/md-rashed-zaman/apptremind/libs/metrics"
 	otelx "github.com/md-rashed-zaman/apptremind/libs/otel"
 	"github.com/segmentio/kafka-go"
 )
 
+var (
+	outboxBacklog = metrics.NewGauge("outbox_backlog_events",
+		"Outbox events not yet published to Kafka.")
+	outboxOldestAge = metrics.NewGauge("outbox_oldest_unpublished_age_seconds",
+		"Age of the oldest unpublished outbox event; 0 when the backlog is empty.")
+	outboxPublished = metrics.NewCounter("outbox_published_total",
+		"Outbox events written to Kafka by topic.", "topic")
+	outboxPublishErrors = metrics.NewCounter("outbox_publish_errors_total",
+		"Outbox publish batches that failed and will be retried.")
+)
+
 type Publisher struct {
-	pool        *db.Pool
-	repo        *Repository
-	logger      *slog.Logger
-	brokers     []string
-	pollEvery   time.Duration
-	batchSize   int
+	pool      *db.Pool
+	repo      *Repository
+	logger    *slog.Logger
+	brokers   []string
+	pollEvery time.Duration
+	batchSize int
 }
 
 type PublisherConfig struct {
@@ -65,8 +77,10 @@ func (p *Publisher) Run(ctx context.Context) {
 			return
 		case <-ticker.C:
 			if err := p.publishBatch(ctx, writer); err != nil {
+				outboxPublishErrors.Inc()
 				p.logger.Error("outbox publish failed", "err", err)
 			}
+			p.sampleBacklog(ctx)
 		}
 	}
 }
@@ -101,6 +115,7 @@ func (p *Publisher) publishBatch(ctx context.Context, writer *kafka.Writer) erro
 		if err := writer.WriteMessages(ctx, msg); err != nil {
 			return err
 		}
+		outboxPublished.Inc(r.EventType)
 	}
 
 	var ids []int64
@@ -113,3 +128,15 @@ func (p *Publisher) publishBatch(ctx context.Context, writer *kafka.Writer) erro
 
 	return tx.Commit(ctx)
 }
+
+func (p *Publisher) sampleBacklog(ctx context.Context) {
+	count, age, err := p.repo.Backlog(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			p.logger.Warn("outbox backlog sample failed", "err", err)
+		}
+		return
+	}
+	outboxBacklog.Set(float64(count))
+	outboxOldestAge.Set(age)
+}
@@ -2,11 +2,52 @@ package policy
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/reminder"
 )
 
+// QuietHours is a daily local-time window ("HH:MM") in which reminders should not fire.
+type QuietHours struct {
+	Start string
+	End   string
+	Mode  string // later | earlier
+}
+
+type ReminderPolicy struct {
+	Offsets  []time.Duration
+	Timezone string
+	// QuietHours is nil when the business has none configured.
+	QuietHours *QuietHours
+	// Channels is the business's default reminder channels; empty means all.
+	Channels []string
+}
+
+// ResolveChannels picks the customer's choice, then the business default, then
+// all channels. The all-channels result is a copy, so callers may modify it.
+func ResolveChannels(customer []string, businessDefault []string) []string {
+	if len(customer) > 0 {
+		return customer
+	}
+	if d, err := reminder.NormalizeChannels(businessDefault); err == nil && len(d) > 0 {
+		return d
+	}
+	return slices.Clone(reminder.AllChannels)
+}
+
+// ErrUnavailable means the policy source could not be reached at all. It is the
+// only error after which callers may fall back to their configured defaults;
+// any other error means the source answered and failed, and falling back would
+// schedule reminders at offsets the business did not ask for.
+var ErrUnavailable = errors.New("reminder policy source unavailable")
+
+// Provider resolves the reminder policy for a booking. Offsets come from the
+// service when it defines its own, otherwise from the business; callers fall
+// back to their configured defaults when none are returned.
 type Provider interface {
-	ReminderOffsets(ctx context.Context, businessID string) ([]time.Duration, error)
+	ReminderPolicy(ctx context.Context, businessID, serviceID string) (ReminderPolicy, error)
 }
 
 type staticProvider struct {
@@ -17,6 +58,6 @@ func NewStaticProvider(offsets []time.Duration) Provider {
 	return &staticProvider{offsets: offsets}
 }
 
-func (p *staticProvider) ReminderOffsets(_ context.Context, _ string) ([]time.Duration, error) {
-	return p.offsets, nil
+func (p *staticProvider) ReminderPolicy(_ context.Context, _ string, _ string) (ReminderPolicy, error) {
+	return ReminderPolicy{Offsets: p.offsets, Timezone: "UTC"}, nil
 }
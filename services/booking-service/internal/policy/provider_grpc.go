@@ -4,11 +4,14 @@ package policy
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/md-rashed-zaman/apptremind/libs/grpcx"
 	businessv1 "github.com/md-rashed-zaman/apptremind/protos/gen/business/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type grpcProvider struct {
@@ -32,17 +35,25 @@ func NewBusinessPolicyProvider(logger *slog.Logger, fallback []time.Duration, ad
 	return &grpcProvider{client: businessv1.NewBusinessServiceClient(conn)}, nil
 }
 
-func (p *grpcProvider) ReminderOffsets(ctx context.Context, businessID string) ([]time.Duration, error) {
-	resp, err := p.client.GetBusinessProfile(ctx, &businessv1.BusinessProfileRequest{BusinessId: businessID})
+func (p *grpcProvider) ReminderPolicy(ctx context.Context, businessID, serviceID string) (ReminderPolicy, error) {
+	resp, err := p.client.GetBusinessProfile(ctx, &businessv1.BusinessProfileRequest{BusinessId: businessID, ServiceId: serviceID})
 	if err != nil {
-		return nil, err
+		switch status.Code(err) {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+			return ReminderPolicy{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return ReminderPolicy{}, err
 	}
-	var offsets []time.Duration
-	for _, mins := range resp.GetReminderPolicy().GetReminderOffsetsMinutes() {
+	rp := resp.GetReminderPolicy()
+	out := ReminderPolicy{Timezone: rp.GetTimezone(), Channels: rp.GetReminderChannels()}
+	for _, mins := range rp.GetReminderOffsetsMinutes() {
 		if mins <= 0 {
 			continue
 		}
-		offsets = append(offsets, time.Duration(mins)*time.Minute)
+		out.Offsets = append(out.Offsets, time.Duration(mins)*time.Minute)
+	}
+	if qh := rp.GetQuietHours(); qh != nil && qh.GetStart() != "" && qh.GetEnd() != "" {
+		out.QuietHours = &QuietHours{Start: qh.GetStart(), End: qh.GetEnd(), Mode: qh.GetMode()}
 	}
-	return offsets, nil
+	return out, nil
 }
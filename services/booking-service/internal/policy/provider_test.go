@@ -0,0 +1,30 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveChannels(t *testing.T) {
+	cases := []struct {
+		name     string
+		customer []string
+		business []string
+		want     []string
+	}{
+		{"customer wins", []string{"email"}, []string{"sms"}, []string{"email"}},
+		{"business default", nil, []string{"sms"}, []string{"sms"}},
+		{"fallback to all", nil, nil, []string{"email", "sms"}},
+		{"invalid business default", nil, []string{"fax"}, []string{"email", "sms"}},
+	}
+	for _, tc := range cases {
+		if got := ResolveChannels(tc.customer, tc.business); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	all := ResolveChannels(nil, nil)
+	all[0] = "fax"
+	if again := ResolveChannels(nil, nil); again[0] != "email" {
+		t.Fatalf("callers must not be able to change the shared default, got %v", again)
+	}
+}
@@ -72,11 +72,11 @@ func (r *BookingRepository) Create(ctx context.Context, tx pgx.Tx, appt *model.A
 	var id string
 	err := tx.QueryRow(ctx, `
 		INSERT INTO appointments
-			(business_id, service_id, staff_id, customer_name, customer_email, customer_phone, start_time, end_time, status)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			(business_id, service_id, staff_id, customer_name, customer_email, customer_phone, start_time, end_time, status, reminder_channels)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id
 	`, appt.BusinessID, appt.ServiceID, appt.StaffID, appt.CustomerName, appt.CustomerEmail, appt.CustomerPhone,
-		appt.StartTime, appt.EndTime, appt.Status).Scan(&id)
+		appt.StartTime, appt.EndTime, appt.Status, appt.ReminderChannels).Scan(&id)
 	if err != nil {
 		return "", err
 	}
@@ -88,7 +88,7 @@ func (r *BookingRepository) GetAppointmentForUpdate(ctx context.Context, tx pgx.
 	var cancelledAt *time.Time
 	err := tx.QueryRow(ctx, `
 		SELECT id, business_id, service_id, staff_id, customer_name, customer_email, customer_phone,
-			start_time, end_time, status, cancelled_at, COALESCE(cancellation_reason, ''), created_at
+			start_time, end_time, status, cancelled_at, COALESCE(cancellation_reason, ''), created_at, confirmed_at
 		FROM appointments
 		WHERE id = $1 AND business_id = $2
 		FOR UPDATE
@@ -106,6 +106,7 @@ func (r *BookingRepository) GetAppointmentForUpdate(ctx context.Context, tx pgx.
 		&cancelledAt,
 		&appt.CancelReason,
 		&appt.CreatedAt,
+		&appt.ConfirmedAt,
 	)
 	if err != nil {
 		return model.Appointment{}, err
@@ -127,6 +128,18 @@ func (r *BookingRepository) CancelAppointment(ctx context.Context, tx pgx.Tx, bu
 	return cancelledAt, err
 }
 
+// ConfirmAppointment records the customer's confirmation; a repeat keeps the first timestamp.
+func (r *BookingRepository) ConfirmAppointment(ctx context.Context, tx pgx.Tx, businessID, appointmentID string) (time.Time, error) {
+	var confirmedAt time.Time
+	err := tx.QueryRow(ctx, `
+		UPDATE appointments
+		SET confirmed_at = COALESCE(confirmed_at, now())
+		WHERE id = $1 AND business_id = $2
+		RETURNING confirmed_at
+	`, appointmentID, businessID).Scan(&confirmedAt)
+	return confirmedAt, err
+}
+
 func (r *BookingRepository) ListBookedIntervals(ctx context.Context, businessID, staffID string, start, end time.Time) ([]model.Appointment, error) {
 	rows, err := r.pool.Query(ctx, `
 		SELECT id, business_id, service_id, staff_id, customer_name, customer_email, customer_phone,
@@ -174,7 +187,9 @@ func (r *BookingRepository) ListBookedIntervals(ctx context.Context, businessID,
 	return appts, nil
 }
 
-func (r *BookingRepository) ListByBusiness(ctx context.Context, businessID string, limit int) ([]model.Appointment, error) {
+// ListByBusiness returns the latest appointments of businessID, limited to one
+// staff member when staffID is non-empty.
+func (r *BookingRepository) ListByBusiness(ctx context.Context, businessID, staffID string, limit int) ([]model.Appointment, error) {
 	if limit <= 0 {
 		limit = 50
 	}
@@ -183,9 +198,10 @@ func (r *BookingRepository) ListByBusiness(ctx context.Context, businessID strin
 			start_time, end_time, status, cancelled_at, COALESCE(cancellation_reason, ''), created_at
 		FROM appointments
 		WHERE business_id = $1
+			AND (NULLIF($3, '') IS NULL OR staff_id = NULLIF($3, '')::uuid)
 		ORDER BY start_time DESC
 		LIMIT $2
-	`, businessID, limit)
+	`, businessID, limit, staffID)
 	if err != nil {
 		return nil, err
 	}
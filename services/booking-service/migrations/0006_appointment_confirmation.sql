@@ -0,0 +1,2 @@
+ALTER TABLE appointments
+    ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMPTZ;
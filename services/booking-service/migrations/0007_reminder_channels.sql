@@ -0,0 +1,3 @@
+-- Channels the customer (or the business default) chose for reminders at booking time.
+ALTER TABLE appointments
+    ADD COLUMN IF NOT EXISTS reminder_channels TEXT[];
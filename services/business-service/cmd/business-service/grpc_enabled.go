@@ -29,7 +29,7 @@ func startGrpcServer(ctx context.Context, logger *slog.Logger, pool *db.Pool, re
 
 	srv := grpc.NewServer(
 		grpc.StatsHandler(otelgrpc.NewServerHandler()),
-		grpc.ChainUnaryInterceptor(grpcx.UnaryServerRequestIDInterceptor()),
+		grpc.ChainUnaryInterceptor(grpcx.UnaryServerRequestIDInterceptor(), grpcx.UnaryServerMetricsInterceptor()),
 	)
 	grpcserver.Register(srv, pool, repo)
 
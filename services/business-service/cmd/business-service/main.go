@@ -11,6 +11,7 @@ import (
 	otelx "github.com/md-rashed-zaman/apptremind/libs/otel"
 	"github.com/md-rashed-zaman/apptremind/libs/runtime"
 	"github.com/md-rashed-zaman/apptremind/services/business-service/internal/handlers"
+	"github.com/md-rashed-zaman/apptremind/services/business-service/internal/outbox"
 	"github.com/md-rashed-zaman/apptremind/services/business-service/internal/storage"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
@@ -49,7 +50,16 @@ func main() {
 	defer pool.Close()
 
 	repo := storage.NewRepository(pool)
-	httpHandler := handlers.New(repo)
+	outboxRepo := outbox.NewRepository(pool)
+
+	outboxPublisher := outbox.NewPublisher(pool, outboxRepo, logger, outbox.PublisherConfig{
+		Brokers:   config.String("KAFKA_BROKERS", ""),
+		PollEvery: 2 * time.Second,
+		BatchSize: 50,
+	})
+	go outboxPublisher.Run(ctx)
+
+	httpHandler := handlers.New(repo, outboxRepo)
 
 	mux := runtime.NewBaseMuxWithReady(
 		runtime.ReadyCheck{Name: "db", Check: db.ReadyCheck(pool)},
@@ -76,6 +86,7 @@ func main() {
 		}
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	})
+	mux.HandleFunc("/api/v1/business/services/reminders", httpHandler.UpdateServiceReminders)
 	mux.HandleFunc("/api/v1/business/staff", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
 			httpHandler.CreateStaff(w, r)
@@ -116,6 +127,7 @@ func main() {
 	handler := httpx.Chain(mux,
 		httpx.WithRequestID,
 		httpx.WithAccessLog(logger),
+		httpx.WithMetrics,
 	)
 	handler = otelhttp.NewHandler(handler, "business")
 	srv := &http.Server{
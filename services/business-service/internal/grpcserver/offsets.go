@@ -0,0 +1,48 @@
+package grpcserver
+
+import (
+	"strconv"
+	"strings"
+)
+
+// resolveOffsets picks the reminder offsets for a booking: the service's own
+// offsets, then the business's, then the configured defaults. source names the
+// level that won ("service", "business" or "default").
+func resolveOffsets(defaults []int32, business []int, service []int) ([]int32, string) {
+	if out := positiveOffsets(service); len(out) > 0 {
+		return out, "service"
+	}
+	if out := positiveOffsets(business); len(out) > 0 {
+		return out, "business"
+	}
+	return defaults, "default"
+}
+
+func positiveOffsets(in []int) []int32 {
+	var out []int32
+	for _, v := range in {
+		if v > 0 {
+			out = append(out, int32(v))
+		}
+	}
+	return out
+}
+
+func parseOffsets(raw string) []int32 {
+	var out []int32
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		mins, err := strconv.Atoi(part)
+		if err != nil || mins <= 0 {
+			continue
+		}
+		out = append(out, int32(mins))
+	}
+	if len(out) == 0 {
+		out = []int32{1440}
+	}
+	return out
+}
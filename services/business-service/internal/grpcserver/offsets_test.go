@@ -0,0 +1,38 @@
+package grpcserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveOffsets(t *testing.T) {
+	defaults := []int32{1440, 60}
+	cases := []struct {
+		name       string
+		business   []int
+		service    []int
+		want       []int32
+		wantSource string
+	}{
+		{"service overrides business", []int{120}, []int{30, 15}, []int32{30, 15}, "service"},
+		{"service inherits business", []int{120}, nil, []int32{120}, "business"},
+		{"business without offsets uses defaults", nil, nil, []int32{1440, 60}, "default"},
+		{"service without offsets skips to defaults", nil, []int{}, []int32{1440, 60}, "default"},
+		{"non-positive values are ignored", []int{0, -5}, []int{-1}, []int32{1440, 60}, "default"},
+	}
+	for _, tc := range cases {
+		got, source := resolveOffsets(defaults, tc.business, tc.service)
+		if !reflect.DeepEqual(got, tc.want) || source != tc.wantSource {
+			t.Errorf("%s: got %v (%s), want %v (%s)", tc.name, got, source, tc.want, tc.wantSource)
+		}
+	}
+}
+
+func TestParseOffsets(t *testing.T) {
+	if got := parseOffsets(" 1440, x,60,-5,"); !reflect.DeepEqual(got, []int32{1440, 60}) {
+		t.Fatalf("got %v", got)
+	}
+	if got := parseOffsets(""); !reflect.DeepEqual(got, []int32{1440}) {
+		t.Fatalf("empty config: got %v", got)
+	}
+}
@@ -4,15 +4,17 @@ package grpcserver
 
 import (
 	"context"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/md-rashed-zaman/apptremind/libs/config"
 	"github.com/md-rashed-zaman/apptremind/libs/db"
 	businessv1 "github.com/md-rashed-zaman/apptremind/protos/gen/business/v1"
 	"github.com/md-rashed-zaman/apptremind/services/business-service/internal/storage"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -27,9 +29,18 @@ func Register(grpcServer *grpc.Server, pool *db.Pool, repo *storage.Repository)
 }
 
 func (s *server) GetBusinessProfile(ctx context.Context, req *businessv1.BusinessProfileRequest) (*businessv1.BusinessProfileResponse, error) {
-	offsets := parseOffsets(config.String("REMINDER_OFFSETS_MINUTES", "1440,60"))
+	serviceID := strings.TrimSpace(req.GetServiceId())
+	if serviceID != "" {
+		if _, err := uuid.Parse(serviceID); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "service_id must be a UUID")
+		}
+	}
+	defaults := parseOffsets(config.String("REMINDER_OFFSETS_MINUTES", "1440,60"))
 	timezone := config.String("TIMEZONE", "UTC")
 	name := "Demo Business"
+	var quiet *businessv1.QuietHours
+	var channels []string
+	var businessOffsets, serviceOffsets []int
 
 	if s.repo != nil && req.GetBusinessId() != "" {
 		p, err := s.repo.GetOrCreateProfile(ctx, req.GetBusinessId())
@@ -40,20 +51,26 @@ func (s *server) GetBusinessProfile(ctx context.Context, req *businessv1.Busines
 			if strings.TrimSpace(p.Name) != "" {
 				name = strings.TrimSpace(p.Name)
 			}
-			if len(p.OffsetsMins) > 0 {
-				offsets = nil
-				for _, v := range p.OffsetsMins {
-					if v <= 0 {
-						continue
-					}
-					offsets = append(offsets, int32(v))
-				}
-				if len(offsets) == 0 {
-					offsets = parseOffsets("1440,60")
+			if p.QuietHours.Start != "" && p.QuietHours.End != "" {
+				quiet = &businessv1.QuietHours{
+					Start: p.QuietHours.Start,
+					End:   p.QuietHours.End,
+					Mode:  p.QuietHours.Mode,
 				}
 			}
+			channels = p.Channels
+			businessOffsets = p.OffsetsMins
+		}
+		if serviceID != "" {
+			// Booking falls back to its default offsets only when this service is
+			// unreachable; an Internal error makes it refuse the appointment instead.
+			serviceOffsets, err = s.repo.GetServiceReminderOffsets(ctx, req.GetBusinessId(), serviceID)
+			if err != nil {
+				return nil, status.Error(codes.Internal, "failed to load service reminder offsets")
+			}
 		}
 	}
+	offsets, source := resolveOffsets(defaults, businessOffsets, serviceOffsets)
 
 	return &businessv1.BusinessProfileResponse{
 		BusinessId: req.BusinessId,
@@ -61,6 +78,9 @@ func (s *server) GetBusinessProfile(ctx context.Context, req *businessv1.Busines
 		ReminderPolicy: &businessv1.ReminderPolicy{
 			ReminderOffsetsMinutes: offsets,
 			Timezone:               timezone,
+			QuietHours:             quiet,
+			ReminderChannels:       channels,
+			OffsetsSource:          source,
 		},
 	}, nil
 }
@@ -235,22 +255,3 @@ func sortIntervals(in []interval) {
 		}
 	}
 }
-
-func parseOffsets(raw string) []int32 {
-	var out []int32
-	for _, part := range strings.Split(raw, ",") {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
-		}
-		mins, err := strconv.Atoi(part)
-		if err != nil || mins <= 0 {
-			continue
-		}
-		out = append(out, int32(mins))
-	}
-	if len(out) == 0 {
-		out = []int32{1440}
-	}
-	return out
-}
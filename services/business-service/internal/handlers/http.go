@@ -8,22 +8,47 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/md-rashed-zaman/apptremind/libs/reminder"
+	"github.com/md-rashed-zaman/apptremind/services/business-service/internal/outbox"
 	"github.com/md-rashed-zaman/apptremind/services/business-service/internal/storage"
 )
 
 type Handler struct {
-	repo *storage.Repository
+	repo   *storage.Repository
+	outbox *outbox.Repository
 }
 
-func New(repo *storage.Repository) *Handler {
-	return &Handler{repo: repo}
+func New(repo *storage.Repository, outboxRepo *outbox.Repository) *Handler {
+	return &Handler{repo: repo, outbox: outboxRepo}
 }
 
 func businessIDFromHeader(r *http.Request) string {
 	return strings.TrimSpace(r.Header.Get("X-Business-Id"))
 }
 
+// staffIDForRequest resolves which staff member a schedule request targets.
+// Staff users are confined to their own record (X-Staff-Id from the gateway);
+// owners and admins name one with ?staff_id=.
+func staffIDForRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	staffID := strings.TrimSpace(r.URL.Query().Get("staff_id"))
+	if r.Header.Get("X-Role") == "staff" {
+		own := strings.TrimSpace(r.Header.Get("X-Staff-Id"))
+		if own == "" || (staffID != "" && staffID != own) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return "", false
+		}
+		return own, true
+	}
+	if staffID == "" {
+		http.Error(w, "staff_id is required", http.StatusBadRequest)
+		return "", false
+	}
+	return staffID, true
+}
+
 func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -42,12 +67,24 @@ func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_ = json.NewEncoder(w).Encode(map[string]any{
+	resp := map[string]any{
 		"business_id":              p.BusinessID,
 		"name":                     p.Name,
 		"timezone":                 p.Timezone,
 		"reminder_offsets_minutes": p.OffsetsMins,
-	})
+		"quiet_hours":              nil,
+		"reminder_channels":        p.Channels,
+	}
+	if p.QuietHours.Start != "" {
+		resp["quiet_hours"] = quietHoursBody{Start: p.QuietHours.Start, End: p.QuietHours.End, Mode: p.QuietHours.Mode}
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+type quietHoursBody struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Mode  string `json:"mode,omitempty"`
 }
 
 func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
@@ -63,9 +100,11 @@ func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Name                   string `json:"name"`
-		Timezone               string `json:"timezone"`
-		ReminderOffsetsMinutes []int  `json:"reminder_offsets_minutes"`
+		Name                   string          `json:"name"`
+		Timezone               string          `json:"timezone"`
+		ReminderOffsetsMinutes []int           `json:"reminder_offsets_minutes"`
+		QuietHours             json.RawMessage `json:"quiet_hours"`
+		ReminderChannels       []string        `json:"reminder_channels"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid json body", http.StatusBadRequest)
@@ -77,25 +116,83 @@ func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		req.Timezone = "UTC"
 	}
 
-	var offsets []int
-	for _, v := range req.ReminderOffsetsMinutes {
-		if v <= 0 || v > 365*24*60 {
-			http.Error(w, "invalid reminder_offsets_minutes", http.StatusBadRequest)
-			return
-		}
-		offsets = append(offsets, v)
+	offsets, ok := validOffsets(req.ReminderOffsetsMinutes)
+	if !ok {
+		http.Error(w, "invalid reminder_offsets_minutes", http.StatusBadRequest)
+		return
 	}
 	if len(offsets) == 0 {
 		offsets = []int{1440, 60}
 	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		http.Error(w, "invalid timezone", http.StatusBadRequest)
+		return
+	}
+
+	// Omitting quiet_hours keeps the stored window; an explicit null clears it.
+	var quiet *storage.QuietHours
+	switch strings.TrimSpace(string(req.QuietHours)) {
+	case "":
+	case "null":
+		quiet = &storage.QuietHours{}
+	default:
+		var body quietHoursBody
+		if err := json.Unmarshal(req.QuietHours, &body); err != nil {
+			http.Error(w, "invalid quiet_hours", http.StatusBadRequest)
+			return
+		}
+		quiet = &storage.QuietHours{
+			Start: strings.TrimSpace(body.Start),
+			End:   strings.TrimSpace(body.End),
+			Mode:  strings.ToLower(strings.TrimSpace(body.Mode)),
+		}
+		if quiet.Mode == "" {
+			quiet.Mode = "later"
+		}
+		if !validClock(quiet.Start) || !validClock(quiet.End) || quiet.Start == quiet.End {
+			http.Error(w, "quiet_hours start and end must be distinct HH:MM times", http.StatusBadRequest)
+			return
+		}
+		if quiet.Mode != "later" && quiet.Mode != "earlier" {
+			http.Error(w, "quiet_hours mode must be later or earlier", http.StatusBadRequest)
+			return
+		}
+	}
 
-	if err := h.repo.UpdateProfile(r.Context(), businessID, req.Name, req.Timezone, offsets); err != nil {
+	channels, err := reminder.NormalizeChannels(req.ReminderChannels)
+	if err != nil {
+		http.Error(w, "reminder_channels must contain email and/or sms", http.StatusBadRequest)
+		return
+	}
+
+	err = h.withPolicyUpdate(r.Context(), businessID, func(tx pgx.Tx) error {
+		return h.repo.UpdateProfile(r.Context(), tx, businessID, req.Name, req.Timezone, offsets, quiet, channels)
+	})
+	if err != nil {
 		http.Error(w, "failed to update profile", http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// validOffsets checks reminder offsets are positive and at most a year.
+func validOffsets(in []int) ([]int, bool) {
+	var out []int
+	for _, v := range in {
+		if v <= 0 || v > 365*24*60 {
+			return nil, false
+		}
+		out = append(out, v)
+	}
+	return out, true
+}
+
+// validClock accepts a 24h "HH:MM" time of day.
+func validClock(v string) bool {
+	_, err := time.Parse("15:04", v)
+	return err == nil && len(v) == 5
+}
+
 func (h *Handler) CreateService(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -113,6 +210,8 @@ func (h *Handler) CreateService(w http.ResponseWriter, r *http.Request) {
 		DurationMins int     `json:"duration_minutes"`
 		Price        float64 `json:"price"`
 		Description  string  `json:"description"`
+		// Optional; omit to inherit the business reminder offsets.
+		ReminderOffsetsMinutes []int `json:"reminder_offsets_minutes"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid json body", http.StatusBadRequest)
@@ -124,8 +223,13 @@ func (h *Handler) CreateService(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "name and duration_minutes required", http.StatusBadRequest)
 		return
 	}
+	offsets, ok := validOffsets(req.ReminderOffsetsMinutes)
+	if !ok {
+		http.Error(w, "invalid reminder_offsets_minutes", http.StatusBadRequest)
+		return
+	}
 
-	id, err := h.repo.CreateService(r.Context(), businessID, req.Name, req.DurationMins, strconv.FormatFloat(req.Price, 'f', 2, 64), req.Description)
+	id, err := h.repo.CreateService(r.Context(), businessID, req.Name, req.DurationMins, strconv.FormatFloat(req.Price, 'f', 2, 64), req.Description, offsets)
 	if err != nil {
 		http.Error(w, "failed to create service", http.StatusInternalServerError)
 		return
@@ -160,6 +264,60 @@ func (h *Handler) ListServices(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(services)
 }
 
+// UpdateServiceReminders sets per-service reminder offsets
+// (PUT /api/v1/business/services/reminders?service_id=...). An empty or missing
+// list makes the service inherit the business offsets again.
+func (h *Handler) UpdateServiceReminders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	businessID := businessIDFromHeader(r)
+	if businessID == "" {
+		http.Error(w, "missing X-Business-Id", http.StatusBadRequest)
+		return
+	}
+	serviceID := strings.TrimSpace(r.URL.Query().Get("service_id"))
+	if serviceID == "" {
+		http.Error(w, "service_id required", http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(serviceID); err != nil {
+		http.Error(w, "service_id must be a UUID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ReminderOffsetsMinutes []int `json:"reminder_offsets_minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	offsets, ok := validOffsets(req.ReminderOffsetsMinutes)
+	if !ok {
+		http.Error(w, "invalid reminder_offsets_minutes", http.StatusBadRequest)
+		return
+	}
+
+	found := false
+	err := h.withPolicyUpdate(r.Context(), businessID, func(tx pgx.Tx) error {
+		var err error
+		found, err = h.repo.UpdateServiceReminderOffsets(r.Context(), tx, businessID, serviceID, offsets)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "failed to update service", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "service not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *Handler) CreateStaff(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -237,9 +395,8 @@ func (h *Handler) ListWorkingHours(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	staffID := strings.TrimSpace(r.URL.Query().Get("staff_id"))
-	if staffID == "" {
-		http.Error(w, "staff_id is required", http.StatusBadRequest)
+	staffID, ok := staffIDForRequest(w, r)
+	if !ok {
 		return
 	}
 
@@ -265,9 +422,8 @@ func (h *Handler) UpsertWorkingHours(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	staffID := strings.TrimSpace(r.URL.Query().Get("staff_id"))
-	if staffID == "" {
-		http.Error(w, "staff_id is required", http.StatusBadRequest)
+	staffID, ok := staffIDForRequest(w, r)
+	if !ok {
 		return
 	}
 
@@ -317,9 +473,8 @@ func (h *Handler) CreateTimeOff(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	staffID := strings.TrimSpace(r.URL.Query().Get("staff_id"))
-	if staffID == "" {
-		http.Error(w, "staff_id is required", http.StatusBadRequest)
+	staffID, ok := staffIDForRequest(w, r)
+	if !ok {
 		return
 	}
 
@@ -376,9 +531,8 @@ func (h *Handler) ListTimeOff(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	staffID := strings.TrimSpace(r.URL.Query().Get("staff_id"))
-	if staffID == "" {
-		http.Error(w, "staff_id is required", http.StatusBadRequest)
+	staffID, ok := staffIDForRequest(w, r)
+	if !ok {
 		return
 	}
 
@@ -430,7 +584,19 @@ func (h *Handler) DeleteTimeOff(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "id is required", http.StatusBadRequest)
 		return
 	}
-	if err := h.repo.DeleteTimeOff(r.Context(), businessID, id); err != nil {
+	// Owners may delete any entry in the business; staff only their own.
+	staffID := ""
+	if r.Header.Get("X-Role") == "staff" {
+		var ok bool
+		if staffID, ok = staffIDForRequest(w, r); !ok {
+			return
+		}
+	}
+	if err := h.repo.DeleteTimeOff(r.Context(), businessID, staffID, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "time off not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "failed to delete time off", http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/md-rashed-zaman/apptremind/libs/db/dbtest"
+	"github.com/md-rashed-zaman/apptremind/services/business-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/business-service/internal/storage"
+)
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	pool := dbtest.Open(t, "../../migrations")
+	return New(storage.NewRepository(pool), outbox.NewRepository(pool))
+}
+
+func businessRequest(method, target, businessID, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("X-Business-Id", businessID)
+	req.Header.Set("X-Role", "owner")
+	return req
+}
+
+func putProfile(t *testing.T, h *Handler, businessID, body string) {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	h.UpdateProfile(rw, businessRequest(http.MethodPut, "/api/v1/business/profile", businessID, body))
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("PUT %s: expected 204, got %d: %s", body, rw.Code, rw.Body.String())
+	}
+}
+
+func profileQuietHours(t *testing.T, h *Handler, businessID string) *quietHoursBody {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	h.GetProfile(rw, businessRequest(http.MethodGet, "/api/v1/business/profile", businessID, ""))
+	var out struct {
+		QuietHours *quietHoursBody `json:"quiet_hours"`
+	}
+	if err := json.Unmarshal(rw.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode profile: %v", err)
+	}
+	return out.QuietHours
+}
+
+func TestUpdateProfileQuietHoursOmittedKeepsNullClears(t *testing.T) {
+	h := newTestHandler(t)
+	businessID := uuid.NewString()
+
+	putProfile(t, h, businessID, `{"name":"Salon","timezone":"UTC","quiet_hours":{"start":"21:00","end":"08:00","mode":"earlier"}}`)
+	if qh := profileQuietHours(t, h, businessID); qh == nil || qh.Start != "21:00" || qh.End != "08:00" || qh.Mode != "earlier" {
+		t.Fatalf("unexpected quiet hours after set: %+v", qh)
+	}
+
+	putProfile(t, h, businessID, `{"name":"Salon renamed","timezone":"UTC"}`)
+	if qh := profileQuietHours(t, h, businessID); qh == nil || qh.Start != "21:00" || qh.Mode != "earlier" {
+		t.Fatalf("omitting quiet_hours changed them: %+v", qh)
+	}
+
+	putProfile(t, h, businessID, `{"name":"Salon renamed","timezone":"UTC","quiet_hours":null}`)
+	if qh := profileQuietHours(t, h, businessID); qh != nil {
+		t.Fatalf("explicit null did not clear quiet hours: %+v", qh)
+	}
+}
+
+func TestUpdateProfileRejectsInvalidInput(t *testing.T) {
+	// Validation runs before any storage access, so no database is needed.
+	h := New(nil, nil)
+	for _, body := range []string{
+		`{"quiet_hours":"21:00-08:00"}`,
+		`{"quiet_hours":{"start":"21:00","end":"21:00"}}`,
+		`{"quiet_hours":{"start":"9pm","end":"08:00"}}`,
+		`{"quiet_hours":{"start":"21:00","end":"08:00","mode":"sometimes"}}`,
+		`{"reminder_channels":["email","fax"]}`,
+	} {
+		rw := httptest.NewRecorder()
+		h.UpdateProfile(rw, businessRequest(http.MethodPut, "/api/v1/business/profile", uuid.NewString(), body))
+		if rw.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rw.Code)
+		}
+	}
+}
+
+func createTestService(t *testing.T, h *Handler, businessID string) string {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	h.CreateService(rw, businessRequest(http.MethodPost, "/api/v1/business/services", businessID, `{"name":"Consult","duration_minutes":30}`))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("create service: expected 201, got %d: %s", rw.Code, rw.Body.String())
+	}
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rw.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode service: %v", err)
+	}
+	return out.ID
+}
+
+func serviceOffsets(t *testing.T, h *Handler, businessID, serviceID string) []int {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	h.ListServices(rw, businessRequest(http.MethodGet, "/api/v1/business/services", businessID, ""))
+	var services []storage.BusinessService
+	if err := json.Unmarshal(rw.Body.Bytes(), &services); err != nil {
+		t.Fatalf("decode services: %v", err)
+	}
+	for _, s := range services {
+		if s.ID == serviceID {
+			return s.ReminderOffsetsMins
+		}
+	}
+	t.Fatalf("service %s not listed", serviceID)
+	return nil
+}
+
+func TestUpdateServiceReminders(t *testing.T) {
+	h := newTestHandler(t)
+	businessID := uuid.NewString()
+	serviceID := createTestService(t, h, businessID)
+	put := func(business, service, body string) int {
+		rw := httptest.NewRecorder()
+		h.UpdateServiceReminders(rw, businessRequest(http.MethodPut, "/api/v1/business/services/reminders?service_id="+service, business, body))
+		return rw.Code
+	}
+
+	if code := put(businessID, serviceID, `{"reminder_offsets_minutes":[2880,180]}`); code != http.StatusNoContent {
+		t.Fatalf("set: expected 204, got %d", code)
+	}
+	if got := serviceOffsets(t, h, businessID, serviceID); len(got) != 2 || got[0] != 2880 || got[1] != 180 {
+		t.Fatalf("unexpected offsets %v", got)
+	}
+	if code := put(uuid.NewString(), serviceID, `{"reminder_offsets_minutes":[30]}`); code != http.StatusNotFound {
+		t.Fatalf("other business: expected 404, got %d", code)
+	}
+	if code := put(businessID, uuid.NewString(), `{"reminder_offsets_minutes":[30]}`); code != http.StatusNotFound {
+		t.Fatalf("unknown service: expected 404, got %d", code)
+	}
+	if code := put(businessID, serviceID, `{"reminder_offsets_minutes":[]}`); code != http.StatusNoContent {
+		t.Fatalf("clear: expected 204, got %d", code)
+	}
+	if got := serviceOffsets(t, h, businessID, serviceID); got != nil {
+		t.Fatalf("expected the service to inherit again, got %v", got)
+	}
+}
+
+func TestUpdateServiceRemindersRejectsInvalidInput(t *testing.T) {
+	// Validation runs before any storage access, so no database is needed.
+	h := New(nil, nil)
+	for _, tc := range []struct{ name, serviceID, body string }{
+		{"missing service_id", "", `{}`},
+		{"service_id not a uuid", "1%20OR%201=1", `{}`},
+		{"negative offset", uuid.NewString(), `{"reminder_offsets_minutes":[-5]}`},
+	} {
+		rw := httptest.NewRecorder()
+		h.UpdateServiceReminders(rw, businessRequest(http.MethodPut, "/api/v1/business/services/reminders?service_id="+tc.serviceID, uuid.NewString(), tc.body))
+		if rw.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tc.name, rw.Code)
+		}
+	}
+}
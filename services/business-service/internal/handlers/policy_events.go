@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/services/business-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/business-service/internal/storage"
+)
+
+// withPolicyUpdate runs update in a transaction and, when it changed the
+// reminder policy snapshot, writes business.reminder_policy.updated.v1 so
+// scheduler-service can re-plan already scheduled reminders.
+func (h *Handler) withPolicyUpdate(ctx context.Context, businessID string, update func(tx pgx.Tx) error) error {
+	tx, err := h.repo.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	before, err := h.repo.LoadReminderPolicy(ctx, tx, businessID)
+	if err != nil {
+		return err
+	}
+	if err := update(tx); err != nil {
+		return err
+	}
+	after, err := h.repo.LoadReminderPolicy(ctx, tx, businessID)
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		payload, err := json.Marshal(struct {
+			storage.ReminderPolicySnapshot
+			UpdatedAt string `json:"updated_at"`
+		}{after, time.Now().UTC().Format(time.RFC3339Nano)})
+		if err != nil {
+			return err
+		}
+		if err := h.outbox.Insert(ctx, tx, outbox.Event{
+			AggregateType: "business",
+			AggregateID:   businessID,
+			EventType:     "business.reminder_policy.updated.v1",
+			Payload:       payload,
+		}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,8 @@
+package outbox
+
+type Event struct {
+	AggregateType string
+	AggregateID   string
+	EventType     string
+	Payload       []byte
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,6 +23,22 @@ type BusinessProfile struct {
 	Name        string
 	Timezone    string
 	OffsetsMins []int
+	QuietHours  QuietHours
+	// Channels are the default reminder channels ("email", "sms") used when the
+	// customer does not choose at booking time.
+	Channels []string
+}
+
+// QuietHours is a daily local-time window ("HH:MM") in which reminders are not sent.
+// Start == "" means the business has no quiet hours.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Mode  string `json:"mode"`
+}
+
+func (r *Repository) Begin(ctx context.Context) (pgx.Tx, error) {
+	return r.pool.Begin(ctx)
 }
 
 func (r *Repository) GetOrCreateProfile(ctx context.Context, businessID string) (BusinessProfile, error) {
@@ -37,45 +54,65 @@ func (r *Repository) GetOrCreateProfile(ctx context.Context, businessID string)
 
 	var p BusinessProfile
 	err = r.pool.QueryRow(ctx, `
-		SELECT business_id::text, name, timezone, reminder_offsets_minutes
+		SELECT business_id::text, name, timezone, reminder_offsets_minutes,
+			COALESCE(quiet_hours_start, ''), COALESCE(quiet_hours_end, ''), quiet_hours_mode, reminder_channels
 		FROM business_profiles
 		WHERE business_id = $1
-	`, businessID).Scan(&p.BusinessID, &p.Name, &p.Timezone, &p.OffsetsMins)
+	`, businessID).Scan(&p.BusinessID, &p.Name, &p.Timezone, &p.OffsetsMins, &p.QuietHours.Start, &p.QuietHours.End, &p.QuietHours.Mode, &p.Channels)
 	return p, err
 }
 
-func (r *Repository) UpdateProfile(ctx context.Context, businessID string, name string, timezone string, offsetsMins []int) error {
+// UpdateProfile replaces the profile. A nil quiet keeps the stored quiet hours;
+// a zero QuietHours clears them.
+func (r *Repository) UpdateProfile(ctx context.Context, tx pgx.Tx, businessID string, name string, timezone string, offsetsMins []int, quiet *QuietHours, channels []string) error {
 	if len(offsetsMins) == 0 {
 		offsetsMins = []int{1440, 60}
 	}
-	_, err := r.pool.Exec(ctx, `
-		INSERT INTO business_profiles (business_id, name, timezone, reminder_offsets_minutes)
-		VALUES ($1, $2, $3, $4)
+	if len(channels) == 0 {
+		channels = []string{"email", "sms"}
+	}
+	keepQuiet := quiet == nil
+	var q QuietHours
+	if quiet != nil {
+		q = *quiet
+	}
+	if q.Mode == "" {
+		q.Mode = "later"
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO business_profiles (business_id, name, timezone, reminder_offsets_minutes, quiet_hours_start, quiet_hours_end, quiet_hours_mode, reminder_channels)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8)
 		ON CONFLICT (business_id) DO UPDATE
 		SET name = EXCLUDED.name,
 			timezone = EXCLUDED.timezone,
 			reminder_offsets_minutes = EXCLUDED.reminder_offsets_minutes,
+			quiet_hours_start = CASE WHEN $9 THEN business_profiles.quiet_hours_start ELSE EXCLUDED.quiet_hours_start END,
+			quiet_hours_end = CASE WHEN $9 THEN business_profiles.quiet_hours_end ELSE EXCLUDED.quiet_hours_end END,
+			quiet_hours_mode = CASE WHEN $9 THEN business_profiles.quiet_hours_mode ELSE EXCLUDED.quiet_hours_mode END,
+			reminder_channels = EXCLUDED.reminder_channels,
 			updated_at = now()
-	`, businessID, name, timezone, offsetsMins)
+	`, businessID, name, timezone, offsetsMins, q.Start, q.End, q.Mode, channels, keepQuiet)
 	return err
 }
 
 type BusinessService struct {
-	ID           string
-	BusinessID   string
-	Name         string
-	DurationMins int
-	Price        string
-	Description  string
-	CreatedAt    time.Time
+	ID           string    `json:"id"`
+	BusinessID   string    `json:"business_id"`
+	Name         string    `json:"name"`
+	DurationMins int       `json:"duration_minutes"`
+	Price        string    `json:"price"`
+	Description  string    `json:"description"`
+	CreatedAt    time.Time `json:"created_at"`
+	// ReminderOffsetsMins overrides the business offsets for this service; nil inherits them.
+	ReminderOffsetsMins []int `json:"reminder_offsets_minutes"`
 }
 
-func (r *Repository) CreateService(ctx context.Context, businessID, name string, durationMinutes int, price string, description string) (string, error) {
+func (r *Repository) CreateService(ctx context.Context, businessID, name string, durationMinutes int, price string, description string, reminderOffsetsMins []int) (string, error) {
 	id := uuid.NewString()
 	_, err := r.pool.Exec(ctx, `
-		INSERT INTO business_services (id, business_id, name, duration_minutes, price, description)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, id, businessID, name, durationMinutes, price, description)
+		INSERT INTO business_services (id, business_id, name, duration_minutes, price, description, reminder_offsets_minutes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, id, businessID, name, durationMinutes, price, description, nullableInts(reminderOffsetsMins))
 	if err != nil {
 		return "", err
 	}
@@ -87,7 +124,7 @@ func (r *Repository) ListServices(ctx context.Context, businessID string, limit
 		limit = 100
 	}
 	rows, err := r.pool.Query(ctx, `
-		SELECT id::text, business_id::text, name, duration_minutes, price::text, description, created_at
+		SELECT id::text, business_id::text, name, duration_minutes, price::text, description, created_at, reminder_offsets_minutes
 		FROM business_services
 		WHERE business_id = $1
 		ORDER BY created_at DESC
@@ -101,7 +138,7 @@ func (r *Repository) ListServices(ctx context.Context, businessID string, limit
 	var out []BusinessService
 	for rows.Next() {
 		var s BusinessService
-		if err := rows.Scan(&s.ID, &s.BusinessID, &s.Name, &s.DurationMins, &s.Price, &s.Description, &s.CreatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.BusinessID, &s.Name, &s.DurationMins, &s.Price, &s.Description, &s.CreatedAt, &s.ReminderOffsetsMins); err != nil {
 			return nil, err
 		}
 		out = append(out, s)
@@ -122,6 +159,95 @@ func (r *Repository) GetServiceDuration(ctx context.Context, businessID, service
 	return mins, err
 }
 
+// GetServiceReminderOffsets returns the service's own offsets, or nil when it
+// inherits the business offsets (or does not exist).
+func (r *Repository) GetServiceReminderOffsets(ctx context.Context, businessID, serviceID string) ([]int, error) {
+	var offsets []int
+	err := r.pool.QueryRow(ctx, `
+		SELECT reminder_offsets_minutes
+		FROM business_services
+		WHERE business_id = $1 AND id = $2
+	`, businessID, serviceID).Scan(&offsets)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return offsets, err
+}
+
+// UpdateServiceReminderOffsets sets (or, with an empty slice, clears) a service's
+// offsets. It reports false when the service does not exist.
+func (r *Repository) UpdateServiceReminderOffsets(ctx context.Context, tx pgx.Tx, businessID, serviceID string, offsetsMins []int) (bool, error) {
+	tag, err := tx.Exec(ctx, `
+		UPDATE business_services
+		SET reminder_offsets_minutes = $3
+		WHERE business_id = $1 AND id = $2
+	`, businessID, serviceID, nullableInts(offsetsMins))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ReminderPolicySnapshot is everything that decides when a business's reminders
+// fire; it is the payload of business.reminder_policy.updated.v1.
+type ReminderPolicySnapshot struct {
+	BusinessID     string                  `json:"business_id"`
+	Timezone       string                  `json:"timezone"`
+	OffsetsMins    []int                   `json:"reminder_offsets_minutes"`
+	ServiceOffsets []ServiceReminderOffset `json:"service_reminder_offsets"`
+	QuietHours     *QuietHours             `json:"quiet_hours,omitempty"`
+}
+
+type ServiceReminderOffset struct {
+	ServiceID   string `json:"service_id"`
+	OffsetsMins []int  `json:"reminder_offsets_minutes"`
+}
+
+// LoadReminderPolicy reads the snapshot inside tx so it reflects uncommitted updates.
+// A business without a profile row gets the column defaults.
+func (r *Repository) LoadReminderPolicy(ctx context.Context, tx pgx.Tx, businessID string) (ReminderPolicySnapshot, error) {
+	snap := ReminderPolicySnapshot{BusinessID: businessID, Timezone: "UTC", OffsetsMins: []int{1440, 60}, ServiceOffsets: []ServiceReminderOffset{}}
+	var quiet QuietHours
+	err := tx.QueryRow(ctx, `
+		SELECT timezone, reminder_offsets_minutes,
+			COALESCE(quiet_hours_start, ''), COALESCE(quiet_hours_end, ''), quiet_hours_mode
+		FROM business_profiles
+		WHERE business_id = $1
+	`, businessID).Scan(&snap.Timezone, &snap.OffsetsMins, &quiet.Start, &quiet.End, &quiet.Mode)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return snap, err
+	}
+	if quiet.Start != "" && quiet.End != "" {
+		snap.QuietHours = &quiet
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT id::text, reminder_offsets_minutes
+		FROM business_services
+		WHERE business_id = $1 AND reminder_offsets_minutes IS NOT NULL
+		ORDER BY id
+	`, businessID)
+	if err != nil {
+		return snap, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var so ServiceReminderOffset
+		if err := rows.Scan(&so.ServiceID, &so.OffsetsMins); err != nil {
+			return snap, err
+		}
+		snap.ServiceOffsets = append(snap.ServiceOffsets, so)
+	}
+	return snap, rows.Err()
+}
+
+func nullableInts(v []int) []int {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
 type Staff struct {
 	ID         string
 	BusinessID string
@@ -344,14 +470,17 @@ func (r *Repository) ListTimeOff(ctx context.Context, businessID, staffID string
 	return out, nil
 }
 
-func (r *Repository) DeleteTimeOff(ctx context.Context, businessID, timeOffID string) error {
+// DeleteTimeOff removes one time-off entry of businessID. A non-empty staffID
+// additionally requires the entry to belong to that staff member.
+func (r *Repository) DeleteTimeOff(ctx context.Context, businessID, staffID, timeOffID string) error {
 	tag, err := r.pool.Exec(ctx, `
 		DELETE FROM staff_time_off t
 		USING staff s
 		WHERE t.staff_id = s.id
 		  AND s.business_id = $1
 		  AND t.id = $2
-	`, businessID, timeOffID)
+		  AND (NULLIF($3, '') IS NULL OR t.staff_id = NULLIF($3, '')::uuid)
+	`, businessID, timeOffID, staffID)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,4 @@
+ALTER TABLE business_profiles
+ADD COLUMN IF NOT EXISTS quiet_hours_start TEXT,
+ADD COLUMN IF NOT EXISTS quiet_hours_end TEXT,
+ADD COLUMN IF NOT EXISTS quiet_hours_mode TEXT NOT NULL DEFAULT 'later';
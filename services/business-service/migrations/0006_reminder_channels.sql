@@ -0,0 +1,2 @@
+ALTER TABLE business_profiles
+ADD COLUMN IF NOT EXISTS reminder_channels TEXT[] NOT NULL DEFAULT ARRAY['email', 'sms'];
@@ -0,0 +1,3 @@
+-- NULL means the service inherits business_profiles.reminder_offsets_minutes.
+ALTER TABLE business_services
+ADD COLUMN IF NOT EXISTS reminder_offsets_minutes INT[];
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -13,8 +15,11 @@ import (
 	"github.com/md-rashed-zaman/apptremind/libs/auth"
 	"github.com/md-rashed-zaman/apptremind/libs/config"
 	"github.com/md-rashed-zaman/apptremind/libs/httpx"
+	"github.com/md-rashed-zaman/apptremind/libs/metrics"
 	otelx "github.com/md-rashed-zaman/apptremind/libs/otel"
 	"github.com/md-rashed-zaman/apptremind/libs/runtime"
+	"github.com/md-rashed-zaman/apptremind/services/gateway-service/internal/apikeys"
+	"github.com/md-rashed-zaman/apptremind/services/gateway-service/internal/revocation"
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
@@ -28,6 +33,12 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	// Metrics name internal routes and traffic volumes, so they are served on
+	// a separate port that is not published, never on the public listener.
+	metricsPort, err := config.Port("METRICS_PORT", "9100")
+	if err != nil {
+		panic(err)
+	}
 	logger := runtime.NewLogger(service)
 
 	ctx, stop := runtime.SignalContext()
@@ -44,14 +55,30 @@ func main() {
 		}()
 	}
 
-	mux := runtime.NewBaseMuxWithReady()
-	jwtSecret := config.String("JWT_SECRET", "dev-secret")
-	jwksURL := config.String("JWKS_URL", "")
+	mux := runtime.NewHealthMux()
 	jwksTTL, err := strconv.Atoi(config.String("JWKS_CACHE_SECONDS", "300"))
 	if err != nil || jwksTTL <= 0 {
 		jwksTTL = 300
 	}
-	registerRoutes(mux, jwtSecret, jwksURL, time.Duration(jwksTTL)*time.Second)
+	verifier := &tokenVerifier{
+		secret:   config.String("JWT_SECRET", "dev-secret"),
+		issuer:   config.String("JWT_ISSUER", "apptremind-auth"),
+		audience: config.String("JWT_AUDIENCE", "apptremind-api"),
+		// A revoked token must not get through while the cache is down, so
+		// the gateway fails closed unless told otherwise.
+		failOpen: isTruthy(config.String("TOKEN_REVOCATION_FAIL_OPEN", "false")),
+		logger:   logger,
+	}
+	if jwksURL := config.String("JWKS_URL", ""); jwksURL != "" {
+		verifier.jwks = auth.NewJWKSClient(jwksURL, time.Duration(jwksTTL)*time.Second)
+	}
+	keyAuth := &apiKeyAuth{
+		client:       apikeys.NewClient(config.String("AUTH_URL", "http://auth-service:8081"), config.String("AUTH_INTERNAL_TOKEN", ""), time.Duration(envInt("API_KEY_CACHE_SECONDS", 30))*time.Second),
+		limiter:      apikeys.NewMemoryLimiter(),
+		defaultLimit: envInt("API_KEY_RATE_LIMIT_PER_MINUTE", 120),
+		logger:       logger,
+	}
+	registerRoutes(mux, verifier, keyAuth)
 
 	bodyLimit := int64(1 << 20) // 1MB
 	if v, err := strconv.Atoi(config.String("REQUEST_BODY_LIMIT_BYTES", "1048576")); err == nil && v > 0 {
@@ -68,6 +95,7 @@ func main() {
 	}
 
 	var rateLimitMW httpx.Middleware
+	var revocations revocation.Store
 	if addr := strings.TrimSpace(config.String("REDIS_ADDR", "")); addr != "" {
 		redisDB := 0
 		if v, err := strconv.Atoi(config.String("REDIS_DB", "0")); err == nil && v >= 0 {
@@ -83,10 +111,29 @@ func main() {
 		rl := httpx.NewRedisRateLimiter(rdb, limitPerMinute, time.Minute, config.String("RATE_LIMIT_PREFIX", "rl"))
 		rateLimitMW = rl.Middleware(logger, isTruthy(config.String("RATE_LIMIT_FAIL_OPEN", "true")))
 		logger.Info("rate limiting enabled (redis)", "per_minute", limitPerMinute, "redis_addr", addr)
+		revocations = revocation.NewRedisStore(rdb, config.String("TOKEN_REVOCATION_PREFIX", "apptremind:gateway:revoked"))
+		keyAuth.limiter = apikeys.NewRedisLimiter(rdb, config.String("API_KEY_RATE_LIMIT_PREFIX", "apptremind:gateway:apikey-rl"))
 	} else {
 		rl := httpx.NewRateLimiter(limitPerMinute, time.Minute)
 		rateLimitMW = rl.Middleware()
 		logger.Info("rate limiting enabled (in-memory)", "per_minute", limitPerMinute)
+		revocations = revocation.NewMemoryStore()
+	}
+
+	// Without Kafka there are no revocation events, and tokens are checked by
+	// signature, expiry, issuer and audience only.
+	if brokers := strings.TrimSpace(config.String("KAFKA_BROKERS", "")); brokers != "" {
+		replayMinutes := 60
+		if v, err := strconv.Atoi(config.String("TOKEN_REVOCATION_REPLAY_MINUTES", "60")); err == nil && v > 0 {
+			replayMinutes = v
+		}
+		verifier.revocations = revocations
+		go revocation.NewConsumer(logger, revocations, revocation.Config{
+			Brokers: brokers,
+			Topic:   config.String("KAFKA_TOKEN_REVOCATION_TOPIC", "auth.access_token.revoked.v1"),
+			Replay:  time.Duration(replayMinutes) * time.Minute,
+		}).Run(ctx)
+		logger.Info("token revocation enabled", "replay_minutes", replayMinutes)
 	}
 
 	handler := httpx.Chain(mux,
@@ -101,6 +148,9 @@ func main() {
 		httpx.WithAccessLog(logger),
 		httpx.WithBodyLimit(bodyLimit),
 		httpx.WithTimeout(requestTimeout),
+		// Inside the timeout, which copies the request; rate-limited requests never
+		// reach the mux and are counted under route "unmatched".
+		httpx.WithMetrics,
 		rateLimitMW,
 	)
 	handler = otelhttp.NewHandler(handler, "gateway")
@@ -110,12 +160,20 @@ func main() {
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
+	metricsSrv := newMetricsServer(":" + metricsPort)
+
 	go func() {
 		logger.Info("http server starting", "addr", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Error("http server error", "err", err)
 		}
 	}()
+	go func() {
+		logger.Info("metrics server starting", "addr", metricsSrv.Addr)
+		if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("metrics server error", "err", err)
+		}
+	}()
 
 	<-ctx.Done()
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -123,9 +181,21 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Error("http server shutdown error", "err", err)
 	}
+	_ = metricsSrv.Shutdown(shutdownCtx)
 	logger.Info("http server stopped")
 }
 
+// newMetricsServer serves /metrics on the internal listener.
+func newMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
 func isTruthy(s string) bool {
 	switch strings.TrimSpace(strings.ToLower(s)) {
 	case "1", "true", "t", "yes", "y", "on":
@@ -146,6 +216,14 @@ func parseList(raw string) []string {
 	return out
 }
 
+func envInt(key string, fallback int) int {
+	v, err := strconv.Atoi(config.String(key, strconv.Itoa(fallback)))
+	if err != nil || v <= 0 {
+		return fallback
+	}
+	return v
+}
+
 func corsMaxAgeSeconds() int {
 	value := 600
 	if v, err := strconv.Atoi(config.String("CORS_MAX_AGE_SECONDS", "600")); err == nil && v > 0 {
@@ -154,37 +232,60 @@ func corsMaxAgeSeconds() int {
 	return value
 }
 
-func registerRoutes(mux *http.ServeMux, jwtSecret string, jwksURL string, jwksTTL time.Duration) {
+func registerRoutes(mux *http.ServeMux, verifier *tokenVerifier, keyAuth *apiKeyAuth) {
 	authURL := mustParseURL(config.String("AUTH_URL", "http://auth-service:8081"))
 	businessURL := mustParseURL(config.String("BUSINESS_URL", "http://business-service:8082"))
 	bookingURL := mustParseURL(config.String("BOOKING_URL", "http://booking-service:8083"))
 	billingURL := mustParseURL(config.String("BILLING_URL", "http://billing-service:8084"))
+	notificationURL := mustParseURL(config.String("NOTIFICATION_URL", "http://notification-service:8085"))
+	schedulerURL := mustParseURL(config.String("SCHEDULER_URL", "http://scheduler-service:8087"))
 
 	authProxy := httputil.NewSingleHostReverseProxy(authURL)
 	businessProxy := httputil.NewSingleHostReverseProxy(businessURL)
 	bookingProxy := httputil.NewSingleHostReverseProxy(bookingURL)
 	billingProxy := httputil.NewSingleHostReverseProxy(billingURL)
+	notificationProxy := httputil.NewSingleHostReverseProxy(notificationURL)
+	schedulerProxy := httputil.NewSingleHostReverseProxy(schedulerURL)
 	otelTransport := otelhttp.NewTransport(http.DefaultTransport)
 	authProxy.Transport = otelTransport
 	businessProxy.Transport = otelTransport
 	bookingProxy.Transport = otelTransport
 	billingProxy.Transport = otelTransport
-
-	var jwksClient *auth.JWKSClient
-	if jwksURL != "" {
-		jwksClient = auth.NewJWKSClient(jwksURL, jwksTTL)
-	}
+	notificationProxy.Transport = otelTransport
+	schedulerProxy.Transport = otelTransport
 
 	registerProxy(mux, "/api/v1/auth", authProxy)
+	// Team management is owner/admin only; the emailed accept link carries a
+	// signed token instead of a JWT.
+	registerProxy(mux, "/api/v1/auth/invitations", requireAuth(requireRole(authProxy, "owner", "admin"), verifier))
+	registerProxy(mux, "/api/v1/auth/invitations/accept", authProxy)
+	registerProxy(mux, "/api/v1/auth/members", requireAuth(requireRole(authProxy, "owner", "admin"), verifier))
+	registerProxy(mux, "/api/v1/auth/admin", requireAuth(requireRole(authProxy, "admin"), verifier))
+	// The rest of /mfa is reached mid-login with an mfa_token, before any JWT exists.
+	registerProxy(mux, "/api/v1/auth/mfa/policy", requireAuth(requireRole(authProxy, "owner", "admin"), verifier))
+	registerProxy(mux, "/api/v1/auth/api-keys", requireAuth(requireRole(authProxy, "owner", "admin"), verifier))
+	// SSO start and callback run before any JWT exists; only the IdP settings are guarded.
+	registerProxy(mux, "/api/v1/auth/sso/config", requireAuth(requireRole(authProxy, "owner", "admin"), verifier))
 	registerProxy(mux, "/api/v1/public", bookingProxy)
-	registerProxy(mux, "/api/v1/business", requireAuth(requireRole(businessProxy, "owner", "admin"), jwtSecret, jwksClient))
-	registerProxy(mux, "/api/v1/appointments", requireAuth(bookingProxy, jwtSecret, jwksClient))
+	registerProxy(mux, "/api/v1/business", requireAuth(requireRole(businessProxy, "owner", "admin"), verifier))
+	// Staff manage their own schedule; everything else under /business stays owner/admin.
+	registerProxy(mux, "/api/v1/business/staff/working-hours", requireAuth(requireRole(requireOwnStaff(businessProxy), "owner", "admin", "staff"), verifier))
+	registerProxy(mux, "/api/v1/business/staff/time-off", requireAuth(requireRole(requireOwnStaff(businessProxy), "owner", "admin", "staff"), verifier))
+	// Integrations may call appointments with a business API key instead of a JWT.
+	registerProxy(mux, "/api/v1/appointments", acceptAPIKey(requireAuth(requireRole(requireOwnStaff(bookingProxy), "owner", "admin", "receptionist", "staff"), verifier), bookingProxy, keyAuth, "appointments"))
 	// Stripe needs to reach the webhook endpoint without a JWT; signature verification is the auth.
 	registerProxy(mux, "/api/v1/billing/webhooks/stripe", billingProxy)
 	// Checkout return page can poll this without a JWT.
 	registerProxy(mux, "/api/v1/billing/checkout/session", billingProxy)
 	registerProxy(mux, "/api/v1/billing/checkout/session/ack", billingProxy)
-	registerProxy(mux, "/api/v1/billing", requireAuth(requireRole(billingProxy, "owner", "admin"), jwtSecret, jwksClient))
+	registerProxy(mux, "/api/v1/billing", requireAuth(requireRole(billingProxy, "owner", "admin"), verifier))
+	// SMS/email providers post delivery callbacks without a JWT; HMAC signatures are the auth.
+	registerProxy(mux, "/api/v1/notifications/webhooks", notificationProxy)
+	// Unsubscribe links in emails carry a signed token instead of a JWT.
+	registerProxy(mux, "/api/v1/notifications/unsubscribe", notificationProxy)
+	registerProxy(mux, "/api/v1/notifications/suppressions", requireAuth(requireRole(notificationProxy, "owner", "admin"), verifier))
+	// Platform operators only; business owners never see other tenants' jobs.
+	registerProxy(mux, "/api/v1/admin/scheduler", requireAuth(requireRole(schedulerProxy, "admin"), verifier))
 	registerProxy(mux, "/.well-known/jwks.json", authProxy)
 
 	mux.HandleFunc("/billing/success", func(w http.ResponseWriter, r *http.Request) {
@@ -194,6 +295,11 @@ func registerRoutes(mux *http.ServeMux, jwtSecret string, jwksURL string, jwksTT
 		renderCheckoutReturnPage(w, r, "Payment canceled", "cancel")
 	})
 
+	mux.HandleFunc("/invite", renderInvitePage)
+	mux.HandleFunc("/reset-password", renderResetPasswordPage)
+	mux.HandleFunc("/verify-email", renderVerifyEmailPage)
+	mux.HandleFunc("/sso/callback", renderSSOCallbackPage)
+
 	mux.HandleFunc("/openapi", func(w http.ResponseWriter, _ *http.Request) {
 		data, err := openAPISpec.ReadFile("assets/gateway.v1.yaml")
 		if err != nil {
@@ -264,6 +370,179 @@ poll();
 	_, _ = w.Write([]byte(`</body></html>`))
 }
 
+// renderInvitePage is where invitation emails link to: it asks for a password
+// (a new one, or the current one for an existing account) and posts the token
+// to the accept endpoint.
+func renderInvitePage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`<!doctype html><html><head><meta charset="utf-8">`))
+	_, _ = w.Write([]byte(`<meta name="viewport" content="width=device-width, initial-scale=1">`))
+	_, _ = w.Write([]byte(`<title>Join your team</title>`))
+	_, _ = w.Write([]byte(`<style>body{font-family:system-ui,-apple-system,Segoe UI,Roboto,Ubuntu,Arial,sans-serif;margin:40px;max-width:480px;line-height:1.4}input,button{font-size:1em;padding:6px}</style>`))
+	_, _ = w.Write([]byte(`</head><body><h1>Join your team</h1>`))
+	if token == "" {
+		_, _ = w.Write([]byte(`<p>Missing <code>token</code> query parameter.</p></body></html>`))
+		return
+	}
+	_, _ = w.Write([]byte(`<form id="f"><p><label>Password <input id="pw" type="password" required></label></p>`))
+	_, _ = w.Write([]byte(`<p><small>New here? Choose a password. Already have an account? Use your current one.</small></p><button type="submit">Accept invitation</button></form><p id="status"></p>`))
+	_, _ = w.Write([]byte(`<script>
+const token = ` + "`" + htmlEscape(token) + "`" + `;
+document.getElementById('f').addEventListener('submit', async (e) => {
+  e.preventDefault();
+  const status = document.getElementById('status');
+  try {
+    const resp = await fetch('/api/v1/auth/invitations/accept', {
+      method: 'POST',
+      headers: {'Content-Type':'application/json'},
+      body: JSON.stringify({token: token, password: document.getElementById('pw').value}),
+    });
+    status.textContent = resp.ok ? 'Invitation accepted. You can now log in.' : 'Could not accept invitation (' + resp.status + ').';
+  } catch (err) {
+    status.textContent = 'error';
+  }
+});
+</script>`))
+	_, _ = w.Write([]byte(`</body></html>`))
+}
+
+// renderResetPasswordPage is where password reset emails link to: it asks for
+// the new password and posts it with the token.
+func renderResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`<!doctype html><html><head><meta charset="utf-8">`))
+	_, _ = w.Write([]byte(`<meta name="viewport" content="width=device-width, initial-scale=1">`))
+	_, _ = w.Write([]byte(`<title>Reset your password</title>`))
+	_, _ = w.Write([]byte(`<style>body{font-family:system-ui,-apple-system,Segoe UI,Roboto,Ubuntu,Arial,sans-serif;margin:40px;max-width:480px;line-height:1.4}input,button{font-size:1em;padding:6px}</style>`))
+	_, _ = w.Write([]byte(`</head><body><h1>Reset your password</h1>`))
+	if token == "" {
+		_, _ = w.Write([]byte(`<p>Missing <code>token</code> query parameter.</p></body></html>`))
+		return
+	}
+	_, _ = w.Write([]byte(`<form id="f"><p><label>New password <input id="pw" type="password" required></label></p>`))
+	_, _ = w.Write([]byte(`<button type="submit">Set password</button></form><p id="status"></p>`))
+	_, _ = w.Write([]byte(`<script>
+const token = ` + "`" + htmlEscape(token) + "`" + `;
+document.getElementById('f').addEventListener('submit', async (e) => {
+  e.preventDefault();
+  const status = document.getElementById('status');
+  try {
+    const resp = await fetch('/api/v1/auth/password/reset', {
+      method: 'POST',
+      headers: {'Content-Type':'application/json'},
+      body: JSON.stringify({token: token, password: document.getElementById('pw').value}),
+    });
+    status.textContent = resp.ok ? 'Password changed. Log in again on every device.' : 'Could not reset password (' + resp.status + ').';
+  } catch (err) {
+    status.textContent = 'error';
+  }
+});
+</script>`))
+	_, _ = w.Write([]byte(`</body></html>`))
+}
+
+// renderVerifyEmailPage is where verification emails link to: it confirms the
+// token as soon as the page loads.
+func renderVerifyEmailPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`<!doctype html><html><head><meta charset="utf-8">`))
+	_, _ = w.Write([]byte(`<meta name="viewport" content="width=device-width, initial-scale=1">`))
+	_, _ = w.Write([]byte(`<title>Confirm your email</title>`))
+	_, _ = w.Write([]byte(`<style>body{font-family:system-ui,-apple-system,Segoe UI,Roboto,Ubuntu,Arial,sans-serif;margin:40px;max-width:480px;line-height:1.4}</style>`))
+	_, _ = w.Write([]byte(`</head><body><h1>Confirm your email</h1>`))
+	if token == "" {
+		_, _ = w.Write([]byte(`<p>Missing <code>token</code> query parameter.</p></body></html>`))
+		return
+	}
+	_, _ = w.Write([]byte(`<p id="status">Confirming…</p>`))
+	_, _ = w.Write([]byte(`<script>
+const token = ` + "`" + htmlEscape(token) + "`" + `;
+(async () => {
+  const status = document.getElementById('status');
+  try {
+    const resp = await fetch('/api/v1/auth/email/verify', {
+      method: 'POST',
+      headers: {'Content-Type':'application/json'},
+      body: JSON.stringify({token: token}),
+    });
+    status.textContent = resp.ok ? 'Email address confirmed.' : 'Could not confirm email (' + resp.status + ').';
+  } catch (err) {
+    status.textContent = 'error';
+  }
+})();
+</script>`))
+	_, _ = w.Write([]byte(`</body></html>`))
+}
+
+// renderSSOCallbackPage is the redirect_uri registered with business identity
+// providers: it posts the code and state it was sent back with, along with the
+// binding cookie set by start and any signed-in session (needed to finish a
+// link), and keeps the resulting tokens in sessionStorage.
+func renderSSOCallbackPage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	code, state, idpError := q.Get("code"), q.Get("state"), q.Get("error")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// The code is single-use, but keep it out of Referer headers anyway.
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`<!doctype html><html><head><meta charset="utf-8">`))
+	_, _ = w.Write([]byte(`<meta name="viewport" content="width=device-width, initial-scale=1">`))
+	_, _ = w.Write([]byte(`<title>Signing you in</title>`))
+	_, _ = w.Write([]byte(`<style>body{font-family:system-ui,-apple-system,Segoe UI,Roboto,Ubuntu,Arial,sans-serif;margin:40px;max-width:480px;line-height:1.4}</style>`))
+	_, _ = w.Write([]byte(`</head><body><h1>Signing you in</h1>`))
+	if idpError != "" {
+		_, _ = w.Write([]byte(`<p>Your identity provider did not sign you in: <code>` + htmlEscape(idpError) + `</code>.</p></body></html>`))
+		return
+	}
+	if code == "" || state == "" {
+		_, _ = w.Write([]byte(`<p>Missing <code>code</code> or <code>state</code> query parameter.</p></body></html>`))
+		return
+	}
+	_, _ = w.Write([]byte(`<p id="status">Signing in…</p>`))
+	_, _ = w.Write([]byte(`<script>
+const code = ` + "`" + htmlEscape(code) + "`" + `;
+const state = ` + "`" + htmlEscape(state) + "`" + `;
+(async () => {
+  const status = document.getElementById('status');
+  try {
+    // Linking an identity must be finished by the account that started it.
+    const headers = {'Content-Type':'application/json'};
+    const current = JSON.parse(sessionStorage.getItem('apptremind.session') || 'null');
+    if (current && current.access_token) {
+      headers['Authorization'] = 'Bearer ' + current.access_token;
+    }
+    const resp = await fetch('/api/v1/auth/sso/callback', {
+      method: 'POST',
+      credentials: 'same-origin',
+      headers: headers,
+      body: JSON.stringify({code: code, state: state}),
+    });
+    if (!resp.ok) {
+      status.textContent = 'Could not sign in (' + resp.status + ').';
+      return;
+    }
+    const body = await resp.json();
+    if (body.mfa_token) {
+      status.textContent = 'Finish signing in with your authenticator app.';
+      sessionStorage.setItem('apptremind.mfa', JSON.stringify(body));
+      return;
+    }
+    sessionStorage.setItem('apptremind.session', JSON.stringify(body));
+    status.textContent = 'Signed in.';
+  } catch (err) {
+    status.textContent = 'error';
+  }
+})();
+</script>`))
+	_, _ = w.Write([]byte(`</body></html>`))
+}
+
 func htmlEscape(s string) string {
 	// Minimal escaping for our use case (query string reflected in HTML/JS).
 	s = strings.ReplaceAll(s, "&", "&amp;")
@@ -271,6 +550,10 @@ func htmlEscape(s string) string {
 	s = strings.ReplaceAll(s, ">", "&gt;")
 	s = strings.ReplaceAll(s, `"`, "&quot;")
 	s = strings.ReplaceAll(s, `'`, "&#39;")
+	// Tokens are also dropped into JS template literals.
+	s = strings.ReplaceAll(s, "`", "&#96;")
+	s = strings.ReplaceAll(s, `\`, "&#92;")
+	s = strings.ReplaceAll(s, "$", "&#36;")
 	return s
 }
 
@@ -291,7 +574,68 @@ func mustParseURL(raw string) *url.URL {
 	return u
 }
 
-func requireAuth(next http.Handler, jwtSecret string, jwksClient *auth.JWKSClient) http.Handler {
+// tokenVerifier checks access tokens: the signature (HS256, or RS256 keys from
+// JWKS), expiry, issuer and audience, and finally the revocation store.
+type tokenVerifier struct {
+	secret   string
+	jwks     *auth.JWKSClient
+	issuer   string
+	audience string
+	// revocations is nil when no revocation events are consumed.
+	revocations revocation.Store
+	// failOpen lets tokens through when the revocation store is unreachable.
+	failOpen bool
+	logger   *slog.Logger
+}
+
+var errTokenRevoked = errors.New("token revoked")
+
+func (v *tokenVerifier) verify(ctx context.Context, token string) (*auth.Claims, error) {
+	claims, err := v.verifySignature(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := claims.CheckIssuerAudience(v.issuer, v.audience); err != nil {
+		return nil, err
+	}
+	if v.revocations == nil {
+		return claims, nil
+	}
+	revoked, err := v.revocations.IsRevoked(ctx, claims.Sub, claims.ID, time.Unix(claims.Iat, 0))
+	if err != nil {
+		if v.logger != nil {
+			v.logger.Warn("token revocation check failed", "err", err)
+		}
+		if v.failOpen {
+			return claims, nil
+		}
+		return nil, err
+	}
+	if revoked {
+		return nil, errTokenRevoked
+	}
+	return claims, nil
+}
+
+func (v *tokenVerifier) verifySignature(token string) (*auth.Claims, error) {
+	if v.jwks == nil {
+		return auth.ParseAndVerifyHS256(token, v.secret)
+	}
+	header, err := auth.ParseHeader(token)
+	if err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" || header.Kid == "" {
+		return auth.ParseAndVerifyHS256(token, v.secret)
+	}
+	pub, err := v.jwks.Get(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	return auth.VerifyRS256(token, pub)
+}
+
+func requireAuth(next http.Handler, verifier *tokenVerifier) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if !strings.HasPrefix(authHeader, "Bearer ") || len(strings.TrimSpace(authHeader)) <= len("Bearer ") {
@@ -300,27 +644,10 @@ func requireAuth(next http.Handler, jwtSecret string, jwksClient *auth.JWKSClien
 		}
 
 		token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
-		var claims *auth.Claims
-		var err error
-
-		if jwksClient != nil {
-			header, err := auth.ParseHeader(token)
-			if err != nil {
-				http.Error(w, "invalid token header", http.StatusUnauthorized)
-				return
-			}
-			if header.Alg == "RS256" && header.Kid != "" {
-				pub, err := jwksClient.Get(header.Kid)
-				if err != nil {
-					http.Error(w, "invalid token key", http.StatusUnauthorized)
-					return
-				}
-				claims, err = auth.VerifyRS256(token, pub)
-			} else {
-				claims, err = auth.ParseAndVerifyHS256(token, jwtSecret)
-			}
-		} else {
-			claims, err = auth.ParseAndVerifyHS256(token, jwtSecret)
+		claims, err := verifier.verify(r.Context(), token)
+		if err == errTokenRevoked {
+			http.Error(w, "token revoked", http.StatusUnauthorized)
+			return
 		}
 		if err != nil {
 			http.Error(w, "invalid token", http.StatusUnauthorized)
@@ -330,13 +657,80 @@ func requireAuth(next http.Handler, jwtSecret string, jwksClient *auth.JWKSClien
 		r.Header.Del("X-User-Id")
 		r.Header.Del("X-Business-Id")
 		r.Header.Del("X-Role")
+		r.Header.Del("X-Staff-Id")
+		r.Header.Del("X-Api-Key-Id")
 		r.Header.Set("X-User-Id", claims.Sub)
 		r.Header.Set("X-Business-Id", claims.BusinessID)
 		r.Header.Set("X-Role", claims.Role)
+		if claims.StaffID != "" {
+			r.Header.Set("X-Staff-Id", claims.StaffID)
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// apiKeyAuth resolves "Authorization: ApiKey ..." headers and enforces each
+// key's rate limit (defaultLimit when the key sets none).
+type apiKeyAuth struct {
+	client       *apikeys.Client
+	limiter      apikeys.Limiter
+	defaultLimit int
+	logger       *slog.Logger
+}
+
+// acceptAPIKey sends requests carrying an API key to keyed after checking the
+// key's scope for resource; everything else goes to next (the JWT chain). A
+// keyed request gets X-Business-Id, X-Api-Key-Id and X-Role "integration",
+// which services treat like a role that sees the whole business.
+func acceptAPIKey(next http.Handler, keyed http.Handler, keyAuth *apiKeyAuth, resource string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if keyAuth == nil || !strings.HasPrefix(authHeader, "ApiKey ") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		raw := strings.TrimSpace(strings.TrimPrefix(authHeader, "ApiKey "))
+		key, err := keyAuth.client.Verify(r.Context(), raw)
+		if err == apikeys.ErrInvalidKey {
+			http.Error(w, "invalid api key", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			if keyAuth.logger != nil {
+				keyAuth.logger.Warn("api key verification failed", "err", err)
+			}
+			http.Error(w, "api key verification unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if !key.Allows(r.Method, resource) {
+			http.Error(w, "api key lacks scope "+apikeys.RequiredScope(r.Method, resource), http.StatusForbidden)
+			return
+		}
+		limit := keyAuth.defaultLimit
+		if key.RateLimitPerMinute != nil {
+			limit = *key.RateLimitPerMinute
+		}
+		allowed, err := keyAuth.limiter.Allow(r.Context(), key.ID, limit)
+		if err != nil && keyAuth.logger != nil {
+			// Like the global limiter, a store outage fails open.
+			keyAuth.logger.Warn("api key rate limiter error", "err", err)
+		}
+		if err == nil && !allowed {
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		r.Header.Del("Authorization")
+		r.Header.Del("X-User-Id")
+		r.Header.Del("X-Staff-Id")
+		r.Header.Set("X-Business-Id", key.BusinessID)
+		r.Header.Set("X-Role", "integration")
+		r.Header.Set("X-Api-Key-Id", key.ID)
+		keyed.ServeHTTP(w, r)
+	})
+}
+
 func requireRole(next http.Handler, roles ...string) http.Handler {
 	allowed := map[string]struct{}{}
 	for _, r := range roles {
@@ -352,3 +746,29 @@ func requireRole(next http.Handler, roles ...string) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requireOwnStaff pins "staff" users to their own staff record: a staff token
+// without a staff_id is refused, a staff_id query naming someone else is
+// refused, and a missing one is filled in. Other roles pass through untouched.
+// Services repeat the check; this keeps obviously foreign requests off them.
+func requireOwnStaff(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Role") != "staff" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		staffID := r.Header.Get("X-Staff-Id")
+		if staffID == "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		q := r.URL.Query()
+		if requested := strings.TrimSpace(q.Get("staff_id")); requested != "" && requested != staffID {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		q.Set("staff_id", staffID)
+		r.URL.RawQuery = q.Encode()
+		next.ServeHTTP(w, r)
+	})
+}
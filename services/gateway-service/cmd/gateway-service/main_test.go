@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/md-rashed-zaman/apptremind/libs/auth"
+	"github.com/md-rashed-zaman/apptremind/libs/runtime"
+	"github.com/md-rashed-zaman/apptremind/services/gateway-service/internal/apikeys"
+	"github.com/md-rashed-zaman/apptremind/services/gateway-service/internal/revocation"
 )
 
 func TestRequireRole(t *testing.T) {
@@ -59,7 +67,7 @@ func TestRequireAuthHS256(t *testing.T) {
 			return
 		}
 		w.WriteHeader(http.StatusOK)
-	}), secret, nil)
+	}), &tokenVerifier{secret: secret})
 
 	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -77,3 +85,335 @@ func TestRequireAuthHS256(t *testing.T) {
 		t.Fatalf("expected 401, got %d", rwBad.Code)
 	}
 }
+
+func TestRequireAuthChecksIssuerAudienceAndRevocation(t *testing.T) {
+	secret := "test-secret"
+	now := time.Now()
+	sign := func(jti string, iss string, iat time.Time) string {
+		token, err := auth.SignHS256(auth.Claims{
+			ID:         jti,
+			Issuer:     iss,
+			Audience:   "apptremind-api",
+			Sub:        "user-1",
+			BusinessID: "biz-1",
+			Role:       "owner",
+			Iat:        iat.Unix(),
+			Exp:        now.Add(time.Hour).Unix(),
+		}, secret)
+		if err != nil {
+			t.Fatalf("SignHS256 failed: %v", err)
+		}
+		return token
+	}
+
+	store := revocation.NewMemoryStore()
+	ctx := context.Background()
+	_ = store.RevokeToken(ctx, "revoked-jti", now.Add(time.Hour))
+	_ = store.RevokeUser(ctx, "user-1", now.Add(-time.Minute), now.Add(time.Hour))
+
+	h := requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), &tokenVerifier{secret: secret, issuer: "apptremind-auth", audience: "apptremind-api", revocations: store})
+
+	cases := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"valid", sign("live-jti", "apptremind-auth", now), http.StatusOK},
+		{"other issuer", sign("live-jti", "someone-else", now), http.StatusUnauthorized},
+		{"revoked jti", sign("revoked-jti", "apptremind-auth", now), http.StatusUnauthorized},
+		{"issued before user watermark", sign("old-jti", "apptremind-auth", now.Add(-10*time.Minute)), http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+			if rw.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rw.Code, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestAcceptAPIKey(t *testing.T) {
+	authSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		limit := 2
+		switch req["key"] {
+		case "ak_reader_secret":
+			_ = json.NewEncoder(w).Encode(apikeys.Key{ID: "key-1", BusinessID: "biz-1", Scopes: []string{"appointments:read"}, RateLimitPerMinute: &limit})
+		default:
+			http.Error(w, "invalid api key", http.StatusUnauthorized)
+		}
+	}))
+	defer authSrv.Close()
+
+	keyAuth := &apiKeyAuth{
+		client:       apikeys.NewClient(authSrv.URL, "internal-secret", time.Minute),
+		limiter:      apikeys.NewMemoryLimiter(),
+		defaultLimit: 100,
+	}
+	jwtChain := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	keyed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Business-Id") != "biz-1" || r.Header.Get("X-Role") != "integration" || r.Header.Get("X-Api-Key-Id") != "key-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("X-User-Id") != "" || r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	h := acceptAPIKey(jwtChain, keyed, keyAuth, "appointments")
+
+	cases := []struct {
+		name   string
+		method string
+		auth   string
+		want   int
+	}{
+		{"bearer goes to jwt chain", http.MethodGet, "Bearer abc", http.StatusTeapot},
+		{"read with read scope", http.MethodGet, "ApiKey ak_reader_secret", http.StatusOK},
+		{"write without write scope", http.MethodPost, "ApiKey ak_reader_secret", http.StatusForbidden},
+		{"unknown key", http.MethodGet, "ApiKey ak_other_secret", http.StatusUnauthorized},
+		{"second read within limit", http.MethodGet, "ApiKey ak_reader_secret", http.StatusOK},
+		{"per-key limit", http.MethodGet, "ApiKey ak_reader_secret", http.StatusTooManyRequests},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/api/v1/appointments", nil)
+			req.Header.Set("Authorization", tc.auth)
+			req.Header.Set("X-User-Id", "spoofed")
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+			if rw.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rw.Code, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminSchedulerRouteRequiresAdmin(t *testing.T) {
+	secret := "test-secret"
+	mux := http.NewServeMux()
+	registerRoutes(mux, &tokenVerifier{secret: secret}, nil)
+
+	token, err := auth.SignHS256(auth.Claims{
+		Sub:        "user-1",
+		BusinessID: "biz-1",
+		Role:       "owner",
+		Iat:        time.Now().Unix(),
+		Exp:        time.Now().Add(1 * time.Hour).Unix(),
+	}, secret)
+	if err != nil {
+		t.Fatalf("SignHS256 failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/admin/scheduler/jobs", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rw := httptest.NewRecorder()
+	mux.ServeHTTP(rw, req)
+	if rw.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for owner, got %d", rw.Code)
+	}
+}
+
+func TestTeamRoutesRequireOwner(t *testing.T) {
+	secret := "test-secret"
+	mux := http.NewServeMux()
+	registerRoutes(mux, &tokenVerifier{secret: secret}, nil)
+
+	token, err := auth.SignHS256(auth.Claims{
+		Sub:        "user-1",
+		BusinessID: "biz-1",
+		Role:       "receptionist",
+		Iat:        time.Now().Unix(),
+		Exp:        time.Now().Add(1 * time.Hour).Unix(),
+	}, secret)
+	if err != nil {
+		t.Fatalf("SignHS256 failed: %v", err)
+	}
+
+	for _, path := range []string{"/api/v1/auth/invitations", "/api/v1/auth/invitations/revoke", "/api/v1/auth/members", "/api/v1/auth/members/remove", "/api/v1/auth/admin/unlock", "/api/v1/auth/mfa/policy", "/api/v1/auth/api-keys", "/api/v1/auth/api-keys/33333333-3333-3333-3333-333333333333", "/api/v1/auth/sso/config"} {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com"+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, req)
+		if rw.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403 for receptionist, got %d", path, rw.Code)
+		}
+	}
+
+	// Accepting an invitation must not demand a JWT; the proxy is reached and
+	// fails upstream instead.
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/api/v1/auth/invitations/accept", nil)
+	rw := httptest.NewRecorder()
+	mux.ServeHTTP(rw, req)
+	if rw.Code == http.StatusUnauthorized || rw.Code == http.StatusForbidden {
+		t.Fatalf("accept route should be public, got %d", rw.Code)
+	}
+}
+
+func TestRequireOwnStaff(t *testing.T) {
+	var gotQuery string
+	h := requireOwnStaff(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query().Get("staff_id")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	cases := []struct {
+		name      string
+		role      string
+		staffID   string
+		query     string
+		wantCode  int
+		wantQuery string
+	}{
+		{name: "owner untouched", role: "owner", query: "?staff_id=s2", wantCode: http.StatusOK, wantQuery: "s2"},
+		{name: "staff filled in", role: "staff", staffID: "s1", wantCode: http.StatusOK, wantQuery: "s1"},
+		{name: "staff own id", role: "staff", staffID: "s1", query: "?staff_id=s1", wantCode: http.StatusOK, wantQuery: "s1"},
+		{name: "staff other id", role: "staff", staffID: "s1", query: "?staff_id=s2", wantCode: http.StatusForbidden},
+		{name: "staff without link", role: "staff", wantCode: http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotQuery = ""
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/appointments"+tc.query, nil)
+			req.Header.Set("X-Role", tc.role)
+			if tc.staffID != "" {
+				req.Header.Set("X-Staff-Id", tc.staffID)
+			}
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+			if rw.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d", tc.wantCode, rw.Code)
+			}
+			if gotQuery != tc.wantQuery {
+				t.Fatalf("staff_id = %q, want %q", gotQuery, tc.wantQuery)
+			}
+		})
+	}
+}
+
+func TestStaffDeniedBusinessSettingsAndBilling(t *testing.T) {
+	secret := "test-secret"
+	mux := http.NewServeMux()
+	registerRoutes(mux, &tokenVerifier{secret: secret}, nil)
+
+	token, err := auth.SignHS256(auth.Claims{
+		Sub:        "user-1",
+		BusinessID: "biz-1",
+		Role:       "staff",
+		StaffID:    "staff-1",
+		Iat:        time.Now().Unix(),
+		Exp:        time.Now().Add(1 * time.Hour).Unix(),
+	}, secret)
+	if err != nil {
+		t.Fatalf("SignHS256 failed: %v", err)
+	}
+
+	for _, path := range []string{"/api/v1/business/profile", "/api/v1/business/staff", "/api/v1/billing/subscription", "/api/v1/auth/members"} {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, req)
+		if rw.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403 for staff, got %d", path, rw.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/business/staff/time-off?staff_id=staff-2", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rw := httptest.NewRecorder()
+	mux.ServeHTTP(rw, req)
+	if rw.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another staff member's time off, got %d", rw.Code)
+	}
+}
+
+func TestTokenPagesEscapeToken(t *testing.T) {
+	const evil = "x`;alert(1);`${document.cookie}"
+	for name, render := range map[string]http.HandlerFunc{
+		"invite":         renderInvitePage,
+		"reset-password": renderResetPasswordPage,
+		"verify-email":   renderVerifyEmailPage,
+	} {
+		rw := httptest.NewRecorder()
+		render(rw, httptest.NewRequest(http.MethodGet, "/"+name+"?token="+url.QueryEscape(evil), nil))
+		body := rw.Body.String()
+		if strings.Contains(body, "x`") || strings.Contains(body, "${document") {
+			t.Fatalf("%s page reflects the token unescaped:\n%s", name, body)
+		}
+	}
+	for _, query := range []string{"code=" + url.QueryEscape(evil) + "&state=" + url.QueryEscape(evil), "error=" + url.QueryEscape(evil)} {
+		rw := httptest.NewRecorder()
+		renderSSOCallbackPage(rw, httptest.NewRequest(http.MethodGet, "/sso/callback?"+query, nil))
+		body := rw.Body.String()
+		if strings.Contains(body, "x`") || strings.Contains(body, "${document") {
+			t.Fatalf("sso callback page reflects %s unescaped:\n%s", query, body)
+		}
+	}
+}
+
+type unreachableStore struct{ revocation.Store }
+
+func (unreachableStore) IsRevoked(context.Context, string, string, time.Time) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestRevocationStoreOutage(t *testing.T) {
+	token, err := auth.SignHS256(auth.Claims{
+		ID:       "live-jti",
+		Issuer:   "apptremind-auth",
+		Audience: "apptremind-api",
+		Sub:      "user-1",
+		Role:     "owner",
+		Iat:      time.Now().Unix(),
+		Exp:      time.Now().Add(time.Hour).Unix(),
+	}, "test-secret")
+	if err != nil {
+		t.Fatalf("SignHS256 failed: %v", err)
+	}
+	for _, tc := range []struct {
+		name     string
+		failOpen bool
+		want     int
+	}{
+		{"fail closed", false, http.StatusUnauthorized},
+		{"fail open", true, http.StatusOK},
+	} {
+		h := requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}), &tokenVerifier{secret: "test-secret", issuer: "apptremind-auth", audience: "apptremind-api", revocations: unreachableStore{}, failOpen: tc.failOpen})
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		if rw.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, rw.Code)
+		}
+	}
+}
+
+func TestMetricsOnlyOnInternalListener(t *testing.T) {
+	public := runtime.NewHealthMux()
+	registerRoutes(public, &tokenVerifier{secret: "test-secret"}, nil)
+	rw := httptest.NewRecorder()
+	public.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://example.com/metrics", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("public listener served /metrics: %d", rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	newMetricsServer(":0").Handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://example.com/metrics", nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("internal listener: expected 200, got %d", rw.Code)
+	}
+}
@@ -0,0 +1,143 @@
+// Package apikeys authenticates business API keys at the gateway by asking
+// auth-service, and applies their per-key rate limits.
+package apikeys
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidKey = errors.New("invalid api key")
+
+// Key is what auth-service reports about a live key.
+type Key struct {
+	ID                 string   `json:"key_id"`
+	BusinessID         string   `json:"business_id"`
+	Scopes             []string `json:"scopes"`
+	RateLimitPerMinute *int     `json:"rate_limit_per_minute,omitempty"`
+}
+
+// Allows reports whether the key may call resource with method: reads need
+// "<resource>:read", everything else "<resource>:write".
+func (k Key) Allows(method string, resource string) bool {
+	return k.HasScope(RequiredScope(method, resource))
+}
+
+func (k Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func RequiredScope(method string, resource string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return resource + ":read"
+	default:
+		return resource + ":write"
+	}
+}
+
+// Client calls auth-service's internal verify endpoint. Answers, including
+// rejections, are cached for ttl, which bounds how long a revoked key keeps
+// working.
+type Client struct {
+	baseURL string
+	token   string
+	ttl     time.Duration
+	http    *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	key       Key
+	err       error
+	expiresAt time.Time
+}
+
+// maxCached bounds the cache; it is cleared when full.
+const maxCached = 10000
+
+func NewClient(baseURL string, token string, ttl time.Duration) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(strings.TrimSpace(baseURL), "/"),
+		token:   strings.TrimSpace(token),
+		ttl:     ttl,
+		http: &http.Client{
+			Timeout: 3 * time.Second,
+		},
+		cache: map[string]cached{},
+	}
+}
+
+// Verify returns the key raw belongs to, or ErrInvalidKey.
+func (c *Client) Verify(ctx context.Context, raw string) (Key, error) {
+	sum := sha256.Sum256([]byte(raw))
+	id := hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	hit, ok := c.cache[id]
+	c.mu.Unlock()
+	if ok && time.Now().Before(hit.expiresAt) {
+		return hit.key, hit.err
+	}
+
+	key, err := c.fetch(ctx, raw)
+	if err != nil && err != ErrInvalidKey {
+		return Key{}, err
+	}
+	if c.ttl > 0 {
+		c.mu.Lock()
+		if len(c.cache) >= maxCached {
+			c.cache = map[string]cached{}
+		}
+		c.cache[id] = cached{key: key, err: err, expiresAt: time.Now().Add(c.ttl)}
+		c.mu.Unlock()
+	}
+	return key, err
+}
+
+func (c *Client) fetch(ctx context.Context, raw string) (Key, error) {
+	body, err := json.Marshal(map[string]string{"key": raw})
+	if err != nil {
+		return Key{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/internal/v1/api-keys/verify", bytes.NewReader(body))
+	if err != nil {
+		return Key{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Token", c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Key{}, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		var key Key
+		if err := json.NewDecoder(resp.Body).Decode(&key); err != nil {
+			return Key{}, err
+		}
+		return key, nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusBadRequest:
+		return Key{}, ErrInvalidKey
+	default:
+		return Key{}, fmt.Errorf("api key verify: unexpected status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,71 @@
+package apikeys
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientVerifiesAndCaches(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/internal/v1/api-keys/verify" || r.Header.Get("X-Internal-Token") != "internal-secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["key"] != "ak_good_secret" {
+			http.Error(w, "invalid api key", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Key{ID: "key-1", BusinessID: "biz-1", Scopes: []string{"appointments:read"}})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "internal-secret", time.Minute)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		key, err := c.Verify(ctx, "ak_good_secret")
+		if err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		if key.BusinessID != "biz-1" || !key.Allows(http.MethodGet, "appointments") || key.Allows(http.MethodPost, "appointments") {
+			t.Fatalf("unexpected key %+v", key)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Verify(ctx, "ak_bad_secret"); err != ErrInvalidKey {
+			t.Fatalf("expected ErrInvalidKey, got %v", err)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected one call per distinct key, got %d", n)
+	}
+
+	down := NewClient("http://127.0.0.1:1", "internal-secret", time.Minute)
+	if _, err := down.Verify(ctx, "ak_good_secret"); err == nil || err == ErrInvalidKey {
+		t.Fatalf("expected a transport error, got %v", err)
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	l := NewMemoryLimiter()
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow(ctx, "key-1", 3); !ok {
+			t.Fatalf("request %d refused", i+1)
+		}
+	}
+	if ok, _ := l.Allow(ctx, "key-1", 3); ok {
+		t.Fatal("fourth request allowed")
+	}
+	if ok, _ := l.Allow(ctx, "key-2", 3); !ok {
+		t.Fatal("limits leaked between keys")
+	}
+}
@@ -0,0 +1,75 @@
+package apikeys
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limiter counts requests per key in one-minute fixed windows.
+type Limiter interface {
+	Allow(ctx context.Context, keyID string, perMinute int) (bool, error)
+}
+
+// MemoryLimiter is per gateway replica; use RedisLimiter when running several.
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*window
+}
+
+type window struct {
+	count   int
+	resetAt time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{windows: map[string]*window{}}
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, keyID string, perMinute int) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	w := l.windows[keyID]
+	if w == nil || now.After(w.resetAt) {
+		l.windows[keyID] = &window{count: 1, resetAt: now.Add(time.Minute)}
+		return true, nil
+	}
+	if w.count >= perMinute {
+		return false, nil
+	}
+	w.count++
+	return true, nil
+}
+
+// RedisLimiter shares the per-key counters between gateway replicas.
+type RedisLimiter struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+func NewRedisLimiter(rdb *redis.Client, prefix string) *RedisLimiter {
+	if prefix == "" {
+		prefix = "apikey-rl"
+	}
+	return &RedisLimiter{rdb: rdb, prefix: prefix}
+}
+
+var redisKeyWindowScript = redis.NewScript(`
+local current = redis.call("INCR", KEYS[1])
+if current == 1 then
+  redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return current
+`)
+
+func (l *RedisLimiter) Allow(ctx context.Context, keyID string, perMinute int) (bool, error) {
+	count, err := redisKeyWindowScript.Run(ctx, l.rdb, []string{l.prefix + ":" + keyID}, time.Minute.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return count <= int64(perMinute), nil
+}
@@ -0,0 +1,101 @@
+package revocation
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/kafkax"
+	"github.com/segmentio/kafka-go"
+)
+
+type Config struct {
+	Brokers string
+	Topic   string
+	// Replay is how far back to read on start-up; it should cover the
+	// access-token lifetime so a new replica learns of live revocations.
+	Replay time.Duration
+}
+
+// Consumer applies revocation events to a Store. It reads every partition
+// without a consumer group: with a MemoryStore each replica must see every
+// event, and replays are idempotent either way.
+type Consumer struct {
+	cfg    Config
+	store  Store
+	logger *slog.Logger
+}
+
+func NewConsumer(logger *slog.Logger, store Store, cfg Config) *Consumer {
+	if cfg.Replay <= 0 {
+		cfg.Replay = time.Hour
+	}
+	return &Consumer{cfg: cfg, store: store, logger: logger}
+}
+
+func (c *Consumer) Run(ctx context.Context) {
+	brokers := kafkax.SplitBrokers(c.cfg.Brokers)
+	if len(brokers) == 0 {
+		return
+	}
+	partitions, err := c.partitions(ctx, brokers)
+	if err != nil {
+		return
+	}
+	for _, p := range partitions {
+		go c.readPartition(ctx, brokers, p.ID)
+	}
+	<-ctx.Done()
+}
+
+// partitions retries until the topic is reachable, since the gateway may
+// start before Kafka.
+func (c *Consumer) partitions(ctx context.Context, brokers []string) ([]kafka.Partition, error) {
+	for {
+		partitions, err := kafka.DefaultDialer.LookupPartitions(ctx, "tcp", brokers[0], c.cfg.Topic)
+		if err == nil && len(partitions) > 0 {
+			return partitions, nil
+		}
+		c.logger.Warn("revocation topic lookup failed", "topic", c.cfg.Topic, "err", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (c *Consumer) readPartition(ctx context.Context, brokers []string, partition int) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   brokers,
+		Topic:     c.cfg.Topic,
+		Partition: partition,
+		MinBytes:  1,
+		MaxBytes:  10e6,
+	})
+	defer reader.Close()
+	if err := reader.SetOffsetAt(ctx, time.Now().Add(-c.cfg.Replay)); err != nil {
+		c.logger.Warn("revocation replay offset failed, reading from the start", "partition", partition, "err", err)
+		_ = reader.SetOffset(kafka.FirstOffset)
+	}
+
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.logger.Error("kafka read error", "err", err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
+		readAt := time.Now()
+		meta := kafkax.ExtractEventMeta(msg)
+		if err := Apply(ctx, c.store, msg.Value); err != nil {
+			c.logger.Error("revocation apply failed", "err", err, "event_id", meta.EventID)
+			kafkax.ObserveConsumed(msg, "gateway-revocations", kafkax.ConsumeError, readAt)
+			continue
+		}
+		kafkax.ObserveConsumed(msg, "gateway-revocations", kafkax.ConsumeOK, readAt)
+	}
+}
@@ -0,0 +1,63 @@
+// Package revocation keeps the gateway's view of revoked access tokens, fed by
+// auth.access_token.revoked.v1 events from auth-service.
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Store remembers revocations until the tokens they cover would have expired
+// anyway.
+type Store interface {
+	// RevokeToken denies the token named jti until expiresAt.
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeUser denies every token of userID issued before issuedBefore,
+	// remembering it until expiresAt. An earlier watermark never replaces a
+	// later one.
+	RevokeUser(ctx context.Context, userID string, issuedBefore time.Time, expiresAt time.Time) error
+	// IsRevoked reports whether a token of userID named jti and issued at
+	// issuedAt has been revoked.
+	IsRevoked(ctx context.Context, userID string, jti string, issuedAt time.Time) (bool, error)
+}
+
+// Event is the auth.access_token.revoked.v1 payload. Exactly one of JTI and
+// IssuedBefore is set.
+type Event struct {
+	UserID       string     `json:"user_id"`
+	JTI          string     `json:"jti,omitempty"`
+	IssuedBefore *time.Time `json:"issued_before,omitempty"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	Reason       string     `json:"reason"`
+	RevokedAt    time.Time  `json:"revoked_at"`
+}
+
+var ErrInvalidEvent = errors.New("invalid revocation event")
+
+// Apply records the revocation in raw, a JSON Event, in store. Events whose
+// tokens have all expired are ignored.
+func Apply(ctx context.Context, store Store, raw []byte) error {
+	var evt Event
+	if err := json.Unmarshal(raw, &evt); err != nil {
+		return ErrInvalidEvent
+	}
+	evt.UserID = strings.TrimSpace(evt.UserID)
+	evt.JTI = strings.TrimSpace(evt.JTI)
+	if evt.UserID == "" || evt.ExpiresAt.IsZero() {
+		return ErrInvalidEvent
+	}
+	if !evt.ExpiresAt.After(time.Now()) {
+		return nil
+	}
+	switch {
+	case evt.JTI != "":
+		return store.RevokeToken(ctx, evt.JTI, evt.ExpiresAt)
+	case evt.IssuedBefore != nil:
+		return store.RevokeUser(ctx, evt.UserID, *evt.IssuedBefore, evt.ExpiresAt)
+	default:
+		return ErrInvalidEvent
+	}
+}
@@ -0,0 +1,88 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestApplyToMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now()
+	issuedBefore := now.Truncate(time.Second).UTC().Format(time.RFC3339)
+	expiresAt := now.Add(time.Hour).UTC().Format(time.RFC3339)
+
+	if err := Apply(ctx, store, []byte(`{"user_id":"u1","jti":"t1","expires_at":"`+expiresAt+`","reason":"logout","revoked_at":"`+issuedBefore+`"}`)); err != nil {
+		t.Fatalf("apply jti: %v", err)
+	}
+	if err := Apply(ctx, store, []byte(`{"user_id":"u2","issued_before":"`+issuedBefore+`","expires_at":"`+expiresAt+`","reason":"password_reset","revoked_at":"`+issuedBefore+`"}`)); err != nil {
+		t.Fatalf("apply watermark: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		userID   string
+		jti      string
+		issuedAt time.Time
+		want     bool
+	}{
+		{"denied jti", "u1", "t1", now.Add(-time.Minute), true},
+		{"other jti of same user", "u1", "t2", now.Add(-time.Minute), false},
+		{"issued before watermark", "u2", "t3", now.Add(-time.Minute), true},
+		{"issued after watermark", "u2", "t4", now.Add(time.Minute), false},
+		{"unknown user", "u3", "", now.Add(-time.Minute), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := store.IsRevoked(ctx, tc.userID, tc.jti, tc.issuedAt)
+			if err != nil {
+				t.Fatalf("IsRevoked: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyRejectsAndSkips(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	for _, raw := range []string{
+		`{`,
+		`{"jti":"t1","expires_at":"` + future + `"}`,
+		`{"user_id":"u1","expires_at":"` + future + `"}`,
+	} {
+		if err := Apply(ctx, store, []byte(raw)); err != ErrInvalidEvent {
+			t.Fatalf("expected ErrInvalidEvent for %s, got %v", raw, err)
+		}
+	}
+	if err := Apply(ctx, store, []byte(`{"user_id":"u1","jti":"t1","expires_at":"`+past+`"}`)); err != nil {
+		t.Fatalf("expired event should be skipped, got %v", err)
+	}
+	if revoked, _ := store.IsRevoked(ctx, "u1", "t1", time.Now()); revoked {
+		t.Fatal("expired event must not be stored")
+	}
+}
+
+func TestMemoryStoreWatermarkOnlyMovesForward(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now()
+	later := now.Add(10 * time.Minute)
+
+	_ = store.RevokeUser(ctx, "u1", later, now.Add(time.Hour))
+	_ = store.RevokeUser(ctx, "u1", now, now.Add(time.Hour))
+	if revoked, _ := store.IsRevoked(ctx, "u1", "", now.Add(5*time.Minute)); !revoked {
+		t.Fatal("an older watermark replaced a newer one")
+	}
+
+	store.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if revoked, _ := store.IsRevoked(ctx, "u1", "", now); revoked {
+		t.Fatal("expired watermark still applies")
+	}
+}
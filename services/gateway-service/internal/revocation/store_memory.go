@@ -0,0 +1,82 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps revocations in process. Each gateway replica then needs
+// to read every event itself, which the Consumer does.
+type MemoryStore struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time
+	users  map[string]watermark
+	now    func() time.Time
+}
+
+type watermark struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tokens: map[string]time.Time{},
+		users:  map[string]watermark{},
+		now:    time.Now,
+	}
+}
+
+func (s *MemoryStore) RevokeToken(_ context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	if expiresAt.After(s.tokens[jti]) {
+		s.tokens[jti] = expiresAt
+	}
+	return nil
+}
+
+func (s *MemoryStore) RevokeUser(_ context.Context, userID string, issuedBefore time.Time, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	current, ok := s.users[userID]
+	if ok && !issuedBefore.After(current.issuedBefore) {
+		return nil
+	}
+	s.users[userID] = watermark{issuedBefore: issuedBefore, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryStore) IsRevoked(_ context.Context, userID string, jti string, issuedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if jti != "" {
+		if until, ok := s.tokens[jti]; ok && now.Before(until) {
+			return true, nil
+		}
+	}
+	if mark, ok := s.users[userID]; ok && now.Before(mark.expiresAt) && issuedAt.Before(mark.issuedBefore) {
+		return true, nil
+	}
+	return false, nil
+}
+
+// prune drops entries past their expiry. Revocations are rare, so a full scan
+// on each write is cheap.
+func (s *MemoryStore) prune() {
+	now := s.now()
+	for jti, until := range s.tokens {
+		if !now.Before(until) {
+			delete(s.tokens, jti)
+		}
+	}
+	for userID, mark := range s.users {
+		if !now.Before(mark.expiresAt) {
+			delete(s.users, userID)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package revocation
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore shares revocations between gateway replicas. Keys expire with
+// the tokens they cover.
+type RedisStore struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+func NewRedisStore(rdb *redis.Client, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "revoked"
+	}
+	return &RedisStore{rdb: rdb, prefix: prefix}
+}
+
+// The watermark only moves forward, so replaying older events is harmless.
+var redisRaiseWatermarkScript = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+if tonumber(ARGV[1]) > current then
+  redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+end
+return 1
+`)
+
+func (s *RedisStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.rdb.Set(ctx, s.tokenKey(jti), "1", ttl).Err()
+}
+
+func (s *RedisStore) RevokeUser(ctx context.Context, userID string, issuedBefore time.Time, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return redisRaiseWatermarkScript.Run(ctx, s.rdb, []string{s.userKey(userID)}, issuedBefore.Unix(), ttl.Milliseconds()).Err()
+}
+
+func (s *RedisStore) IsRevoked(ctx context.Context, userID string, jti string, issuedAt time.Time) (bool, error) {
+	keys := []string{s.userKey(userID)}
+	if jti != "" {
+		keys = append(keys, s.tokenKey(jti))
+	}
+	values, err := s.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return false, err
+	}
+	if len(values) > 1 && values[1] != nil {
+		return true, nil
+	}
+	if raw, ok := values[0].(string); ok {
+		before, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return false, err
+		}
+		return issuedAt.Unix() < before, nil
+	}
+	return false, nil
+}
+
+func (s *RedisStore) tokenKey(jti string) string {
+	return s.prefix + ":jti:" + jti
+}
+
+func (s *RedisStore) userKey(userID string) string {
+	return s.prefix + ":user:" + userID
+}
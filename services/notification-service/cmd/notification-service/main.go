@@ -3,97 +3,113 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/md-rashed-zaman/apptremind/libs/auth"
 	"github.com/md-rashed-zaman/apptremind/libs/config"
 	"github.com/md-rashed-zaman/apptremind/libs/db"
 	"github.com/md-rashed-zaman/apptremind/libs/httpx"
 	"github.com/md-rashed-zaman/apptremind/libs/kafkax"
 	otelx "github.com/md-rashed-zaman/apptremind/libs/otel"
 	"github.com/md-rashed-zaman/apptremind/libs/runtime"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/accountmail"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/booking"
 	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/consumer"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/delivery"
 	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/email"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/handlers"
 	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/inbox"
 	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/providers"
 	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/sms"
 	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/storage"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/suppression"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/twoway"
 	"github.com/segmentio/kafka-go"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-type reminderPayload struct {
-	AppointmentID string         `json:"appointment_id"`
-	BusinessID    string         `json:"business_id"`
-	Channel       string         `json:"channel"`
-	Recipient     string         `json:"recipient"`
-	RemindAt      string         `json:"remind_at"`
-	TemplateData  map[string]any `json:"template_data"`
-}
-
-func writeOutboxSent(ctx context.Context, pool *db.Pool, outboxRepo *outbox.Repository, payload reminderPayload, providerID string) error {
-	tx, err := pool.Begin(ctx)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = tx.Rollback(ctx) }()
-
-	if strings.TrimSpace(providerID) == "" {
-		providerID = "unknown"
+func intFromEnv(key string, fallback int) int {
+	raw := strings.TrimSpace(config.String(key, ""))
+	if raw == "" {
+		return fallback
 	}
-	eventPayload, err := json.Marshal(map[string]any{
-		"appointment_id": payload.AppointmentID,
-		"business_id":    payload.BusinessID,
-		"channel":        payload.Channel,
-		"provider_id":    providerID,
-		"sent_at":        time.Now().UTC().Format(time.RFC3339),
-	})
-	if err != nil {
-		return err
-	}
-
-	if err := outboxRepo.Insert(ctx, tx, outbox.Event{
-		AggregateType: "notification",
-		AggregateID:   payload.AppointmentID,
-		EventType:     "notification.sent.v1",
-		Payload:       eventPayload,
-	}); err != nil {
-		return err
+	v, err := strconv.Atoi(raw)
+	if err != nil || v <= 0 {
+		return fallback
 	}
-
-	return tx.Commit(ctx)
+	return v
 }
 
-func writeOutboxFailed(ctx context.Context, pool *db.Pool, outboxRepo *outbox.Repository, payload reminderPayload, reason string) error {
-	tx, err := pool.Begin(ctx)
-	if err != nil {
-		return err
+// emailProviders builds the ordered email chain from EMAIL_PROVIDERS (comma separated).
+func emailProviders(logger *slog.Logger) []providers.Provider {
+	var out []providers.Provider
+	for _, name := range splitList(config.String("EMAIL_PROVIDERS", "smtp")) {
+		switch name {
+		case "smtp":
+			sender := email.NewSMTPSender(
+				config.String("SMTP_HOST", "mailpit"),
+				config.String("SMTP_PORT", "1025"),
+				config.String("SMTP_FROM", "no-reply@apptremind.local"),
+			)
+			out = append(out, providers.FromEmail(sender))
+		case "smtp-secondary":
+			sender := email.NewSMTPSender(
+				config.String("SMTP_SECONDARY_HOST", ""),
+				config.String("SMTP_SECONDARY_PORT", "25"),
+				config.String("SMTP_SECONDARY_FROM", config.String("SMTP_FROM", "no-reply@apptremind.local")),
+			)
+			sender.SetProviderID("smtp-secondary")
+			out = append(out, providers.FromEmail(sender))
+		default:
+			logger.Warn("unknown email provider ignored", "provider", name)
+		}
 	}
-	defer func() { _ = tx.Rollback(ctx) }()
+	return out
+}
 
-	eventPayload, err := json.Marshal(map[string]any{
-		"appointment_id": payload.AppointmentID,
-		"business_id":    payload.BusinessID,
-		"channel":        payload.Channel,
-		"error_reason":   reason,
-		"failed_at":      time.Now().UTC().Format(time.RFC3339),
-	})
-	if err != nil {
-		return err
+// smsProviders builds the ordered SMS chain from SMS_PROVIDERS, falling back to
+// the single SMS_PROVIDER setting for older deployments.
+func smsProviders(logger *slog.Logger) []providers.Provider {
+	var out []providers.Provider
+	list := config.String("SMS_PROVIDERS", config.String("SMS_PROVIDER", "noop"))
+	for _, name := range splitList(list) {
+		switch name {
+		case "webhook":
+			out = append(out, providers.FromSMS(sms.NewWebhookSender(
+				config.String("SMS_WEBHOOK_URL", ""),
+				config.String("SMS_WEBHOOK_TOKEN", ""),
+			)))
+		case "webhook-secondary":
+			sender := sms.NewWebhookSender(
+				config.String("SMS_SECONDARY_WEBHOOK_URL", ""),
+				config.String("SMS_SECONDARY_WEBHOOK_TOKEN", ""),
+			)
+			sender.SetProviderID("sms-webhook-secondary")
+			out = append(out, providers.FromSMS(sender))
+		case "noop":
+			out = append(out, providers.FromSMS(sms.NewNoopSender()))
+		default:
+			logger.Warn("unknown sms provider ignored", "provider", name)
+		}
 	}
+	return out
+}
 
-	if err := outboxRepo.Insert(ctx, tx, outbox.Event{
-		AggregateType: "notification",
-		AggregateID:   payload.AppointmentID,
-		EventType:     "notification.failed.v1",
-		Payload:       eventPayload,
-	}); err != nil {
-		return err
+func splitList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part != "" {
+			out = append(out, part)
+		}
 	}
-
-	return tx.Commit(ctx)
+	return out
 }
 
 func main() {
@@ -140,33 +156,41 @@ func main() {
 	})
 	go outboxPublisher.Run(ctx)
 
-	smtpHost := config.String("SMTP_HOST", "mailpit")
-	smtpPort := config.String("SMTP_PORT", "1025")
-	smtpFrom := config.String("SMTP_FROM", "no-reply@apptremind.local")
-	emailSender := email.NewSMTPSender(smtpHost, smtpPort, smtpFrom)
-	emailProviderID := "smtp"
-
-	smsProvider := strings.ToLower(config.String("SMS_PROVIDER", "noop"))
-	smsWebhookURL := config.String("SMS_WEBHOOK_URL", "")
-	smsWebhookToken := config.String("SMS_WEBHOOK_TOKEN", "")
-	var smsSender sms.Sender
-	switch smsProvider {
-	case "webhook":
-		smsSender = sms.NewWebhookSender(smsWebhookURL, smsWebhookToken)
-	case "noop":
-		smsSender = sms.NewNoopSender()
-	default:
-		smsSender = sms.NewWebhookSender(smsWebhookURL, smsWebhookToken)
+	breakerCfg := providers.BreakerConfig{
+		FailureThreshold: intFromEnv("NOTIFICATION_BREAKER_FAILURES", 3),
+		Cooldown:         time.Duration(intFromEnv("NOTIFICATION_BREAKER_COOLDOWN_SECONDS", 60)) * time.Second,
 	}
+	emailChain := providers.NewChain("email", logger, breakerCfg, emailProviders(logger)...)
+	smsChain := providers.NewChain("sms", logger, breakerCfg, smsProviders(logger)...)
+
+	retriesRepo := storage.NewRetryRepository(pool)
+	suppressionsRepo := suppression.NewRepository(pool)
+	unsubscribeSecret := config.String("UNSUBSCRIBE_TOKEN_SECRET", "")
+	deliveryService := delivery.NewService(pool, notificationsRepo, retriesRepo, suppressionsRepo, outboxRepo, map[string]*providers.Chain{
+		"email": emailChain,
+		"sms":   smsChain,
+	}, logger, delivery.Config{
+		MaxAttempts: intFromEnv("NOTIFICATION_RETRY_MAX_ATTEMPTS", 5),
+		BaseBackoff: time.Duration(intFromEnv("NOTIFICATION_RETRY_BASE_SECONDS", 30)) * time.Second,
+		MaxBackoff:  time.Duration(intFromEnv("NOTIFICATION_RETRY_MAX_BACKOFF_SECONDS", 900)) * time.Second,
+		FailSuffix:  config.String("NOTIFICATION_FAIL_SUFFIX", ""),
+		// Public base URL of the gateway, e.g. https://api.example.com.
+		UnsubscribeBaseURL: config.String("PUBLIC_BASE_URL", ""),
+		UnsubscribeSecret:  unsubscribeSecret,
+	})
+	retryWorker := delivery.NewRetryWorker(deliveryService, retriesRepo, logger, delivery.RetryWorkerConfig{
+		Interval:  5 * time.Second,
+		BatchSize: 20,
+	})
+	go retryWorker.Run(ctx)
 
-	failSuffix := config.String("NOTIFICATION_FAIL_SUFFIX", "")
 	consumerCfg := consumer.Config{
 		Brokers: config.String("KAFKA_BROKERS", ""),
 		GroupID: config.String("KAFKA_GROUP_ID", "notification-service"),
 		Topic:   config.String("KAFKA_CONSUME_TOPIC", "scheduler.reminder.due.v1"),
 	}
 	eventConsumer := consumer.New(logger, inboxRepo, consumerCfg, func(ctx context.Context, msg kafka.Message) error {
-		var payload reminderPayload
+		var payload delivery.Reminder
 		if err := json.Unmarshal(msg.Value, &payload); err != nil {
 			logger.Error("invalid reminder payload", "err", err)
 			return nil
@@ -175,89 +199,137 @@ func main() {
 			logger.Error("missing reminder fields")
 			return nil
 		}
-		if _, err := time.Parse(time.RFC3339, payload.RemindAt); err != nil {
-			logger.Error("invalid remind_at", "err", err)
-			return nil
+		// Process records a reminder with an unparseable remind_at as failed.
+		if err := deliveryService.Process(ctx, payload, 1, 0); err != nil {
+			logger.Error("failed to record notification outcome", "err", err)
+			return err
 		}
+		return nil
+	})
+	go eventConsumer.Run(ctx)
 
-		status := "sent"
-		failureReason := ""
-		if failSuffix != "" && strings.HasSuffix(payload.Recipient, failSuffix) {
-			status = "failed"
-			failureReason = "simulated failure"
+	// auth-service seals account links with the same key.
+	linkSealer, err := auth.NewLinkSealer(config.String("ACCOUNT_LINK_KEY", "dev-account-link-key"))
+	if err != nil {
+		logger.Error("invalid account link key", "err", err)
+		panic(err)
+	}
+	mailer := accountmail.NewMailer(emailChain, linkSealer, logger)
+	invitationConsumer := consumer.New(logger, inboxRepo, consumer.Config{
+		Brokers: config.String("KAFKA_BROKERS", ""),
+		GroupID: config.String("KAFKA_GROUP_ID", "notification-service"),
+		Topic:   config.String("KAFKA_INVITATION_TOPIC", accountmail.InvitationEvent),
+	}, func(ctx context.Context, msg kafka.Message) error {
+		var payload accountmail.Invitation
+		if err := json.Unmarshal(msg.Value, &payload); err != nil {
+			logger.Error("invalid invitation payload", "err", err)
+			return nil
 		}
-
-		providerID := ""
-		if status == "sent" {
-			switch strings.ToLower(payload.Channel) {
-			case "email":
-				subject := "Appointment reminder"
-				body := fmt.Sprintf("Reminder for appointment %s at %s.", payload.AppointmentID, payload.RemindAt)
-				if name, ok := payload.TemplateData["business_name"].(string); ok && name != "" {
-					body = fmt.Sprintf("[%s] %s", name, body)
-				}
-				if err := emailSender.Send(payload.Recipient, subject, body); err != nil {
-					status = "failed"
-					failureReason = err.Error()
-					logger.Error("email send failed", "err", err, "recipient", payload.Recipient)
-				} else {
-					providerID = emailProviderID
-				}
-			case "sms":
-				body := fmt.Sprintf("Reminder: appointment %s at %s.", payload.AppointmentID, payload.RemindAt)
-				if name, ok := payload.TemplateData["business_name"].(string); ok && name != "" {
-					body = fmt.Sprintf("[%s] %s", name, body)
-				}
-				if err := smsSender.Send(ctx, payload.Recipient, body); err != nil {
-					status = "failed"
-					failureReason = err.Error()
-					logger.Error("sms send failed", "err", err, "recipient", payload.Recipient)
-				} else {
-					providerID = smsSender.ProviderID()
-				}
-			default:
-				status = "failed"
-				failureReason = "unsupported channel: " + payload.Channel
-				logger.Error("unsupported channel", "channel", payload.Channel)
-			}
+		if payload.InvitationID == "" || payload.Email == "" || payload.AcceptURLSealed == "" {
+			logger.Error("missing invitation fields")
+			return nil
 		}
-
-		if err := notificationsRepo.Insert(ctx, storage.Notification{
-			AppointmentID: payload.AppointmentID,
-			BusinessID:    payload.BusinessID,
-			Channel:       payload.Channel,
-			Recipient:     payload.Recipient,
-			Payload:       payload.TemplateData,
-			Status:        status,
-		}); err != nil {
-			logger.Error("failed to persist notification", "err", err)
+		if err := mailer.SendInvitation(ctx, payload); err != nil {
+			if errors.Is(err, auth.ErrSealedLink) {
+				logger.Error("invitation link cannot be opened; check ACCOUNT_LINK_KEY", "invitation_id", payload.InvitationID)
+				return nil
+			}
+			logger.Error("invitation email failed", "err", err, "invitation_id", payload.InvitationID)
 			return err
 		}
-
-		if status == "failed" {
-			if err := writeOutboxFailed(ctx, pool, outboxRepo, payload, failureReason); err != nil {
-				logger.Error("failed to enqueue notification.failed", "err", err)
-				return err
+		return nil
+	})
+	go invitationConsumer.Run(ctx)
+	passwordResetConsumer := consumer.New(logger, inboxRepo, consumer.Config{
+		Brokers: config.String("KAFKA_BROKERS", ""),
+		GroupID: config.String("KAFKA_GROUP_ID", "notification-service"),
+		Topic:   config.String("KAFKA_PASSWORD_RESET_TOPIC", accountmail.PasswordResetEvent),
+	}, func(ctx context.Context, msg kafka.Message) error {
+		var payload accountmail.PasswordReset
+		if err := json.Unmarshal(msg.Value, &payload); err != nil {
+			logger.Error("invalid password reset payload", "err", err)
+			return nil
+		}
+		if payload.Email == "" || payload.ResetURLSealed == "" {
+			logger.Error("missing password reset fields")
+			return nil
+		}
+		if err := mailer.SendPasswordReset(ctx, payload); err != nil {
+			if errors.Is(err, auth.ErrSealedLink) {
+				logger.Error("password reset link cannot be opened; check ACCOUNT_LINK_KEY", "user_id", payload.UserID)
+				return nil
 			}
-		} else {
-			if err := writeOutboxSent(ctx, pool, outboxRepo, payload, providerID); err != nil {
-				logger.Error("failed to enqueue notification.sent", "err", err)
-				return err
+			logger.Error("password reset email failed", "err", err, "user_id", payload.UserID)
+			return err
+		}
+		return nil
+	})
+	go passwordResetConsumer.Run(ctx)
+	verificationConsumer := consumer.New(logger, inboxRepo, consumer.Config{
+		Brokers: config.String("KAFKA_BROKERS", ""),
+		GroupID: config.String("KAFKA_GROUP_ID", "notification-service"),
+		Topic:   config.String("KAFKA_EMAIL_VERIFICATION_TOPIC", accountmail.EmailVerificationEvent),
+	}, func(ctx context.Context, msg kafka.Message) error {
+		var payload accountmail.EmailVerification
+		if err := json.Unmarshal(msg.Value, &payload); err != nil {
+			logger.Error("invalid email verification payload", "err", err)
+			return nil
+		}
+		if payload.Email == "" || payload.VerifyURLSealed == "" {
+			logger.Error("missing email verification fields")
+			return nil
+		}
+		if err := mailer.SendEmailVerification(ctx, payload); err != nil {
+			if errors.Is(err, auth.ErrSealedLink) {
+				logger.Error("verification link cannot be opened; check ACCOUNT_LINK_KEY", "user_id", payload.UserID)
+				return nil
 			}
+			logger.Error("verification email failed", "err", err, "user_id", payload.UserID)
+			return err
 		}
-
-		logger.Info("reminder processed", "appointment_id", payload.AppointmentID, "channel", payload.Channel, "status", status)
 		return nil
 	})
-	go eventConsumer.Run(ctx)
+	go verificationConsumer.Run(ctx)
 
 	mux := runtime.NewBaseMuxWithReady(
 		runtime.ReadyCheck{Name: "db", Check: db.ReadyCheck(pool)},
 		runtime.ReadyCheck{Name: "kafka", Check: kafkax.ReadyCheck(config.String("KAFKA_BROKERS", ""))},
 	)
+	twoWayService := twoway.NewService(
+		notificationsRepo,
+		booking.NewClient(config.String("BOOKING_URL", "http://booking-service:8083"), config.String("BOOKING_INTERNAL_TOKEN", "")),
+		twoway.NewSuppressionOptOut(pool, suppressionsRepo),
+		inboxRepo,
+		smsChain,
+		logger,
+		twoway.Config{
+			Keywords: twoway.Keywords{
+				Confirm: splitList(config.String("SMS_CONFIRM_KEYWORDS", "")),
+				Cancel:  splitList(config.String("SMS_CANCEL_KEYWORDS", "")),
+				Help:    splitList(config.String("SMS_HELP_KEYWORDS", "")),
+			},
+			Replies: twoway.Replies{Help: config.String("SMS_HELP_REPLY", "")},
+		},
+	)
+	webhookHandler := handlers.NewWebhookHandler(pool, notificationsRepo, suppressionsRepo, outboxRepo, twoWayService, logger, handlers.WebhookConfig{
+		SMSSecret:   config.String("SMS_STATUS_WEBHOOK_SECRET", ""),
+		EmailSecret: config.String("EMAIL_EVENTS_WEBHOOK_SECRET", ""),
+		Tolerance:   time.Duration(intFromEnv("NOTIFICATION_WEBHOOK_TOLERANCE_SECONDS", 300)) * time.Second,
+	})
+	mux.HandleFunc("/api/v1/notifications/webhooks/sms", webhookHandler.SMSReceipt)
+	mux.HandleFunc("/api/v1/notifications/webhooks/email", webhookHandler.EmailEvent)
+	mux.HandleFunc("/api/v1/notifications/webhooks/sms/inbound", webhookHandler.InboundSMS)
+
+	suppressionHandler := handlers.NewSuppressionHandler(pool, suppressionsRepo, logger, unsubscribeSecret)
+	mux.HandleFunc("/api/v1/notifications/suppressions", suppressionHandler.Suppressions)
+	mux.HandleFunc("/api/v1/notifications/suppressions/remove", suppressionHandler.Remove)
+	mux.HandleFunc("/api/v1/notifications/unsubscribe", suppressionHandler.Unsubscribe)
+	debugHandler := handlers.NewDebugHandler(emailChain, smsChain, config.String("NOTIFICATION_DEBUG_TOKEN", ""))
+	mux.HandleFunc("/debug/providers", debugHandler.Providers)
 	handler := httpx.Chain(mux,
 		httpx.WithRequestID,
 		httpx.WithAccessLog(logger),
+		httpx.WithMetrics,
 	)
 	handler = otelhttp.NewHandler(handler, "notification")
 	srv := &http.Server{
@@ -0,0 +1,135 @@
+package accountmail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/md-rashed-zaman/apptremind/libs/auth"
+
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/providers"
+)
+
+// Invitation is the payload of auth.invitation.created.v1.
+type Invitation struct {
+	InvitationID string `json:"invitation_id"`
+	BusinessID   string `json:"business_id"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	InvitedBy    string `json:"invited_by"`
+	// AcceptURLSealed is the accept link sealed with auth.LinkSealer.
+	AcceptURLSealed string `json:"accept_url_sealed"`
+	ExpiresAt       string `json:"expires_at"`
+}
+
+// PasswordReset is the payload of auth.password_reset.requested.v1.
+type PasswordReset struct {
+	UserID         string `json:"user_id"`
+	Email          string `json:"email"`
+	ResetURLSealed string `json:"reset_url_sealed"`
+	ExpiresAt      string `json:"expires_at"`
+}
+
+// EmailVerification is the payload of auth.email_verification.requested.v1.
+type EmailVerification struct {
+	UserID          string `json:"user_id"`
+	Email           string `json:"email"`
+	VerifyURLSealed string `json:"verify_url_sealed"`
+	ExpiresAt       string `json:"expires_at"`
+}
+
+// Event types whose links the mailer opens.
+const (
+	InvitationEvent        = "auth.invitation.created.v1"
+	PasswordResetEvent     = "auth.password_reset.requested.v1"
+	EmailVerificationEvent = "auth.email_verification.requested.v1"
+)
+
+// Mailer sends account emails on behalf of auth-service. Unlike reminders they
+// are transactional: suppressions do not apply and nothing is written to the
+// notifications table. Links arrive sealed and are only opened to build the
+// email body. A link that cannot be opened fails with auth.ErrSealedLink.
+type Mailer struct {
+	email  *providers.Chain
+	links  *auth.LinkSealer
+	logger *slog.Logger
+}
+
+func NewMailer(email *providers.Chain, links *auth.LinkSealer, logger *slog.Logger) *Mailer {
+	return &Mailer{email: email, links: links, logger: logger}
+}
+
+func (m *Mailer) SendInvitation(ctx context.Context, inv Invitation) error {
+	acceptURL, err := m.links.Open(inv.AcceptURLSealed, InvitationEvent, inv.Email)
+	if err != nil {
+		return err
+	}
+	body := strings.Join([]string{
+		fmt.Sprintf("You have been invited to join a team on ApptRemind as %s.", inv.Role),
+		"",
+		"Accept the invitation and choose a password here:",
+		acceptURL,
+		"",
+		fmt.Sprintf("The link expires at %s. If you were not expecting this, ignore this email.", inv.ExpiresAt),
+	}, "\n")
+	receipt, err := m.email.Send(ctx, providers.Message{
+		To:      inv.Email,
+		Subject: "You're invited to join your team",
+		Body:    body,
+	})
+	if err != nil {
+		return err
+	}
+	m.logger.Info("invitation email sent", "invitation_id", inv.InvitationID, "business_id", inv.BusinessID, "provider_id", receipt.ProviderID)
+	return nil
+}
+
+func (m *Mailer) SendPasswordReset(ctx context.Context, req PasswordReset) error {
+	resetURL, err := m.links.Open(req.ResetURLSealed, PasswordResetEvent, req.Email)
+	if err != nil {
+		return err
+	}
+	body := strings.Join([]string{
+		"Someone asked to reset the password of your ApptRemind account.",
+		"",
+		"Choose a new password here:",
+		resetURL,
+		"",
+		fmt.Sprintf("The link expires at %s and works once. Resetting signs you out on every device.", req.ExpiresAt),
+		"If you did not ask for this, ignore this email; your password stays the same.",
+	}, "\n")
+	receipt, err := m.email.Send(ctx, providers.Message{
+		To:      req.Email,
+		Subject: "Reset your password",
+		Body:    body,
+	})
+	if err != nil {
+		return err
+	}
+	m.logger.Info("password reset email sent", "user_id", req.UserID, "provider_id", receipt.ProviderID)
+	return nil
+}
+
+func (m *Mailer) SendEmailVerification(ctx context.Context, req EmailVerification) error {
+	verifyURL, err := m.links.Open(req.VerifyURLSealed, EmailVerificationEvent, req.Email)
+	if err != nil {
+		return err
+	}
+	body := strings.Join([]string{
+		"Confirm that this address belongs to your ApptRemind account:",
+		verifyURL,
+		"",
+		fmt.Sprintf("The link expires at %s.", req.ExpiresAt),
+	}, "\n")
+	receipt, err := m.email.Send(ctx, providers.Message{
+		To:      req.Email,
+		Subject: "Confirm your email address",
+		Body:    body,
+	})
+	if err != nil {
+		return err
+	}
+	m.logger.Info("verification email sent", "user_id", req.UserID, "provider_id", receipt.ProviderID)
+	return nil
+}
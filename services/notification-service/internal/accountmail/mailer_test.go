@@ -0,0 +1,116 @@
+package accountmail
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/auth"
+
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/providers"
+)
+
+type captureProvider struct {
+	sent []providers.Message
+}
+
+func (p *captureProvider) ID() string { return "capture" }
+
+func (p *captureProvider) Send(_ context.Context, msg providers.Message) (string, error) {
+	p.sent = append(p.sent, msg)
+	return "msg-1", nil
+}
+
+func newTestMailer(t *testing.T) (*Mailer, *captureProvider, *auth.LinkSealer) {
+	t.Helper()
+	capture := &captureProvider{}
+	chain := providers.NewChain("email", nil, providers.BreakerConfig{FailureThreshold: 3, Cooldown: time.Minute}, capture)
+	links, err := auth.NewLinkSealer("test-link-key")
+	if err != nil {
+		t.Fatalf("NewLinkSealer: %v", err)
+	}
+	return NewMailer(chain, links, slog.Default()), capture, links
+}
+
+func seal(t *testing.T, links *auth.LinkSealer, link string, eventType string, email string) string {
+	t.Helper()
+	sealed, err := links.Seal(link, eventType, email)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	return sealed
+}
+
+func TestSendInvitation(t *testing.T) {
+	m, capture, links := newTestMailer(t)
+
+	err := m.SendInvitation(context.Background(), Invitation{
+		InvitationID:    "inv-1",
+		BusinessID:      "biz-1",
+		Email:           "new@example.com",
+		Role:            "receptionist",
+		AcceptURLSealed: seal(t, links, "http://localhost:8080/invite?token=a.b", InvitationEvent, "new@example.com"),
+		ExpiresAt:       "2026-01-01T00:00:00Z",
+	})
+	if err != nil {
+		t.Fatalf("SendInvitation: %v", err)
+	}
+	if len(capture.sent) != 1 {
+		t.Fatalf("expected one email, got %d", len(capture.sent))
+	}
+	msg := capture.sent[0]
+	if msg.To != "new@example.com" || msg.Subject == "" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	for _, want := range []string{"receptionist", "http://localhost:8080/invite?token=a.b", "2026-01-01T00:00:00Z"} {
+		if !strings.Contains(msg.Body, want) {
+			t.Fatalf("body missing %q:\n%s", want, msg.Body)
+		}
+	}
+}
+
+func TestSendPasswordResetAndVerification(t *testing.T) {
+	m, capture, links := newTestMailer(t)
+
+	if err := m.SendPasswordReset(context.Background(), PasswordReset{
+		UserID:         "user-1",
+		Email:          "owner@example.com",
+		ResetURLSealed: seal(t, links, "http://localhost:8080/reset-password?token=abc", PasswordResetEvent, "owner@example.com"),
+		ExpiresAt:      "2026-01-01T00:00:00Z",
+	}); err != nil {
+		t.Fatalf("SendPasswordReset: %v", err)
+	}
+	if err := m.SendEmailVerification(context.Background(), EmailVerification{
+		UserID:          "user-1",
+		Email:           "owner@example.com",
+		VerifyURLSealed: seal(t, links, "http://localhost:8080/verify-email?token=def", EmailVerificationEvent, "owner@example.com"),
+		ExpiresAt:       "2026-01-02T00:00:00Z",
+	}); err != nil {
+		t.Fatalf("SendEmailVerification: %v", err)
+	}
+	if len(capture.sent) != 2 {
+		t.Fatalf("expected two emails, got %d", len(capture.sent))
+	}
+	for i, want := range []string{"reset-password?token=abc", "verify-email?token=def"} {
+		msg := capture.sent[i]
+		if msg.To != "owner@example.com" || !strings.Contains(msg.Body, want) {
+			t.Fatalf("email %d missing %q: %+v", i, want, msg)
+		}
+	}
+}
+
+func TestSendRefusesLinkSealedForSomeoneElse(t *testing.T) {
+	m, capture, links := newTestMailer(t)
+	err := m.SendPasswordReset(context.Background(), PasswordReset{
+		UserID:         "user-1",
+		Email:          "mallory@example.com",
+		ResetURLSealed: seal(t, links, "http://localhost:8080/reset-password?token=abc", PasswordResetEvent, "owner@example.com"),
+		ExpiresAt:      "2026-01-01T00:00:00Z",
+	})
+	if !errors.Is(err, auth.ErrSealedLink) || len(capture.sent) != 0 {
+		t.Fatalf("expected ErrSealedLink and no email, got %v and %d emails", err, len(capture.sent))
+	}
+}
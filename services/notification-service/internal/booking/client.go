@@ -0,0 +1,83 @@
+package booking
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("appointment not found")
+	// ErrConflict means the appointment can no longer change state (e.g. already cancelled).
+	ErrConflict = errors.New("appointment cannot be changed")
+)
+
+// Client calls booking-service's internal appointment API.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func NewClient(baseURL string, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(strings.TrimSpace(baseURL), "/"),
+		token:   strings.TrimSpace(token),
+		http: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+func (c *Client) Confirm(ctx context.Context, businessID, appointmentID, source string) error {
+	return c.post(ctx, "/internal/v1/appointments/confirm", map[string]string{
+		"business_id":    businessID,
+		"appointment_id": appointmentID,
+		"source":         source,
+	})
+}
+
+func (c *Client) Cancel(ctx context.Context, businessID, appointmentID, reason string) error {
+	return c.post(ctx, "/internal/v1/appointments/cancel", map[string]string{
+		"business_id":    businessID,
+		"appointment_id": appointmentID,
+		"reason":         reason,
+	})
+}
+
+func (c *Client) post(ctx context.Context, path string, body map[string]string) error {
+	if c.baseURL == "" {
+		return errors.New("booking url not configured")
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Token", c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusConflict:
+		return ErrConflict
+	default:
+		return fmt.Errorf("booking internal api returned status %d", resp.StatusCode)
+	}
+}
@@ -20,6 +20,7 @@ type Consumer struct {
 	logger  *slog.Logger
 	inbox   *inbox.Repository
 	handler Handler
+	group   string
 }
 
 type Config struct {
@@ -42,6 +43,7 @@ func New(logger *slog.Logger, inboxRepo *inbox.Repository, cfg Config, handler H
 		logger:  logger,
 		inbox:   inboxRepo,
 		handler: handler,
+		group:   cfg.GroupID,
 	}
 }
 
@@ -58,6 +60,7 @@ func (c *Consumer) Run(ctx context.Context) {
 			time.Sleep(1 * time.Second)
 			continue
 		}
+		readAt := time.Now()
 
 		ctxMsg := kafkax.ExtractTraceContext(ctx, msg)
 		ctxSpan, span := otel.Tracer("kafka").Start(ctxMsg, "kafka.consume",
@@ -74,11 +77,13 @@ func (c *Consumer) Run(ctx context.Context) {
 			c.logger.Error("inbox record failed", "err", err)
 			span.RecordError(err)
 			span.End()
+			kafkax.ObserveConsumed(msg, c.group, kafkax.ConsumeError, readAt)
 			continue
 		}
 		if !ok {
 			c.logger.Info("duplicate event ignored", "event_id", meta.EventID, "event_type", meta.EventType)
 			span.End()
+			kafkax.ObserveConsumed(msg, c.group, kafkax.ConsumeDuplicate, readAt)
 			continue
 		}
 
@@ -86,8 +91,10 @@ func (c *Consumer) Run(ctx context.Context) {
 			c.logger.Error("handler error", "err", err, "event_id", meta.EventID)
 			span.RecordError(err)
 			span.End()
+			kafkax.ObserveConsumed(msg, c.group, kafkax.ConsumeError, readAt)
 			continue
 		}
 		span.End()
+		kafkax.ObserveConsumed(msg, c.group, kafkax.ConsumeOK, readAt)
 	}
 }
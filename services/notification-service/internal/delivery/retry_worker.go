@@ -0,0 +1,86 @@
+package delivery
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	otelx "github.com/md-rashed-zaman/apptremind/libs/otel"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/storage"
+)
+
+type RetryWorkerConfig struct {
+	Interval  time.Duration
+	BatchSize int
+	// Lease is how long a claimed retry stays invisible to other workers.
+	Lease time.Duration
+}
+
+// RetryWorker drains notification_retries, handing each due row back to Service.
+type RetryWorker struct {
+	service   *Service
+	retries   *storage.RetryRepository
+	logger    *slog.Logger
+	interval  time.Duration
+	batchSize int
+	lease     time.Duration
+}
+
+func NewRetryWorker(service *Service, retries *storage.RetryRepository, logger *slog.Logger, cfg RetryWorkerConfig) *RetryWorker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 20
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 2 * time.Minute
+	}
+	return &RetryWorker{
+		service:   service,
+		retries:   retries,
+		logger:    logger,
+		interval:  cfg.Interval,
+		batchSize: cfg.BatchSize,
+		lease:     cfg.Lease,
+	}
+}
+
+func (w *RetryWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.processBatch(ctx); err != nil {
+				w.logger.Error("notification retry batch failed", "err", err)
+			}
+		}
+	}
+}
+
+func (w *RetryWorker) processBatch(ctx context.Context) error {
+	due, err := w.retries.Claim(ctx, w.batchSize, w.lease)
+	if err != nil {
+		return err
+	}
+	for _, rt := range due {
+		retryCtx := otelx.ContextWithTraceContext(ctx, rt.Traceparent, rt.Tracestate)
+		reminder := Reminder{
+			AppointmentID: rt.AppointmentID,
+			BusinessID:    rt.BusinessID,
+			Channel:       rt.Channel,
+			Recipient:     rt.Recipient,
+			RemindAt:      rt.RemindAt.UTC().Format(time.RFC3339),
+			TemplateData:  rt.TemplateData,
+		}
+		if err := w.service.Process(retryCtx, reminder, rt.Attempts+1, rt.ID); err != nil {
+			// The lease expires and the row is picked up again.
+			w.logger.Error("notification retry failed", "err", err, "retry_id", rt.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+	"github.com/md-rashed-zaman/apptremind/libs/metrics"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/providers"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/storage"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/suppression"
+)
+
+var deliveries = metrics.NewCounter("notification_deliveries_total",
+	"Reminder delivery attempts by channel, provider and resulting status (sent, retrying, failed, suppressed).", "channel", "provider", "status")
+
+// Reminder is the payload of scheduler.reminder.due.v1.
+type Reminder struct {
+	AppointmentID string         `json:"appointment_id"`
+	BusinessID    string         `json:"business_id"`
+	Channel       string         `json:"channel"`
+	Recipient     string         `json:"recipient"`
+	RemindAt      string         `json:"remind_at"`
+	TemplateData  map[string]any `json:"template_data"`
+}
+
+type Config struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// FailSuffix simulates a permanent failure for recipients ending with it (local testing).
+	FailSuffix string
+	// UnsubscribeBaseURL and UnsubscribeSecret add a signed unsubscribe link to
+	// emails; the link is omitted when either is empty.
+	UnsubscribeBaseURL string
+	UnsubscribeSecret  string
+}
+
+// Service sends a reminder through the channel's provider chain and records the
+// outcome: sent, queued for another attempt, or failed for good.
+type Service struct {
+	pool          *db.Pool
+	notifications *storage.Repository
+	retries       *storage.RetryRepository
+	suppressions  *suppression.Repository
+	outbox        *outbox.Repository
+	chains        map[string]*providers.Chain
+	logger        *slog.Logger
+	cfg           Config
+}
+
+func NewService(pool *db.Pool, notifications *storage.Repository, retries *storage.RetryRepository, suppressions *suppression.Repository, outboxRepo *outbox.Repository, chains map[string]*providers.Chain, logger *slog.Logger, cfg Config) *Service {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = 30 * time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 15 * time.Minute
+	}
+	return &Service{
+		pool:          pool,
+		notifications: notifications,
+		retries:       retries,
+		suppressions:  suppressions,
+		outbox:        outboxRepo,
+		chains:        chains,
+		logger:        logger,
+		cfg:           cfg,
+	}
+}
+
+// Process makes attempt number `attempt` (1-based) for r. retryID is the
+// notification_retries row being worked, or 0 for a first attempt.
+func (s *Service) Process(ctx context.Context, r Reminder, attempt int, retryID int64) error {
+	suppressed, err := s.suppressions.IsSuppressed(ctx, r.BusinessID, strings.ToLower(r.Channel), r.Recipient)
+	if err != nil {
+		return err
+	}
+	if suppressed {
+		return s.recordSuppressed(ctx, r, retryID)
+	}
+
+	var receipt providers.Receipt
+	var sendErr error
+	remindAt, err := time.Parse(time.RFC3339, r.RemindAt)
+	if err != nil {
+		// Without a valid time the reminder can be neither rendered nor
+		// retried, so it fails for good without a send.
+		sendErr = providers.Permanent(fmt.Errorf("invalid remind_at %q", r.RemindAt))
+	} else {
+		receipt, sendErr = s.send(ctx, r)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	status := "sent"
+	switch {
+	case sendErr == nil:
+		if err := s.recordSent(ctx, tx, r, receipt); err != nil {
+			return err
+		}
+	case providers.IsTransient(sendErr) && attempt < s.cfg.MaxAttempts:
+		status = "retrying"
+		nextRunAt := time.Now().UTC().Add(Backoff(attempt, s.cfg.BaseBackoff, s.cfg.MaxBackoff))
+		if retryID == 0 {
+			err = s.retries.Insert(ctx, tx, storage.Retry{
+				AppointmentID: r.AppointmentID,
+				BusinessID:    r.BusinessID,
+				Channel:       r.Channel,
+				Recipient:     r.Recipient,
+				RemindAt:      remindAt,
+				TemplateData:  r.TemplateData,
+				Attempts:      attempt,
+				LastError:     sendErr.Error(),
+				NextRunAt:     nextRunAt,
+			})
+		} else {
+			err = s.retries.Reschedule(ctx, tx, retryID, attempt, nextRunAt, sendErr.Error())
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		status = "failed"
+		if err := s.recordFailed(ctx, tx, r, sendErr.Error(), attempt); err != nil {
+			return err
+		}
+	}
+	if retryID != 0 && status != "retrying" {
+		if err := s.retries.Delete(ctx, tx, retryID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	deliveries.Inc(s.channelLabel(r.Channel), deliveryProvider(receipt, sendErr), status)
+
+	if sendErr != nil {
+		s.logger.Error("notification send failed", "err", sendErr, "appointment_id", r.AppointmentID, "channel", r.Channel, "attempt", attempt, "status", status)
+	}
+	s.logger.Info("reminder processed", "appointment_id", r.AppointmentID, "channel", r.Channel, "status", status, "attempt", attempt)
+	return nil
+}
+
+func (s *Service) send(ctx context.Context, r Reminder) (providers.Receipt, error) {
+	if s.cfg.FailSuffix != "" && strings.HasSuffix(r.Recipient, s.cfg.FailSuffix) {
+		return providers.Receipt{}, providers.Permanent(errors.New("simulated failure"))
+	}
+
+	channel := strings.ToLower(r.Channel)
+	chain, ok := s.chains[channel]
+	if !ok {
+		return providers.Receipt{}, providers.Permanent(errors.New("unsupported channel: " + r.Channel))
+	}
+
+	msg := providers.Message{To: r.Recipient}
+	switch channel {
+	case "email":
+		msg.Subject = "Appointment reminder"
+		msg.Body = fmt.Sprintf("Reminder for appointment %s at %s.", r.AppointmentID, r.RemindAt)
+	default:
+		msg.Body = fmt.Sprintf("Reminder: appointment %s at %s.", r.AppointmentID, r.RemindAt)
+	}
+	if name, ok := r.TemplateData["business_name"].(string); ok && name != "" {
+		msg.Body = fmt.Sprintf("[%s] %s", name, msg.Body)
+	}
+	if channel == "email" {
+		if link := s.unsubscribeLink(r); link != "" {
+			msg.Body += "\n\nTo stop receiving these reminders: " + link
+			// RFC 8058 one-click: the client POSTs to the link, which the
+			// unsubscribe handler applies without the confirmation page.
+			msg.Headers = map[string]string{
+				"List-Unsubscribe":      "<" + link + ">",
+				"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+			}
+		}
+	}
+	return chain.Send(ctx, msg)
+}
+
+// channelLabel keeps unknown channels from reaching metric labels.
+func (s *Service) channelLabel(channel string) string {
+	channel = strings.ToLower(channel)
+	if _, ok := s.chains[channel]; !ok {
+		return "other"
+	}
+	return channel
+}
+
+// deliveryProvider names the provider that accepted or rejected the message,
+// or "none" when no provider gave a verdict.
+func deliveryProvider(receipt providers.Receipt, sendErr error) string {
+	if receipt.ProviderID != "" {
+		return receipt.ProviderID
+	}
+	var se *providers.SendError
+	if errors.As(sendErr, &se) && se.ProviderID != "" {
+		return se.ProviderID
+	}
+	return "none"
+}
+
+func (s *Service) unsubscribeLink(r Reminder) string {
+	if s.cfg.UnsubscribeBaseURL == "" || s.cfg.UnsubscribeSecret == "" {
+		return ""
+	}
+	token, err := suppression.SignToken(s.cfg.UnsubscribeSecret, suppression.Subject{
+		BusinessID: r.BusinessID,
+		Channel:    "email",
+		Recipient:  suppression.NormalizeRecipient("email", r.Recipient),
+	})
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(s.cfg.UnsubscribeBaseURL, "/") + "/api/v1/notifications/unsubscribe?token=" + url.QueryEscape(token)
+}
+
+// recordSuppressed stores the skipped reminder without emitting an event; the
+// recipient opted out (or bounced), which is neither a send nor a failure.
+func (s *Service) recordSuppressed(ctx context.Context, r Reminder, retryID int64) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := s.notifications.Insert(ctx, tx, storage.Notification{
+		AppointmentID: r.AppointmentID,
+		BusinessID:    r.BusinessID,
+		Channel:       r.Channel,
+		Recipient:     r.Recipient,
+		Payload:       r.TemplateData,
+		Status:        "suppressed",
+	}); err != nil {
+		return err
+	}
+	if retryID != 0 {
+		if err := s.retries.Delete(ctx, tx, retryID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	deliveries.Inc(s.channelLabel(r.Channel), "none", "suppressed")
+	s.logger.Info("reminder suppressed", "appointment_id", r.AppointmentID, "channel", r.Channel)
+	return nil
+}
+
+func (s *Service) recordSent(ctx context.Context, tx pgx.Tx, r Reminder, receipt providers.Receipt) error {
+	if err := s.notifications.Insert(ctx, tx, storage.Notification{
+		AppointmentID:     r.AppointmentID,
+		BusinessID:        r.BusinessID,
+		Channel:           r.Channel,
+		Recipient:         r.Recipient,
+		Payload:           r.TemplateData,
+		Status:            "sent",
+		ProviderID:        receipt.ProviderID,
+		ProviderMessageID: receipt.MessageID,
+	}); err != nil {
+		return err
+	}
+
+	providerID := receipt.ProviderID
+	if strings.TrimSpace(providerID) == "" {
+		providerID = "unknown"
+	}
+	payload, err := json.Marshal(map[string]any{
+		"appointment_id": r.AppointmentID,
+		"business_id":    r.BusinessID,
+		"channel":        r.Channel,
+		"provider_id":    providerID,
+		"sent_at":        time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	return s.outbox.Insert(ctx, tx, outbox.Event{
+		AggregateType: "notification",
+		AggregateID:   r.AppointmentID,
+		EventType:     "notification.sent.v1",
+		Payload:       payload,
+	})
+}
+
+func (s *Service) recordFailed(ctx context.Context, tx pgx.Tx, r Reminder, reason string, attempts int) error {
+	if err := s.notifications.Insert(ctx, tx, storage.Notification{
+		AppointmentID: r.AppointmentID,
+		BusinessID:    r.BusinessID,
+		Channel:       r.Channel,
+		Recipient:     r.Recipient,
+		Payload:       r.TemplateData,
+		Status:        "failed",
+	}); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"appointment_id": r.AppointmentID,
+		"business_id":    r.BusinessID,
+		"channel":        r.Channel,
+		"error_reason":   reason,
+		"attempts":       attempts,
+		"failed_at":      time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	return s.outbox.Insert(ctx, tx, outbox.Event{
+		AggregateType: "notification",
+		AggregateID:   r.AppointmentID,
+		EventType:     "notification.failed.v1",
+		Payload:       payload,
+	})
+}
+
+// Backoff returns the delay before the attempt following `attempt`:
+// base, 2*base, 4*base, ... capped at max.
+func Backoff(attempt int, base time.Duration, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := base
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
@@ -0,0 +1,209 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+	"github.com/md-rashed-zaman/apptremind/libs/db/dbtest"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/providers"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/sms"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/storage"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/suppression"
+)
+
+func TestBackoffDoublesAndCaps(t *testing.T) {
+	base := 30 * time.Second
+	max := 5 * time.Minute
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, w := range want {
+		if got := Backoff(i+1, base, max); got != w {
+			t.Fatalf("attempt %d: got %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestEmailReminderCarriesOneClickUnsubscribe(t *testing.T) {
+	provider := &stubProvider{}
+	chain := providers.NewChain("email", nil, providers.BreakerConfig{FailureThreshold: 100, Cooldown: time.Minute}, provider)
+	svc := NewService(nil, nil, nil, nil, nil, map[string]*providers.Chain{"email": chain}, slog.Default(), Config{
+		UnsubscribeBaseURL: "https://app.example.com/",
+		UnsubscribeSecret:  "unsubscribe-secret",
+	})
+	r := testReminder()
+	if _, err := svc.send(context.Background(), r); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	msg := provider.sent[0]
+	link := strings.TrimSuffix(strings.TrimPrefix(msg.Headers["List-Unsubscribe"], "<"), ">")
+	if !strings.HasPrefix(link, "https://app.example.com/api/v1/notifications/unsubscribe?token=") || !strings.Contains(msg.Body, link) {
+		t.Fatalf("List-Unsubscribe %q does not match the body link: %s", msg.Headers["List-Unsubscribe"], msg.Body)
+	}
+	if msg.Headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Fatalf("missing one-click header: %v", msg.Headers)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parse link: %v", err)
+	}
+	subject, err := suppression.VerifyToken("unsubscribe-secret", u.Query().Get("token"))
+	if err != nil || subject.BusinessID != r.BusinessID || subject.Recipient != r.Recipient {
+		t.Fatalf("token subject %+v, %v", subject, err)
+	}
+}
+
+type stubProvider struct {
+	errs  []error
+	calls int
+	sent  []providers.Message
+}
+
+func (p *stubProvider) ID() string { return "stub" }
+
+// Send fails with the next queued error, then succeeds.
+func (p *stubProvider) Send(_ context.Context, msg providers.Message) (string, error) {
+	p.calls++
+	p.sent = append(p.sent, msg)
+	if len(p.errs) == 0 {
+		return "stub-msg", nil
+	}
+	err := p.errs[0]
+	p.errs = p.errs[1:]
+	return "", err
+}
+
+func newTestService(t *testing.T, provider *stubProvider, maxAttempts int) (*Service, *RetryWorker, *db.Pool) {
+	t.Helper()
+	pool := dbtest.Open(t, "../../migrations")
+	retries := storage.NewRetryRepository(pool)
+	chain := providers.NewChain("email", nil, providers.BreakerConfig{FailureThreshold: 100, Cooldown: time.Minute}, provider)
+	svc := NewService(pool, storage.NewRepository(pool), retries, suppression.NewRepository(pool), outbox.NewRepository(pool),
+		map[string]*providers.Chain{"email": chain}, slog.Default(), Config{
+			MaxAttempts: maxAttempts,
+			BaseBackoff: time.Millisecond,
+			MaxBackoff:  time.Millisecond,
+		})
+	return svc, NewRetryWorker(svc, retries, slog.Default(), RetryWorkerConfig{}), pool
+}
+
+func testReminder() Reminder {
+	return Reminder{
+		AppointmentID: uuid.NewString(),
+		BusinessID:    uuid.NewString(),
+		Channel:       "email",
+		Recipient:     "ana@example.com",
+		RemindAt:      "2026-03-01T09:00:00Z",
+		TemplateData:  map[string]any{},
+	}
+}
+
+// outcome reports the notification statuses, pending retries and outbox
+// events recorded for appointmentID.
+func outcome(t *testing.T, pool *db.Pool, appointmentID string) (statuses []string, retries int, events []string) {
+	t.Helper()
+	ctx := context.Background()
+	rows, err := pool.Query(ctx, `SELECT status FROM notifications WHERE appointment_id = $1 ORDER BY id`, appointmentID)
+	if err != nil {
+		t.Fatalf("query notifications: %v", err)
+	}
+	statuses, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatalf("scan notifications: %v", err)
+	}
+	if err := pool.QueryRow(ctx, `SELECT count(*) FROM notification_retries WHERE appointment_id = $1`, appointmentID).Scan(&retries); err != nil {
+		t.Fatalf("count retries: %v", err)
+	}
+	rows, err = pool.Query(ctx, `SELECT event_type FROM outbox_events WHERE aggregate_id = $1 ORDER BY id`, appointmentID)
+	if err != nil {
+		t.Fatalf("query outbox: %v", err)
+	}
+	events, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatalf("scan outbox: %v", err)
+	}
+	return statuses, retries, events
+}
+
+func TestTransientFailureIsRetriedUntilMaxAttempts(t *testing.T) {
+	provider := &stubProvider{errs: []error{&sms.StatusError{StatusCode: 503}, &sms.StatusError{StatusCode: 503}}}
+	svc, worker, pool := newTestService(t, provider, 2)
+	ctx := context.Background()
+	r := testReminder()
+
+	if err := svc.Process(ctx, r, 1, 0); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	statuses, retries, events := outcome(t, pool, r.AppointmentID)
+	if len(statuses) != 0 || retries != 1 || len(events) != 0 {
+		t.Fatalf("after attempt 1: statuses %v, retries %d, events %v", statuses, retries, events)
+	}
+	var remindAt time.Time
+	if err := pool.QueryRow(ctx, `SELECT remind_at FROM notification_retries WHERE appointment_id = $1`, r.AppointmentID).Scan(&remindAt); err != nil || !remindAt.Equal(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("retry remind_at %v, %v", remindAt, err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := worker.processBatch(ctx); err != nil {
+		t.Fatalf("retry batch: %v", err)
+	}
+	statuses, retries, events = outcome(t, pool, r.AppointmentID)
+	if provider.calls != 2 || len(statuses) != 1 || statuses[0] != "failed" || retries != 0 || len(events) != 1 || events[0] != "notification.failed.v1" {
+		t.Fatalf("after attempt 2: calls %d, statuses %v, retries %d, events %v", provider.calls, statuses, retries, events)
+	}
+}
+
+func TestRetrySucceedsOnSecondAttempt(t *testing.T) {
+	provider := &stubProvider{errs: []error{&sms.StatusError{StatusCode: 429}}}
+	svc, worker, pool := newTestService(t, provider, 5)
+	ctx := context.Background()
+	r := testReminder()
+
+	if err := svc.Process(ctx, r, 1, 0); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := worker.processBatch(ctx); err != nil {
+		t.Fatalf("retry batch: %v", err)
+	}
+	statuses, retries, events := outcome(t, pool, r.AppointmentID)
+	if len(statuses) != 1 || statuses[0] != "sent" || retries != 0 || len(events) != 1 || events[0] != "notification.sent.v1" {
+		t.Fatalf("statuses %v, retries %d, events %v", statuses, retries, events)
+	}
+}
+
+func TestPermanentFailuresAreNotRetried(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err      error
+		remindAt string
+		calls    int
+	}{
+		"unknown provider error": {err: errors.New("unexpected response"), remindAt: "2026-03-01T09:00:00Z", calls: 1},
+		"rejected credentials":   {err: &sms.StatusError{StatusCode: 401}, remindAt: "2026-03-01T09:00:00Z", calls: 1},
+		"invalid remind_at":      {remindAt: "tomorrow", calls: 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			provider := &stubProvider{}
+			if tc.err != nil {
+				provider.errs = []error{tc.err}
+			}
+			svc, _, pool := newTestService(t, provider, 5)
+			r := testReminder()
+			r.RemindAt = tc.remindAt
+			if err := svc.Process(context.Background(), r, 1, 0); err != nil {
+				t.Fatalf("process: %v", err)
+			}
+			statuses, retries, events := outcome(t, pool, r.AppointmentID)
+			if provider.calls != tc.calls || len(statuses) != 1 || statuses[0] != "failed" || retries != 0 || len(events) != 1 || events[0] != "notification.failed.v1" {
+				t.Fatalf("calls %d, statuses %v, retries %d, events %v", provider.calls, statuses, retries, events)
+			}
+		})
+	}
+}
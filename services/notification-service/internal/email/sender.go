@@ -3,15 +3,23 @@ package email
 import (
 	"fmt"
 	"net/smtp"
+	"sort"
 	"strings"
+
+	"github.com/google/uuid"
 )
 
 type Sender interface {
-	Send(to string, subject string, body string) error
+	// Send returns the Message-ID assigned to the email so delivery
+	// notifications can be matched back to the notification row. headers
+	// adds extra header fields such as List-Unsubscribe; may be nil.
+	Send(to string, subject string, body string, headers map[string]string) (string, error)
+	ProviderID() string
 }
 
 // SMTPSender sends email via unauthenticated SMTP (Mailpit-compatible).
 type SMTPSender struct {
+	id   string
 	addr string
 	from string
 }
@@ -24,23 +32,63 @@ func NewSMTPSender(host string, port string, from string) *SMTPSender {
 		from = "no-reply@apptremind.local"
 	}
 	return &SMTPSender{
+		id:   "smtp",
 		addr: fmt.Sprintf("%s:%s", host, port),
 		from: from,
 	}
 }
 
-func (s *SMTPSender) Send(to string, subject string, body string) error {
-	msg := buildMessage(s.from, to, subject, body)
-	return smtp.SendMail(s.addr, nil, s.from, []string{to}, []byte(msg))
+// SetProviderID overrides the default "smtp" ID, e.g. for a secondary relay.
+func (s *SMTPSender) SetProviderID(id string) {
+	if id = strings.TrimSpace(id); id != "" {
+		s.id = id
+	}
+}
+
+func (s *SMTPSender) ProviderID() string {
+	return s.id
+}
+
+func (s *SMTPSender) Send(to string, subject string, body string, headers map[string]string) (string, error) {
+	messageID := newMessageID(s.from)
+	msg := buildMessage(s.from, to, subject, body, messageID, headers)
+	if err := smtp.SendMail(s.addr, nil, s.from, []string{to}, []byte(msg)); err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
+
+func newMessageID(from string) string {
+	domain := "apptremind.local"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = from[at+1:]
+	}
+	return uuid.NewString() + "@" + domain
 }
 
-func buildMessage(from, to, subject, body string) string {
+func buildMessage(from, to, subject, body, messageID string, headers map[string]string) string {
 	// Minimal RFC 5322 message; enough for Mailpit and most SMTP relays.
+	var extra strings.Builder
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := headers[name]
+		if strings.ContainsAny(name, "\r\n:") || strings.ContainsAny(value, "\r\n") {
+			// A line break would let the value inject further headers.
+			continue
+		}
+		extra.WriteString(name + ": " + value + "\r\n")
+	}
 	return fmt.Sprintf(
-		"From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nMessage-ID: <%s>\r\n%sMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
 		from,
 		to,
 		subject,
+		messageID,
+		extra.String(),
 		body,
 	)
 }
@@ -0,0 +1,29 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildMessageAddsHeadersWithoutInjection(t *testing.T) {
+	msg := buildMessage("no-reply@example.com", "ana@example.com", "Reminder", "body", "id@example.com", map[string]string{
+		"List-Unsubscribe":      "<https://app.example.com/unsubscribe?token=t>",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		"X-Injected":            "ok\r\nBcc: mallory@example.com",
+	})
+	head, body, _ := strings.Cut(msg, "\r\n\r\n")
+	for _, want := range []string{
+		"\r\nList-Unsubscribe: <https://app.example.com/unsubscribe?token=t>\r\n",
+		"\r\nList-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n",
+	} {
+		if !strings.Contains(head+"\r\n", want) {
+			t.Fatalf("missing %q in headers:\n%s", want, head)
+		}
+	}
+	if strings.Contains(head, "Bcc:") || strings.Contains(head, "X-Injected") {
+		t.Fatalf("header with a line break was written:\n%s", head)
+	}
+	if body != "body\r\n" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/providers"
+)
+
+const debugTokenHeader = "X-Internal-Token"
+
+// ProviderHealth reports a provider chain's breaker state.
+type ProviderHealth interface {
+	Health() []providers.Health
+}
+
+// DebugHandler exposes provider breaker state to operators. It reveals which
+// providers are configured and failing, so it requires a shared token and is
+// disabled when none is set.
+type DebugHandler struct {
+	email ProviderHealth
+	sms   ProviderHealth
+	token string
+}
+
+func NewDebugHandler(email, sms ProviderHealth, token string) *DebugHandler {
+	return &DebugHandler{email: email, sms: sms, token: strings.TrimSpace(token)}
+}
+
+func (h *DebugHandler) Providers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.token == "" {
+		http.Error(w, "debug api not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(debugTokenHeader)), []byte(h.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"email": h.email.Health(),
+		"sms":   h.sms.Health(),
+	})
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/providers"
+)
+
+type fixedHealth []providers.Health
+
+func (f fixedHealth) Health() []providers.Health { return f }
+
+func TestDebugProvidersRequiresToken(t *testing.T) {
+	health := fixedHealth{{ProviderID: "smtp", Channel: "email"}}
+	for _, tc := range []struct {
+		name, configured, sent string
+		want                   int
+	}{
+		{"disabled", "", "", http.StatusServiceUnavailable},
+		{"missing token", "debug-secret", "", http.StatusUnauthorized},
+		{"wrong token", "debug-secret", "guess", http.StatusUnauthorized},
+		{"valid token", "debug-secret", "debug-secret", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/debug/providers", nil)
+		if tc.sent != "" {
+			req.Header.Set(debugTokenHeader, tc.sent)
+		}
+		rw := httptest.NewRecorder()
+		NewDebugHandler(health, health, tc.configured).Providers(rw, req)
+		if rw.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, rw.Code)
+		}
+		if tc.want == http.StatusOK && !strings.Contains(rw.Body.String(), `"provider_id":"smtp"`) {
+			t.Fatalf("%s: unexpected body %s", tc.name, rw.Body.String())
+		}
+	}
+}
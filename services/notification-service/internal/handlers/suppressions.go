@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/suppression"
+)
+
+// SuppressionHandler serves the per-business suppression list (owner/admin via the
+// gateway) and the public unsubscribe link embedded in reminder emails.
+type SuppressionHandler struct {
+	pool              *db.Pool
+	repo              *suppression.Repository
+	logger            *slog.Logger
+	unsubscribeSecret string
+}
+
+func NewSuppressionHandler(pool *db.Pool, repo *suppression.Repository, logger *slog.Logger, unsubscribeSecret string) *SuppressionHandler {
+	return &SuppressionHandler{
+		pool:              pool,
+		repo:              repo,
+		logger:            logger,
+		unsubscribeSecret: strings.TrimSpace(unsubscribeSecret),
+	}
+}
+
+type suppressionRequest struct {
+	BusinessID string `json:"business_id,omitempty"` // admin only
+	Channel    string `json:"channel"`
+	Recipient  string `json:"recipient"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// Suppressions handles GET (list) and POST (add a manual entry).
+func (h *SuppressionHandler) Suppressions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.list(w, r)
+	case http.MethodPost:
+		h.add(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *SuppressionHandler) list(w http.ResponseWriter, r *http.Request) {
+	businessID := resolveBusinessID(r, r.URL.Query().Get("business_id"))
+	if businessID == "" {
+		http.Error(w, "business_id is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(businessID); err != nil {
+		http.Error(w, "business_id must be a UUID", http.StatusBadRequest)
+		return
+	}
+	channel := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("channel")))
+	if channel != "" && !validChannel(channel) {
+		http.Error(w, "channel must be email or sms", http.StatusBadRequest)
+		return
+	}
+	limit, _ := strconv.Atoi(strings.TrimSpace(r.URL.Query().Get("limit")))
+
+	entries, err := h.repo.List(r.Context(), businessID, channel, limit)
+	if err != nil {
+		http.Error(w, "failed to list suppressions", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []suppression.Entry{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": entries})
+}
+
+func (h *SuppressionHandler) add(w http.ResponseWriter, r *http.Request) {
+	req, businessID, ok := decodeSuppressionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := h.pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	if err := h.repo.Add(r.Context(), tx, suppression.Entry{
+		BusinessID: businessID,
+		Channel:    req.Channel,
+		Recipient:  req.Recipient,
+		Reason:     suppression.ReasonManual,
+		Detail:     strings.TrimSpace(req.Detail),
+	}); err != nil {
+		http.Error(w, "failed to add suppression", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("suppression added", "business_id", businessID, "channel", req.Channel, "actor_id", r.Header.Get("X-User-Id"))
+	writeJSON(w, http.StatusCreated, map[string]any{"status": "suppressed"})
+}
+
+// Remove deletes an entry so reminders to the recipient resume.
+func (h *SuppressionHandler) Remove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, businessID, ok := decodeSuppressionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := h.repo.Remove(r.Context(), businessID, req.Channel, req.Recipient)
+	if err != nil {
+		http.Error(w, "failed to remove suppression", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "suppression not found", http.StatusNotFound)
+		return
+	}
+
+	h.logger.Info("suppression removed", "business_id", businessID, "channel", req.Channel, "actor_id", r.Header.Get("X-User-Id"))
+	writeJSON(w, http.StatusOK, map[string]any{"status": "removed"})
+}
+
+// Unsubscribe is the public target of the email unsubscribe link. GET renders a
+// confirmation form (link scanners must not unsubscribe anyone); POST applies it,
+// which is also the one-click target of the List-Unsubscribe header (RFC 8058).
+func (h *SuppressionHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.unsubscribeSecret == "" {
+		http.Error(w, "unsubscribe not configured", http.StatusServiceUnavailable)
+		return
+	}
+	token := strings.TrimSpace(r.URL.Query().Get("token"))
+	subject, err := suppression.VerifyToken(h.unsubscribeSecret, token)
+	if err != nil {
+		http.Error(w, "invalid unsubscribe link", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		renderUnsubscribePage(w, "Unsubscribe from reminders?",
+			`<form method="post" action="?token=`+html.EscapeString(token)+`"><button type="submit">Unsubscribe `+html.EscapeString(subject.Recipient)+`</button></form>`)
+		return
+	}
+
+	tx, err := h.pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	if err := h.repo.Add(r.Context(), tx, suppression.Entry{
+		BusinessID: subject.BusinessID,
+		Channel:    subject.Channel,
+		Recipient:  subject.Recipient,
+		Reason:     suppression.ReasonUnsubscribe,
+	}); err != nil {
+		http.Error(w, "failed to unsubscribe", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("recipient unsubscribed", "business_id", subject.BusinessID, "channel", subject.Channel)
+	renderUnsubscribePage(w, "You have been unsubscribed", `<p>You will no longer receive appointment reminders from this business.</p>`)
+}
+
+func decodeSuppressionRequest(w http.ResponseWriter, r *http.Request) (suppressionRequest, string, bool) {
+	var req suppressionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return req, "", false
+	}
+	req.Channel = strings.ToLower(strings.TrimSpace(req.Channel))
+	req.Recipient = strings.TrimSpace(req.Recipient)
+
+	businessID := resolveBusinessID(r, req.BusinessID)
+	if businessID == "" || req.Recipient == "" {
+		http.Error(w, "business_id and recipient are required", http.StatusBadRequest)
+		return req, "", false
+	}
+	if _, err := uuid.Parse(businessID); err != nil {
+		http.Error(w, "business_id must be a UUID", http.StatusBadRequest)
+		return req, "", false
+	}
+	if !validChannel(req.Channel) {
+		http.Error(w, "channel must be email or sms", http.StatusBadRequest)
+		return req, "", false
+	}
+	return req, businessID, true
+}
+
+// resolveBusinessID scopes requests to the caller's business; only admins may
+// act on another business.
+func resolveBusinessID(r *http.Request, requested string) string {
+	callerBusinessID := strings.TrimSpace(r.Header.Get("X-Business-Id"))
+	requested = strings.TrimSpace(requested)
+	if r.Header.Get("X-Role") == "admin" && requested != "" {
+		return requested
+	}
+	return callerBusinessID
+}
+
+func validChannel(channel string) bool {
+	return channel == "email" || channel == "sms"
+}
+
+func renderUnsubscribePage(w http.ResponseWriter, title string, content string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`<!doctype html><html><head><meta charset="utf-8"><title>` + html.EscapeString(title) + `</title></head><body>`))
+	_, _ = w.Write([]byte(`<h1>` + html.EscapeString(title) + `</h1>` + content + `</body></html>`))
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/md-rashed-zaman/apptremind/libs/db/dbtest"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/suppression"
+)
+
+const testUnsubscribeSecret = "unsubscribe-secret"
+
+func newTestSuppressionHandler(t *testing.T) *SuppressionHandler {
+	t.Helper()
+	pool := dbtest.Open(t, "../../migrations")
+	return NewSuppressionHandler(pool, suppression.NewRepository(pool), slog.Default(), testUnsubscribeSecret)
+}
+
+func suppressionRequestFor(method, target, businessID, role, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("X-Business-Id", businessID)
+	req.Header.Set("X-Role", role)
+	return req
+}
+
+func listedRecipients(t *testing.T, h *SuppressionHandler, businessID string) []string {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	h.Suppressions(rw, suppressionRequestFor(http.MethodGet, "/api/v1/notifications/suppressions", businessID, "owner", ""))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("list: expected 200, got %d: %s", rw.Code, rw.Body.String())
+	}
+	var out struct {
+		Items []suppression.Entry `json:"items"`
+	}
+	if err := json.Unmarshal(rw.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	recipients := make([]string, 0, len(out.Items))
+	for _, e := range out.Items {
+		recipients = append(recipients, e.Channel+":"+e.Recipient)
+	}
+	return recipients
+}
+
+func TestSuppressionsAddListRemove(t *testing.T) {
+	h := newTestSuppressionHandler(t)
+	businessID := uuid.NewString()
+	otherBusiness := uuid.NewString()
+
+	rw := httptest.NewRecorder()
+	h.Suppressions(rw, suppressionRequestFor(http.MethodPost, "/api/v1/notifications/suppressions", businessID, "owner",
+		`{"channel":"Email","recipient":" Ana@Example.com ","detail":"asked by phone"}`))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("add: expected 201, got %d: %s", rw.Code, rw.Body.String())
+	}
+	// An owner cannot write into another business by naming it.
+	rw = httptest.NewRecorder()
+	h.Suppressions(rw, suppressionRequestFor(http.MethodPost, "/api/v1/notifications/suppressions", businessID, "owner",
+		`{"business_id":"`+otherBusiness+`","channel":"sms","recipient":"+15550001"}`))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("add sms: expected 201, got %d", rw.Code)
+	}
+
+	got := listedRecipients(t, h, businessID)
+	if len(got) != 2 || !slices.Contains(got, "email:ana@example.com") || !slices.Contains(got, "sms:+15550001") {
+		t.Fatalf("unexpected list %v", got)
+	}
+	if got := listedRecipients(t, h, otherBusiness); len(got) != 0 {
+		t.Fatalf("owner wrote into another business: %v", got)
+	}
+
+	rw = httptest.NewRecorder()
+	h.Remove(rw, suppressionRequestFor(http.MethodPost, "/api/v1/notifications/suppressions/remove", businessID, "owner",
+		`{"channel":"email","recipient":"ANA@example.com"}`))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("remove: expected 200, got %d: %s", rw.Code, rw.Body.String())
+	}
+	rw = httptest.NewRecorder()
+	h.Remove(rw, suppressionRequestFor(http.MethodPost, "/api/v1/notifications/suppressions/remove", businessID, "owner",
+		`{"channel":"email","recipient":"ana@example.com"}`))
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("second remove: expected 404, got %d", rw.Code)
+	}
+	if got := listedRecipients(t, h, businessID); len(got) != 1 || got[0] != "sms:+15550001" {
+		t.Fatalf("unexpected list after remove %v", got)
+	}
+}
+
+func TestSuppressionsAdminActsOnNamedBusiness(t *testing.T) {
+	h := newTestSuppressionHandler(t)
+	target := uuid.NewString()
+
+	rw := httptest.NewRecorder()
+	h.Suppressions(rw, suppressionRequestFor(http.MethodPost, "/api/v1/notifications/suppressions", uuid.NewString(), "admin",
+		`{"business_id":"`+target+`","channel":"email","recipient":"ana@example.com"}`))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("admin add: expected 201, got %d", rw.Code)
+	}
+	if got := listedRecipients(t, h, target); len(got) != 1 || got[0] != "email:ana@example.com" {
+		t.Fatalf("unexpected list %v", got)
+	}
+}
+
+func TestSuppressionsRejectInvalidInput(t *testing.T) {
+	// Validation runs before any storage access, so no database is needed.
+	h := NewSuppressionHandler(nil, nil, slog.Default(), testUnsubscribeSecret)
+	businessID := uuid.NewString()
+	for _, tc := range []struct {
+		name, method, target, role, body string
+	}{
+		{"admin business_id not a uuid", http.MethodPost, "/s", "admin", `{"business_id":"not-a-uuid","channel":"email","recipient":"a@example.com"}`},
+		{"admin list business_id not a uuid", http.MethodGet, "/s?business_id=x'--", "admin", ""},
+		{"missing recipient", http.MethodPost, "/s", "owner", `{"channel":"email"}`},
+		{"unknown channel", http.MethodPost, "/s", "owner", `{"channel":"fax","recipient":"a@example.com"}`},
+		{"list unknown channel", http.MethodGet, "/s?channel=fax", "owner", ""},
+		{"bad json", http.MethodPost, "/s", "owner", `{`},
+	} {
+		rw := httptest.NewRecorder()
+		h.Suppressions(rw, suppressionRequestFor(tc.method, tc.target, businessID, tc.role, tc.body))
+		if rw.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tc.name, rw.Code)
+		}
+	}
+}
+
+func TestUnsubscribeConfirmsThenSuppresses(t *testing.T) {
+	h := newTestSuppressionHandler(t)
+	businessID := uuid.NewString()
+	token, err := suppression.SignToken(testUnsubscribeSecret, suppression.Subject{
+		BusinessID: businessID,
+		Channel:    "email",
+		Recipient:  "ana@example.com",
+	})
+	if err != nil {
+		t.Fatalf("SignToken: %v", err)
+	}
+	target := "/api/v1/notifications/unsubscribe?token=" + url.QueryEscape(token)
+
+	// GET only renders the confirmation form, so link scanners change nothing.
+	rw := httptest.NewRecorder()
+	h.Unsubscribe(rw, httptest.NewRequest(http.MethodGet, target, nil))
+	if rw.Code != http.StatusOK || !strings.Contains(rw.Body.String(), `<form method="post"`) {
+		t.Fatalf("get: unexpected %d %s", rw.Code, rw.Body.String())
+	}
+	if got := listedRecipients(t, h, businessID); len(got) != 0 {
+		t.Fatalf("GET unsubscribed: %v", got)
+	}
+
+	// One-click clients POST the RFC 8058 body to the List-Unsubscribe URL.
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader("List-Unsubscribe=One-Click"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rw = httptest.NewRecorder()
+	h.Unsubscribe(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("post: expected 200, got %d", rw.Code)
+	}
+	if got := listedRecipients(t, h, businessID); len(got) != 1 || got[0] != "email:ana@example.com" {
+		t.Fatalf("unexpected list after unsubscribe %v", got)
+	}
+
+	forged, _ := suppression.SignToken("other-secret", suppression.Subject{BusinessID: businessID, Channel: "sms", Recipient: "+15550001"})
+	rw = httptest.NewRecorder()
+	h.Unsubscribe(rw, httptest.NewRequest(http.MethodPost, "/api/v1/notifications/unsubscribe?token="+url.QueryEscape(forged), nil))
+	if rw.Code != http.StatusBadRequest {
+		t.Fatalf("forged token: expected 400, got %d", rw.Code)
+	}
+}
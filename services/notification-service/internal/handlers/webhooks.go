@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/storage"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/suppression"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/twoway"
+)
+
+const (
+	signatureHeader = "X-Webhook-Signature"
+	timestampHeader = "X-Webhook-Timestamp"
+)
+
+var (
+	errMissingSignature = errors.New("missing signature")
+	errStaleTimestamp   = errors.New("timestamp outside tolerance")
+	errBadSignature     = errors.New("invalid signature")
+)
+
+type WebhookConfig struct {
+	SMSSecret   string
+	EmailSecret string
+	// Tolerance bounds how old a signed callback may be (replay protection).
+	Tolerance time.Duration
+}
+
+// WebhookHandler receives delivery callbacks from SMS and email providers.
+// There is no JWT on these routes; the HMAC signature is the auth.
+type WebhookHandler struct {
+	pool          *db.Pool
+	notifications *storage.Repository
+	suppressions  *suppression.Repository
+	outbox        *outbox.Repository
+	twoWay        *twoway.Service
+	logger        *slog.Logger
+	cfg           WebhookConfig
+}
+
+func NewWebhookHandler(pool *db.Pool, notifications *storage.Repository, suppressions *suppression.Repository, outboxRepo *outbox.Repository, twoWay *twoway.Service, logger *slog.Logger, cfg WebhookConfig) *WebhookHandler {
+	if cfg.Tolerance <= 0 {
+		cfg.Tolerance = 5 * time.Minute
+	}
+	return &WebhookHandler{
+		pool:          pool,
+		notifications: notifications,
+		suppressions:  suppressions,
+		outbox:        outboxRepo,
+		twoWay:        twoWay,
+		logger:        logger,
+		cfg:           cfg,
+	}
+}
+
+type smsReceiptRequest struct {
+	EventID      string `json:"event_id"`
+	MessageID    string `json:"message_id"`
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message"`
+	OccurredAt   string `json:"occurred_at"`
+}
+
+type emailEventRequest struct {
+	EventID    string `json:"event_id"`
+	MessageID  string `json:"message_id"`
+	Type       string `json:"type"`
+	BounceType string `json:"bounce_type"`
+	Reason     string `json:"reason"`
+	OccurredAt string `json:"occurred_at"`
+}
+
+// deliveryOutcome is a provider callback normalised across channels.
+type deliveryOutcome struct {
+	Channel    string
+	EventID    string
+	MessageID  string
+	Delivered  bool
+	BounceType string // hard | soft | complaint
+	Reason     string
+	OccurredAt time.Time
+}
+
+// SMSReceipt handles SMS delivery receipts:
+// {"event_id","message_id","status":"delivered|undelivered|failed","error_message","occurred_at"}.
+func (h *WebhookHandler) SMSReceipt(w http.ResponseWriter, r *http.Request) {
+	body, ok := h.readSigned(w, r, h.cfg.SMSSecret)
+	if !ok {
+		return
+	}
+
+	var req smsReceiptRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	out := deliveryOutcome{
+		Channel:   "sms",
+		EventID:   strings.TrimSpace(req.EventID),
+		MessageID: strings.TrimSpace(req.MessageID),
+		Reason:    strings.TrimSpace(req.ErrorMessage),
+	}
+	switch strings.ToLower(strings.TrimSpace(req.Status)) {
+	case "delivered":
+		out.Delivered = true
+	case "undelivered":
+		// Carrier could not reach the handset right now (off, out of coverage).
+		out.BounceType = "soft"
+	case "failed":
+		out.BounceType = "hard"
+	default:
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	h.apply(w, r, out, req.OccurredAt, body)
+}
+
+// EmailEvent handles email delivery notifications:
+// {"event_id","message_id","type":"delivered|bounce|complaint","bounce_type":"hard|soft","reason","occurred_at"}.
+func (h *WebhookHandler) EmailEvent(w http.ResponseWriter, r *http.Request) {
+	body, ok := h.readSigned(w, r, h.cfg.EmailSecret)
+	if !ok {
+		return
+	}
+
+	var req emailEventRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	out := deliveryOutcome{
+		Channel:   "email",
+		EventID:   strings.TrimSpace(req.EventID),
+		MessageID: strings.Trim(strings.TrimSpace(req.MessageID), "<>"),
+		Reason:    strings.TrimSpace(req.Reason),
+	}
+	switch strings.ToLower(strings.TrimSpace(req.Type)) {
+	case "delivered":
+		out.Delivered = true
+	case "bounce":
+		out.BounceType = "hard"
+		if strings.EqualFold(strings.TrimSpace(req.BounceType), "soft") {
+			out.BounceType = "soft"
+		}
+	case "complaint":
+		out.BounceType = "complaint"
+	default:
+		http.Error(w, "invalid type", http.StatusBadRequest)
+		return
+	}
+	h.apply(w, r, out, req.OccurredAt, body)
+}
+
+func (h *WebhookHandler) readSigned(w http.ResponseWriter, r *http.Request, secret string) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	if strings.TrimSpace(secret) == "" {
+		http.Error(w, "webhook not configured", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // 1 MiB hard cap
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return nil, false
+	}
+	if err := verifySignature(secret, r.Header.Get(timestampHeader), r.Header.Get(signatureHeader), body, time.Now(), h.cfg.Tolerance); err != nil {
+		if errors.Is(err, errMissingSignature) {
+			http.Error(w, "missing "+signatureHeader+" or "+timestampHeader+" header", http.StatusBadRequest)
+			return nil, false
+		}
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return nil, false
+	}
+	return body, true
+}
+
+func (h *WebhookHandler) apply(w http.ResponseWriter, r *http.Request, out deliveryOutcome, occurredAtRaw string, body []byte) {
+	if out.EventID == "" || out.MessageID == "" {
+		http.Error(w, "missing required fields", http.StatusBadRequest)
+		return
+	}
+	out.OccurredAt = time.Now().UTC()
+	if raw := strings.TrimSpace(occurredAtRaw); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "invalid occurred_at", http.StatusBadRequest)
+			return
+		}
+		out.OccurredAt = t.UTC()
+	}
+	status := "delivered"
+	switch out.BounceType {
+	case "":
+	case "complaint":
+		status = "complained"
+	default:
+		status = "bounced"
+	}
+
+	ctx := r.Context()
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Idempotency: providers retry callbacks until they see a 2xx.
+	if err := h.notifications.InsertDeliveryEvent(ctx, tx, out.Channel, out.EventID, out.MessageID, status, body); err != nil {
+		if errors.Is(err, storage.ErrDuplicateDeliveryEvent) {
+			_ = tx.Commit(ctx)
+			writeJSON(w, http.StatusOK, map[string]any{"status": "duplicate"})
+			return
+		}
+		http.Error(w, "failed to record delivery event", http.StatusInternalServerError)
+		return
+	}
+
+	n, err := h.notifications.FindByProviderMessageID(ctx, tx, out.Channel, out.MessageID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			// Keep the callback for investigation but don't make the provider retry it.
+			if err := tx.Commit(ctx); err != nil {
+				http.Error(w, "db error", http.StatusInternalServerError)
+				return
+			}
+			h.logger.Warn("delivery callback for unknown message", "channel", out.Channel, "provider_message_id", out.MessageID)
+			writeJSON(w, http.StatusAccepted, map[string]any{"status": "unmatched"})
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	// A late "delivered" must not hide an earlier bounce or complaint.
+	if out.Delivered && (n.Status == "bounced" || n.Status == "complained") {
+		if err := tx.Commit(ctx); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ignored"})
+		return
+	}
+
+	if err := h.notifications.UpdateStatus(ctx, tx, n.ID, status); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.writeOutcomeEvent(r, tx, n, out); err != nil {
+		http.Error(w, "failed to enqueue event", http.StatusInternalServerError)
+		return
+	}
+	// Hard bounces and complaints stop future reminders; soft bounces may recover.
+	if reason := suppressionReason(out.BounceType); reason != "" {
+		if err := h.suppressions.Add(ctx, tx, suppression.Entry{
+			BusinessID: n.BusinessID,
+			Channel:    n.Channel,
+			Recipient:  n.Recipient,
+			Reason:     reason,
+			Detail:     out.Reason,
+		}); err != nil {
+			http.Error(w, "failed to record suppression", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("delivery status recorded",
+		"channel", out.Channel,
+		"provider_id", n.ProviderID,
+		"provider_message_id", out.MessageID,
+		"appointment_id", n.AppointmentID,
+		"status", status,
+	)
+	writeJSON(w, http.StatusOK, map[string]any{"status": status})
+}
+
+func suppressionReason(bounceType string) string {
+	switch bounceType {
+	case "hard":
+		return suppression.ReasonBounce
+	case "complaint":
+		return suppression.ReasonComplaint
+	default:
+		return ""
+	}
+}
+
+type inboundSMSRequest struct {
+	EventID    string `json:"event_id"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Body       string `json:"body"`
+	ReceivedAt string `json:"received_at"`
+}
+
+// InboundSMS handles replies to the reminder number:
+// {"event_id","from","to","body","received_at"}, where event_id is the provider's
+// message ID. The two-way service confirms or cancels the sender's latest
+// reminded upcoming appointment, applies STOP/START to the suppression list, and
+// texts back an auto-reply; a redelivered event_id is acknowledged only.
+func (h *WebhookHandler) InboundSMS(w http.ResponseWriter, r *http.Request) {
+	body, ok := h.readSigned(w, r, h.cfg.SMSSecret)
+	if !ok {
+		return
+	}
+
+	var req inboundSMSRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.EventID = strings.TrimSpace(req.EventID)
+	req.From = strings.TrimSpace(req.From)
+	if req.EventID == "" || req.From == "" {
+		http.Error(w, "event_id and from are required", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.twoWay.Handle(r.Context(), req.EventID, req.From, req.Body)
+	if err != nil {
+		h.logger.Error("inbound sms failed", "err", err, "event_id", req.EventID)
+		http.Error(w, "failed to process inbound sms", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("inbound sms processed", "event_id", req.EventID, "intent", res.Intent, "action", res.Action, "appointment_id", res.AppointmentID)
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (h *WebhookHandler) writeOutcomeEvent(r *http.Request, tx pgx.Tx, n storage.Notification, out deliveryOutcome) error {
+	providerID := n.ProviderID
+	if providerID == "" {
+		providerID = "unknown"
+	}
+	fields := map[string]any{
+		"appointment_id":      n.AppointmentID,
+		"business_id":         n.BusinessID,
+		"channel":             n.Channel,
+		"provider_id":         providerID,
+		"provider_message_id": out.MessageID,
+	}
+	eventType := "notification.delivered.v1"
+	if out.Delivered {
+		fields["delivered_at"] = out.OccurredAt.Format(time.RFC3339)
+	} else {
+		eventType = "notification.bounced.v1"
+		fields["bounce_type"] = out.BounceType
+		fields["reason"] = out.Reason
+		fields["bounced_at"] = out.OccurredAt.Format(time.RFC3339)
+	}
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return h.outbox.Insert(r.Context(), tx, outbox.Event{
+		AggregateType: "notification",
+		AggregateID:   n.AppointmentID,
+		EventType:     eventType,
+		Payload:       payload,
+	})
+}
+
+// verifySignature checks header `X-Webhook-Signature: sha256=<hex>` where the
+// MAC is HMAC-SHA256(secret, "<timestamp>.<body>") and timestamp is unix seconds.
+func verifySignature(secret, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	timestamp = strings.TrimSpace(timestamp)
+	signature = strings.TrimSpace(signature)
+	if timestamp == "" || signature == "" {
+		return errMissingSignature
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errBadSignature
+	}
+	age := now.Sub(time.Unix(ts, 0))
+	if age > tolerance || age < -tolerance {
+		return errStaleTimestamp
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return errBadSignature
+	}
+	if !hmac.Equal(got, computeSignature(secret, timestamp, body)) {
+		return errBadSignature
+	}
+	return nil
+}
+
+func computeSignature(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"event_id":"evt_1","message_id":"m1","status":"delivered"}`)
+	now := time.Unix(1_700_000_000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := "sha256=" + hex.EncodeToString(computeSignature(secret, ts, body))
+
+	if err := verifySignature(secret, ts, sig, body, now, 5*time.Minute); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	if err := verifySignature("other", ts, sig, body, now, 5*time.Minute); !errors.Is(err, errBadSignature) {
+		t.Fatalf("expected bad signature for wrong secret, got %v", err)
+	}
+	if err := verifySignature(secret, ts, sig, append(body, ' '), now, 5*time.Minute); !errors.Is(err, errBadSignature) {
+		t.Fatalf("expected bad signature for modified body, got %v", err)
+	}
+	if err := verifySignature(secret, ts, sig, body, now.Add(10*time.Minute), 5*time.Minute); !errors.Is(err, errStaleTimestamp) {
+		t.Fatalf("expected stale timestamp, got %v", err)
+	}
+	if err := verifySignature(secret, "", sig, body, now, 5*time.Minute); !errors.Is(err, errMissingSignature) {
+		t.Fatalf("expected missing signature, got %v", err)
+	}
+}
@@ -31,3 +31,24 @@ func (r *Repository) Record(ctx context.Context, eventID string, eventType strin
 	return false, err
 }
 
+
+// ClaimInboundSMS records an inbound SMS by provider message ID; false means
+// the message was already handled.
+func (r *Repository) ClaimInboundSMS(ctx context.Context, messageID string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO inbound_sms_messages (provider_message_id)
+		VALUES ($1)
+		ON CONFLICT (provider_message_id) DO NOTHING
+	`, messageID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseInboundSMS forgets a claim whose processing failed, so the provider's
+// redelivery is handled.
+func (r *Repository) ReleaseInboundSMS(ctx context.Context, messageID string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM inbound_sms_messages WHERE provider_message_id = $1`, messageID)
+	return err
+}
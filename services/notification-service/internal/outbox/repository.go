@@ -66,6 +66,19 @@ func (r *Repository) FetchUnpublished(ctx context.Context, tx pgx.Tx, limit int)
 	return records, nil
 }
 
+// Backlog returns the number of unpublished events and the age in seconds of
+// the oldest one.
+func (r *Repository) Backlog(ctx context.Context) (int64, float64, error) {
+	var count int64
+	var age float64
+	err := r.pool.QueryRow(ctx, `
+		SELECT count(*), COALESCE(EXTRACT(EPOCH FROM now() - min(created_at)), 0)::float8
+		FROM outbox_events
+		WHERE published_at IS NULL
+	`).Scan(&count, &age)
+	return count, age, err
+}
+
 func (r *Repository) MarkPublished(ctx context.Context, tx pgx.Tx, ids []int64) error {
 	if len(ids) == 0 {
 		return nil
@@ -0,0 +1,137 @@
+package providers
+
+import (
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	StateClosed   BreakerState = "closed"
+	StateOpen     BreakerState = "open"
+	StateHalfOpen BreakerState = "half_open"
+)
+
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker.
+	FailureThreshold int
+	// Cooldown is how long an open breaker skips the provider before allowing a trial send.
+	Cooldown time.Duration
+}
+
+// Breaker is a consecutive-failure circuit breaker for a single provider.
+// After Cooldown an open breaker lets exactly one trial request through (half-open);
+// success closes it again, failure re-opens it for another cooldown.
+type Breaker struct {
+	mu          sync.Mutex
+	threshold   int
+	cooldown    time.Duration
+	now         func() time.Time
+	state       BreakerState
+	failures    int
+	openedUntil time.Time
+	trialActive bool
+	lastError   string
+	lastFailure time.Time
+	lastSuccess time.Time
+}
+
+func NewBreaker(cfg BreakerConfig) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 3
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 1 * time.Minute
+	}
+	return &Breaker{
+		threshold: cfg.FailureThreshold,
+		cooldown:  cfg.Cooldown,
+		now:       time.Now,
+		state:     StateClosed,
+	}
+}
+
+// Allow reports whether a request may be sent to the provider right now.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateClosed:
+		return true
+	case StateOpen:
+		if b.now().Before(b.openedUntil) {
+			return false
+		}
+		b.state = StateHalfOpen
+		b.trialActive = true
+		return true
+	case StateHalfOpen:
+		if b.trialActive {
+			return false
+		}
+		b.trialActive = true
+		return true
+	}
+	return false
+}
+
+func (b *Breaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = StateClosed
+	b.failures = 0
+	b.trialActive = false
+	b.lastSuccess = b.now()
+}
+
+func (b *Breaker) RecordFailure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.failures++
+	b.lastFailure = now
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedUntil = now.Add(b.cooldown)
+	}
+	b.trialActive = false
+}
+
+type Health struct {
+	ProviderID          string       `json:"provider_id"`
+	Channel             string       `json:"channel"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	LastError           string       `json:"last_error,omitempty"`
+	LastFailureAt       string       `json:"last_failure_at,omitempty"`
+	LastSuccessAt       string       `json:"last_success_at,omitempty"`
+	OpenUntil           string       `json:"open_until,omitempty"`
+}
+
+func (b *Breaker) snapshot() Health {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h := Health{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if !b.lastFailure.IsZero() {
+		h.LastFailureAt = b.lastFailure.UTC().Format(time.RFC3339)
+	}
+	if !b.lastSuccess.IsZero() {
+		h.LastSuccessAt = b.lastSuccess.UTC().Format(time.RFC3339)
+	}
+	if b.state == StateOpen {
+		h.OpenUntil = b.openedUntil.UTC().Format(time.RFC3339)
+	}
+	return h
+}
@@ -0,0 +1,160 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/metrics"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/email"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/sms"
+)
+
+var ErrNoProviderAvailable = errors.New("no provider available")
+
+var (
+	providerSends = metrics.NewCounter("notification_provider_sends_total",
+		"Provider send attempts by channel, provider and outcome (accepted, rejected, error, circuit_open).", "channel", "provider", "outcome")
+	providerLatency = metrics.NewHistogram("notification_provider_send_seconds",
+		"Provider send latency by channel and provider.", nil, "channel", "provider")
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	// Headers are extra email header fields; SMS providers ignore them.
+	Headers map[string]string
+}
+
+// Receipt identifies an accepted message: which provider took it and the
+// provider's own message ID (empty when the provider does not report one).
+type Receipt struct {
+	ProviderID string
+	MessageID  string
+}
+
+// Provider is a single delivery backend for one channel.
+type Provider interface {
+	ID() string
+	Send(ctx context.Context, msg Message) (string, error)
+}
+
+type emailProvider struct {
+	sender email.Sender
+}
+
+func FromEmail(sender email.Sender) Provider {
+	return &emailProvider{sender: sender}
+}
+
+func (p *emailProvider) ID() string {
+	return p.sender.ProviderID()
+}
+
+func (p *emailProvider) Send(_ context.Context, msg Message) (string, error) {
+	return p.sender.Send(msg.To, msg.Subject, msg.Body, msg.Headers)
+}
+
+type smsProvider struct {
+	sender sms.Sender
+}
+
+func FromSMS(sender sms.Sender) Provider {
+	return &smsProvider{sender: sender}
+}
+
+func (p *smsProvider) ID() string {
+	return p.sender.ProviderID()
+}
+
+func (p *smsProvider) Send(ctx context.Context, msg Message) (string, error) {
+	return p.sender.Send(ctx, msg.To, msg.Body)
+}
+
+type member struct {
+	provider Provider
+	breaker  *Breaker
+}
+
+// Chain tries providers in order, skipping any whose breaker is open.
+type Chain struct {
+	channel string
+	logger  *slog.Logger
+	members []member
+}
+
+func NewChain(channel string, logger *slog.Logger, cfg BreakerConfig, providers ...Provider) *Chain {
+	members := make([]member, 0, len(providers))
+	for _, p := range providers {
+		if p == nil {
+			continue
+		}
+		members = append(members, member{provider: p, breaker: NewBreaker(cfg)})
+	}
+	return &Chain{
+		channel: channel,
+		logger:  logger,
+		members: members,
+	}
+}
+
+// Send delivers msg through the first healthy provider and returns a receipt
+// naming the provider that accepted it. A permanent error stops the chain, since another
+// provider would reject the same recipient; transient errors fail over to the
+// next provider. The returned error is always a *SendError.
+func (c *Chain) Send(ctx context.Context, msg Message) (Receipt, error) {
+	if len(c.members) == 0 {
+		return Receipt{}, Permanent(fmt.Errorf("%w: no %s providers configured", ErrNoProviderAvailable, c.channel))
+	}
+
+	var failures []string
+	for _, m := range c.members {
+		id := m.provider.ID()
+		if !m.breaker.Allow() {
+			providerSends.Inc(c.channel, id, "circuit_open")
+			failures = append(failures, id+": circuit open")
+			continue
+		}
+		start := time.Now()
+		messageID, err := m.provider.Send(ctx, msg)
+		providerLatency.Observe(time.Since(start).Seconds(), c.channel, id)
+		if err != nil {
+			sendErr := Classify(id, err)
+			if !sendErr.Transient {
+				// The provider answered; the message itself was rejected.
+				providerSends.Inc(c.channel, id, "rejected")
+				m.breaker.RecordSuccess()
+				return Receipt{}, sendErr
+			}
+			providerSends.Inc(c.channel, id, "error")
+			m.breaker.RecordFailure(err)
+			failures = append(failures, id+": "+err.Error())
+			if c.logger != nil {
+				c.logger.Warn("provider send failed", "channel", c.channel, "provider_id", id, "err", err)
+			}
+			continue
+		}
+		providerSends.Inc(c.channel, id, "accepted")
+		m.breaker.RecordSuccess()
+		return Receipt{ProviderID: id, MessageID: messageID}, nil
+	}
+	return Receipt{}, &SendError{
+		Transient: true,
+		Err:       fmt.Errorf("%w: %s", ErrNoProviderAvailable, strings.Join(failures, "; ")),
+	}
+}
+
+func (c *Chain) Health() []Health {
+	out := make([]Health, 0, len(c.members))
+	for _, m := range c.members {
+		h := m.breaker.snapshot()
+		h.ProviderID = m.provider.ID()
+		h.Channel = c.channel
+		out = append(out, h)
+	}
+	return out
+}
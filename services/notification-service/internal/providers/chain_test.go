@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/sms"
+)
+
+type fakeProvider struct {
+	id    string
+	err   error
+	calls int
+}
+
+func (p *fakeProvider) ID() string { return p.id }
+
+func (p *fakeProvider) Send(_ context.Context, _ Message) (string, error) {
+	p.calls++
+	if p.err != nil {
+		return "", p.err
+	}
+	return p.id + "-msg", nil
+}
+
+// errDown is a transient provider failure.
+var errDown = &sms.StatusError{StatusCode: 503}
+
+func TestChainFailsOverToNextProvider(t *testing.T) {
+	primary := &fakeProvider{id: "primary", err: errDown}
+	secondary := &fakeProvider{id: "secondary"}
+	chain := NewChain("email", nil, BreakerConfig{FailureThreshold: 2, Cooldown: time.Minute}, primary, secondary)
+
+	receipt, err := chain.Send(context.Background(), Message{To: "a@example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.ProviderID != "secondary" || receipt.MessageID != "secondary-msg" {
+		t.Fatalf("expected secondary receipt, got %+v", receipt)
+	}
+}
+
+func TestChainSkipsOpenBreakerUntilCooldown(t *testing.T) {
+	primary := &fakeProvider{id: "primary", err: errDown}
+	secondary := &fakeProvider{id: "secondary"}
+	chain := NewChain("sms", nil, BreakerConfig{FailureThreshold: 2, Cooldown: time.Minute}, primary, secondary)
+
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	chain.members[0].breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := chain.Send(context.Background(), Message{To: "+15550001"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if primary.calls != 2 {
+		t.Fatalf("expected primary to be skipped once open, got %d calls", primary.calls)
+	}
+	if got := chain.Health()[0].State; got != StateOpen {
+		t.Fatalf("expected open breaker, got %s", got)
+	}
+
+	// After the cooldown a single trial is allowed; success closes the breaker.
+	now = now.Add(2 * time.Minute)
+	primary.err = nil
+	receipt, err := chain.Send(context.Background(), Message{To: "+15550001"})
+	if err != nil || receipt.ProviderID != "primary" {
+		t.Fatalf("expected primary after cooldown, got %+v err=%v", receipt, err)
+	}
+	if got := chain.Health()[0].State; got != StateClosed {
+		t.Fatalf("expected closed breaker, got %s", got)
+	}
+}
+
+func TestChainAllProvidersFail(t *testing.T) {
+	chain := NewChain("email", nil, BreakerConfig{}, &fakeProvider{id: "only", err: errDown})
+	if _, err := chain.Send(context.Background(), Message{}); !errors.Is(err, ErrNoProviderAvailable) {
+		t.Fatalf("expected ErrNoProviderAvailable, got %v", err)
+	}
+}
+
+func TestChainStopsOnPermanentError(t *testing.T) {
+	primary := &fakeProvider{id: "primary", err: &sms.StatusError{StatusCode: 400}}
+	secondary := &fakeProvider{id: "secondary"}
+	chain := NewChain("sms", nil, BreakerConfig{}, primary, secondary)
+
+	_, err := chain.Send(context.Background(), Message{To: "not-a-number"})
+	if err == nil || IsTransient(err) {
+		t.Fatalf("expected permanent error, got %v", err)
+	}
+	if secondary.calls != 0 {
+		t.Fatalf("expected no failover on permanent error")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"webhook 503", &sms.StatusError{StatusCode: 503}, true},
+		{"webhook 429", &sms.StatusError{StatusCode: 429}, true},
+		{"webhook 408", &sms.StatusError{StatusCode: 408}, true},
+		{"webhook 400", &sms.StatusError{StatusCode: 400}, false},
+		{"webhook 401", &sms.StatusError{StatusCode: 401}, false},
+		{"webhook 403", &sms.StatusError{StatusCode: 403}, false},
+		{"smtp 421", &textproto.Error{Code: 421, Msg: "try again"}, true},
+		{"smtp 550", &textproto.Error{Code: 550, Msg: "no such user"}, false},
+		{"timeout", context.DeadlineExceeded, true},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"wrapped timeout", fmt.Errorf("send: %w", context.DeadlineExceeded), true},
+		{"unknown", errors.New("boom"), false},
+		{"already classified", &SendError{Transient: true, Err: errors.New("boom")}, true},
+	}
+	for _, tc := range cases {
+		if got := Classify("p", tc.err).Transient; got != tc.transient {
+			t.Errorf("%s: transient=%v, want %v", tc.name, got, tc.transient)
+		}
+	}
+	// IsTransient applies the same rule to errors that skipped Classify.
+	if IsTransient(errors.New("boom")) || IsTransient(nil) {
+		t.Error("unclassified errors must not be retried")
+	}
+}
+
+func TestChainStopsOnUnknownError(t *testing.T) {
+	primary := &fakeProvider{id: "primary", err: errors.New("unexpected response")}
+	secondary := &fakeProvider{id: "secondary"}
+	chain := NewChain("email", nil, BreakerConfig{}, primary, secondary)
+
+	_, err := chain.Send(context.Background(), Message{To: "a@example.com"})
+	if err == nil || IsTransient(err) || secondary.calls != 0 {
+		t.Fatalf("expected a permanent error without failover, got %v (secondary calls %d)", err, secondary.calls)
+	}
+}
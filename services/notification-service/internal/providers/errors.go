@@ -0,0 +1,87 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/sms"
+)
+
+// SendError carries the retry classification of a failed send.
+// Transient errors (timeouts, 5xx, throttling) are worth retrying later;
+// permanent ones (invalid recipient, rejected message) are not. Only errors
+// known to be temporary are transient: anything unclassified is permanent, so
+// a misbehaving provider or an unexpected error cannot keep a reminder in the
+// retry queue, and it surfaces as a failed notification instead.
+type SendError struct {
+	ProviderID string
+	StatusCode int
+	Transient  bool
+	Err        error
+}
+
+func (e *SendError) Error() string {
+	if e.ProviderID == "" {
+		return e.Err.Error()
+	}
+	return e.ProviderID + ": " + e.Err.Error()
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks err as not retryable.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &SendError{Err: err}
+}
+
+// IsTransient reports whether a send error should be retried.
+func IsTransient(err error) bool {
+	var sendErr *SendError
+	return errors.As(err, &sendErr) && sendErr.Transient
+}
+
+// Classify wraps a raw provider error in a SendError, marking timeouts,
+// network errors, throttling, 5xx and SMTP 4yz replies transient.
+func Classify(providerID string, err error) *SendError {
+	var existing *SendError
+	if errors.As(err, &existing) {
+		return existing
+	}
+	out := &SendError{ProviderID: providerID, Err: err}
+
+	var statusErr *sms.StatusError
+	var smtpErr *textproto.Error
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr):
+		out.StatusCode = statusErr.StatusCode
+		out.Transient = transientHTTPStatus(statusErr.StatusCode)
+	case errors.As(err, &smtpErr):
+		// SMTP 4yz replies are temporary, 5yz are permanent (RFC 5321 section 4.2.1).
+		out.StatusCode = smtpErr.Code
+		out.Transient = smtpErr.Code < 500
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		out.Transient = true
+	}
+	return out
+}
+
+func transientHTTPStatus(code int) bool {
+	switch {
+	case code >= 500:
+		return true
+	case code == 408, code == 429:
+		return true
+	default:
+		// 401/403 included: bad credentials do not fix themselves between
+		// attempts, and failing loudly surfaces the misconfiguration.
+		return false
+	}
+}
@@ -5,17 +5,33 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Sender interface {
-	Send(ctx context.Context, to string, body string) error
+	// Send returns the provider's message ID when it reports one, so delivery
+	// receipts can be matched back to the notification row.
+	Send(ctx context.Context, to string, body string) (string, error)
 	ProviderID() string
 }
 
+// StatusError is returned when the webhook answers with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("sms webhook returned status %d", e.StatusCode)
+}
+
 type WebhookSender struct {
+	id    string
 	url   string
 	token string
 	http  *http.Client
@@ -23,6 +39,7 @@ type WebhookSender struct {
 
 func NewWebhookSender(url string, token string) *WebhookSender {
 	return &WebhookSender{
+		id:    "sms-webhook",
 		url:   strings.TrimSpace(url),
 		token: strings.TrimSpace(token),
 		http: &http.Client{
@@ -31,13 +48,20 @@ func NewWebhookSender(url string, token string) *WebhookSender {
 	}
 }
 
+// SetProviderID overrides the default "sms-webhook" ID, e.g. for a secondary gateway.
+func (s *WebhookSender) SetProviderID(id string) {
+	if id = strings.TrimSpace(id); id != "" {
+		s.id = id
+	}
+}
+
 func (s *WebhookSender) ProviderID() string {
-	return "sms-webhook"
+	return s.id
 }
 
-func (s *WebhookSender) Send(ctx context.Context, to string, body string) error {
+func (s *WebhookSender) Send(ctx context.Context, to string, body string) (string, error) {
 	if s.url == "" {
-		return errors.New("sms webhook url not configured")
+		return "", errors.New("sms webhook url not configured")
 	}
 	payload := map[string]string{
 		"to":   to,
@@ -45,11 +69,11 @@ func (s *WebhookSender) Send(ctx context.Context, to string, body string) error
 	}
 	raw, err := json.Marshal(payload)
 	if err != nil {
-		return err
+		return "", err
 	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(raw))
 	if err != nil {
-		return err
+		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	if s.token != "" {
@@ -57,13 +81,19 @@ func (s *WebhookSender) Send(ctx context.Context, to string, body string) error
 	}
 	resp, err := s.http.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return errors.New("sms webhook returned non-2xx")
+		return "", &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	// Gateways that support delivery receipts answer with {"message_id": "..."}.
+	var accepted struct {
+		MessageID string `json:"message_id"`
 	}
-	return nil
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&accepted)
+	return strings.TrimSpace(accepted.MessageID), nil
 }
 
 type NoopSender struct{}
@@ -76,6 +106,6 @@ func (s *NoopSender) ProviderID() string {
 	return "sms-noop"
 }
 
-func (s *NoopSender) Send(_ context.Context, _ string, _ string) error {
-	return nil
+func (s *NoopSender) Send(_ context.Context, _ string, _ string) (string, error) {
+	return "noop-" + uuid.NewString(), nil
 }
@@ -3,19 +3,30 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/md-rashed-zaman/apptremind/libs/db"
 )
 
 type Notification struct {
-	AppointmentID string
-	BusinessID    string
-	Channel       string
-	Recipient     string
-	Payload       map[string]any
-	Status        string
+	ID                int64
+	AppointmentID     string
+	BusinessID        string
+	Channel           string
+	Recipient         string
+	Payload           map[string]any
+	Status            string
+	ProviderID        string
+	ProviderMessageID string
 }
 
+var (
+	ErrNotFound               = errors.New("notification not found")
+	ErrDuplicateDeliveryEvent = errors.New("duplicate delivery event")
+)
+
 type Repository struct {
 	pool *db.Pool
 }
@@ -24,16 +35,76 @@ func NewRepository(pool *db.Pool) *Repository {
 	return &Repository{pool: pool}
 }
 
-func (r *Repository) Insert(ctx context.Context, n Notification) error {
+func (r *Repository) Insert(ctx context.Context, tx pgx.Tx, n Notification) error {
 	payload, err := json.Marshal(n.Payload)
 	if err != nil {
 		return err
 	}
 
-	_, err = r.pool.Exec(ctx, `
-		INSERT INTO notifications (appointment_id, business_id, channel, recipient, payload, status)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, n.AppointmentID, n.BusinessID, n.Channel, n.Recipient, payload, n.Status)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO notifications (appointment_id, business_id, channel, recipient, payload, status, provider_id, provider_message_id)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''))
+	`, n.AppointmentID, n.BusinessID, n.Channel, n.Recipient, payload, n.Status, n.ProviderID, n.ProviderMessageID)
 	return err
 }
 
+// FindByProviderMessageID locks the notification a delivery callback refers to.
+func (r *Repository) FindByProviderMessageID(ctx context.Context, tx pgx.Tx, channel, messageID string) (Notification, error) {
+	var n Notification
+	err := tx.QueryRow(ctx, `
+		SELECT id, appointment_id, business_id, channel, recipient, status, COALESCE(provider_id, ''), provider_message_id
+		FROM notifications
+		WHERE channel = $1 AND provider_message_id = $2
+		ORDER BY id DESC
+		LIMIT 1
+		FOR UPDATE
+	`, channel, messageID).Scan(&n.ID, &n.AppointmentID, &n.BusinessID, &n.Channel, &n.Recipient, &n.Status, &n.ProviderID, &n.ProviderMessageID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Notification{}, ErrNotFound
+	}
+	return n, err
+}
+
+// LatestReminderForRecipient returns the most recent reminder that reached the
+// recipient for an appointment that has not started yet, used to tie an inbound
+// SMS reply to an appointment. A late reply must not act on a past appointment.
+func (r *Repository) LatestReminderForRecipient(ctx context.Context, channel, recipient string) (Notification, error) {
+	var n Notification
+	err := r.pool.QueryRow(ctx, `
+		SELECT id, appointment_id, business_id, channel, recipient, status, COALESCE(provider_id, ''), COALESCE(provider_message_id, '')
+		FROM notifications
+		WHERE channel = $1 AND replace(recipient, ' ', '') = $2 AND status IN ('sent', 'delivered')
+		  AND (payload->>'start_time')::timestamptz > now()
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`, channel, strings.ReplaceAll(strings.TrimSpace(recipient), " ", "")).Scan(&n.ID, &n.AppointmentID, &n.BusinessID, &n.Channel, &n.Recipient, &n.Status, &n.ProviderID, &n.ProviderMessageID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Notification{}, ErrNotFound
+	}
+	return n, err
+}
+
+func (r *Repository) UpdateStatus(ctx context.Context, tx pgx.Tx, id int64, status string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE notifications SET status = $2, status_updated_at = now() WHERE id = $1
+	`, id, status)
+	return err
+}
+
+// InsertDeliveryEvent records a provider callback; replays of the same
+// provider event ID return ErrDuplicateDeliveryEvent.
+func (r *Repository) InsertDeliveryEvent(ctx context.Context, tx pgx.Tx, channel, providerEventID, messageID, status string, payload []byte) error {
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO delivery_events (channel, provider_event_id, provider_message_id, status, payload)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (channel, provider_event_id) DO NOTHING
+	`, channel, providerEventID, messageID, status, payload)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDuplicateDeliveryEvent
+	}
+	return nil
+}
+
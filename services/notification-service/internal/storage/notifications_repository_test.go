@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/md-rashed-zaman/apptremind/libs/db/dbtest"
+)
+
+func TestLatestReminderForRecipientSkipsPastAppointments(t *testing.T) {
+	pool := dbtest.Open(t, "../../migrations")
+	ctx := context.Background()
+	repo := NewRepository(pool)
+
+	insert := func(start time.Time) string {
+		t.Helper()
+		n := Notification{
+			AppointmentID: uuid.NewString(),
+			BusinessID:    uuid.NewString(),
+			Channel:       "sms",
+			Recipient:     "+1 555 0001",
+			Payload:       map[string]any{"start_time": start.UTC().Format(time.RFC3339)},
+			Status:        "sent",
+		}
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			t.Fatalf("begin: %v", err)
+		}
+		defer func() { _ = tx.Rollback(ctx) }()
+		if err := repo.Insert(ctx, tx, n); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+		return n.AppointmentID
+	}
+
+	upcoming := insert(time.Now().Add(24 * time.Hour))
+	// Reminded later, but the appointment is already over.
+	insert(time.Now().Add(-time.Hour))
+
+	n, err := repo.LatestReminderForRecipient(ctx, "sms", "+15550001")
+	if err != nil || n.AppointmentID != upcoming {
+		t.Fatalf("expected upcoming appointment %s, got %+v err=%v", upcoming, n, err)
+	}
+	if _, err := repo.LatestReminderForRecipient(ctx, "sms", "+15550002"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
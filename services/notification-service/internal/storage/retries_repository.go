@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+	otelx "github.com/md-rashed-zaman/apptremind/libs/otel"
+)
+
+// Retry is a reminder whose last send failed transiently and is waiting for
+// another attempt. Attempts counts the sends made so far.
+type Retry struct {
+	ID            int64
+	AppointmentID string
+	BusinessID    string
+	Channel       string
+	Recipient     string
+	RemindAt      time.Time
+	TemplateData  map[string]any
+	Attempts      int
+	LastError     string
+	Traceparent   string
+	Tracestate    string
+	NextRunAt     time.Time
+}
+
+type RetryRepository struct {
+	pool *db.Pool
+}
+
+func NewRetryRepository(pool *db.Pool) *RetryRepository {
+	return &RetryRepository{pool: pool}
+}
+
+func (r *RetryRepository) Insert(ctx context.Context, tx pgx.Tx, retry Retry) error {
+	payload, err := json.Marshal(retry.TemplateData)
+	if err != nil {
+		return err
+	}
+	traceparent, tracestate := otelx.TraceContextStrings(ctx)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO notification_retries (appointment_id, business_id, channel, recipient, remind_at, template_data, attempts, last_error, next_run_at, traceparent, tracestate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, retry.AppointmentID, retry.BusinessID, retry.Channel, retry.Recipient, retry.RemindAt, payload, retry.Attempts, retry.LastError, retry.NextRunAt, traceparent, tracestate)
+	return err
+}
+
+// Claim leases up to limit due retries by pushing their next_run_at forward, so a
+// concurrent worker (or a crash mid-send) cannot cause the same attempt twice
+// before the lease expires.
+func (r *RetryRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]Retry, error) {
+	rows, err := r.pool.Query(ctx, `
+		UPDATE notification_retries
+		SET next_run_at = now() + make_interval(secs => $2), updated_at = now()
+		WHERE id IN (
+			SELECT id FROM notification_retries
+			WHERE next_run_at <= now()
+			ORDER BY next_run_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, appointment_id, business_id, channel, recipient, remind_at, template_data, attempts,
+			COALESCE(last_error, ''), COALESCE(traceparent, ''), COALESCE(tracestate, ''), next_run_at
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Retry
+	for rows.Next() {
+		var rt Retry
+		var raw []byte
+		if err := rows.Scan(&rt.ID, &rt.AppointmentID, &rt.BusinessID, &rt.Channel, &rt.Recipient, &rt.RemindAt, &raw, &rt.Attempts, &rt.LastError, &rt.Traceparent, &rt.Tracestate, &rt.NextRunAt); err != nil {
+			return nil, err
+		}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &rt.TemplateData); err != nil {
+				return nil, err
+			}
+		} else {
+			rt.TemplateData = map[string]any{}
+		}
+		out = append(out, rt)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return out, nil
+}
+
+func (r *RetryRepository) Reschedule(ctx context.Context, tx pgx.Tx, id int64, attempts int, nextRunAt time.Time, lastError string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE notification_retries
+		SET attempts = $2, next_run_at = $3, last_error = $4, updated_at = now()
+		WHERE id = $1
+	`, id, attempts, nextRunAt, lastError)
+	return err
+}
+
+func (r *RetryRepository) Delete(ctx context.Context, tx pgx.Tx, id int64) error {
+	_, err := tx.Exec(ctx, `DELETE FROM notification_retries WHERE id = $1`, id)
+	return err
+}
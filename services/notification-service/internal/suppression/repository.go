@@ -0,0 +1,134 @@
+package suppression
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+)
+
+// Reasons a recipient ends up on a business's suppression list.
+const (
+	ReasonManual      = "manual"
+	ReasonBounce      = "bounce"
+	ReasonComplaint   = "complaint"
+	ReasonStop        = "stop"
+	ReasonUnsubscribe = "unsubscribe"
+)
+
+type Entry struct {
+	ID         int64     `json:"id"`
+	BusinessID string    `json:"business_id"`
+	Channel    string    `json:"channel"`
+	Recipient  string    `json:"recipient"`
+	Reason     string    `json:"reason"`
+	Detail     string    `json:"detail,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Repository struct {
+	pool *db.Pool
+}
+
+func NewRepository(pool *db.Pool) *Repository {
+	return &Repository{pool: pool}
+}
+
+// NormalizeRecipient makes lookups insensitive to email case and stray whitespace.
+func NormalizeRecipient(channel, recipient string) string {
+	recipient = strings.TrimSpace(recipient)
+	if strings.EqualFold(channel, "email") {
+		return strings.ToLower(recipient)
+	}
+	return strings.ReplaceAll(recipient, " ", "")
+}
+
+func (r *Repository) IsSuppressed(ctx context.Context, businessID, channel, recipient string) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM suppressions
+			WHERE business_id = $1 AND channel = $2 AND recipient = $3
+		)
+	`, businessID, strings.ToLower(channel), NormalizeRecipient(channel, recipient)).Scan(&exists)
+	return exists, err
+}
+
+// Add inserts an entry; an existing entry for the same recipient is left as is.
+func (r *Repository) Add(ctx context.Context, tx pgx.Tx, e Entry) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO suppressions (business_id, channel, recipient, reason, detail)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		ON CONFLICT (business_id, channel, recipient) DO NOTHING
+	`, e.BusinessID, strings.ToLower(e.Channel), NormalizeRecipient(e.Channel, e.Recipient), e.Reason, e.Detail)
+	return err
+}
+
+func (r *Repository) List(ctx context.Context, businessID, channel string, limit int) ([]Entry, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, business_id, channel, recipient, reason, COALESCE(detail, ''), created_at
+		FROM suppressions
+		WHERE business_id = $1 AND ($2 = '' OR channel = $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, businessID, strings.ToLower(channel), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.BusinessID, &e.Channel, &e.Recipient, &e.Reason, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repository) Remove(ctx context.Context, businessID, channel, recipient string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		DELETE FROM suppressions
+		WHERE business_id = $1 AND channel = $2 AND recipient = $3
+	`, businessID, strings.ToLower(channel), NormalizeRecipient(channel, recipient))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// StopAllForNumber suppresses an SMS number for every business that has messaged it.
+// Inbound replies reach a shared sender number, so STOP can't be tied to one business.
+func (r *Repository) StopAllForNumber(ctx context.Context, tx pgx.Tx, number, detail string) (int64, error) {
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO suppressions (business_id, channel, recipient, reason, detail)
+		SELECT DISTINCT business_id, 'sms', $1, 'stop', NULLIF($2, '')
+		FROM notifications
+		WHERE channel = 'sms' AND replace(recipient, ' ', '') = $1
+		ON CONFLICT (business_id, channel, recipient) DO NOTHING
+	`, NormalizeRecipient("sms", number), detail)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// StartAllForNumber lifts STOP suppressions for a number (START/UNSTOP keyword).
+// Entries added for other reasons stay in place.
+func (r *Repository) StartAllForNumber(ctx context.Context, tx pgx.Tx, number string) (int64, error) {
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM suppressions
+		WHERE channel = 'sms' AND recipient = $1 AND reason = 'stop'
+	`, NormalizeRecipient("sms", number))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,57 @@
+package suppression
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// Subject is what an unsubscribe token authorises: removing one recipient from
+// one business's reminders on one channel.
+type Subject struct {
+	BusinessID string `json:"b"`
+	Channel    string `json:"c"`
+	Recipient  string `json:"r"`
+}
+
+// SignToken returns "<base64url(json)>.<base64url(hmac-sha256)>". Tokens do not
+// expire: an unsubscribe link in an old email must keep working.
+func SignToken(secret string, s Subject) (string, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(tokenMAC(secret, payload)), nil
+}
+
+func VerifyToken(secret string, token string) (Subject, error) {
+	payload, sig, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || payload == "" || sig == "" {
+		return Subject{}, ErrInvalidToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, tokenMAC(secret, payload)) {
+		return Subject{}, ErrInvalidToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Subject{}, ErrInvalidToken
+	}
+	var s Subject
+	if err := json.Unmarshal(raw, &s); err != nil || s.BusinessID == "" || s.Channel == "" || s.Recipient == "" {
+		return Subject{}, ErrInvalidToken
+	}
+	return s, nil
+}
+
+func tokenMAC(secret, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,40 @@
+package suppression
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTokenRoundTrip(t *testing.T) {
+	subject := Subject{BusinessID: "b1", Channel: "email", Recipient: "a@example.com"}
+	token, err := SignToken("secret", subject)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	got, err := VerifyToken("secret", token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if got != subject {
+		t.Fatalf("got %+v, want %+v", got, subject)
+	}
+}
+
+func TestTokenRejectsTampering(t *testing.T) {
+	token, _ := SignToken("secret", Subject{BusinessID: "b1", Channel: "email", Recipient: "a@example.com"})
+	other, _ := SignToken("secret", Subject{BusinessID: "b2", Channel: "email", Recipient: "a@example.com"})
+
+	// Payload from one token with the signature of another.
+	otherPayload, _, _ := strings.Cut(other, ".")
+	_, sig, _ := strings.Cut(token, ".")
+	forged := otherPayload + "." + sig
+	for _, tc := range []string{"", "abc", token + "x", forged} {
+		if _, err := VerifyToken("secret", tc); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken for %q, got %v", tc, err)
+		}
+	}
+	if _, err := VerifyToken("other-secret", token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken for wrong secret")
+	}
+}
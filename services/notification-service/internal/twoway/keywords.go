@@ -0,0 +1,86 @@
+package twoway
+
+import (
+	"strings"
+	"unicode"
+)
+
+type Intent string
+
+const (
+	IntentConfirm Intent = "confirm"
+	IntentCancel  Intent = "cancel"
+	IntentStop    Intent = "stop"
+	IntentStart   Intent = "start"
+	IntentHelp    Intent = "help"
+	IntentUnknown Intent = "unknown"
+)
+
+// Keywords maps reply words to intents. Matching is case-insensitive and looks
+// at the first word only, so "Yes please!" confirms.
+type Keywords struct {
+	Confirm []string
+	Cancel  []string
+	Stop    []string
+	Start   []string
+	Help    []string
+}
+
+func DefaultKeywords() Keywords {
+	return Keywords{
+		Confirm: []string{"C", "Y", "YES", "CONFIRM"},
+		Cancel:  []string{"X", "N", "NO", "CANCEL"},
+		// Carrier-standard opt-out and opt-in keywords.
+		Stop:  []string{"STOP", "STOPALL", "UNSUBSCRIBE", "END", "QUIT"},
+		Start: []string{"START", "UNSTOP"},
+		Help:  []string{"HELP", "INFO"},
+	}
+}
+
+// withDefaults fills empty lists from DefaultKeywords.
+func (k Keywords) withDefaults() Keywords {
+	d := DefaultKeywords()
+	if len(k.Confirm) == 0 {
+		k.Confirm = d.Confirm
+	}
+	if len(k.Cancel) == 0 {
+		k.Cancel = d.Cancel
+	}
+	if len(k.Stop) == 0 {
+		k.Stop = d.Stop
+	}
+	if len(k.Start) == 0 {
+		k.Start = d.Start
+	}
+	if len(k.Help) == 0 {
+		k.Help = d.Help
+	}
+	return k
+}
+
+// Match classifies an inbound message. Opt-out keywords win over everything
+// else so a configured keyword can never shadow STOP.
+func (k Keywords) Match(body string) Intent {
+	fields := strings.Fields(body)
+	if len(fields) == 0 {
+		return IntentUnknown
+	}
+	word := strings.ToUpper(strings.TrimFunc(fields[0], unicode.IsPunct))
+	for _, c := range []struct {
+		intent Intent
+		words  []string
+	}{
+		{IntentStop, k.Stop},
+		{IntentStart, k.Start},
+		{IntentHelp, k.Help},
+		{IntentConfirm, k.Confirm},
+		{IntentCancel, k.Cancel},
+	} {
+		for _, w := range c.words {
+			if strings.EqualFold(strings.TrimSpace(w), word) {
+				return c.intent
+			}
+		}
+	}
+	return IntentUnknown
+}
@@ -0,0 +1,239 @@
+package twoway
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/booking"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/providers"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/storage"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/suppression"
+)
+
+// ReminderFinder resolves the appointment a reply refers to; it returns
+// storage.ErrNotFound when the number has no reminded appointment still ahead.
+type ReminderFinder interface {
+	LatestReminderForRecipient(ctx context.Context, channel, recipient string) (storage.Notification, error)
+}
+
+// Booking changes appointment state; booking.ErrNotFound and booking.ErrConflict
+// mean the appointment can no longer be changed.
+type Booking interface {
+	Confirm(ctx context.Context, businessID, appointmentID, source string) error
+	Cancel(ctx context.Context, businessID, appointmentID, reason string) error
+}
+
+// Inbox deduplicates inbound messages by provider message ID.
+type Inbox interface {
+	ClaimInboundSMS(ctx context.Context, messageID string) (bool, error)
+	ReleaseInboundSMS(ctx context.Context, messageID string) error
+}
+
+type OptOut interface {
+	Stop(ctx context.Context, number, keyword string) (int64, error)
+	Start(ctx context.Context, number string) (int64, error)
+}
+
+type Replies struct {
+	Confirmed     string
+	Cancelled     string
+	NoAppointment string
+	NotChangeable string
+	Failed        string
+	Stopped       string
+	Started       string
+	Help          string
+}
+
+func DefaultReplies() Replies {
+	return Replies{
+		Confirmed:     "Thanks, your appointment is confirmed.",
+		Cancelled:     "Your appointment has been cancelled.",
+		NoAppointment: "We couldn't find an upcoming appointment for this number.",
+		NotChangeable: "This appointment can no longer be changed. Please contact the business.",
+		Failed:        "Sorry, we couldn't process your reply. Please try again later.",
+		Stopped:       "You will no longer receive appointment reminders. Reply START to resubscribe.",
+		Started:       "You will receive appointment reminders again. Reply STOP to opt out.",
+		Help:          "Reply C to confirm or X to cancel your appointment. Reply STOP to opt out.",
+	}
+}
+
+type Config struct {
+	Keywords Keywords
+	// Replies overrides the default auto-replies; empty fields keep the default.
+	Replies Replies
+}
+
+// Result describes what an inbound message did.
+type Result struct {
+	Intent        Intent `json:"intent"`
+	Action        string `json:"action"`
+	AppointmentID string `json:"appointment_id,omitempty"`
+	Replied       bool   `json:"replied"`
+}
+
+// Service interprets replies to SMS reminders and answers the sender.
+type Service struct {
+	finder   ReminderFinder
+	booking  Booking
+	optOut   OptOut
+	inbox    Inbox
+	sms      *providers.Chain
+	logger   *slog.Logger
+	keywords Keywords
+	replies  Replies
+}
+
+func NewService(finder ReminderFinder, bookingClient Booking, optOut OptOut, inbox Inbox, smsChain *providers.Chain, logger *slog.Logger, cfg Config) *Service {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	replies := DefaultReplies()
+	for _, o := range []struct {
+		dst *string
+		v   string
+	}{
+		{&replies.Confirmed, cfg.Replies.Confirmed},
+		{&replies.Cancelled, cfg.Replies.Cancelled},
+		{&replies.NoAppointment, cfg.Replies.NoAppointment},
+		{&replies.NotChangeable, cfg.Replies.NotChangeable},
+		{&replies.Failed, cfg.Replies.Failed},
+		{&replies.Stopped, cfg.Replies.Stopped},
+		{&replies.Started, cfg.Replies.Started},
+		{&replies.Help, cfg.Replies.Help},
+	} {
+		if o.v != "" {
+			*o.dst = o.v
+		}
+	}
+	return &Service{
+		finder:   finder,
+		booking:  bookingClient,
+		optOut:   optOut,
+		inbox:    inbox,
+		sms:      smsChain,
+		logger:   logger,
+		keywords: cfg.Keywords.withDefaults(),
+		replies:  replies,
+	}
+}
+
+// Handle processes one inbound message, identified by the provider's message
+// ID. A redelivered message is acknowledged as "duplicate" without acting or
+// replying again. Only storage failures are returned as errors (the provider
+// should redeliver, so the claim is released); everything else is answered with
+// an auto-reply.
+func (s *Service) Handle(ctx context.Context, messageID, from, body string) (Result, error) {
+	res := Result{Intent: s.keywords.Match(body)}
+	claimed, err := s.inbox.ClaimInboundSMS(ctx, messageID)
+	if err != nil {
+		return res, err
+	}
+	if !claimed {
+		res.Action = "duplicate"
+		return res, nil
+	}
+	var reply string
+
+	switch res.Intent {
+	case IntentStop:
+		if _, err := s.optOut.Stop(ctx, from, strings.ToUpper(strings.TrimSpace(body))); err != nil {
+			return res, s.release(ctx, messageID, err)
+		}
+		res.Action, reply = "stopped", s.replies.Stopped
+	case IntentStart:
+		if _, err := s.optOut.Start(ctx, from); err != nil {
+			return res, s.release(ctx, messageID, err)
+		}
+		res.Action, reply = "started", s.replies.Started
+	case IntentConfirm, IntentCancel:
+		res.Action, reply = s.changeAppointment(ctx, from, res.Intent, &res)
+	default:
+		res.Action, reply = "help", s.replies.Help
+	}
+
+	if _, err := s.sms.Send(ctx, providers.Message{To: from, Body: reply}); err != nil {
+		s.logger.Error("sms auto-reply failed", "err", err, "action", res.Action)
+	} else {
+		res.Replied = true
+	}
+	return res, nil
+}
+
+// release drops the claim on a message that failed with cause.
+func (s *Service) release(ctx context.Context, messageID string, cause error) error {
+	if err := s.inbox.ReleaseInboundSMS(ctx, messageID); err != nil {
+		s.logger.Error("inbound sms claim release failed", "err", err, "provider_message_id", messageID)
+	}
+	return cause
+}
+
+func (s *Service) changeAppointment(ctx context.Context, from string, intent Intent, res *Result) (string, string) {
+	n, err := s.finder.LatestReminderForRecipient(ctx, "sms", from)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "no_appointment", s.replies.NoAppointment
+	}
+	if err != nil {
+		s.logger.Error("inbound sms lookup failed", "err", err)
+		return "failed", s.replies.Failed
+	}
+	res.AppointmentID = n.AppointmentID
+
+	action, reply := "confirmed", s.replies.Confirmed
+	if intent == IntentCancel {
+		action, reply = "cancelled", s.replies.Cancelled
+		err = s.booking.Cancel(ctx, n.BusinessID, n.AppointmentID, "cancelled by customer via sms")
+	} else {
+		err = s.booking.Confirm(ctx, n.BusinessID, n.AppointmentID, "sms")
+	}
+	switch {
+	case errors.Is(err, booking.ErrNotFound), errors.Is(err, booking.ErrConflict):
+		return "not_changeable", s.replies.NotChangeable
+	case err != nil:
+		s.logger.Error("booking update from sms failed", "err", err, "appointment_id", n.AppointmentID, "intent", intent)
+		return "failed", s.replies.Failed
+	}
+	return action, reply
+}
+
+// SuppressionOptOut applies STOP/START to the suppression list, one
+// transaction per message.
+type SuppressionOptOut struct {
+	pool *db.Pool
+	repo *suppression.Repository
+}
+
+func NewSuppressionOptOut(pool *db.Pool, repo *suppression.Repository) *SuppressionOptOut {
+	return &SuppressionOptOut{pool: pool, repo: repo}
+}
+
+func (o *SuppressionOptOut) Stop(ctx context.Context, number, keyword string) (int64, error) {
+	tx, err := o.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	affected, err := o.repo.StopAllForNumber(ctx, tx, number, "inbound "+keyword)
+	if err != nil {
+		return 0, err
+	}
+	return affected, tx.Commit(ctx)
+}
+
+func (o *SuppressionOptOut) Start(ctx context.Context, number string) (int64, error) {
+	tx, err := o.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	affected, err := o.repo.StartAllForNumber(ctx, tx, number)
+	if err != nil {
+		return 0, err
+	}
+	return affected, tx.Commit(ctx)
+}
@@ -0,0 +1,219 @@
+package twoway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/booking"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/providers"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/sms"
+	"github.com/md-rashed-zaman/apptremind/services/notification-service/internal/storage"
+)
+
+// fakeGateway is a local SMS gateway that records the auto-replies it receives.
+type fakeGateway struct {
+	mu      sync.Mutex
+	replies []map[string]string
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var msg map[string]string
+	_ = json.NewDecoder(r.Body).Decode(&msg)
+	g.mu.Lock()
+	g.replies = append(g.replies, msg)
+	g.mu.Unlock()
+	_ = json.NewEncoder(w).Encode(map[string]string{"message_id": "reply-1"})
+}
+
+func (g *fakeGateway) last(t *testing.T) map[string]string {
+	t.Helper()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.replies) == 0 {
+		t.Fatalf("expected an auto-reply")
+	}
+	return g.replies[len(g.replies)-1]
+}
+
+type fakeFinder struct {
+	byNumber map[string]storage.Notification
+}
+
+func (f *fakeFinder) LatestReminderForRecipient(_ context.Context, _ string, recipient string) (storage.Notification, error) {
+	n, ok := f.byNumber[recipient]
+	if !ok {
+		return storage.Notification{}, storage.ErrNotFound
+	}
+	return n, nil
+}
+
+type fakeOptOut struct {
+	stopped []string
+}
+
+func (o *fakeOptOut) Stop(_ context.Context, number, _ string) (int64, error) {
+	o.stopped = append(o.stopped, number)
+	return 1, nil
+}
+
+func (o *fakeOptOut) Start(_ context.Context, _ string) (int64, error) { return 1, nil }
+
+type failingOptOut struct{ fakeOptOut }
+
+func (o *failingOptOut) Stop(_ context.Context, _, _ string) (int64, error) {
+	return 0, errors.New("db down")
+}
+
+// fakeInbox stands in for the inbound_sms_messages table.
+type fakeInbox struct {
+	claimed map[string]bool
+}
+
+func (i *fakeInbox) ClaimInboundSMS(_ context.Context, messageID string) (bool, error) {
+	if i.claimed[messageID] {
+		return false, nil
+	}
+	i.claimed[messageID] = true
+	return true, nil
+}
+
+func (i *fakeInbox) ReleaseInboundSMS(_ context.Context, messageID string) error {
+	delete(i.claimed, messageID)
+	return nil
+}
+
+func newTestService(t *testing.T, bookingStatus int) (*Service, *fakeGateway, *[]string) {
+	t.Helper()
+	gateway := &fakeGateway{}
+	gatewaySrv := httptest.NewServer(gateway)
+	t.Cleanup(gatewaySrv.Close)
+
+	var bookingCalls []string
+	bookingSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Internal-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		bookingCalls = append(bookingCalls, r.URL.Path)
+		w.WriteHeader(bookingStatus)
+	}))
+	t.Cleanup(bookingSrv.Close)
+
+	finder := &fakeFinder{byNumber: map[string]storage.Notification{
+		"+15550001": {AppointmentID: "appt-1", BusinessID: "biz-1"},
+	}}
+	chain := providers.NewChain("sms", nil, providers.BreakerConfig{}, providers.FromSMS(sms.NewWebhookSender(gatewaySrv.URL, "")))
+	svc := NewService(finder, booking.NewClient(bookingSrv.URL, "secret"), &fakeOptOut{}, &fakeInbox{claimed: map[string]bool{}}, chain, nil, Config{})
+	return svc, gateway, &bookingCalls
+}
+
+func TestHandleConfirmAndCancel(t *testing.T) {
+	cases := []struct {
+		body   string
+		action string
+		path   string
+		reply  string
+	}{
+		{"yes", "confirmed", "/internal/v1/appointments/confirm", DefaultReplies().Confirmed},
+		{"C.", "confirmed", "/internal/v1/appointments/confirm", DefaultReplies().Confirmed},
+		{"Cancel please", "cancelled", "/internal/v1/appointments/cancel", DefaultReplies().Cancelled},
+	}
+	for _, tc := range cases {
+		svc, gateway, calls := newTestService(t, http.StatusOK)
+		res, err := svc.Handle(context.Background(), "in-1", "+15550001", tc.body)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.body, err)
+		}
+		if res.Action != tc.action || res.AppointmentID != "appt-1" || !res.Replied {
+			t.Fatalf("%q: unexpected result %+v", tc.body, res)
+		}
+		if len(*calls) != 1 || (*calls)[0] != tc.path {
+			t.Fatalf("%q: expected booking call to %s, got %v", tc.body, tc.path, *calls)
+		}
+		if got := gateway.last(t); got["to"] != "+15550001" || got["body"] != tc.reply {
+			t.Fatalf("%q: unexpected reply %v", tc.body, got)
+		}
+	}
+}
+
+func TestHandleAppointmentNoLongerChangeable(t *testing.T) {
+	svc, gateway, _ := newTestService(t, http.StatusConflict)
+	res, err := svc.Handle(context.Background(), "in-1", "+15550001", "X")
+	if err != nil || res.Action != "not_changeable" {
+		t.Fatalf("expected not_changeable, got %+v err=%v", res, err)
+	}
+	if got := gateway.last(t)["body"]; got != DefaultReplies().NotChangeable {
+		t.Fatalf("unexpected reply %q", got)
+	}
+}
+
+func TestHandleUnknownNumberAndHelp(t *testing.T) {
+	svc, gateway, calls := newTestService(t, http.StatusOK)
+
+	res, _ := svc.Handle(context.Background(), "in-1", "+15559999", "yes")
+	if res.Action != "no_appointment" || len(*calls) != 0 {
+		t.Fatalf("expected no_appointment without booking call, got %+v calls=%v", res, *calls)
+	}
+
+	res, _ = svc.Handle(context.Background(), "in-2", "+15550001", "what is this?")
+	if res.Intent != IntentUnknown || res.Action != "help" {
+		t.Fatalf("expected help for unknown text, got %+v", res)
+	}
+	if got := gateway.last(t)["body"]; got != DefaultReplies().Help {
+		t.Fatalf("unexpected reply %q", got)
+	}
+}
+
+func TestHandleStop(t *testing.T) {
+	svc, _, calls := newTestService(t, http.StatusOK)
+	optOut := svc.optOut.(*fakeOptOut)
+
+	res, err := svc.Handle(context.Background(), "in-1", "+15550001", "stop")
+	if err != nil || res.Action != "stopped" {
+		t.Fatalf("expected stopped, got %+v err=%v", res, err)
+	}
+	if len(optOut.stopped) != 1 || len(*calls) != 0 {
+		t.Fatalf("expected opt-out only, got stopped=%v calls=%v", optOut.stopped, *calls)
+	}
+}
+
+func TestHandleRedeliveredMessageActsOnce(t *testing.T) {
+	svc, gateway, calls := newTestService(t, http.StatusOK)
+
+	if res, err := svc.Handle(context.Background(), "in-1", "+15550001", "X"); err != nil || res.Action != "cancelled" {
+		t.Fatalf("first delivery: got %+v err=%v", res, err)
+	}
+	res, err := svc.Handle(context.Background(), "in-1", "+15550001", "X")
+	if err != nil || res.Action != "duplicate" || res.Replied {
+		t.Fatalf("redelivery: expected duplicate without reply, got %+v err=%v", res, err)
+	}
+	if len(*calls) != 1 || len(gateway.replies) != 1 {
+		t.Fatalf("redelivery acted again: calls=%v replies=%d", *calls, len(gateway.replies))
+	}
+}
+
+func TestHandleReleasesClaimWhenOptOutFails(t *testing.T) {
+	svc, _, _ := newTestService(t, http.StatusOK)
+	svc.optOut = &failingOptOut{}
+
+	if _, err := svc.Handle(context.Background(), "in-1", "+15550001", "STOP"); err == nil {
+		t.Fatal("expected the opt-out failure to be returned")
+	}
+	// The provider redelivers after the error; that attempt must be processed.
+	svc.optOut = &fakeOptOut{}
+	if res, err := svc.Handle(context.Background(), "in-1", "+15550001", "STOP"); err != nil || res.Action != "stopped" {
+		t.Fatalf("redelivery after failure: got %+v err=%v", res, err)
+	}
+}
+
+func TestKeywordsStopCannotBeShadowed(t *testing.T) {
+	k := Keywords{Confirm: []string{"stop"}}.withDefaults()
+	if got := k.Match("STOP"); got != IntentStop {
+		t.Fatalf("expected stop intent, got %s", got)
+	}
+}
@@ -0,0 +1,2 @@
+ALTER TABLE notifications
+ADD COLUMN IF NOT EXISTS provider_id TEXT;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS notification_retries (
+    id BIGSERIAL PRIMARY KEY,
+    appointment_id UUID NOT NULL,
+    business_id UUID NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    remind_at TIMESTAMPTZ NOT NULL,
+    template_data JSONB NOT NULL DEFAULT '{}'::jsonb,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    traceparent TEXT,
+    tracestate TEXT,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_retries_next_run
+    ON notification_retries (next_run_at);
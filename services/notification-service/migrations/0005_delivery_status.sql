@@ -0,0 +1,18 @@
+ALTER TABLE notifications
+ADD COLUMN IF NOT EXISTS provider_message_id TEXT,
+ADD COLUMN IF NOT EXISTS status_updated_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_provider_message
+    ON notifications (channel, provider_message_id)
+    WHERE provider_message_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS delivery_events (
+    id BIGSERIAL PRIMARY KEY,
+    channel VARCHAR(20) NOT NULL,
+    provider_event_id TEXT NOT NULL,
+    provider_message_id TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    payload JSONB NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (channel, provider_event_id)
+);
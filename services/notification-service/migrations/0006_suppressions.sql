@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS suppressions (
+    id BIGSERIAL PRIMARY KEY,
+    business_id UUID NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    reason VARCHAR(20) NOT NULL,
+    detail TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (business_id, channel, recipient)
+);
@@ -0,0 +1,3 @@
+-- Inbound SMS replies are matched to the latest reminder sent to the number.
+CREATE INDEX IF NOT EXISTS idx_notifications_sms_recipient
+    ON notifications (channel, (replace(recipient, ' ', '')), created_at DESC);
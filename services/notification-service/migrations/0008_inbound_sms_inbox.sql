@@ -0,0 +1,6 @@
+-- Providers redeliver inbound SMS until they see a 2xx; the provider message ID
+-- is recorded first so a redelivered "C" or "X" is applied only once.
+CREATE TABLE IF NOT EXISTS inbound_sms_messages (
+    provider_message_id TEXT PRIMARY KEY,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
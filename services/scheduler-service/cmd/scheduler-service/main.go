@@ -4,19 +4,28 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/md-rashed-zaman/apptremind/libs/config"
 	"github.com/md-rashed-zaman/apptremind/libs/db"
 	"github.com/md-rashed-zaman/apptremind/libs/httpx"
 	"github.com/md-rashed-zaman/apptremind/libs/kafkax"
 	otelx "github.com/md-rashed-zaman/apptremind/libs/otel"
 	"github.com/md-rashed-zaman/apptremind/libs/runtime"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/audit"
 	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/consumer"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/delayed"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/handlers"
 	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/inbox"
 	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/jobs"
 	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/outbox"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/quiethours"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/replan"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/shard"
 	"github.com/segmentio/kafka-go"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
@@ -70,11 +79,70 @@ func main() {
 	if err != nil || backoffSeconds <= 0 {
 		backoffSeconds = 60
 	}
-	jobWorker := jobs.NewWorker(pool, jobRepo, outboxRepo, logger, jobs.WorkerConfig{
-		Interval:  2 * time.Second,
+	maxBackoffSeconds, err := strconv.Atoi(config.String("SCHEDULER_BACKOFF_MAX_SECONDS", "1800"))
+	if err != nil || maxBackoffSeconds <= 0 {
+		maxBackoffSeconds = 1800
+	}
+	maxAttempts, err := strconv.Atoi(config.String("SCHEDULER_MAX_ATTEMPTS", "0"))
+	if err != nil || maxAttempts < 0 {
+		maxAttempts = 0
+	}
+	maxPerBusiness, err := strconv.Atoi(config.String("SCHEDULER_MAX_JOBS_PER_BUSINESS", "5"))
+	if err != nil || maxPerBusiness < 0 {
+		maxPerBusiness = 5
+	}
+	// SCHEDULER_WHEEL_HORIZON_SECONDS > 0 fires near-term jobs from an in-memory
+	// timer wheel; polling then only backs it up, so it defaults to every 30s.
+	wheelHorizon, err := strconv.Atoi(config.String("SCHEDULER_WHEEL_HORIZON_SECONDS", "0"))
+	if err != nil || wheelHorizon < 0 {
+		wheelHorizon = 0
+	}
+	wheelTickMS, err := strconv.Atoi(config.String("SCHEDULER_WHEEL_TICK_MS", "100"))
+	if err != nil || wheelTickMS <= 0 {
+		wheelTickMS = 100
+	}
+	defaultPoll := "2"
+	if wheelHorizon > 0 {
+		defaultPoll = "30"
+	}
+	pollSeconds, err := strconv.Atoi(config.String("SCHEDULER_POLL_INTERVAL_SECONDS", defaultPoll))
+	if err != nil || pollSeconds <= 0 {
+		pollSeconds, _ = strconv.Atoi(defaultPoll)
+	}
+	workerCfg := jobs.WorkerConfig{
+		Interval:  time.Duration(pollSeconds) * time.Second,
 		BatchSize: 50,
-		Backoff:   time.Duration(backoffSeconds) * time.Second,
-	})
+		Retry: jobs.RetryPolicy{
+			Base:        time.Duration(backoffSeconds) * time.Second,
+			Max:         time.Duration(maxBackoffSeconds) * time.Second,
+			MaxAttempts: maxAttempts,
+		},
+		MaxPerBusiness: maxPerBusiness,
+		Wheel: jobs.WheelConfig{
+			Horizon: time.Duration(wheelHorizon) * time.Second,
+			Tick:    time.Duration(wheelTickMS) * time.Millisecond,
+		},
+	}
+
+	// SCHEDULER_SHARDS > 0 splits businesses across replicas by hash with leased ownership.
+	var leaser *shard.Leaser
+	if shards, err := strconv.Atoi(config.String("SCHEDULER_SHARDS", "0")); err == nil && shards > 0 {
+		leaseTTL, err := strconv.Atoi(config.String("SCHEDULER_LEASE_TTL_SECONDS", "30"))
+		if err != nil || leaseTTL <= 0 {
+			leaseTTL = 30
+		}
+		leaser = shard.NewLeaser(pool, logger, shard.Config{
+			WorkerID: workerID(),
+			Shards:   shards,
+			TTL:      time.Duration(leaseTTL) * time.Second,
+		})
+		go leaser.Run(ctx)
+		workerCfg.Shards = leaser
+	}
+	lagReporter := shard.NewLagReporter(pool, jobRepo, leaser, logger, 15*time.Second)
+	go lagReporter.Run(ctx)
+
+	jobWorker := jobs.NewWorker(pool, jobRepo, outboxRepo, logger, workerCfg)
 	go jobWorker.Run(ctx)
 
 	consumerCfg := consumer.Config{
@@ -90,6 +158,14 @@ func main() {
 		Recipient     string         `json:"recipient"`
 		RemindAt      string         `json:"remind_at"`
 		TemplateData  map[string]any `json:"template_data"`
+		// AppointmentStart and QuietHours are optional; older producers omit them.
+		AppointmentStart string `json:"appointment_start"`
+		QuietHours       *struct {
+			Start    string `json:"start"`
+			End      string `json:"end"`
+			Mode     string `json:"mode"`
+			Timezone string `json:"timezone"`
+		} `json:"quiet_hours"`
 	}
 
 	eventConsumer := consumer.New(logger, inboxRepo, consumerCfg, func(ctx context.Context, msg kafka.Message) error {
@@ -110,13 +186,7 @@ func main() {
 
 		idempotencyKey := payload.AppointmentID + "|" + payload.RemindAt + "|" + payload.Channel
 
-		tx, err := pool.Begin(ctx)
-		if err != nil {
-			return err
-		}
-		defer func() { _ = tx.Rollback(ctx) }()
-
-		if err := jobRepo.Insert(ctx, tx, jobs.Job{
+		job := jobs.Job{
 			IdempotencyKey: idempotencyKey,
 			AppointmentID:  payload.AppointmentID,
 			BusinessID:     payload.BusinessID,
@@ -124,7 +194,31 @@ func main() {
 			Recipient:      payload.Recipient,
 			RemindAt:       remindAt,
 			TemplateData:   payload.TemplateData,
-		}); err != nil {
+		}
+		if payload.AppointmentStart != "" {
+			if start, err := time.Parse(time.RFC3339, payload.AppointmentStart); err == nil {
+				job.AppointmentStart = &start
+			}
+		}
+		if qh := payload.QuietHours; qh != nil {
+			if _, _, err := quiethours.Parse(qh.Start, qh.End, qh.Mode, qh.Timezone); err != nil {
+				logger.Warn("ignoring invalid quiet hours", "err", err, "business_id", payload.BusinessID)
+			} else {
+				job.QuietHoursStart, job.QuietHoursEnd, job.QuietHoursMode, job.Timezone = qh.Start, qh.End, qh.Mode, qh.Timezone
+			}
+		}
+		job.NextRunAt = job.AvoidQuietHours(remindAt)
+		if !job.NextRunAt.Equal(remindAt) {
+			logger.Info("reminder shifted out of quiet hours", "appointment_id", payload.AppointmentID, "remind_at", payload.RemindAt, "next_run_at", job.NextRunAt.UTC().Format(time.RFC3339))
+		}
+
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback(ctx) }()
+
+		if err := jobRepo.Insert(ctx, tx, job); err != nil {
 			return err
 		}
 
@@ -132,13 +226,111 @@ func main() {
 	})
 	go eventConsumer.Run(ctx)
 
+	replanner := replan.New(pool, jobRepo, logger, replan.Config{BatchSize: 100})
+	policyConsumerCfg := consumer.Config{
+		Brokers: config.String("KAFKA_BROKERS", ""),
+		GroupID: config.String("KAFKA_GROUP_ID", "scheduler-service"),
+		Topic:   config.String("KAFKA_POLICY_TOPIC", "business.reminder_policy.updated.v1"),
+	}
+	policyConsumer := consumer.New(logger, inboxRepo, policyConsumerCfg, func(ctx context.Context, msg kafka.Message) error {
+		var policy replan.Policy
+		if err := json.Unmarshal(msg.Value, &policy); err != nil {
+			logger.Error("invalid reminder policy event", "err", err)
+			return nil
+		}
+		if policy.BusinessID == "" || len(policy.OffsetsMins) == 0 {
+			logger.Error("missing reminder policy fields")
+			return nil
+		}
+		return replanner.Apply(ctx, policy)
+	})
+	go policyConsumer.Run(ctx)
+
+	delayedRepo := delayed.NewRepository()
+	delayedWorker := delayed.NewWorker(pool, delayedRepo, outboxRepo, logger, delayed.WorkerConfig{
+		Interval:  2 * time.Second,
+		BatchSize: 50,
+	})
+	go delayedWorker.Run(ctx)
+
+	targetTopics := delayed.NewTopics(strings.Split(config.String("SCHEDULER_ALLOWED_TARGET_TOPICS", ""), ","))
+	jobRequestCfg := consumer.Config{
+		Brokers: config.String("KAFKA_BROKERS", ""),
+		GroupID: config.String("KAFKA_GROUP_ID", "scheduler-service"),
+		Topic:   config.String("KAFKA_JOB_REQUEST_TOPIC", "scheduler.job.requested.v1"),
+	}
+	jobRequestConsumer := consumer.New(logger, inboxRepo, jobRequestCfg, func(ctx context.Context, msg kafka.Message) error {
+		var req delayed.Request
+		if err := json.Unmarshal(msg.Value, &req); err != nil {
+			logger.Error("invalid delayed job request", "err", err)
+			return nil
+		}
+		job, err := req.Validate(targetTopics)
+		if err != nil {
+			logger.Error("rejected delayed job request", "err", err, "dedup_key", req.DedupKey, "source", req.Source)
+			return nil
+		}
+
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback(ctx) }()
+
+		created, err := delayedRepo.Schedule(ctx, tx, job)
+		if err != nil {
+			return err
+		}
+		if !created {
+			logger.Info("delayed job already known", "dedup_key", job.DedupKey, "source", job.Source)
+		}
+		return tx.Commit(ctx)
+	})
+	go jobRequestConsumer.Run(ctx)
+
+	jobCancelCfg := consumer.Config{
+		Brokers: config.String("KAFKA_BROKERS", ""),
+		GroupID: config.String("KAFKA_GROUP_ID", "scheduler-service"),
+		Topic:   config.String("KAFKA_JOB_CANCEL_TOPIC", "scheduler.job.cancel_requested.v1"),
+	}
+	jobCancelConsumer := consumer.New(logger, inboxRepo, jobCancelCfg, func(ctx context.Context, msg kafka.Message) error {
+		var req delayed.CancelRequest
+		if err := json.Unmarshal(msg.Value, &req); err != nil {
+			logger.Error("invalid delayed job cancel", "err", err)
+			return nil
+		}
+		req, err := req.Validate()
+		if err != nil {
+			logger.Error("rejected delayed job cancel", "err", err, "dedup_key", req.DedupKey, "source", req.Source)
+			return nil
+		}
+
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback(ctx) }()
+
+		if err := delayedRepo.Cancel(ctx, tx, req.Source, req.DedupKey, req.Reason); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	})
+	go jobCancelConsumer.Run(ctx)
+
 	mux := runtime.NewBaseMuxWithReady(
 		runtime.ReadyCheck{Name: "db", Check: db.ReadyCheck(pool)},
 		runtime.ReadyCheck{Name: "kafka", Check: kafkax.ReadyCheck(config.String("KAFKA_BROKERS", ""))},
 	)
+	adminHandler := handlers.NewAdminHandler(pool, jobRepo, audit.NewRepository(), logger)
+	mux.HandleFunc("/api/v1/admin/scheduler/jobs", adminHandler.Jobs)
+	mux.HandleFunc("/api/v1/admin/scheduler/jobs/retry", adminHandler.Retry)
+	mux.HandleFunc("/api/v1/admin/scheduler/jobs/cancel", adminHandler.Cancel)
+	mux.HandleFunc("/api/v1/admin/scheduler/dlq/replay", adminHandler.ReplayDLQ)
 	handler := httpx.Chain(mux,
 		httpx.WithRequestID,
 		httpx.WithAccessLog(logger),
+		httpx.WithMetrics,
 	)
 	handler = otelhttp.NewHandler(handler, "scheduler")
 	srv := &http.Server{
@@ -162,3 +354,15 @@ func main() {
 	}
 	logger.Info("http server stopped")
 }
+
+// workerID identifies this replica in the shard lease table.
+func workerID() string {
+	if id := strings.TrimSpace(config.String("SCHEDULER_WORKER_ID", "")); id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "scheduler"
+	}
+	return host + "-" + uuid.NewString()[:8]
+}
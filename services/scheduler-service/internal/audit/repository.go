@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Event struct {
+	EventType  string
+	ActorType  string
+	ActorID    string
+	BusinessID string
+	Metadata   map[string]any
+}
+
+type Repository struct{}
+
+func NewRepository() *Repository {
+	return &Repository{}
+}
+
+// Insert writes the audit row inside tx so it commits or rolls back with the
+// change it describes.
+func (r *Repository) Insert(ctx context.Context, tx pgx.Tx, evt Event) error {
+	metadata := evt.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO audit_events (event_type, actor_type, actor_id, business_id, metadata)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, '')::uuid, $5)
+	`, evt.EventType, evt.ActorType, evt.ActorID, evt.BusinessID, raw)
+	return err
+}
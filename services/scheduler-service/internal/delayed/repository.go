@@ -0,0 +1,104 @@
+package delayed
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	otelx "github.com/md-rashed-zaman/apptremind/libs/otel"
+)
+
+// Job is a generic delayed event: at FireAt the scheduler publishes Payload,
+// unchanged, to TargetTopic. Source and DedupKey together identify the job
+// for cancellation.
+type Job struct {
+	ID          string
+	DedupKey    string
+	TargetTopic string
+	Payload     json.RawMessage
+	Source      string
+	FireAt      time.Time
+	Traceparent string
+	Tracestate  string
+}
+
+type Repository struct{}
+
+func NewRepository() *Repository {
+	return &Repository{}
+}
+
+// Schedule stores the job unless its source already used the dedup key. A key
+// that was cancelled before the request arrived stays cancelled, so cancel and
+// request may be consumed in either order.
+func (r *Repository) Schedule(ctx context.Context, tx pgx.Tx, job Job) (bool, error) {
+	traceparent, tracestate := otelx.TraceContextStrings(ctx)
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO delayed_jobs (dedup_key, target_topic, payload, source, fire_at, traceparent, tracestate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (source, dedup_key) DO NOTHING
+	`, job.DedupKey, job.TargetTopic, []byte(job.Payload), job.Source, job.FireAt, traceparent, tracestate)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Cancel marks source's job with dedupKey as cancelled if it has not fired yet.
+// When the key is unknown a cancelled placeholder is stored so a late request
+// for the same key is ignored.
+func (r *Repository) Cancel(ctx context.Context, tx pgx.Tx, source, dedupKey, reason string) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO delayed_jobs (source, dedup_key, fire_at, status, cancel_reason)
+		VALUES ($1, $2, now(), 'cancelled', NULLIF($3, ''))
+		ON CONFLICT (source, dedup_key) DO UPDATE
+		SET status = 'cancelled',
+		    cancel_reason = EXCLUDED.cancel_reason,
+		    updated_at = now()
+		WHERE delayed_jobs.status = 'pending'
+	`, source, dedupKey, reason)
+	return err
+}
+
+func (r *Repository) FetchDue(ctx context.Context, tx pgx.Tx, limit int) ([]Job, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT id::text, dedup_key, target_topic, payload, source, fire_at, COALESCE(traceparent, ''), COALESCE(tracestate, '')
+		FROM delayed_jobs
+		WHERE status = 'pending' AND fire_at <= now()
+		ORDER BY fire_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var j Job
+		var raw []byte
+		if err := rows.Scan(&j.ID, &j.DedupKey, &j.TargetTopic, &raw, &j.Source, &j.FireAt, &j.Traceparent, &j.Tracestate); err != nil {
+			return nil, err
+		}
+		j.Payload = raw
+		jobs = append(jobs, j)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return jobs, nil
+}
+
+func (r *Repository) MarkFired(ctx context.Context, tx pgx.Tx, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		UPDATE delayed_jobs
+		SET status = 'fired', fired_at = now(), updated_at = now()
+		WHERE id = ANY($1::uuid[])
+	`, ids)
+	return err
+}
@@ -0,0 +1,50 @@
+package delayed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/db/dbtest"
+)
+
+func TestCancelOnlyReachesTheSameSource(t *testing.T) {
+	pool := dbtest.Open(t, "../../migrations")
+	repo := NewRepository()
+	ctx := context.Background()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	job := Job{DedupKey: "hold:1", TargetTopic: "booking.hold.expired.v1", Payload: []byte(`{}`), Source: "booking", FireAt: time.Now().Add(-time.Minute)}
+	if created, err := repo.Schedule(ctx, tx, job); err != nil || !created {
+		t.Fatalf("schedule: %v, %v", created, err)
+	}
+	// Another producer's cancel for the same key only tombstones its own.
+	if err := repo.Cancel(ctx, tx, "billing", "hold:1", "not mine"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	other := job
+	other.Source = "billing"
+	if created, err := repo.Schedule(ctx, tx, other); err != nil || created {
+		t.Fatalf("expected billing's tombstone to swallow its late request: %v, %v", created, err)
+	}
+
+	due, err := repo.FetchDue(ctx, tx, 10)
+	if err != nil {
+		t.Fatalf("fetch due: %v", err)
+	}
+	if len(due) != 1 || due[0].Source != "booking" {
+		t.Fatalf("expected booking's job to stay due, got %+v", due)
+	}
+
+	if err := repo.Cancel(ctx, tx, "booking", "hold:1", "paid"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if due, err := repo.FetchDue(ctx, tx, 10); err != nil || len(due) != 0 {
+		t.Fatalf("expected no due jobs after the owner cancelled, got %+v, %v", due, err)
+	}
+}
@@ -0,0 +1,115 @@
+package delayed
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Request is the payload of scheduler.job.requested.v1.
+type Request struct {
+	DedupKey    string          `json:"dedup_key"`
+	TargetTopic string          `json:"target_topic"`
+	FireAt      string          `json:"fire_at"`
+	Payload     json.RawMessage `json:"payload"`
+	Source      string          `json:"source"`
+}
+
+// CancelRequest is the payload of scheduler.job.cancel_requested.v1. It
+// only reaches jobs scheduled with the same source.
+type CancelRequest struct {
+	DedupKey string `json:"dedup_key"`
+	Source   string `json:"source"`
+	Reason   string `json:"reason"`
+}
+
+const (
+	maxDedupKeyLen = 255
+	maxSourceLen   = 100
+)
+
+// Topics decides which target topics producers may schedule events onto.
+// Only listed topics are accepted, so an empty allowlist accepts none, and
+// the scheduler's own namespace is never a target.
+type Topics struct {
+	allowed map[string]bool
+}
+
+func NewTopics(allowed []string) Topics {
+	t := Topics{allowed: map[string]bool{}}
+	for _, topic := range allowed {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			t.allowed[topic] = true
+		}
+	}
+	return t
+}
+
+func (t Topics) Allows(topic string) bool {
+	if strings.HasPrefix(topic, "scheduler.") {
+		return false
+	}
+	return t.allowed[topic]
+}
+
+// checkKey validates the (source, dedup_key) pair that identifies a job.
+// Keys are namespaced by source so producers cannot cancel or tombstone
+// each other's jobs.
+func checkKey(source string, key string) error {
+	if source == "" || key == "" {
+		return errors.New("source and dedup_key are required")
+	}
+	if len(source) > maxSourceLen {
+		return fmt.Errorf("source longer than %d characters", maxSourceLen)
+	}
+	if len(key) > maxDedupKeyLen {
+		return fmt.Errorf("dedup_key longer than %d characters", maxDedupKeyLen)
+	}
+	return nil
+}
+
+// Validate trims the cancel request and checks its key.
+func (req CancelRequest) Validate() (CancelRequest, error) {
+	req.DedupKey = strings.TrimSpace(req.DedupKey)
+	req.Source = strings.TrimSpace(req.Source)
+	req.Reason = strings.TrimSpace(req.Reason)
+	return req, checkKey(req.Source, req.DedupKey)
+}
+
+// Validate turns a request into a job. Invalid requests are permanent
+// failures: the caller should log and drop them rather than retry.
+func (req Request) Validate(topics Topics) (Job, error) {
+	key := strings.TrimSpace(req.DedupKey)
+	source := strings.TrimSpace(req.Source)
+	topic := strings.TrimSpace(req.TargetTopic)
+	if err := checkKey(source, key); err != nil {
+		return Job{}, err
+	}
+	if topic == "" || strings.TrimSpace(req.FireAt) == "" {
+		return Job{}, errors.New("target_topic and fire_at are required")
+	}
+	if !topics.Allows(topic) {
+		return Job{}, fmt.Errorf("target_topic %q is not allowed", topic)
+	}
+	fireAt, err := time.Parse(time.RFC3339, strings.TrimSpace(req.FireAt))
+	if err != nil {
+		return Job{}, errors.New("invalid fire_at")
+	}
+	payload := bytes.TrimSpace(req.Payload)
+	if len(payload) == 0 || bytes.Equal(payload, []byte("null")) {
+		payload = []byte("{}")
+	}
+	if payload[0] != '{' {
+		return Job{}, errors.New("payload must be a JSON object")
+	}
+	return Job{
+		DedupKey:    key,
+		TargetTopic: topic,
+		Payload:     payload,
+		Source:      source,
+		FireAt:      fireAt.UTC(),
+	}, nil
+}
@@ -0,0 +1,113 @@
+package delayed
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRequestValidate(t *testing.T) {
+	none := NewTopics(nil)
+	restricted := NewTopics([]string{"billing.trial.expired.v1"})
+	open := NewTopics([]string{"billing.trial.expired.v1", "booking.hold.expired.v1", "a.b.v1", "scheduler.job.requested.v1"})
+
+	tests := []struct {
+		name    string
+		raw     string
+		topics  Topics
+		wantErr bool
+		payload string
+	}{
+		{
+			name:    "valid request",
+			raw:     `{"dedup_key":"trial:1","target_topic":"billing.trial.expired.v1","fire_at":"2026-05-01T10:00:00+02:00","payload":{"business_id":"b1"},"source":"billing"}`,
+			topics:  restricted,
+			payload: `{"business_id":"b1"}`,
+		},
+		{
+			name:    "missing payload defaults to empty object",
+			raw:     `{"dedup_key":"hold:1","target_topic":"booking.hold.expired.v1","fire_at":"2026-05-01T10:00:00Z","source":"booking"}`,
+			topics:  open,
+			payload: `{}`,
+		},
+		{
+			name:    "topic outside allowlist",
+			raw:     `{"dedup_key":"x","target_topic":"booking.hold.expired.v1","fire_at":"2026-05-01T10:00:00Z","source":"booking"}`,
+			topics:  restricted,
+			wantErr: true,
+		},
+		{
+			name:    "scheduler topics are never targets",
+			raw:     `{"dedup_key":"x","target_topic":"scheduler.job.requested.v1","fire_at":"2026-05-01T10:00:00Z","source":"booking"}`,
+			topics:  open,
+			wantErr: true,
+		},
+		{
+			name:    "non-object payload",
+			raw:     `{"dedup_key":"x","target_topic":"a.b.v1","fire_at":"2026-05-01T10:00:00Z","payload":[1],"source":"booking"}`,
+			topics:  open,
+			wantErr: true,
+		},
+		{
+			name:    "bad fire_at",
+			raw:     `{"dedup_key":"x","target_topic":"a.b.v1","fire_at":"tomorrow","source":"booking"}`,
+			topics:  open,
+			wantErr: true,
+		},
+		{
+			name:    "empty allowlist refuses every topic",
+			raw:     `{"dedup_key":"hold:1","target_topic":"booking.hold.expired.v1","fire_at":"2026-05-01T10:00:00Z","source":"booking"}`,
+			topics:  none,
+			wantErr: true,
+		},
+		{
+			name:    "missing source",
+			raw:     `{"dedup_key":"hold:1","target_topic":"booking.hold.expired.v1","fire_at":"2026-05-01T10:00:00Z"}`,
+			topics:  open,
+			wantErr: true,
+		},
+		{
+			name:    "missing dedup key",
+			raw:     `{"target_topic":"a.b.v1","fire_at":"2026-05-01T10:00:00Z","source":"booking"}`,
+			topics:  open,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var req Request
+			if err := json.Unmarshal([]byte(tc.raw), &req); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			job, err := req.Validate(tc.topics)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got job %+v", job)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(job.Payload) != tc.payload {
+				t.Fatalf("payload = %s, want %s", job.Payload, tc.payload)
+			}
+			if job.FireAt.Location().String() != "UTC" {
+				t.Fatalf("fire_at not normalised to UTC: %v", job.FireAt)
+			}
+		})
+	}
+}
+
+func TestCancelRequestValidate(t *testing.T) {
+	req, err := CancelRequest{DedupKey: " hold:1 ", Source: " booking ", Reason: " paid "}.Validate()
+	if err != nil || req.DedupKey != "hold:1" || req.Source != "booking" || req.Reason != "paid" {
+		t.Fatalf("unexpected %+v, %v", req, err)
+	}
+	if _, err := (CancelRequest{DedupKey: "hold:1"}).Validate(); err == nil {
+		t.Fatal("a cancel without source must be rejected")
+	}
+	if _, err := (CancelRequest{Source: "booking"}).Validate(); err == nil {
+		t.Fatal("a cancel without dedup_key must be rejected")
+	}
+}
@@ -0,0 +1,95 @@
+package delayed
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+	otelx "github.com/md-rashed-zaman/apptremind/libs/otel"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/outbox"
+)
+
+type Worker struct {
+	pool      *db.Pool
+	repo      *Repository
+	outbox    *outbox.Repository
+	logger    *slog.Logger
+	interval  time.Duration
+	batchSize int
+}
+
+type WorkerConfig struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewWorker(pool *db.Pool, repo *Repository, outboxRepo *outbox.Repository, logger *slog.Logger, cfg WorkerConfig) *Worker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 2 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	return &Worker{
+		pool:      pool,
+		repo:      repo,
+		outbox:    outboxRepo,
+		logger:    logger,
+		interval:  cfg.Interval,
+		batchSize: cfg.BatchSize,
+	}
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.processBatch(ctx); err != nil {
+				w.logger.Error("delayed job batch failed", "err", err)
+			}
+		}
+	}
+}
+
+// processBatch hands due jobs to the outbox in the same transaction that marks
+// them fired, so a job is published exactly once per successful commit.
+func (w *Worker) processBatch(ctx context.Context) error {
+	tx, err := w.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	jobs, err := w.repo.FetchDue(ctx, tx, w.batchSize)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return tx.Commit(ctx)
+	}
+
+	ids := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		jobCtx := otelx.ContextWithTraceContext(ctx, job.Traceparent, job.Tracestate)
+		if err := w.outbox.Insert(jobCtx, tx, outbox.Event{
+			AggregateType: "delayed_job",
+			AggregateID:   job.ID,
+			EventType:     job.TargetTopic,
+			Payload:       job.Payload,
+		}); err != nil {
+			return err
+		}
+		ids = append(ids, job.ID)
+	}
+
+	if err := w.repo.MarkFired(ctx, tx, ids); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/audit"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/jobs"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+	maxBatchIDs      = 500
+)
+
+// AdminHandler lets platform operators inspect and repair scheduler jobs.
+// The gateway only forwards admin tokens; the role is checked again here so the
+// endpoints stay closed if the service is reached directly.
+type AdminHandler struct {
+	pool   *db.Pool
+	repo   *jobs.Repository
+	audit  *audit.Repository
+	logger *slog.Logger
+}
+
+func NewAdminHandler(pool *db.Pool, repo *jobs.Repository, auditRepo *audit.Repository, logger *slog.Logger) *AdminHandler {
+	return &AdminHandler{pool: pool, repo: repo, audit: auditRepo, logger: logger}
+}
+
+type jobIDsRequest struct {
+	IDs    []int64 `json:"ids"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+type replayRequest struct {
+	BusinessID string `json:"business_id,omitempty"`
+	Since      string `json:"since,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+// Jobs lists jobs, newest first, filtered by business_id, appointment_id and status.
+func (h *AdminHandler) Jobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	filter := jobs.ListFilter{
+		BusinessID:    strings.TrimSpace(q.Get("business_id")),
+		AppointmentID: strings.TrimSpace(q.Get("appointment_id")),
+		Status:        strings.TrimSpace(q.Get("status")),
+		Limit:         defaultListLimit,
+	}
+	if !validUUID(filter.BusinessID) || !validUUID(filter.AppointmentID) {
+		http.Error(w, "business_id and appointment_id must be UUIDs", http.StatusBadRequest)
+		return
+	}
+	if filter.Status != "" && !validStatus(filter.Status) {
+		http.Error(w, "status must be pending, processed, failed, cancelled or expired", http.StatusBadRequest)
+		return
+	}
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(limit, maxListLimit)
+	}
+	if raw := strings.TrimSpace(q.Get("cursor")); raw != "" {
+		before, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || before <= 0 {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		filter.Before = before
+	}
+
+	var items []jobs.Summary
+	err := h.inTx(r.Context(), func(tx pgx.Tx) error {
+		var err error
+		items, err = h.repo.List(r.Context(), tx, filter)
+		if err != nil {
+			return err
+		}
+		return h.record(r.Context(), tx, r, "scheduler.admin.jobs.listed", filter.BusinessID, map[string]any{
+			"appointment_id": filter.AppointmentID,
+			"status":         filter.Status,
+			"cursor":         filter.Before,
+			"returned":       len(items),
+		})
+	})
+	if err != nil {
+		h.logger.Error("admin job list failed", "err", err)
+		http.Error(w, "failed to list jobs", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]any{"items": items}
+	if items == nil {
+		resp["items"] = []jobs.Summary{}
+	}
+	if len(items) == filter.Limit {
+		resp["next_cursor"] = strconv.FormatInt(items[len(items)-1].ID, 10)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Retry re-queues failed jobs with their attempts reset.
+func (h *AdminHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	h.updateByIDs(w, r, "scheduler.admin.jobs.retried", "retried", h.repo.Retry)
+}
+
+// Cancel stops pending jobs from firing.
+func (h *AdminHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	h.updateByIDs(w, r, "scheduler.admin.jobs.cancelled", "cancelled", h.repo.Cancel)
+}
+
+func (h *AdminHandler) updateByIDs(w http.ResponseWriter, r *http.Request, eventType string, resultKey string, update func(context.Context, pgx.Tx, []int64) ([]int64, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req jobIDsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxBatchIDs {
+		http.Error(w, "ids must contain between 1 and 500 job ids", http.StatusBadRequest)
+		return
+	}
+
+	var changed []int64
+	err := h.inTx(r.Context(), func(tx pgx.Tx) error {
+		var err error
+		changed, err = update(r.Context(), tx, req.IDs)
+		if err != nil {
+			return err
+		}
+		return h.record(r.Context(), tx, r, eventType, "", map[string]any{
+			"requested_ids": req.IDs,
+			"changed_ids":   changed,
+			"reason":        strings.TrimSpace(req.Reason),
+		})
+	})
+	if err != nil {
+		h.logger.Error("admin job update failed", "err", err, "event_type", eventType)
+		http.Error(w, "failed to update jobs", http.StatusInternalServerError)
+		return
+	}
+	if changed == nil {
+		changed = []int64{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{resultKey: changed})
+}
+
+// ReplayDLQ re-queues jobs that exhausted their attempts and were sent to
+// scheduler.reminder.dlq.v1, oldest first.
+func (h *AdminHandler) ReplayDLQ(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req replayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	req.BusinessID = strings.TrimSpace(req.BusinessID)
+	if !validUUID(req.BusinessID) {
+		http.Error(w, "business_id must be a UUID", http.StatusBadRequest)
+		return
+	}
+	var since *time.Time
+	if raw := strings.TrimSpace(req.Since); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		since = &t
+	}
+	if req.Limit <= 0 || req.Limit > maxBatchIDs {
+		req.Limit = maxBatchIDs
+	}
+
+	var replayed []int64
+	err := h.inTx(r.Context(), func(tx pgx.Tx) error {
+		var err error
+		replayed, err = h.repo.ReplayFailed(r.Context(), tx, req.BusinessID, since, req.Limit)
+		if err != nil {
+			return err
+		}
+		return h.record(r.Context(), tx, r, "scheduler.admin.dlq.replayed", req.BusinessID, map[string]any{
+			"since":        strings.TrimSpace(req.Since),
+			"limit":        req.Limit,
+			"replayed_ids": replayed,
+		})
+	})
+	if err != nil {
+		h.logger.Error("admin dlq replay failed", "err", err)
+		http.Error(w, "failed to replay jobs", http.StatusInternalServerError)
+		return
+	}
+	if replayed == nil {
+		replayed = []int64{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"replayed": replayed})
+}
+
+func (h *AdminHandler) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := h.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (h *AdminHandler) record(ctx context.Context, tx pgx.Tx, r *http.Request, eventType string, businessID string, metadata map[string]any) error {
+	if reqID := strings.TrimSpace(r.Header.Get("X-Request-Id")); reqID != "" {
+		metadata["request_id"] = reqID
+	}
+	return h.audit.Insert(ctx, tx, audit.Event{
+		EventType:  eventType,
+		ActorType:  "admin",
+		ActorID:    strings.TrimSpace(r.Header.Get("X-User-Id")),
+		BusinessID: businessID,
+		Metadata:   metadata,
+	})
+}
+
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("X-Role") != "admin" || strings.TrimSpace(r.Header.Get("X-User-Id")) == "" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func validUUID(s string) bool {
+	if s == "" {
+		return true
+	}
+	_, err := uuid.Parse(s)
+	return err == nil
+}
+
+func validStatus(status string) bool {
+	switch status {
+	case "pending", "processed", "failed", "cancelled", "expired":
+		return true
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// These cases are rejected before the handler touches the database.
+func TestAdminHandlerRejectsBadRequests(t *testing.T) {
+	h := NewAdminHandler(nil, nil, nil, nil)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+		role    string
+		want    int
+	}{
+		{"non-admin list", h.Jobs, http.MethodGet, "/api/v1/admin/scheduler/jobs", "", "owner", http.StatusForbidden},
+		{"wrong method", h.Jobs, http.MethodPost, "/api/v1/admin/scheduler/jobs", "", "admin", http.StatusMethodNotAllowed},
+		{"unknown status", h.Jobs, http.MethodGet, "/api/v1/admin/scheduler/jobs?status=lost", "", "admin", http.StatusBadRequest},
+		{"bad business id", h.Jobs, http.MethodGet, "/api/v1/admin/scheduler/jobs?business_id=acme", "", "admin", http.StatusBadRequest},
+		{"bad cursor", h.Jobs, http.MethodGet, "/api/v1/admin/scheduler/jobs?cursor=-1", "", "admin", http.StatusBadRequest},
+		{"non-admin retry", h.Retry, http.MethodPost, "/api/v1/admin/scheduler/jobs/retry", `{"ids":[1]}`, "owner", http.StatusForbidden},
+		{"retry without ids", h.Retry, http.MethodPost, "/api/v1/admin/scheduler/jobs/retry", `{"ids":[]}`, "admin", http.StatusBadRequest},
+		{"cancel invalid json", h.Cancel, http.MethodPost, "/api/v1/admin/scheduler/jobs/cancel", `{`, "admin", http.StatusBadRequest},
+		{"replay bad since", h.ReplayDLQ, http.MethodPost, "/api/v1/admin/scheduler/dlq/replay", `{"since":"yesterday"}`, "admin", http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			req.Header.Set("X-Role", tc.role)
+			req.Header.Set("X-User-Id", "user-1")
+			rw := httptest.NewRecorder()
+			tc.handler(rw, req)
+			if rw.Code != tc.want {
+				t.Fatalf("status = %d, want %d (%s)", rw.Code, tc.want, strings.TrimSpace(rw.Body.String()))
+			}
+		})
+	}
+}
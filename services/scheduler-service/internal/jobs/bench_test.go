@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+	"github.com/md-rashed-zaman/apptremind/libs/db/dbtest"
+)
+
+// These benchmarks run the worker's queries against a real Postgres (see
+// dbtest) seeded with 200k pending jobs over 500 businesses, one of which
+// owns 40% of them. Half of the jobs are overdue, the rest are due within
+// the next hour. Each iteration runs the query in its own transaction and
+// rolls it back, so ns/op is the database round trip as the worker sees it.
+//
+//	TEST_DATABASE_URL=... go test ./internal/jobs -run '^$' -bench Query
+func seedBacklog(b *testing.B) *db.Pool {
+	b.Helper()
+	pool := dbtest.Open(b, "../../migrations")
+	ctx := context.Background()
+	_, err := pool.Exec(ctx, `
+		INSERT INTO scheduler_jobs (idempotency_key, appointment_id, business_id, channel, recipient, remind_at, template_data, next_run_at)
+		SELECT 'bench-' || g,
+		       md5('appointment-' || g)::uuid,
+		       md5('business-' || CASE WHEN g % 10 < 4 THEN 0 ELSE g % 500 END)::uuid,
+		       'email', 'bench@example.com', t, '{}', t
+		FROM generate_series(1, 200000) AS g,
+		     LATERAL (SELECT now() + make_interval(secs => (g % 7200) - 3600)) AS due(t)
+	`)
+	if err != nil {
+		b.Fatalf("seed: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `ANALYZE scheduler_jobs`); err != nil {
+		b.Fatalf("analyze: %v", err)
+	}
+	return pool
+}
+
+func BenchmarkQueryFetchDue(b *testing.B) {
+	pool := seedBacklog(b)
+	repo := NewRepository()
+	ctx := context.Background()
+	filter := DueFilter{Limit: 100, MaxPerBusiness: 10}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			b.Fatalf("begin: %v", err)
+		}
+		jobs, err := repo.FetchDue(ctx, tx, filter)
+		_ = tx.Rollback(ctx)
+		if err != nil || len(jobs) != filter.Limit {
+			b.Fatalf("fetch due: %d jobs, %v", len(jobs), err)
+		}
+	}
+}
+
+func BenchmarkQueryFetchUpcoming(b *testing.B) {
+	pool := seedBacklog(b)
+	repo := NewRepository()
+	ctx := context.Background()
+	filter := DueFilter{Limit: 10000, MaxPerBusiness: 10}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			b.Fatalf("begin: %v", err)
+		}
+		upcoming, err := repo.FetchUpcoming(ctx, tx, filter, 5*time.Minute)
+		_ = tx.Rollback(ctx)
+		if err != nil || len(upcoming) == 0 {
+			b.Fatalf("fetch upcoming: %d jobs, %v", len(upcoming), err)
+		}
+	}
+}
+
+func BenchmarkQueryClaimDue(b *testing.B) {
+	pool := seedBacklog(b)
+	repo := NewRepository()
+	ctx := context.Background()
+	var ids []int64
+	if err := pool.QueryRow(ctx, `
+		SELECT array_agg(id) FROM (
+			SELECT id FROM scheduler_jobs WHERE next_run_at <= now() ORDER BY next_run_at LIMIT 50
+		) due
+	`).Scan(&ids); err != nil {
+		b.Fatalf("pick ids: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			b.Fatalf("begin: %v", err)
+		}
+		jobs, err := repo.ClaimDue(ctx, tx, ids, 100*time.Millisecond)
+		_ = tx.Rollback(ctx)
+		if err != nil || len(jobs) != len(ids) {
+			b.Fatalf("claim due: %d jobs, %v", len(jobs), err)
+		}
+	}
+}
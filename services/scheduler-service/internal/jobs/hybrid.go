@@ -0,0 +1,148 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/timewheel"
+)
+
+// WheelConfig tunes hybrid mode: jobs due within Horizon are loaded into an
+// in-memory timing wheel and claimed the tick they come due, instead of
+// waiting for the next poll. The database stays the source of truth; the
+// regular poll keeps running at a slower pace to pick up anything the wheel
+// missed (a crash, a failed claim, jobs created between reloads).
+type WheelConfig struct {
+	// Horizon is how far ahead jobs are loaded; 0 disables hybrid mode.
+	Horizon time.Duration
+	// Tick is the firing resolution.
+	Tick time.Duration
+	// Reload is how often upcoming jobs are re-read, which also picks up jobs
+	// created or rescheduled since the last load.
+	Reload time.Duration
+	// Capacity caps how many jobs one reload brings into memory.
+	Capacity int
+	// MaxPerBusiness caps one business's share of a reload; 0 means a tenth
+	// of Capacity. It is separate from the poll's cap, which is sized for one
+	// batch and would leave a busy business's near-term jobs to the slow poll.
+	MaxPerBusiness int
+}
+
+func (c WheelConfig) withDefaults() WheelConfig {
+	if c.Tick <= 0 {
+		c.Tick = 100 * time.Millisecond
+	}
+	if c.Reload <= 0 {
+		c.Reload = min(c.Horizon/4, 15*time.Second)
+	}
+	if c.Reload < c.Tick {
+		c.Reload = c.Tick
+	}
+	if c.Capacity <= 0 {
+		c.Capacity = 10000
+	}
+	if c.MaxPerBusiness <= 0 {
+		c.MaxPerBusiness = max(1, c.Capacity/10)
+	}
+	return c
+}
+
+// 256 slots over 3 levels spans 256^3 ticks, about 19 days at 100ms.
+const (
+	wheelSlots  = 256
+	wheelLevels = 3
+)
+
+func (w *Worker) runHybrid(ctx context.Context) {
+	wheel := timewheel.New(w.wheel.Tick, wheelSlots, wheelLevels, w.now())
+	if err := w.preload(ctx, wheel); err != nil {
+		w.logger.Error("scheduler wheel load failed", "err", err)
+	}
+
+	fire := time.NewTicker(w.wheel.Tick)
+	reload := time.NewTicker(w.wheel.Reload)
+	poll := time.NewTicker(w.interval)
+	defer fire.Stop()
+	defer reload.Stop()
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-fire.C:
+			if ids := wheel.Advance(w.now()); len(ids) > 0 {
+				if err := w.fire(ctx, ids); err != nil {
+					w.logger.Error("scheduler wheel fire failed", "err", err, "jobs", len(ids))
+				}
+			}
+		case <-reload.C:
+			if err := w.preload(ctx, wheel); err != nil {
+				w.logger.Error("scheduler wheel load failed", "err", err)
+			}
+		case <-poll.C:
+			if err := w.processBatch(ctx); err != nil {
+				w.logger.Error("scheduler batch failed", "err", err)
+			}
+		}
+	}
+}
+
+// preload (re)schedules every pending job due within the horizon. Jobs whose
+// next_run_at moved are re-slotted; ones that were cancelled stay in the wheel
+// and are skipped when they fire.
+func (w *Worker) preload(ctx context.Context, wheel *timewheel.Wheel) error {
+	filter, ok := w.shardFilter(DueFilter{Limit: w.wheel.Capacity, MaxPerBusiness: w.wheel.MaxPerBusiness})
+	if !ok {
+		return nil
+	}
+	tx, err := w.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	upcoming, err := w.repo.FetchUpcoming(ctx, tx, filter, w.wheel.Horizon)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	for _, u := range upcoming {
+		wheel.Add(u.ID, u.NextRunAt)
+	}
+	return nil
+}
+
+// fire claims and dispatches the ids the wheel released, batchSize at a time.
+// A failed batch is left to the next reload or poll, which still see the jobs
+// as pending.
+func (w *Worker) fire(ctx context.Context, ids []int64) error {
+	for len(ids) > 0 {
+		n := min(len(ids), w.batchSize)
+		if err := w.claimAndDispatch(ctx, ids[:n]); err != nil {
+			return err
+		}
+		ids = ids[n:]
+	}
+	return nil
+}
+
+func (w *Worker) claimAndDispatch(ctx context.Context, ids []int64) error {
+	tx, err := w.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// One tick of slack absorbs clock skew between this host and the database.
+	jobs, err := w.repo.ClaimDue(ctx, tx, ids, w.wheel.Tick)
+	if err != nil {
+		return err
+	}
+	if err := w.dispatch(ctx, tx, jobs); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/md-rashed-zaman/apptremind/libs/db/dbtest"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/timewheel"
+)
+
+func TestPreloadIsNotLimitedByThePollCap(t *testing.T) {
+	pool := dbtest.Open(t, "../../migrations")
+	ctx := context.Background()
+	repo := NewRepository()
+	business := uuid.NewString()
+	soon := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	for i := 0; i < 12; i++ {
+		if err := repo.Insert(ctx, tx, testJob(uuid.NewString(), business, soon.Add(time.Duration(i)*time.Second), "email")); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	// The poll cap defaults to 5 here, a tenth of the batch size.
+	w := NewWorker(pool, repo, nil, slog.Default(), WorkerConfig{Wheel: WheelConfig{Horizon: 5 * time.Minute}})
+	wheel := timewheel.New(w.wheel.Tick, wheelSlots, wheelLevels, time.Now())
+	if err := w.preload(ctx, wheel); err != nil {
+		t.Fatalf("preload: %v", err)
+	}
+	if wheel.Len() != 12 {
+		t.Fatalf("expected all 12 jobs in the wheel, got %d", wheel.Len())
+	}
+}
+
+func TestWheelConfigDefaultsItsOwnBusinessCap(t *testing.T) {
+	w := NewWorker(nil, nil, nil, slog.Default(), WorkerConfig{MaxPerBusiness: 5, Wheel: WheelConfig{Horizon: time.Minute}})
+	if w.wheel.MaxPerBusiness != 1000 || w.maxPerBusiness != 5 {
+		t.Fatalf("expected wheel cap 1000 and poll cap 5, got %d and %d", w.wheel.MaxPerBusiness, w.maxPerBusiness)
+	}
+}
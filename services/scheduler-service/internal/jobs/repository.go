@@ -3,10 +3,12 @@ package jobs
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	otelx "github.com/md-rashed-zaman/apptremind/libs/otel"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/quiethours"
 )
 
 type Job struct {
@@ -23,6 +25,49 @@ type Job struct {
 	Attempts       int
 	MaxAttempts    int
 	NextRunAt      time.Time
+	Status         string
+	// AppointmentStart bounds quiet-hours shifts; nil for requests that predate it.
+	AppointmentStart *time.Time
+	Timezone         string
+	QuietHoursStart  string
+	QuietHoursEnd    string
+	QuietHoursMode   string
+	// CancelReason says who cancelled the job; empty unless Status is cancelled.
+	CancelReason string
+}
+
+// Cancel reasons stored with cancelled jobs. Only jobs the re-planner
+// cancelled itself may be revived by a later policy change.
+const (
+	CancelReasonAdmin  = "admin"
+	CancelReasonReplan = "replan"
+)
+
+// AppointmentTime is the appointment start, falling back to template_data for
+// jobs created before appointment_start was stored. Zero when unknown.
+func (j Job) AppointmentTime() time.Time {
+	if j.AppointmentStart != nil {
+		return *j.AppointmentStart
+	}
+	if raw, ok := j.TemplateData["start_time"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// AvoidQuietHours moves t out of the job's quiet-hours window, if it has one.
+func (j Job) AvoidQuietHours(t time.Time) time.Time {
+	w, ok, err := quiethours.Parse(j.QuietHoursStart, j.QuietHoursEnd, j.QuietHoursMode, j.Timezone)
+	if err != nil || !ok {
+		return t
+	}
+	var appointmentStart time.Time
+	if j.AppointmentStart != nil {
+		appointmentStart = *j.AppointmentStart
+	}
+	return w.Adjust(t, appointmentStart)
 }
 
 type Repository struct{}
@@ -32,38 +77,439 @@ func NewRepository() *Repository {
 }
 
 func (r *Repository) Insert(ctx context.Context, tx pgx.Tx, job Job) error {
+	return r.insert(ctx, tx, job, `ON CONFLICT (idempotency_key) DO NOTHING`)
+}
+
+// InsertOrRevive behaves like Insert but puts a job the re-planner cancelled
+// earlier back into the pending state, so re-planning to an offset that was
+// removed earlier reuses the original row. Jobs cancelled for any other
+// reason stay cancelled.
+func (r *Repository) InsertOrRevive(ctx context.Context, tx pgx.Tx, job Job) error {
+	return r.insert(ctx, tx, job, `ON CONFLICT (idempotency_key) DO UPDATE
+		SET status = 'pending',
+		    cancel_reason = NULL,
+		    attempts = 0,
+		    last_error = NULL,
+		    next_run_at = EXCLUDED.next_run_at,
+		    appointment_start = EXCLUDED.appointment_start,
+		    timezone = EXCLUDED.timezone,
+		    quiet_hours_start = EXCLUDED.quiet_hours_start,
+		    quiet_hours_end = EXCLUDED.quiet_hours_end,
+		    quiet_hours_mode = EXCLUDED.quiet_hours_mode,
+		    updated_at = now()
+		WHERE scheduler_jobs.status = 'cancelled' AND scheduler_jobs.cancel_reason = '`+CancelReasonReplan+`'`)
+}
+
+func (r *Repository) insert(ctx context.Context, tx pgx.Tx, job Job, onConflict string) error {
 	payload, err := json.Marshal(job.TemplateData)
 	if err != nil {
 		return err
 	}
+	nextRunAt := job.NextRunAt
+	if nextRunAt.IsZero() {
+		nextRunAt = job.RemindAt
+	}
 	traceparent, tracestate := otelx.TraceContextStrings(ctx)
 	_, err = tx.Exec(ctx, `
-		INSERT INTO scheduler_jobs (idempotency_key, appointment_id, business_id, channel, recipient, remind_at, template_data, next_run_at, traceparent, tracestate)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $6, $8, $9)
-		ON CONFLICT (idempotency_key) DO NOTHING
-	`, job.IdempotencyKey, job.AppointmentID, job.BusinessID, job.Channel, job.Recipient, job.RemindAt, payload, traceparent, tracestate)
+		INSERT INTO scheduler_jobs (idempotency_key, appointment_id, business_id, channel, recipient, remind_at, template_data, next_run_at, traceparent, tracestate,
+			appointment_start, timezone, quiet_hours_start, quiet_hours_end, quiet_hours_mode)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), NULLIF($13, ''), NULLIF($14, ''), NULLIF($15, ''))
+		`+onConflict, job.IdempotencyKey, job.AppointmentID, job.BusinessID, job.Channel, job.Recipient, job.RemindAt, payload, nextRunAt, traceparent, tracestate,
+		job.AppointmentStart, job.Timezone, job.QuietHoursStart, job.QuietHoursEnd, job.QuietHoursMode)
 	return err
 }
 
-func (r *Repository) FetchDue(ctx context.Context, tx pgx.Tx, limit int) ([]Job, error) {
+const jobColumns = `id, idempotency_key, appointment_id, business_id, channel, recipient, remind_at, template_data, traceparent, tracestate, attempts, max_attempts, next_run_at, status,
+		       appointment_start, COALESCE(timezone, ''), COALESCE(quiet_hours_start, ''), COALESCE(quiet_hours_end, ''), COALESCE(quiet_hours_mode, ''),
+		       COALESCE(cancel_reason, '')`
+
+// DueFilter selects which due jobs a worker may claim.
+type DueFilter struct {
+	Limit int
+	// MaxPerBusiness caps how many jobs one business gets per batch. Jobs are
+	// picked round-robin across businesses (every business's oldest job first,
+	// then every business's second oldest, ...) so a large backlog cannot
+	// starve other tenants.
+	MaxPerBusiness int
+	// ShardCount and Shards restrict the batch to businesses hashed onto the
+	// given shards. ShardCount 0 means unsharded.
+	ShardCount int
+	Shards     []int32
+}
+
+// shardOf maps business_id onto [0, n); n is bound as a query parameter.
+const shardOf = `mod(hashtext(business_id::text)::bigint + 2147483648, %s)`
+
+// fairPick selects the ids of the first $1 pending jobs due by now() + $5
+// seconds, round-robin across businesses with at most $4 per business. $2
+// and $3 are the shard count and shards, as in DueFilter.
+//
+// Finding the due businesses still visits one index entry per due job: the
+// index-only scan of idx_scheduler_jobs_due_business avoids the heap, but the
+// GROUP BY runs over the whole due backlog. Only the second step is bounded,
+// fetching at most $4 rows per picked business, so at most $1 x $4 heap rows.
+var fairPick = `
+		WITH businesses AS (
+			SELECT business_id, min(next_run_at) AS oldest
+			FROM scheduler_jobs
+			WHERE status = 'pending' AND next_run_at <= now() + make_interval(secs => $5)
+			  AND CASE WHEN $2 = 0 THEN true ELSE ` + fmt.Sprintf(shardOf, "$2") + ` = ANY($3) END
+			GROUP BY business_id
+			ORDER BY oldest
+			LIMIT $1
+		), picked AS (
+			SELECT j.id
+			FROM businesses b
+			CROSS JOIN LATERAL (
+				SELECT id, next_run_at, row_number() OVER (ORDER BY next_run_at, id) AS rn
+				FROM (
+					SELECT id, next_run_at
+					FROM scheduler_jobs
+					WHERE business_id = b.business_id AND status = 'pending'
+					  AND next_run_at <= now() + make_interval(secs => $5)
+					ORDER BY next_run_at, id
+					LIMIT $4
+				) head
+			) j
+			ORDER BY j.rn, j.next_run_at
+			LIMIT $1
+		)`
+
+func (f DueFilter) maxPerBusiness() int {
+	if f.MaxPerBusiness <= 0 {
+		return f.Limit
+	}
+	return f.MaxPerBusiness
+}
+
+func (r *Repository) FetchDue(ctx context.Context, tx pgx.Tx, filter DueFilter) ([]Job, error) {
+	rows, err := tx.Query(ctx, fairPick+`
+		SELECT `+jobColumns+`
+		FROM scheduler_jobs
+		WHERE id IN (SELECT id FROM picked) AND status = 'pending'
+		ORDER BY next_run_at
+		FOR UPDATE SKIP LOCKED
+	`, filter.Limit, filter.ShardCount, filter.Shards, filter.maxPerBusiness(), 0)
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
+// Upcoming is a pending job's position in the timer wheel.
+type Upcoming struct {
+	ID        int64
+	NextRunAt time.Time
+}
+
+// FetchUpcoming lists pending jobs due within horizon (including overdue ones),
+// soonest first, without locking them. All of filter applies, so one business
+// cannot fill the wheel with more than MaxPerBusiness jobs per load; the rest
+// are picked up by later loads or polls. The wheel passes its own, much larger
+// cap than a poll batch uses.
+func (r *Repository) FetchUpcoming(ctx context.Context, tx pgx.Tx, filter DueFilter, horizon time.Duration) ([]Upcoming, error) {
+	rows, err := tx.Query(ctx, fairPick+`
+		SELECT id, next_run_at
+		FROM scheduler_jobs
+		WHERE id IN (SELECT id FROM picked)
+		ORDER BY next_run_at
+	`, filter.Limit, filter.ShardCount, filter.Shards, filter.maxPerBusiness(), horizon.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Upcoming
+	for rows.Next() {
+		var u Upcoming
+		if err := rows.Scan(&u.ID, &u.NextRunAt); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return out, nil
+}
+
+// ClaimDue locks the given jobs if they are still pending and due, allowing
+// them to be up to early ahead of the database clock. Jobs that were
+// cancelled, rescheduled or claimed by another replica are skipped.
+func (r *Repository) ClaimDue(ctx context.Context, tx pgx.Tx, ids []int64, early time.Duration) ([]Job, error) {
 	rows, err := tx.Query(ctx, `
-		SELECT id, idempotency_key, appointment_id, business_id, channel, recipient, remind_at, template_data, traceparent, tracestate, attempts, max_attempts, next_run_at
+		SELECT `+jobColumns+`
 		FROM scheduler_jobs
-		WHERE status = 'pending' AND next_run_at <= now()
+		WHERE id = ANY($1) AND status = 'pending' AND next_run_at <= now() + make_interval(secs => $2)
 		ORDER BY next_run_at
-		LIMIT $1
 		FOR UPDATE SKIP LOCKED
-	`, limit)
+	`, ids, early.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
+// ShardLag describes the due backlog of one shard. Shard is -1 when unsharded.
+type ShardLag struct {
+	Shard      int
+	Due        int64
+	LagSeconds float64
+}
+
+// DueLag reports the number of due pending jobs and the age of the oldest one,
+// per shard. With shardCount 0 everything is reported as a single shard -1.
+func (r *Repository) DueLag(ctx context.Context, tx pgx.Tx, shardCount int) ([]ShardLag, error) {
+	shardExpr, args := "-1", []any{}
+	if shardCount > 0 {
+		shardExpr, args = fmt.Sprintf(shardOf, "$1"), []any{shardCount}
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT (`+shardExpr+`)::int AS shard, count(*), COALESCE(EXTRACT(EPOCH FROM now() - min(next_run_at)), 0)::float8
+		FROM scheduler_jobs
+		WHERE status = 'pending' AND next_run_at <= now()
+		GROUP BY 1
+		ORDER BY 1
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ShardLag
+	for rows.Next() {
+		var l ShardLag
+		if err := rows.Scan(&l.Shard, &l.Due, &l.LagSeconds); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return out, nil
+}
+
+// AcceptPolicy records updatedAt as the business's latest applied reminder
+// policy unless a newer one was applied already, and reports whether the
+// policy is still current. The row stays locked until tx ends, so re-plans of
+// one business never interleave their batches.
+func (r *Repository) AcceptPolicy(ctx context.Context, tx pgx.Tx, businessID string, updatedAt time.Time) (bool, error) {
+	var current bool
+	err := tx.QueryRow(ctx, `
+		INSERT INTO reminder_policy_versions (business_id, updated_at)
+		VALUES ($1, $2)
+		ON CONFLICT (business_id) DO UPDATE
+		SET updated_at = EXCLUDED.updated_at
+		WHERE reminder_policy_versions.updated_at <= EXCLUDED.updated_at
+		RETURNING true
+	`, businessID, updatedAt).Scan(&current)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	return current, err
+}
+
+// FutureAppointmentIDs pages through the appointments of a business that have
+// not started yet, ordered by id. Pass the last id of the previous page as after.
+func (r *Repository) FutureAppointmentIDs(ctx context.Context, tx pgx.Tx, businessID, after string, limit int) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT DISTINCT appointment_id::text
+		FROM scheduler_jobs
+		WHERE business_id = $1
+		  AND ($2 = '' OR appointment_id > $2::uuid)
+		  AND COALESCE(appointment_start, (template_data->>'start_time')::timestamptz) > now()
+		ORDER BY appointment_id::text
+		LIMIT $3
+	`, businessID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return ids, nil
+}
+
+// ListForAppointments returns every job of the given appointments, locking the
+// rows so the worker cannot dispatch them while they are being re-planned.
+func (r *Repository) ListForAppointments(ctx context.Context, tx pgx.Tx, appointmentIDs []string) ([]Job, error) {
+	if len(appointmentIDs) == 0 {
+		return nil, nil
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT `+jobColumns+`
+		FROM scheduler_jobs
+		WHERE appointment_id = ANY($1::uuid[])
+		ORDER BY appointment_id, remind_at, id
+		FOR UPDATE
+	`, appointmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
+// Cancel marks pending jobs as cancelled by an operator and returns the ids
+// that changed; jobs in any other state are left alone.
+func (r *Repository) Cancel(ctx context.Context, tx pgx.Tx, ids []int64) ([]int64, error) {
+	return r.CancelWithReason(ctx, tx, ids, CancelReasonAdmin)
+}
+
+// CancelWithReason is Cancel recording reason instead of CancelReasonAdmin.
+func (r *Repository) CancelWithReason(ctx context.Context, tx pgx.Tx, ids []int64, reason string) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	rows, err := tx.Query(ctx, `
+		UPDATE scheduler_jobs
+		SET status = 'cancelled', cancel_reason = $2, updated_at = now()
+		WHERE id = ANY($1) AND status = 'pending'
+		RETURNING id
+	`, ids, reason)
+	if err != nil {
+		return nil, err
+	}
+	return scanIDs(rows)
+}
+
+// Retry puts failed jobs back into the queue with a fresh attempt budget.
+// last_error is kept so operators can still see why the job failed before.
+func (r *Repository) Retry(ctx context.Context, tx pgx.Tx, ids []int64) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	rows, err := tx.Query(ctx, `
+		UPDATE scheduler_jobs
+		SET status = 'pending', attempts = 0, next_run_at = now(), updated_at = now()
+		WHERE id = ANY($1) AND status = 'failed'
+		RETURNING id
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	return scanIDs(rows)
+}
+
+// ReplayFailed retries up to limit failed jobs, oldest first, optionally
+// restricted to one business and to jobs that failed since the given time.
+func (r *Repository) ReplayFailed(ctx context.Context, tx pgx.Tx, businessID string, since *time.Time, limit int) ([]int64, error) {
+	rows, err := tx.Query(ctx, `
+		UPDATE scheduler_jobs
+		SET status = 'pending', attempts = 0, next_run_at = now(), updated_at = now()
+		WHERE id IN (
+			SELECT id FROM scheduler_jobs
+			WHERE status = 'failed'
+			  AND ($1 = '' OR business_id = $1::uuid)
+			  AND ($2::timestamptz IS NULL OR updated_at >= $2)
+			ORDER BY updated_at, id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`, businessID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanIDs(rows)
+}
+
+// ListFilter narrows the admin job listing. Empty fields match everything;
+// Before is the id cursor returned with the previous page.
+type ListFilter struct {
+	BusinessID    string
+	AppointmentID string
+	Status        string
+	Before        int64
+	Limit         int
+}
+
+// Summary is the operator view of a job.
+type Summary struct {
+	ID             int64     `json:"id"`
+	IdempotencyKey string    `json:"idempotency_key"`
+	AppointmentID  string    `json:"appointment_id"`
+	BusinessID     string    `json:"business_id"`
+	Channel        string    `json:"channel"`
+	Recipient      string    `json:"recipient"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	MaxAttempts    int       `json:"max_attempts"`
+	RemindAt       time.Time `json:"remind_at"`
+	NextRunAt      time.Time `json:"next_run_at"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (r *Repository) List(ctx context.Context, tx pgx.Tx, filter ListFilter) ([]Summary, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT id, idempotency_key, appointment_id::text, business_id::text, channel, recipient, status, attempts, max_attempts,
+		       remind_at, next_run_at, COALESCE(last_error, ''), created_at, updated_at
+		FROM scheduler_jobs
+		WHERE ($1 = '' OR business_id = $1::uuid)
+		  AND ($2 = '' OR appointment_id = $2::uuid)
+		  AND ($3 = '' OR status = $3)
+		  AND ($4 = 0 OR id < $4)
+		ORDER BY id DESC
+		LIMIT $5
+	`, filter.BusinessID, filter.AppointmentID, filter.Status, filter.Before, filter.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	var out []Summary
+	for rows.Next() {
+		var s Summary
+		if err := rows.Scan(&s.ID, &s.IdempotencyKey, &s.AppointmentID, &s.BusinessID, &s.Channel, &s.Recipient, &s.Status, &s.Attempts, &s.MaxAttempts,
+			&s.RemindAt, &s.NextRunAt, &s.LastError, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return out, nil
+}
+
+func scanIDs(rows pgx.Rows) ([]int64, error) {
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return ids, nil
+}
+
+func scanJobs(rows pgx.Rows) ([]Job, error) {
+	defer rows.Close()
+
 	var jobs []Job
 	for rows.Next() {
 		var j Job
 		var raw []byte
-		if err := rows.Scan(&j.ID, &j.IdempotencyKey, &j.AppointmentID, &j.BusinessID, &j.Channel, &j.Recipient, &j.RemindAt, &raw, &j.Traceparent, &j.Tracestate, &j.Attempts, &j.MaxAttempts, &j.NextRunAt); err != nil {
+		if err := rows.Scan(&j.ID, &j.IdempotencyKey, &j.AppointmentID, &j.BusinessID, &j.Channel, &j.Recipient, &j.RemindAt, &raw, &j.Traceparent, &j.Tracestate, &j.Attempts, &j.MaxAttempts, &j.NextRunAt, &j.Status,
+			&j.AppointmentStart, &j.Timezone, &j.QuietHoursStart, &j.QuietHoursEnd, &j.QuietHoursMode, &j.CancelReason); err != nil {
 			return nil, err
 		}
 		if len(raw) > 0 {
@@ -93,6 +539,17 @@ func (r *Repository) MarkProcessed(ctx context.Context, tx pgx.Tx, ids []int64)
 	return err
 }
 
+// MarkExpired retires a job whose appointment has started; sending it now would
+// only confuse the customer.
+func (r *Repository) MarkExpired(ctx context.Context, tx pgx.Tx, id int64, reason string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE scheduler_jobs
+		SET status = 'expired', last_error = $2, updated_at = now()
+		WHERE id = $1
+	`, id, reason)
+	return err
+}
+
 func (r *Repository) MarkFailed(ctx context.Context, tx pgx.Tx, id int64, attempts int, maxAttempts int, nextRunAt time.Time, lastError string) error {
 	status := "pending"
 	if attempts >= maxAttempts {
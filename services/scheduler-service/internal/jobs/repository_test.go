@@ -0,0 +1,167 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db/dbtest"
+)
+
+func beginTest(t *testing.T) (context.Context, pgx.Tx) {
+	t.Helper()
+	pool := dbtest.Open(t, "../../migrations")
+	ctx := context.Background()
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	t.Cleanup(func() { _ = tx.Rollback(ctx) })
+	return ctx, tx
+}
+
+func testJob(appointmentID string, businessID string, remindAt time.Time, channel string) Job {
+	start := remindAt.Add(time.Hour)
+	return Job{
+		IdempotencyKey:   appointmentID + "|" + remindAt.UTC().Format(time.RFC3339) + "|" + channel,
+		AppointmentID:    appointmentID,
+		BusinessID:       businessID,
+		Channel:          channel,
+		Recipient:        channel + "-recipient",
+		RemindAt:         remindAt,
+		TemplateData:     map[string]any{},
+		AppointmentStart: &start,
+	}
+}
+
+func TestInsertOrReviveOnlyRevivesReplannedJobs(t *testing.T) {
+	ctx, tx := beginTest(t)
+	repo := NewRepository()
+	appointmentID, businessID := uuid.NewString(), uuid.NewString()
+	remindAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	byOperator := testJob(appointmentID, businessID, remindAt, "email")
+	byReplan := testJob(appointmentID, businessID, remindAt, "sms")
+	for _, job := range []Job{byOperator, byReplan} {
+		if err := repo.Insert(ctx, tx, job); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	listed, err := repo.ListForAppointments(ctx, tx, []string{appointmentID})
+	if err != nil || len(listed) != 2 {
+		t.Fatalf("list: %d jobs, %v", len(listed), err)
+	}
+	ids := map[string]int64{}
+	for _, job := range listed {
+		ids[job.Channel] = job.ID
+	}
+	if _, err := repo.Cancel(ctx, tx, []int64{ids["email"]}); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if _, err := repo.CancelWithReason(ctx, tx, []int64{ids["sms"]}, CancelReasonReplan); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+
+	for _, job := range []Job{byOperator, byReplan} {
+		if err := repo.InsertOrRevive(ctx, tx, job); err != nil {
+			t.Fatalf("insert or revive: %v", err)
+		}
+	}
+	listed, err = repo.ListForAppointments(ctx, tx, []string{appointmentID})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	for _, job := range listed {
+		switch job.Channel {
+		case "email":
+			if job.Status != "cancelled" || job.CancelReason != CancelReasonAdmin {
+				t.Fatalf("operator-cancelled job was revived: %s/%s", job.Status, job.CancelReason)
+			}
+		case "sms":
+			if job.Status != "pending" || job.CancelReason != "" {
+				t.Fatalf("re-plan-cancelled job not revived: %s/%s", job.Status, job.CancelReason)
+			}
+		}
+	}
+}
+
+func TestAcceptPolicyIgnoresOlderPolicies(t *testing.T) {
+	ctx, tx := beginTest(t)
+	repo := NewRepository()
+	businessID := uuid.NewString()
+	newer := time.Date(2026, 3, 1, 10, 0, 0, 500, time.UTC)
+
+	for _, tc := range []struct {
+		name      string
+		updatedAt time.Time
+		want      bool
+	}{
+		{"first policy", newer, true},
+		{"redelivered policy", newer, true},
+		{"late older policy", newer.Add(-time.Microsecond), false},
+		{"newest policy", newer.Add(time.Second), true},
+	} {
+		got, err := repo.AcceptPolicy(ctx, tx, businessID, tc.updatedAt)
+		if err != nil || got != tc.want {
+			t.Fatalf("%s: AcceptPolicy = %v, %v; want %v", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestFetchDueCapsEachBusiness(t *testing.T) {
+	ctx, tx := beginTest(t)
+	repo := NewRepository()
+	busy, quiet := uuid.NewString(), uuid.NewString()
+	base := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	// The busy business's backlog is entirely older than the quiet one's job.
+	for i := 0; i < 5; i++ {
+		if err := repo.Insert(ctx, tx, testJob(uuid.NewString(), busy, base.Add(time.Duration(i)*time.Second), "email")); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if err := repo.Insert(ctx, tx, testJob(uuid.NewString(), quiet, base.Add(time.Minute), "email")); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := repo.Insert(ctx, tx, testJob(uuid.NewString(), quiet, time.Now().Add(time.Hour), "email")); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	due, err := repo.FetchDue(ctx, tx, DueFilter{Limit: 4, MaxPerBusiness: 2})
+	if err != nil {
+		t.Fatalf("fetch due: %v", err)
+	}
+	perBusiness := map[string]int{}
+	for _, job := range due {
+		perBusiness[job.BusinessID]++
+	}
+	if len(due) != 3 || perBusiness[busy] != 2 || perBusiness[quiet] != 1 {
+		t.Fatalf("expected 2 busy + 1 quiet job, got %v", perBusiness)
+	}
+	if !due[0].NextRunAt.Equal(base) || !due[1].NextRunAt.Equal(base.Add(time.Second)) {
+		t.Fatalf("expected the busy business's oldest jobs first, got %v and %v", due[0].NextRunAt, due[1].NextRunAt)
+	}
+}
+
+func TestFetchUpcomingCapsEachBusiness(t *testing.T) {
+	ctx, tx := beginTest(t)
+	repo := NewRepository()
+	busy, quiet := uuid.NewString(), uuid.NewString()
+	soon := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+	for i := 0; i < 5; i++ {
+		if err := repo.Insert(ctx, tx, testJob(uuid.NewString(), busy, soon.Add(time.Duration(i)*time.Second), "email")); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if err := repo.Insert(ctx, tx, testJob(uuid.NewString(), quiet, soon.Add(time.Minute), "email")); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	upcoming, err := repo.FetchUpcoming(ctx, tx, DueFilter{Limit: 100, MaxPerBusiness: 2}, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("fetch upcoming: %v", err)
+	}
+	if len(upcoming) != 3 || !upcoming[0].NextRunAt.Equal(soon) || !upcoming[2].NextRunAt.Equal(soon.Add(time.Minute)) {
+		t.Fatalf("expected the busy business's first 2 jobs and the quiet one's, got %+v", upcoming)
+	}
+}
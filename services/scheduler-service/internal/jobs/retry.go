@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how failed jobs are rescheduled. Delays grow
+// exponentially from Base and are capped at Max; each delay is drawn uniformly
+// from [0, cap] ("full jitter") so a burst of failures does not retry in lockstep.
+type RetryPolicy struct {
+	Base time.Duration
+	Max  time.Duration
+	// MaxAttempts overrides the per-row max_attempts when positive.
+	MaxAttempts int
+	// Jitter returns a value in [0, n); nil means math/rand.
+	Jitter func(n int64) int64
+}
+
+// Delay returns the wait before retrying after the given failed attempt (1-based).
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	ceiling := p.Base
+	for i := 1; i < attempt && ceiling < p.Max; i++ {
+		ceiling *= 2
+	}
+	if ceiling > p.Max {
+		ceiling = p.Max
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	jitter := p.Jitter
+	if jitter == nil {
+		jitter = rand.Int64N
+	}
+	return time.Duration(jitter(int64(ceiling) + 1))
+}
+
+func (p RetryPolicy) maxAttempts(job Job) int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return job.MaxAttempts
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure that retrying cannot fix, such as a payload
+// that cannot be encoded. The worker sends such jobs to the DLQ immediately.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	// Jitter returning n-1 exposes the ceiling for each attempt.
+	ceiling := RetryPolicy{Base: time.Minute, Max: 10 * time.Minute, Jitter: func(n int64) int64 { return n - 1 }}
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute}
+	for i, w := range want {
+		if got := ceiling.Delay(i + 1); got != w {
+			t.Fatalf("attempt %d: delay = %v, want %v", i+1, got, w)
+		}
+	}
+
+	floor := RetryPolicy{Base: time.Minute, Max: 10 * time.Minute, Jitter: func(int64) int64 { return 0 }}
+	if got := floor.Delay(3); got != 0 {
+		t.Fatalf("full jitter lower bound = %v, want 0", got)
+	}
+
+	random := RetryPolicy{Base: time.Second, Max: time.Hour}
+	for attempt := 1; attempt <= 40; attempt++ {
+		if got := random.Delay(attempt); got < 0 || got > time.Hour {
+			t.Fatalf("attempt %d: delay %v outside [0, 1h]", attempt, got)
+		}
+	}
+}
+
+func TestRetryPolicyMaxAttempts(t *testing.T) {
+	job := Job{MaxAttempts: 5}
+	if got := (RetryPolicy{}).maxAttempts(job); got != 5 {
+		t.Fatalf("row default = %d, want 5", got)
+	}
+	if got := (RetryPolicy{MaxAttempts: 8}).maxAttempts(job); got != 8 {
+		t.Fatalf("override = %d, want 8", got)
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	_, marshalErr := json.Marshal(map[string]any{"bad": func() {}})
+	err := fmt.Errorf("dispatch: %w", Permanent(marshalErr))
+	if !IsPermanent(err) {
+		t.Fatal("wrapped permanent error not detected")
+	}
+	if IsPermanent(errors.New("connection reset")) {
+		t.Fatal("plain error reported as permanent")
+	}
+	if Permanent(nil) != nil {
+		t.Fatal("Permanent(nil) should be nil")
+	}
+}
@@ -3,6 +3,7 @@ package jobs
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"time"
 
@@ -13,19 +14,36 @@ import (
 )
 
 type Worker struct {
-	pool      *db.Pool
-	repo      *Repository
-	outbox    *outbox.Repository
-	logger    *slog.Logger
-	interval  time.Duration
-	batchSize int
-	backoff   time.Duration
+	pool           *db.Pool
+	repo           *Repository
+	outbox         *outbox.Repository
+	logger         *slog.Logger
+	interval       time.Duration
+	batchSize      int
+	retry          RetryPolicy
+	maxPerBusiness int
+	shards         ShardOwner
+	wheel          WheelConfig
+	now            func() time.Time
+}
+
+// ShardOwner reports which hash shards of the job table this replica owns.
+type ShardOwner interface {
+	Total() int
+	Owned(now time.Time) []int32
 }
 
 type WorkerConfig struct {
 	Interval  time.Duration
 	BatchSize int
-	Backoff   time.Duration
+	Retry     RetryPolicy
+	// MaxPerBusiness caps one business's share of a batch; 0 means a tenth of BatchSize.
+	MaxPerBusiness int
+	// Shards restricts the worker to leased shards; nil processes every job.
+	Shards ShardOwner
+	// Wheel enables hybrid mode when Wheel.Horizon is positive; Interval then
+	// only paces the fallback poll.
+	Wheel WheelConfig
 }
 
 func NewWorker(pool *db.Pool, repo *Repository, outboxRepo *outbox.Repository, logger *slog.Logger, cfg WorkerConfig) *Worker {
@@ -35,21 +53,41 @@ func NewWorker(pool *db.Pool, repo *Repository, outboxRepo *outbox.Repository, l
 	if cfg.BatchSize <= 0 {
 		cfg.BatchSize = 50
 	}
-	if cfg.Backoff <= 0 {
-		cfg.Backoff = 1 * time.Minute
+	if cfg.MaxPerBusiness <= 0 {
+		cfg.MaxPerBusiness = max(1, cfg.BatchSize/10)
+	}
+	if cfg.Wheel.Horizon > 0 {
+		cfg.Wheel = cfg.Wheel.withDefaults()
+	}
+	if cfg.Retry.Base <= 0 {
+		cfg.Retry.Base = 1 * time.Minute
+	}
+	if cfg.Retry.Max < cfg.Retry.Base {
+		cfg.Retry.Max = 30 * time.Minute
+		if cfg.Retry.Max < cfg.Retry.Base {
+			cfg.Retry.Max = cfg.Retry.Base
+		}
 	}
 	return &Worker{
-		pool:      pool,
-		repo:      repo,
-		outbox:    outboxRepo,
-		logger:    logger,
-		interval:  cfg.Interval,
-		batchSize: cfg.BatchSize,
-		backoff:   cfg.Backoff,
+		pool:           pool,
+		repo:           repo,
+		outbox:         outboxRepo,
+		logger:         logger,
+		interval:       cfg.Interval,
+		batchSize:      cfg.BatchSize,
+		retry:          cfg.Retry,
+		maxPerBusiness: cfg.MaxPerBusiness,
+		shards:         cfg.Shards,
+		wheel:          cfg.Wheel,
+		now:            time.Now,
 	}
 }
 
 func (w *Worker) Run(ctx context.Context) {
+	if w.wheel.Horizon > 0 {
+		w.runHybrid(ctx)
+		return
+	}
 	ticker := time.NewTicker(w.interval)
 	defer ticker.Stop()
 
@@ -66,73 +104,132 @@ func (w *Worker) Run(ctx context.Context) {
 }
 
 func (w *Worker) processBatch(ctx context.Context) error {
+	filter, ok := w.shardFilter(DueFilter{Limit: w.batchSize, MaxPerBusiness: w.maxPerBusiness})
+	if !ok {
+		return nil
+	}
+
 	tx, err := w.pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
-	jobs, err := w.repo.FetchDue(ctx, tx, w.batchSize)
+	jobs, err := w.repo.FetchDue(ctx, tx, filter)
 	if err != nil {
 		return err
 	}
-	if len(jobs) == 0 {
-		return tx.Commit(ctx)
+	if err := w.dispatch(ctx, tx, jobs); err != nil {
+		return err
 	}
+	return tx.Commit(ctx)
+}
 
+// dispatch enqueues jobs already locked by tx; the caller commits.
+func (w *Worker) dispatch(ctx context.Context, tx pgx.Tx, jobs []Job) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	now := w.now().UTC()
 	var ids []int64
-	var failed []Job
 	for _, job := range jobs {
 		jobCtx := otelx.ContextWithTraceContext(ctx, job.Traceparent, job.Tracestate)
-		payload, err := json.Marshal(map[string]any{
-			"appointment_id": job.AppointmentID,
-			"business_id":    job.BusinessID,
-			"channel":        job.Channel,
-			"recipient":      job.Recipient,
-			"remind_at":      job.RemindAt.UTC().Format(time.RFC3339),
-			"template_data":  job.TemplateData,
-		})
-		if err != nil {
-			failed = append(failed, job)
+
+		if start := job.AppointmentTime(); !start.IsZero() && !now.Before(start) {
+			w.logger.Info("reminder expired", "job_id", job.ID, "appointment_id", job.AppointmentID, "remind_at", job.RemindAt.UTC().Format(time.RFC3339))
+			if err := w.repo.MarkExpired(ctx, tx, job.ID, "appointment already started"); err != nil {
+				return err
+			}
 			continue
 		}
 
-		if err := w.outbox.Insert(jobCtx, tx, outbox.Event{
-			AggregateType: "scheduler_job",
-			AggregateID:   job.AppointmentID,
-			EventType:     "scheduler.reminder.due.v1",
-			Payload:       payload,
-		}); err != nil {
-			failed = append(failed, job)
+		// A savepoint per job keeps one failed enqueue from aborting the batch.
+		sp, err := tx.Begin(jobCtx)
+		if err != nil {
+			return err
+		}
+		if err := w.enqueueDue(jobCtx, sp, job); err != nil {
+			_ = sp.Rollback(jobCtx)
+			if err := w.handleFailure(jobCtx, tx, job, err, now); err != nil {
+				return err
+			}
 			continue
 		}
+		if err := sp.Commit(jobCtx); err != nil {
+			return err
+		}
 		ids = append(ids, job.ID)
 	}
 
-	if err := w.repo.MarkProcessed(ctx, tx, ids); err != nil {
-		return err
+	return w.repo.MarkProcessed(ctx, tx, ids)
+}
+
+// shardFilter narrows filter to the owned shards; ok is false when the
+// replica currently owns none.
+func (w *Worker) shardFilter(filter DueFilter) (DueFilter, bool) {
+	if w.shards == nil || w.shards.Total() <= 0 {
+		return filter, true
 	}
+	filter.ShardCount = w.shards.Total()
+	filter.Shards = w.shards.Owned(w.now())
+	return filter, len(filter.Shards) > 0
+}
 
-	for _, job := range failed {
-		jobCtx := otelx.ContextWithTraceContext(ctx, job.Traceparent, job.Tracestate)
-		nextRunAt := time.Now().UTC().Add(w.backoff)
-		attempts := job.Attempts + 1
-		if err := w.repo.MarkFailed(ctx, tx, job.ID, attempts, job.MaxAttempts, nextRunAt, "outbox enqueue failed"); err != nil {
-			return err
-		}
+func (w *Worker) enqueueDue(ctx context.Context, tx pgx.Tx, job Job) error {
+	payload, err := json.Marshal(map[string]any{
+		"appointment_id": job.AppointmentID,
+		"business_id":    job.BusinessID,
+		"channel":        job.Channel,
+		"recipient":      job.Recipient,
+		"remind_at":      job.RemindAt.UTC().Format(time.RFC3339),
+		"template_data":  job.TemplateData,
+	})
+	if err != nil {
+		return Permanent(fmt.Errorf("encode payload: %w", err))
+	}
+	if err := w.outbox.Insert(ctx, tx, outbox.Event{
+		AggregateType: "scheduler_job",
+		AggregateID:   job.AppointmentID,
+		EventType:     "scheduler.reminder.due.v1",
+		Payload:       payload,
+	}); err != nil {
+		return fmt.Errorf("outbox enqueue failed: %w", err)
+	}
+	return nil
+}
 
-		if attempts >= job.MaxAttempts {
-			if err := w.enqueueDLQ(jobCtx, tx, job, "max attempts reached"); err != nil {
-				return err
-			}
-		}
+// handleFailure reschedules the job with backoff, or gives up when the error is
+// permanent, attempts are exhausted, or the next try would land after the
+// appointment has started.
+func (w *Worker) handleFailure(ctx context.Context, tx pgx.Tx, job Job, cause error, now time.Time) error {
+	attempts := job.Attempts + 1
+	maxAttempts := w.retry.maxAttempts(job)
+	nextRunAt := job.AvoidQuietHours(now.Add(w.retry.Delay(attempts)))
+	lastError := cause.Error()
+
+	if start := job.AppointmentTime(); !IsPermanent(cause) && attempts < maxAttempts && !start.IsZero() && !nextRunAt.Before(start) {
+		w.logger.Info("reminder expired before retry", "job_id", job.ID, "appointment_id", job.AppointmentID, "err", cause)
+		return w.repo.MarkExpired(ctx, tx, job.ID, lastError)
 	}
 
-	return tx.Commit(ctx)
+	if IsPermanent(cause) {
+		attempts = maxAttempts
+	}
+	if err := w.repo.MarkFailed(ctx, tx, job.ID, attempts, maxAttempts, nextRunAt, lastError); err != nil {
+		return err
+	}
+	if attempts >= maxAttempts {
+		reason := "max attempts reached"
+		if IsPermanent(cause) {
+			reason = "non-retryable: " + lastError
+		}
+		return w.enqueueDLQ(ctx, tx, job, reason)
+	}
+	return nil
 }
 
 func (w *Worker) enqueueDLQ(ctx context.Context, tx pgx.Tx, job Job, reason string) error {
-	payload, err := json.Marshal(map[string]any{
+	fields := map[string]any{
 		"appointment_id": job.AppointmentID,
 		"business_id":    job.BusinessID,
 		"channel":        job.Channel,
@@ -141,9 +238,14 @@ func (w *Worker) enqueueDLQ(ctx context.Context, tx pgx.Tx, job Job, reason stri
 		"template_data":  job.TemplateData,
 		"error_reason":   reason,
 		"failed_at":      time.Now().UTC().Format(time.RFC3339),
-	})
+	}
+	payload, err := json.Marshal(fields)
 	if err != nil {
-		return err
+		// template_data may be what failed to encode; the DLQ entry is still useful without it.
+		delete(fields, "template_data")
+		if payload, err = json.Marshal(fields); err != nil {
+			return err
+		}
 	}
 	return w.outbox.Insert(ctx, tx, outbox.Event{
 		AggregateType: "scheduler_job",
@@ -0,0 +1,104 @@
+package quiethours
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	ModeLater   = "later"
+	ModeEarlier = "earlier"
+)
+
+// Window is a daily [Start, End) interval in a business's local time during
+// which reminders must not fire. Start > End means the window wraps midnight.
+type Window struct {
+	Start    int // minutes after local midnight
+	End      int
+	Mode     string
+	Location *time.Location
+}
+
+// Parse builds a Window from the "HH:MM" values stored on the business profile.
+// An empty start or end means no quiet hours and yields ok=false.
+func Parse(start, end, mode, timezone string) (w Window, ok bool, err error) {
+	start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+	if start == "" || end == "" {
+		return Window{}, false, nil
+	}
+	if w.Start, err = parseClock(start); err != nil {
+		return Window{}, false, err
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return Window{}, false, err
+	}
+	if w.Start == w.End {
+		return Window{}, false, errors.New("quiet hours start and end must differ")
+	}
+	w.Mode = strings.ToLower(strings.TrimSpace(mode))
+	if w.Mode != ModeEarlier {
+		w.Mode = ModeLater
+	}
+	timezone = strings.TrimSpace(timezone)
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if w.Location, err = time.LoadLocation(timezone); err != nil {
+		return Window{}, false, err
+	}
+	return w, true, nil
+}
+
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, errors.New("quiet hours must be HH:MM")
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Adjust moves t out of the window. ModeLater shifts to the window end and
+// ModeEarlier to one minute before the window start. A later shift that would
+// reach appointmentStart falls back to the earlier side; a zero appointmentStart
+// disables that check.
+func (w Window) Adjust(t time.Time, appointmentStart time.Time) time.Time {
+	if w.Location == nil {
+		return t
+	}
+	windowStart, windowEnd, inside := w.bounds(t)
+	if !inside {
+		return t
+	}
+	earlier := windowStart.Add(-time.Minute)
+	if w.Mode == ModeEarlier {
+		return earlier
+	}
+	if !appointmentStart.IsZero() && !windowEnd.Before(appointmentStart) {
+		return earlier
+	}
+	return windowEnd
+}
+
+// bounds returns the occurrence of the window that contains t, if any.
+func (w Window) bounds(t time.Time) (time.Time, time.Time, bool) {
+	local := t.In(w.Location)
+	minute := local.Hour()*60 + local.Minute()
+	day := func(offset int, minutes int) time.Time {
+		return time.Date(local.Year(), local.Month(), local.Day()+offset, minutes/60, minutes%60, 0, 0, w.Location)
+	}
+
+	if w.Start < w.End {
+		if minute >= w.Start && minute < w.End {
+			return day(0, w.Start), day(0, w.End), true
+		}
+		return time.Time{}, time.Time{}, false
+	}
+	switch {
+	case minute >= w.Start:
+		return day(0, w.Start), day(1, w.End), true
+	case minute < w.End:
+		return day(-1, w.Start), day(0, w.End), true
+	}
+	return time.Time{}, time.Time{}, false
+}
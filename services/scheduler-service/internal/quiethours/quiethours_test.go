@@ -0,0 +1,62 @@
+package quiethours
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdjust(t *testing.T) {
+	mustParse := func(start, end, mode, tz string) Window {
+		w, ok, err := Parse(start, end, mode, tz)
+		if err != nil || !ok {
+			t.Fatalf("parse %s-%s: ok=%v err=%v", start, end, ok, err)
+		}
+		return w
+	}
+	at := func(v string) time.Time {
+		ts, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+
+	overnightLater := mustParse("22:00", "07:00", "later", "America/New_York")
+	overnightEarlier := mustParse("22:00", "07:00", "earlier", "America/New_York")
+	daytime := mustParse("12:00", "13:30", "later", "UTC")
+
+	cases := []struct {
+		name string
+		w    Window
+		t    string
+		appt string
+		want string
+	}{
+		// 08:00Z is 03:00 in New York (EST).
+		{"later after midnight", overnightLater, "2025-01-15T08:00:00Z", "2025-01-16T08:00:00Z", "2025-01-15T12:00:00Z"},
+		{"later before midnight", overnightLater, "2025-01-15T03:30:00Z", "2025-01-16T08:00:00Z", "2025-01-15T12:00:00Z"},
+		{"earlier after midnight", overnightEarlier, "2025-01-15T08:00:00Z", "2025-01-16T08:00:00Z", "2025-01-15T02:59:00Z"},
+		{"later capped by appointment", overnightLater, "2025-01-15T07:00:00Z", "2025-01-15T09:00:00Z", "2025-01-15T02:59:00Z"},
+		{"outside window", overnightLater, "2025-01-15T15:00:00Z", "", "2025-01-15T15:00:00Z"},
+		{"end is exclusive", daytime, "2025-01-15T13:30:00Z", "", "2025-01-15T13:30:00Z"},
+		{"daytime window", daytime, "2025-01-15T12:10:00Z", "", "2025-01-15T13:30:00Z"},
+	}
+	for _, tc := range cases {
+		var appt time.Time
+		if tc.appt != "" {
+			appt = at(tc.appt)
+		}
+		if got := tc.w.Adjust(at(tc.t), appt); !got.Equal(at(tc.want)) {
+			t.Errorf("%s: got %s, want %s", tc.name, got.UTC().Format(time.RFC3339), tc.want)
+		}
+	}
+}
+
+func TestParseNoWindow(t *testing.T) {
+	if _, ok, err := Parse("", "", "", "UTC"); ok || err != nil {
+		t.Fatalf("expected no window, got ok=%v err=%v", ok, err)
+	}
+	if _, _, err := Parse("25:00", "07:00", "later", "UTC"); err == nil {
+		t.Fatalf("expected invalid clock error")
+	}
+}
@@ -0,0 +1,242 @@
+package replan
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/jobs"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/quiethours"
+)
+
+// Policy is the payload of business.reminder_policy.updated.v1.
+type Policy struct {
+	BusinessID     string          `json:"business_id"`
+	Timezone       string          `json:"timezone"`
+	OffsetsMins    []int           `json:"reminder_offsets_minutes"`
+	ServiceOffsets []ServiceOffset `json:"service_reminder_offsets"`
+	QuietHours     *QuietHours     `json:"quiet_hours"`
+	UpdatedAt      string          `json:"updated_at"`
+}
+
+type ServiceOffset struct {
+	ServiceID   string `json:"service_id"`
+	OffsetsMins []int  `json:"reminder_offsets_minutes"`
+}
+
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Mode  string `json:"mode"`
+}
+
+// OffsetsFor returns the offsets that apply to appointments of serviceID.
+func (p Policy) OffsetsFor(serviceID string) []int {
+	for _, so := range p.ServiceOffsets {
+		if so.ServiceID == serviceID && len(so.OffsetsMins) > 0 {
+			return so.OffsetsMins
+		}
+	}
+	return p.OffsetsMins
+}
+
+// Plan compares the jobs of one appointment with the offsets it should have.
+// Pending jobs whose remind_at no longer matches an offset are returned in
+// cancel; every channel/recipient pair gets a job for each offset that is
+// still in the future and not already pending or sent. Only jobs an earlier
+// re-plan cancelled are re-added: an operator's cancellation stands.
+func Plan(existing []jobs.Job, offsets []int, policy Policy, now time.Time) (cancel []int64, add []jobs.Job) {
+	if len(existing) == 0 {
+		return nil, nil
+	}
+	latest := existing[len(existing)-1]
+	start := latest.AppointmentTime()
+	if start.IsZero() || !start.After(now) {
+		return nil, nil
+	}
+
+	want := map[int64]bool{}
+	for _, offset := range offsets {
+		want[start.Add(-time.Duration(offset)*time.Minute).Unix()] = true
+	}
+
+	type target struct{ channel, recipient string }
+	var targets []target
+	seenTarget := map[target]bool{}
+	live := map[string]bool{}
+	for _, job := range existing {
+		t := target{job.Channel, job.Recipient}
+		if !seenTarget[t] {
+			seenTarget[t] = true
+			targets = append(targets, t)
+		}
+		switch job.Status {
+		case "pending":
+			if !want[job.RemindAt.Unix()] {
+				cancel = append(cancel, job.ID)
+				continue
+			}
+			live[job.IdempotencyKey] = true
+		case "cancelled":
+			if job.CancelReason != jobs.CancelReasonReplan {
+				live[job.IdempotencyKey] = true
+			}
+		default:
+			live[job.IdempotencyKey] = true
+		}
+	}
+
+	sorted := append([]int(nil), offsets...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	for _, offset := range sorted {
+		remindAt := start.Add(-time.Duration(offset) * time.Minute).UTC()
+		if !remindAt.After(now) {
+			continue
+		}
+		for _, t := range targets {
+			key := Key(latest.AppointmentID, remindAt, t.channel)
+			if live[key] {
+				continue
+			}
+			live[key] = true
+			job := jobs.Job{
+				IdempotencyKey:   key,
+				AppointmentID:    latest.AppointmentID,
+				BusinessID:       latest.BusinessID,
+				Channel:          t.channel,
+				Recipient:        t.recipient,
+				RemindAt:         remindAt,
+				TemplateData:     latest.TemplateData,
+				AppointmentStart: &start,
+			}
+			if qh := policy.QuietHours; qh != nil {
+				if _, ok, err := quiethours.Parse(qh.Start, qh.End, qh.Mode, policy.Timezone); err == nil && ok {
+					job.QuietHoursStart, job.QuietHoursEnd, job.QuietHoursMode, job.Timezone = qh.Start, qh.End, qh.Mode, policy.Timezone
+				}
+			}
+			job.NextRunAt = job.AvoidQuietHours(remindAt)
+			add = append(add, job)
+		}
+	}
+	return cancel, add
+}
+
+// Key matches the idempotency key used for reminders requested by booking-service.
+func Key(appointmentID string, remindAt time.Time, channel string) string {
+	return appointmentID + "|" + remindAt.UTC().Format(time.RFC3339) + "|" + channel
+}
+
+type Replanner struct {
+	pool      *db.Pool
+	repo      *jobs.Repository
+	logger    *slog.Logger
+	batchSize int
+}
+
+type Config struct {
+	BatchSize int
+}
+
+func New(pool *db.Pool, repo *jobs.Repository, logger *slog.Logger, cfg Config) *Replanner {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &Replanner{pool: pool, repo: repo, logger: logger, batchSize: cfg.BatchSize}
+}
+
+// Apply re-plans the reminders of every future appointment of the business,
+// committing once per batch of appointments so large tenants do not hold
+// long-running transactions. A policy older than one already applied is
+// ignored, including when a newer one lands between batches.
+func (r *Replanner) Apply(ctx context.Context, policy Policy) error {
+	updatedAt, err := time.Parse(time.RFC3339Nano, policy.UpdatedAt)
+	if err != nil {
+		r.logger.Error("reminder policy without a valid updated_at", "business_id", policy.BusinessID, "updated_at", policy.UpdatedAt)
+		return nil
+	}
+	after := ""
+	cancelled, added := 0, 0
+	for {
+		last, c, a, err := r.applyBatch(ctx, policy, updatedAt, after)
+		if errors.Is(err, errStalePolicy) {
+			r.logger.Info("stale reminder policy ignored", "business_id", policy.BusinessID, "updated_at", policy.UpdatedAt)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cancelled += c
+		added += a
+		if last == "" {
+			break
+		}
+		after = last
+	}
+	r.logger.Info("reminder policy re-planned", "business_id", policy.BusinessID, "cancelled", cancelled, "added", added)
+	return nil
+}
+
+var errStalePolicy = errors.New("a newer reminder policy was applied")
+
+func (r *Replanner) applyBatch(ctx context.Context, policy Policy, updatedAt time.Time, after string) (string, int, int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	current, err := r.repo.AcceptPolicy(ctx, tx, policy.BusinessID, updatedAt)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if !current {
+		return "", 0, 0, errStalePolicy
+	}
+
+	ids, err := r.repo.FutureAppointmentIDs(ctx, tx, policy.BusinessID, after, r.batchSize)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if len(ids) == 0 {
+		return "", 0, 0, tx.Commit(ctx)
+	}
+	existing, err := r.repo.ListForAppointments(ctx, tx, ids)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	byAppointment := map[string][]jobs.Job{}
+	for _, job := range existing {
+		byAppointment[job.AppointmentID] = append(byAppointment[job.AppointmentID], job)
+	}
+
+	now := time.Now()
+	var cancelIDs []int64
+	added := 0
+	for _, id := range ids {
+		appointmentJobs := byAppointment[id]
+		if len(appointmentJobs) == 0 {
+			continue
+		}
+		serviceID, _ := appointmentJobs[len(appointmentJobs)-1].TemplateData["service_id"].(string)
+		cancel, add := Plan(appointmentJobs, policy.OffsetsFor(serviceID), policy, now)
+		cancelIDs = append(cancelIDs, cancel...)
+		for _, job := range add {
+			if err := r.repo.InsertOrRevive(ctx, tx, job); err != nil {
+				return "", 0, 0, err
+			}
+		}
+		added += len(add)
+	}
+	cancelled, err := r.repo.CancelWithReason(ctx, tx, cancelIDs, jobs.CancelReasonReplan)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", 0, 0, err
+	}
+	return ids[len(ids)-1], len(cancelled), added, nil
+}
@@ -0,0 +1,112 @@
+package replan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/jobs"
+)
+
+func TestPlan(t *testing.T) {
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	start := time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC)
+	job := func(id int64, offset int, channel, status string) jobs.Job {
+		remindAt := start.Add(-time.Duration(offset) * time.Minute)
+		return jobs.Job{
+			ID:               id,
+			IdempotencyKey:   Key("appt-1", remindAt, channel),
+			AppointmentID:    "appt-1",
+			BusinessID:       "biz-1",
+			Channel:          channel,
+			Recipient:        channel + "-recipient",
+			RemindAt:         remindAt,
+			Status:           status,
+			AppointmentStart: &start,
+			TemplateData:     map[string]any{"service_id": "svc-1"},
+		}
+	}
+	replanned := func(j jobs.Job) jobs.Job {
+		j.CancelReason = jobs.CancelReasonReplan
+		return j
+	}
+
+	tests := []struct {
+		name       string
+		existing   []jobs.Job
+		offsets    []int
+		wantCancel []int64
+		wantAdd    []string
+	}{
+		{
+			name:     "unchanged policy is a no-op",
+			existing: []jobs.Job{job(1, 1440, "email", "pending"), job(2, 60, "email", "pending")},
+			offsets:  []int{1440, 60},
+		},
+		{
+			name:       "removed offset cancels and new offset is added per channel",
+			existing:   []jobs.Job{job(1, 1440, "email", "pending"), job(4, 1440, "sms", "pending"), job(2, 60, "email", "pending"), job(3, 60, "sms", "pending")},
+			offsets:    []int{1440, 30},
+			wantCancel: []int64{2, 3},
+			wantAdd: []string{
+				Key("appt-1", start.Add(-30*time.Minute), "email"),
+				Key("appt-1", start.Add(-30*time.Minute), "sms"),
+			},
+		},
+		{
+			name:     "offsets already in the past are skipped",
+			existing: []jobs.Job{job(1, 60, "email", "pending")},
+			offsets:  []int{4320, 60},
+		},
+		{
+			name:     "offset an earlier re-plan cancelled is re-added",
+			existing: []jobs.Job{replanned(job(1, 1440, "email", "cancelled")), job(2, 60, "email", "pending")},
+			offsets:  []int{1440, 60},
+			wantAdd:  []string{Key("appt-1", start.Add(-1440*time.Minute), "email")},
+		},
+		{
+			name:     "reminder an operator cancelled stays cancelled",
+			existing: []jobs.Job{job(1, 1440, "email", "cancelled"), job(2, 60, "email", "pending")},
+			offsets:  []int{1440, 60},
+		},
+		{
+			name:     "sent reminders are neither cancelled nor re-sent",
+			existing: []jobs.Job{job(1, 1440, "email", "processed"), job(2, 60, "email", "pending")},
+			offsets:  []int{1440, 60},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cancel, add := Plan(tc.existing, tc.offsets, Policy{}, now)
+			if len(cancel) != len(tc.wantCancel) {
+				t.Fatalf("cancel = %v, want %v", cancel, tc.wantCancel)
+			}
+			for i := range cancel {
+				if cancel[i] != tc.wantCancel[i] {
+					t.Fatalf("cancel = %v, want %v", cancel, tc.wantCancel)
+				}
+			}
+			if len(add) != len(tc.wantAdd) {
+				t.Fatalf("add = %d jobs, want %d", len(add), len(tc.wantAdd))
+			}
+			for i, job := range add {
+				if job.IdempotencyKey != tc.wantAdd[i] {
+					t.Fatalf("add[%d] = %s, want %s", i, job.IdempotencyKey, tc.wantAdd[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyOffsetsFor(t *testing.T) {
+	p := Policy{
+		OffsetsMins:    []int{1440, 60},
+		ServiceOffsets: []ServiceOffset{{ServiceID: "svc-1", OffsetsMins: []int{120}}},
+	}
+	if got := p.OffsetsFor("svc-1"); len(got) != 1 || got[0] != 120 {
+		t.Fatalf("service override = %v", got)
+	}
+	if got := p.OffsetsFor("svc-2"); len(got) != 2 {
+		t.Fatalf("business offsets = %v", got)
+	}
+}
@@ -0,0 +1,247 @@
+package shard
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+)
+
+// Leaser keeps this replica's share of the scheduler shards. Each replica
+// heartbeats into scheduler_workers, derives its fair share from the number of
+// live replicas, renews the leases it holds, hands back any surplus and claims
+// expired leases up to its share. A replica that dies simply stops renewing and
+// its shards are picked up by the others once the TTL passes.
+//
+// Leases only reduce contention: jobs are still claimed with FOR UPDATE SKIP
+// LOCKED, so two replicas briefly overlapping on a shard cannot double-send.
+type Leaser struct {
+	pool     *db.Pool
+	logger   *slog.Logger
+	workerID string
+	total    int
+	ttl      time.Duration
+
+	mu     sync.RWMutex
+	owned  []int32
+	expiry time.Time
+}
+
+type Config struct {
+	WorkerID string
+	Shards   int
+	TTL      time.Duration
+}
+
+func NewLeaser(pool *db.Pool, logger *slog.Logger, cfg Config) *Leaser {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 30 * time.Second
+	}
+	return &Leaser{
+		pool:     pool,
+		logger:   logger,
+		workerID: cfg.WorkerID,
+		total:    cfg.Shards,
+		ttl:      cfg.TTL,
+	}
+}
+
+// Total is the configured shard count.
+func (l *Leaser) Total() int {
+	return l.total
+}
+
+// Owned returns the shards this replica may work on right now. It is empty
+// once the leases have expired without being renewed.
+func (l *Leaser) Owned(now time.Time) []int32 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if now.After(l.expiry) {
+		return nil
+	}
+	return append([]int32(nil), l.owned...)
+}
+
+func (l *Leaser) Run(ctx context.Context) {
+	if err := l.seed(ctx); err != nil {
+		l.logger.Error("shard lease seed failed", "err", err)
+	}
+	l.tick(ctx)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			l.release(context.Background())
+			return
+		case <-ticker.C:
+			l.tick(ctx)
+		}
+	}
+}
+
+func (l *Leaser) tick(ctx context.Context) {
+	owned, err := l.rebalance(ctx)
+	if err != nil {
+		l.logger.Error("shard lease rebalance failed", "err", err)
+		return
+	}
+	l.mu.Lock()
+	changed := !equal(l.owned, owned)
+	l.owned = owned
+	// Stop a little before the database lease ends so a slow tick cannot overlap the next owner.
+	l.expiry = time.Now().Add(l.ttl * 2 / 3)
+	l.mu.Unlock()
+	if changed {
+		l.logger.Info("scheduler shards rebalanced", "worker_id", l.workerID, "owned", owned, "total", l.total)
+	}
+}
+
+func (l *Leaser) seed(ctx context.Context) error {
+	_, err := l.pool.Exec(ctx, `
+		INSERT INTO scheduler_shard_leases (shard)
+		SELECT generate_series(0, $1 - 1)
+		ON CONFLICT (shard) DO NOTHING
+	`, l.total)
+	return err
+}
+
+func (l *Leaser) rebalance(ctx context.Context) ([]int32, error) {
+	tx, err := l.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	ttl := l.ttl.Seconds()
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO scheduler_workers (worker_id, heartbeat_at)
+		VALUES ($1, now())
+		ON CONFLICT (worker_id) DO UPDATE SET heartbeat_at = now()
+	`, l.workerID); err != nil {
+		return nil, err
+	}
+	var live int
+	if err := tx.QueryRow(ctx, `
+		SELECT count(*) FROM scheduler_workers
+		WHERE heartbeat_at > now() - make_interval(secs => $1)
+	`, ttl).Scan(&live); err != nil {
+		return nil, err
+	}
+	target := FairShare(l.total, live)
+
+	mine, err := queryShards(ctx, tx, `
+		UPDATE scheduler_shard_leases
+		SET expires_at = now() + make_interval(secs => $2)
+		WHERE owner = $1 AND shard < $3
+		RETURNING shard
+	`, l.workerID, ttl, l.total)
+	if err != nil {
+		return nil, err
+	}
+
+	if surplus := len(mine) - target; surplus > 0 {
+		released := mine[len(mine)-surplus:]
+		if _, err := tx.Exec(ctx, `
+			UPDATE scheduler_shard_leases
+			SET owner = NULL, expires_at = 'epoch'
+			WHERE owner = $1 AND shard = ANY($2)
+		`, l.workerID, released); err != nil {
+			return nil, err
+		}
+		mine = mine[:len(mine)-surplus]
+	}
+
+	if missing := target - len(mine); missing > 0 {
+		claimed, err := queryShards(ctx, tx, `
+			UPDATE scheduler_shard_leases
+			SET owner = $1, expires_at = now() + make_interval(secs => $2)
+			WHERE shard IN (
+				SELECT shard FROM scheduler_shard_leases
+				WHERE shard < $3 AND (owner IS NULL OR expires_at < now())
+				ORDER BY shard
+				LIMIT $4
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING shard
+		`, l.workerID, ttl, l.total, missing)
+		if err != nil {
+			return nil, err
+		}
+		mine = append(mine, claimed...)
+		sort.Slice(mine, func(i, j int) bool { return mine[i] < mine[j] })
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM scheduler_workers
+		WHERE heartbeat_at < now() - make_interval(secs => $1) * 10
+	`, ttl); err != nil {
+		return nil, err
+	}
+	return mine, tx.Commit(ctx)
+}
+
+// release hands shards back on shutdown so other replicas do not wait for the TTL.
+func (l *Leaser) release(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := l.pool.Exec(ctx, `
+		UPDATE scheduler_shard_leases SET owner = NULL, expires_at = 'epoch' WHERE owner = $1
+	`, l.workerID); err != nil {
+		l.logger.Error("shard lease release failed", "err", err)
+	}
+	if _, err := l.pool.Exec(ctx, `DELETE FROM scheduler_workers WHERE worker_id = $1`, l.workerID); err != nil {
+		l.logger.Error("scheduler worker deregister failed", "err", err)
+	}
+}
+
+// FairShare is the number of shards each of live replicas should hold,
+// rounded up so every shard has an owner.
+func FairShare(total, live int) int {
+	if total <= 0 {
+		return 0
+	}
+	if live <= 0 {
+		live = 1
+	}
+	return (total + live - 1) / live
+}
+
+func queryShards(ctx context.Context, tx pgx.Tx, sql string, args ...any) ([]int32, error) {
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shards []int32
+	for rows.Next() {
+		var shard int32
+		if err := rows.Scan(&shard); err != nil {
+			return nil, err
+		}
+		shards = append(shards, shard)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i] < shards[j] })
+	return shards, nil
+}
+
+func equal(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
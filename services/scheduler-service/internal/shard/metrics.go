@@ -0,0 +1,119 @@
+package shard
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/md-rashed-zaman/apptremind/libs/db"
+	"github.com/md-rashed-zaman/apptremind/libs/metrics"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/jobs"
+)
+
+var (
+	dueJobs = metrics.NewGauge("scheduler_due_jobs",
+		"Pending jobs whose next_run_at has passed, by shard (\"all\" when unsharded).", "shard")
+	dueLag = metrics.NewGauge("scheduler_due_lag_seconds",
+		"Age of the oldest due pending job, by shard.", "shard")
+	shardsOwned = metrics.NewGauge("scheduler_shards_owned",
+		"Shards currently leased by this replica.")
+)
+
+// LagReporter samples the due-job backlog per shard into the scheduler_due_*
+// gauges. Every replica reports all shards, so any one of them can be scraped
+// to see which shard is falling behind.
+type LagReporter struct {
+	pool     *db.Pool
+	repo     *jobs.Repository
+	leaser   *Leaser
+	logger   *slog.Logger
+	interval time.Duration
+}
+
+// NewLagReporter reports per-shard lag when leaser is non-nil and a single
+// "all" series otherwise.
+func NewLagReporter(pool *db.Pool, repo *jobs.Repository, leaser *Leaser, logger *slog.Logger, interval time.Duration) *LagReporter {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	return &LagReporter{pool: pool, repo: repo, leaser: leaser, logger: logger, interval: interval}
+}
+
+func (r *LagReporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.sample(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Error("scheduler lag sample failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *LagReporter) sample(ctx context.Context) error {
+	total := 0
+	if r.leaser != nil {
+		total = r.leaser.Total()
+	}
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	samples, err := r.repo.DueLag(ctx, tx, total)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// Shards without due jobs report zero rather than disappearing.
+	if total > 0 {
+		byShard := make(map[int]jobs.ShardLag, len(samples))
+		for _, s := range samples {
+			byShard[s.Shard] = s
+		}
+		samples = samples[:0]
+		for i := 0; i < total; i++ {
+			s := byShard[i]
+			s.Shard = i
+			samples = append(samples, s)
+		}
+	} else if len(samples) == 0 {
+		samples = []jobs.ShardLag{{Shard: -1}}
+	}
+
+	owned := -1
+	if r.leaser != nil {
+		owned = len(r.leaser.Owned(time.Now()))
+	}
+	publish(samples, owned)
+	return nil
+}
+
+// publish replaces the lag gauges with samples; owned < 0 leaves the
+// ownership gauge unset.
+func publish(samples []jobs.ShardLag, owned int) {
+	dueJobs.Reset()
+	dueLag.Reset()
+	for _, s := range samples {
+		dueJobs.Set(float64(s.Due), shardLabel(s.Shard))
+		dueLag.Set(s.LagSeconds, shardLabel(s.Shard))
+	}
+	if owned >= 0 {
+		shardsOwned.Set(float64(owned))
+	}
+}
+
+func shardLabel(shard int) string {
+	if shard < 0 {
+		return "all"
+	}
+	return strconv.Itoa(shard)
+}
@@ -0,0 +1,58 @@
+package shard
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/md-rashed-zaman/apptremind/libs/metrics"
+	"github.com/md-rashed-zaman/apptremind/services/scheduler-service/internal/jobs"
+)
+
+func TestFairShare(t *testing.T) {
+	tests := []struct {
+		total, live, want int
+	}{
+		{total: 16, live: 1, want: 16},
+		{total: 16, live: 4, want: 4},
+		{total: 16, live: 3, want: 6},
+		{total: 4, live: 8, want: 1},
+		{total: 8, live: 0, want: 8},
+		{total: 0, live: 3, want: 0},
+	}
+	for _, tc := range tests {
+		if got := FairShare(tc.total, tc.live); got != tc.want {
+			t.Fatalf("FairShare(%d, %d) = %d, want %d", tc.total, tc.live, got, tc.want)
+		}
+		// Every shard must be ownable: live replicas * share covers the total.
+		if tc.total > 0 && max(tc.live, 1)*FairShare(tc.total, tc.live) < tc.total {
+			t.Fatalf("FairShare(%d, %d) leaves shards unowned", tc.total, tc.live)
+		}
+	}
+}
+
+func TestPublishLagMetrics(t *testing.T) {
+	render := func() string {
+		var sb strings.Builder
+		metrics.Default.Render(&sb)
+		return sb.String()
+	}
+
+	publish([]jobs.ShardLag{{Shard: 0, Due: 3, LagSeconds: 12.5}, {Shard: 1}}, 2)
+	out := render()
+	for _, want := range []string{
+		`scheduler_due_jobs{shard="0"} 3`,
+		`scheduler_due_jobs{shard="1"} 0`,
+		`scheduler_due_lag_seconds{shard="0"} 12.5`,
+		`scheduler_shards_owned 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	publish([]jobs.ShardLag{{Shard: -1, Due: 1, LagSeconds: 1}}, -1)
+	out = render()
+	if !strings.Contains(out, `scheduler_due_jobs{shard="all"} 1`) || strings.Contains(out, `scheduler_due_jobs{shard="0"}`) {
+		t.Fatalf("stale shard series survived a resample:\n%s", out)
+	}
+}
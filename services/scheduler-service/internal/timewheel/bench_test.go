@@ -0,0 +1,162 @@
+package timewheel
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+func BenchmarkAdd(b *testing.B) {
+	w := New(100*time.Millisecond, 256, 3, epoch)
+	rng := rand.New(rand.NewPCG(1, 2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Add(int64(i%100000), epoch.Add(time.Duration(rng.Int64N(int64(time.Hour)))))
+	}
+}
+
+func BenchmarkAdvance(b *testing.B) {
+	w := New(100*time.Millisecond, 256, 3, epoch)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 100000; i++ {
+		w.Add(int64(i), epoch.Add(time.Duration(rng.Int64N(int64(time.Hour)))))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Re-arm whatever fired so the wheel stays at 100k timers.
+		now := epoch.Add(time.Duration(i+1) * 100 * time.Millisecond)
+		for _, id := range w.Advance(now) {
+			w.Add(id, now.Add(time.Hour))
+		}
+	}
+}
+
+// The strategy benchmarks are a model, not a load test: they replay one
+// simulated hour of jobs against the worker's two modes and count the
+// database round trips each would issue and how late each job would fire
+// relative to next_run_at. No database is involved, so the numbers say
+// nothing about query cost; for that run the Query benchmarks in
+// internal/jobs against Postgres. Under heavy load wheel claims are issued up
+// to once per tick, so the tick trades firing precision against round trips.
+//
+//	go test ./internal/timewheel -run '^$' -bench Strategy
+func BenchmarkStrategy(b *testing.B) {
+	workloads := []struct {
+		name string
+		jobs int
+	}{
+		{"idle", 0},
+		{"2k_jobs_per_hour", 2000},
+		{"50k_jobs_per_hour", 50000},
+	}
+	for _, wl := range workloads {
+		due := randomDeadlines(wl.jobs, time.Hour)
+		b.Run(wl.name+"/polling_2s", func(b *testing.B) {
+			var r simResult
+			for i := 0; i < b.N; i++ {
+				r = simulatePolling(due, time.Hour, 2*time.Second, 50)
+			}
+			r.report(b)
+		})
+		for _, tick := range []time.Duration{100 * time.Millisecond, 500 * time.Millisecond} {
+			b.Run(wl.name+"/wheel_"+tick.String(), func(b *testing.B) {
+				var r simResult
+				for i := 0; i < b.N; i++ {
+					r = simulateWheel(due, time.Hour, wheelSim{
+						tick:     tick,
+						horizon:  5 * time.Minute,
+						reload:   15 * time.Second,
+						fallback: 30 * time.Second,
+						batch:    50,
+					})
+				}
+				r.report(b)
+			})
+		}
+	}
+}
+
+type simResult struct {
+	queries int
+	latency []time.Duration
+}
+
+func (r simResult) report(b *testing.B) {
+	b.ReportMetric(float64(r.queries), "modelled-queries/h")
+	if len(r.latency) == 0 {
+		return
+	}
+	slices.Sort(r.latency)
+	var sum time.Duration
+	for _, l := range r.latency {
+		sum += l
+	}
+	b.ReportMetric(float64(sum.Milliseconds())/float64(len(r.latency)), "modelled-mean-late-ms")
+	b.ReportMetric(float64(r.latency[len(r.latency)*99/100].Milliseconds()), "modelled-p99-late-ms")
+}
+
+func randomDeadlines(n int, within time.Duration) []time.Duration {
+	rng := rand.New(rand.NewPCG(3, 4))
+	due := make([]time.Duration, n)
+	for i := range due {
+		due[i] = time.Duration(rng.Int64N(int64(within)))
+	}
+	slices.Sort(due)
+	return due
+}
+
+// simulatePolling models FetchDue every interval: one query per poll, plus
+// one more per extra batch when the backlog exceeds batch.
+func simulatePolling(due []time.Duration, length, interval time.Duration, batch int) simResult {
+	var r simResult
+	next := 0
+	for t := interval; t <= length; t += interval {
+		r.queries++
+		fired := 0
+		for next < len(due) && due[next] <= t {
+			r.latency = append(r.latency, t-due[next])
+			next++
+			fired++
+		}
+		if fired > batch {
+			r.queries += (fired - 1) / batch
+		}
+	}
+	return r
+}
+
+type wheelSim struct {
+	tick, horizon, reload, fallback time.Duration
+	batch                           int
+}
+
+// simulateWheel models hybrid mode with the real wheel: a load query every
+// reload, a fallback poll every fallback, and one claim query per batch of
+// ids released by a tick.
+func simulateWheel(due []time.Duration, length time.Duration, cfg wheelSim) simResult {
+	var r simResult
+	w := New(cfg.tick, 256, 3, epoch)
+	loaded := 0
+	for t := time.Duration(0); t <= length; t += cfg.tick {
+		if t%cfg.reload == 0 {
+			r.queries++
+			for loaded < len(due) && due[loaded] <= t+cfg.horizon {
+				w.Add(int64(loaded), epoch.Add(due[loaded]))
+				loaded++
+			}
+		}
+		if t > 0 && t%cfg.fallback == 0 {
+			r.queries++
+		}
+		fired := w.Advance(epoch.Add(t))
+		if len(fired) == 0 {
+			continue
+		}
+		r.queries += (len(fired) + cfg.batch - 1) / cfg.batch
+		for _, id := range fired {
+			r.latency = append(r.latency, t-due[id])
+		}
+	}
+	return r
+}
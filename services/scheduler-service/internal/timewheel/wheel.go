@@ -0,0 +1,176 @@
+// Package timewheel implements a hierarchical timing wheel: O(1) insert and
+// removal, and O(1) amortised work per tick no matter how many timers are
+// pending. The wheel has no goroutines or clock of its own; callers move it
+// forward with Advance, which keeps it deterministic under test.
+package timewheel
+
+import (
+	"time"
+)
+
+type entry struct {
+	deadline int64 // absolute tick
+	level    int
+	slot     int
+}
+
+// Wheel tracks ids by deadline. Level 0 has slots one tick wide; each higher
+// level's slots are as wide as the whole level below, so levels L with S slots
+// cover S^L ticks. Entries in higher levels cascade down as time reaches them.
+type Wheel struct {
+	tick    time.Duration
+	slots   int
+	start   time.Time
+	current int64 // ticks processed so far
+	levels  [][]map[int64]struct{}
+	spans   []int64 // ticks covered by one slot at each level
+	entries map[int64]entry
+	due     []int64 // added with a deadline already in the past
+}
+
+// New creates a wheel anchored at start. With tick 100ms, 64 slots and 3
+// levels it spans about 7.3 hours.
+func New(tick time.Duration, slots int, levels int, start time.Time) *Wheel {
+	if tick <= 0 {
+		tick = 100 * time.Millisecond
+	}
+	if slots < 2 {
+		slots = 64
+	}
+	if levels < 1 {
+		levels = 3
+	}
+	w := &Wheel{
+		tick:    tick,
+		slots:   slots,
+		start:   start,
+		entries: map[int64]entry{},
+	}
+	span := int64(1)
+	for l := 0; l < levels; l++ {
+		buckets := make([]map[int64]struct{}, slots)
+		for i := range buckets {
+			buckets[i] = map[int64]struct{}{}
+		}
+		w.levels = append(w.levels, buckets)
+		w.spans = append(w.spans, span)
+		span *= int64(slots)
+	}
+	return w
+}
+
+// Span is the furthest ahead of the wheel's current time a deadline can be.
+func (w *Wheel) Span() time.Duration {
+	last := len(w.spans) - 1
+	return time.Duration(w.spans[last]*int64(w.slots)) * w.tick
+}
+
+// Len is the number of ids waiting to fire.
+func (w *Wheel) Len() int {
+	return len(w.entries) + len(w.due)
+}
+
+// Has reports whether id is scheduled.
+func (w *Wheel) Has(id int64) bool {
+	if _, ok := w.entries[id]; ok {
+		return true
+	}
+	for _, d := range w.due {
+		if d == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Add schedules id to fire at the first tick at or after at, replacing any
+// earlier deadline for the same id. Deadlines in the past fire on the next
+// Advance. It returns false, without scheduling, when at is beyond Span.
+func (w *Wheel) Add(id int64, at time.Time) bool {
+	deadline := w.ticksAt(at)
+	if deadline-w.current >= w.spans[len(w.spans)-1]*int64(w.slots) {
+		return false
+	}
+	w.Remove(id)
+	if deadline <= w.current {
+		w.due = append(w.due, id)
+		return true
+	}
+	w.place(id, deadline)
+	return true
+}
+
+// Remove unschedules id; unknown ids are ignored.
+func (w *Wheel) Remove(id int64) {
+	e, ok := w.entries[id]
+	if !ok {
+		for i, d := range w.due {
+			if d == id {
+				w.due = append(w.due[:i], w.due[i+1:]...)
+				return
+			}
+		}
+		return
+	}
+	delete(w.levels[e.level][e.slot], id)
+	delete(w.entries, id)
+}
+
+// Advance moves the wheel to now and returns the ids whose deadline passed,
+// in deadline order (ids sharing a tick are unordered).
+func (w *Wheel) Advance(now time.Time) []int64 {
+	fired := w.due
+	w.due = nil
+	target := int64(now.Sub(w.start) / w.tick)
+	for w.current < target {
+		w.current++
+		w.cascade()
+		bucket := w.levels[0][w.current%int64(w.slots)]
+		for id := range bucket {
+			fired = append(fired, id)
+			delete(w.entries, id)
+			delete(bucket, id)
+		}
+	}
+	return fired
+}
+
+// cascade redistributes higher-level slots whose window starts at current.
+func (w *Wheel) cascade() {
+	for l := 1; l < len(w.levels); l++ {
+		if w.current%w.spans[l] != 0 {
+			return
+		}
+		bucket := w.levels[l][(w.current/w.spans[l])%int64(w.slots)]
+		for id := range bucket {
+			e := w.entries[id]
+			delete(bucket, id)
+			if e.deadline <= w.current {
+				// Reaches level 0's current slot, which Advance drains next.
+				w.place(id, w.current)
+				continue
+			}
+			w.place(id, e.deadline)
+		}
+	}
+}
+
+func (w *Wheel) place(id int64, deadline int64) {
+	delta := deadline - w.current
+	level := 0
+	for level < len(w.levels)-1 && delta >= w.spans[level+1] {
+		level++
+	}
+	slot := int((deadline / w.spans[level]) % int64(w.slots))
+	w.levels[level][slot][id] = struct{}{}
+	w.entries[id] = entry{deadline: deadline, level: level, slot: slot}
+}
+
+func (w *Wheel) ticksAt(at time.Time) int64 {
+	d := at.Sub(w.start)
+	ticks := int64(d / w.tick)
+	if d%w.tick > 0 {
+		ticks++
+	}
+	return ticks
+}
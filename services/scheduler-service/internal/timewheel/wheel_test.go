@@ -0,0 +1,126 @@
+package timewheel
+
+import (
+	"math/rand/v2"
+	"sort"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestWheelFiresOnDeadlineTick(t *testing.T) {
+	w := New(100*time.Millisecond, 8, 2, epoch)
+	w.Add(1, epoch.Add(250*time.Millisecond)) // rounds up to 300ms
+	w.Add(2, epoch.Add(100*time.Millisecond))
+
+	if got := w.Advance(epoch.Add(50 * time.Millisecond)); len(got) != 0 {
+		t.Fatalf("fired early: %v", got)
+	}
+	if got := w.Advance(epoch.Add(100 * time.Millisecond)); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("at 100ms fired %v, want [2]", got)
+	}
+	if got := w.Advance(epoch.Add(299 * time.Millisecond)); len(got) != 0 {
+		t.Fatalf("fired before rounded deadline: %v", got)
+	}
+	if got := w.Advance(epoch.Add(300 * time.Millisecond)); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("at 300ms fired %v, want [1]", got)
+	}
+	if w.Len() != 0 {
+		t.Fatalf("len = %d after everything fired", w.Len())
+	}
+}
+
+func TestWheelAddReplacesAndRemove(t *testing.T) {
+	w := New(time.Second, 8, 2, epoch)
+	w.Add(1, epoch.Add(3*time.Second))
+	w.Add(1, epoch.Add(20*time.Second)) // moves to level 1
+	w.Add(2, epoch.Add(5*time.Second))
+	w.Remove(2)
+	w.Remove(99)
+
+	if got := w.Advance(epoch.Add(19 * time.Second)); len(got) != 0 {
+		t.Fatalf("fired %v before the replaced deadline", got)
+	}
+	if got := w.Advance(epoch.Add(20 * time.Second)); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("fired %v, want [1]", got)
+	}
+}
+
+func TestWheelPastDeadlineFiresNextAdvance(t *testing.T) {
+	w := New(time.Second, 8, 2, epoch)
+	w.Advance(epoch.Add(10 * time.Second))
+	w.Add(7, epoch.Add(2*time.Second))
+	if !w.Has(7) || w.Len() != 1 {
+		t.Fatalf("overdue id not tracked")
+	}
+	if got := w.Advance(epoch.Add(10 * time.Second)); len(got) != 1 || got[0] != 7 {
+		t.Fatalf("fired %v, want [7]", got)
+	}
+}
+
+func TestWheelRejectsBeyondSpan(t *testing.T) {
+	w := New(time.Second, 4, 2, epoch)
+	if w.Span() != 16*time.Second {
+		t.Fatalf("span = %v, want 16s", w.Span())
+	}
+	if w.Add(1, epoch.Add(16*time.Second)) {
+		t.Fatalf("accepted deadline at span")
+	}
+	if !w.Add(2, epoch.Add(15*time.Second)) {
+		t.Fatalf("rejected deadline inside span")
+	}
+}
+
+// Random deadlines across every level must each fire exactly once, on their
+// own tick, however coarsely the wheel is advanced.
+func TestWheelCascadesAcrossLevels(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	w := New(time.Millisecond, 4, 4, epoch) // spans 256 ticks
+	want := map[int64]int64{}
+	now := int64(0)
+	var nextID int64
+
+	for now < 2000 {
+		for i := 0; i < 3; i++ {
+			nextID++
+			at := now + rng.Int64N(250)
+			if !w.Add(nextID, epoch.Add(time.Duration(at)*time.Millisecond)) {
+				t.Fatalf("id %d at %d rejected", nextID, at)
+			}
+			want[nextID] = at
+		}
+		now += 1 + rng.Int64N(7)
+		for _, id := range w.Advance(epoch.Add(time.Duration(now) * time.Millisecond)) {
+			at, ok := want[id]
+			if !ok {
+				t.Fatalf("id %d fired twice or was never added", id)
+			}
+			// Fired within the advance window that covered its deadline.
+			if at > now {
+				t.Fatalf("id %d due at %d fired at %d", id, at, now)
+			}
+			delete(want, id)
+		}
+		for id, at := range want {
+			if at <= now {
+				t.Fatalf("id %d due at %d still pending at %d", id, at, now)
+			}
+		}
+	}
+}
+
+func TestWheelAdvanceOrdersByDeadline(t *testing.T) {
+	w := New(time.Millisecond, 4, 3, epoch)
+	deadlines := []int64{40, 3, 17, 9, 63, 1}
+	for i, d := range deadlines {
+		w.Add(int64(i), epoch.Add(time.Duration(d)*time.Millisecond))
+	}
+	got := w.Advance(epoch.Add(time.Second))
+	if len(got) != len(deadlines) {
+		t.Fatalf("fired %d ids, want %d", len(got), len(deadlines))
+	}
+	if !sort.SliceIsSorted(got, func(i, j int) bool { return deadlines[got[i]] < deadlines[got[j]] }) {
+		t.Fatalf("fired out of order: %v", got)
+	}
+}
@@ -0,0 +1,6 @@
+ALTER TABLE scheduler_jobs
+ADD COLUMN IF NOT EXISTS appointment_start TIMESTAMPTZ,
+ADD COLUMN IF NOT EXISTS timezone TEXT,
+ADD COLUMN IF NOT EXISTS quiet_hours_start TEXT,
+ADD COLUMN IF NOT EXISTS quiet_hours_end TEXT,
+ADD COLUMN IF NOT EXISTS quiet_hours_mode TEXT;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS delayed_jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    dedup_key VARCHAR(255) NOT NULL,
+    target_topic VARCHAR(200) NOT NULL DEFAULT '',
+    payload JSONB NOT NULL DEFAULT '{}'::jsonb,
+    source VARCHAR(100),
+    fire_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    traceparent TEXT,
+    tracestate TEXT,
+    cancel_reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    fired_at TIMESTAMPTZ,
+    UNIQUE (dedup_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_delayed_jobs_due
+    ON delayed_jobs (fire_at)
+    WHERE status = 'pending';
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS scheduler_shard_leases (
+    shard INT PRIMARY KEY,
+    owner TEXT,
+    expires_at TIMESTAMPTZ NOT NULL DEFAULT 'epoch'
+);
+
+CREATE TABLE IF NOT EXISTS scheduler_workers (
+    worker_id TEXT PRIMARY KEY,
+    heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Serves the per-business round-robin in FetchDue.
+CREATE INDEX IF NOT EXISTS idx_scheduler_jobs_business_due
+    ON scheduler_jobs (business_id, next_run_at)
+    WHERE status = 'pending';
@@ -0,0 +1,11 @@
+-- Dedup keys are unique per producing service rather than globally, so one
+-- producer cannot cancel, or tombstone in advance, another producer's jobs.
+UPDATE delayed_jobs SET source = '' WHERE source IS NULL;
+
+ALTER TABLE delayed_jobs ALTER COLUMN source SET DEFAULT '';
+ALTER TABLE delayed_jobs ALTER COLUMN source SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_delayed_jobs_source_key
+    ON delayed_jobs (source, dedup_key);
+
+ALTER TABLE delayed_jobs DROP CONSTRAINT IF EXISTS delayed_jobs_dedup_key_key;
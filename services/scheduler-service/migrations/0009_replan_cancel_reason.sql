@@ -0,0 +1,10 @@
+-- Who cancelled a job: 'admin' or 'replan'. Re-planning only revives jobs it
+-- cancelled itself; older cancelled rows have no reason and stay cancelled.
+ALTER TABLE scheduler_jobs ADD COLUMN IF NOT EXISTS cancel_reason VARCHAR(20);
+
+-- The updated_at of the last reminder policy re-planned per business, so a
+-- policy event that arrives late cannot undo a newer one.
+CREATE TABLE IF NOT EXISTS reminder_policy_versions (
+    business_id UUID PRIMARY KEY,
+    updated_at TIMESTAMPTZ NOT NULL
+);
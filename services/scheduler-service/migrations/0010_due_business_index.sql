@@ -0,0 +1,6 @@
+-- Lets FetchDue read the distinct due businesses with an index-only scan.
+-- It has the same leading columns as idx_scheduler_jobs_due, which it replaces.
+CREATE INDEX IF NOT EXISTS idx_scheduler_jobs_due_business
+    ON scheduler_jobs (status, next_run_at, business_id);
+
+DROP INDEX IF EXISTS idx_scheduler_jobs_due;